@@ -0,0 +1,70 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// requireAdmin wraps an admin handler with an ADMIN_API_KEY check. The key is
+// accepted as "Authorization: Bearer <key>" or "X-Admin-Key: <key>".
+func (b *WhatsAppBridge) requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if b.adminAPIKey == "" {
+			http.Error(w, "Admin endpoints disabled (ADMIN_API_KEY not set)", http.StatusForbidden)
+			return
+		}
+
+		key := r.Header.Get("X-Admin-Key")
+		if key == "" {
+			key = strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		}
+		if subtle.ConstantTimeCompare([]byte(key), []byte(b.adminAPIKey)) != 1 {
+			loggerFrom(r.Context()).Warn("🚫 Rejected admin request", "path", r.URL.Path, "remote_addr", r.RemoteAddr)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// handleListDeadWebhooks lists webhooks that exhausted their retries
+func (b *WhatsAppBridge) handleListDeadWebhooks(w http.ResponseWriter, r *http.Request) {
+	jobs, err := b.queue.DeadLetters()
+	if err != nil {
+		loggerFrom(r.Context()).Error("❌ Failed to list dead-lettered webhooks", "error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if jobs == nil {
+		jobs = []*WebhookJob{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"count":    len(jobs),
+		"webhooks": jobs,
+	})
+}
+
+// handleReplayDeadWebhook moves a dead-lettered webhook back onto the queue
+func (b *WhatsAppBridge) handleReplayDeadWebhook(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	if err := b.queue.Replay(id); err != nil {
+		loggerFrom(r.Context()).Error("❌ Failed to replay webhook", "webhook_job", id, "error", err)
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"id":      id,
+		"message": "Webhook re-queued for processing",
+	})
+}
@@ -1,179 +1,150 @@
 package main
 
 import (
-	"encoding/base64"
 	"fmt"
-	"log"
+	"log/slog"
 	"sync"
-	"time"
 
 	"github.com/pion/rtp"
 )
 
-// AudioProcessor handles conversion between RTP and OpenAI's expected format
+const (
+	// jitterBufferDepth is how many packets are held back to reorder late
+	// arrivals before a missing one is concealed (3 x 20 ms = 60 ms)
+	jitterBufferDepth = 3
+
+	// jitterBufferResetGap is a sequence jump treated as a new stream rather
+	// than loss, e.g. after the sender restarts
+	jitterBufferResetGap = 100
+)
+
+// AudioProcessor converts a call's Opus RTP to PCM16 for speech backends and
+// PCM16 back to Opus RTP. Incoming packets pass through a jitter buffer keyed by
+// RTP sequence number; lost packets are concealed by the decoder.
 type AudioProcessor struct {
-	openAIClient      *OpenAIRealtimeClient
-	rtpBuffer         []byte
-	pcmBuffer         []byte
-	audioBuffer       []byte  // Accumulated audio to send
-	lastCommitTime    time.Time
-	mu                sync.Mutex
-	packetCount       int
-	isProcessing      bool
-	stopChan          chan bool
-	lastSequence      uint16
-	firstPacket       bool
+	decoder    *OpusDecoder
+	encoder    *OpusRTPEncoder
+	outputRate int // Sample rate of the PCM16 returned by ProcessRTPPacket
+	logger     *slog.Logger
+
+	jitter       map[uint16]*rtp.Packet // Packets waiting for their turn
+	lastSequence uint16                 // Last sequence number decoded or concealed
+	firstPacket  bool
+
+	packetCount int
+	lostCount   int
+	lateCount   int
+	mu          sync.Mutex
 }
 
-// NewAudioProcessor creates a new audio processor
-func NewAudioProcessor(client *OpenAIRealtimeClient) *AudioProcessor {
-	return &AudioProcessor{
-		openAIClient:   client,
-		rtpBuffer:      make([]byte, 1500),
-		pcmBuffer:      make([]byte, 0, 48000), // 1 second buffer at 48kHz
-		audioBuffer:    make([]byte, 0, 96000), // Buffer for accumulating audio before sending
-		lastCommitTime: time.Now(),
-		stopChan:       make(chan bool),
-		firstPacket:    true,
+// NewAudioProcessor creates a processor that decodes to PCM16 at outputRate
+// (e.g. 24000 for the Realtime API, 16000 for speech-to-text) and encodes
+// with payloadType, logging to logger
+func NewAudioProcessor(outputRate int, payloadType uint8, logger *slog.Logger) (*AudioProcessor, error) {
+	decoder, err := NewOpusDecoder()
+	if err != nil {
+		return nil, err
+	}
+	encoder, err := NewOpusRTPEncoder(payloadType)
+	if err != nil {
+		return nil, err
 	}
+	return &AudioProcessor{
+		decoder:     decoder,
+		encoder:     encoder,
+		outputRate:  outputRate,
+		logger:      logger,
+		jitter:      make(map[uint16]*rtp.Packet),
+		firstPacket: true,
+	}, nil
 }
 
-// ProcessRTPPacket handles incoming RTP packets from WhatsApp
-func (p *AudioProcessor) ProcessRTPPacket(rtpData []byte) error {
-	p.mu.Lock()
-	defer p.mu.Unlock()
-
-	// Parse RTP packet
+// ProcessRTPPacket buffers one Opus RTP packet and returns the PCM16 at the
+// output rate for every 20 ms frame that is now ready, in sequence order.
+// It returns nothing while the jitter buffer fills.
+func (p *AudioProcessor) ProcessRTPPacket(rtpData []byte) ([]int16, error) {
 	packet := &rtp.Packet{}
 	if err := packet.Unmarshal(rtpData); err != nil {
-		return fmt.Errorf("failed to parse RTP packet: %v", err)
-	}
-
-	// Check for packet loss
-	if !p.firstPacket {
-		expectedSeq := p.lastSequence + 1
-		if packet.SequenceNumber != expectedSeq {
-			log.Printf("⚠️ Packet loss detected: expected seq %d, got %d", expectedSeq, packet.SequenceNumber)
-		}
+		return nil, fmt.Errorf("failed to parse RTP packet: %v", err)
 	}
-	p.lastSequence = packet.SequenceNumber
-	p.firstPacket = false
-
-	// OpenAI expects PCM16 audio, not Opus
-	// Extract the Opus payload from RTP packet
-	opusData := packet.Payload
-	
-	// TODO: Properly decode Opus to PCM16
-	// For now, we'll still use silence but log the actual Opus data
-	if p.packetCount < 5 {
-		log.Printf("📊 Opus frame %d: size=%d bytes, first bytes=%x", 
-			p.packetCount, len(opusData), opusData[:min(4, len(opusData))])
+	// Skip DTMF (telephone-event) and empty keep-alive packets
+	if packet.PayloadType != whatsappOpusPayloadType || len(packet.Payload) == 0 {
+		return nil, nil
 	}
-	
-	// Generate 20ms of PCM16 silence as placeholder
-	// In production, this should be: pcm16Data := DecodeOpusToPCM16(opusData)
-	pcm16Data := generateSilencePCM16(20)
-	
-	// Accumulate audio in buffer
-	p.audioBuffer = append(p.audioBuffer, pcm16Data...)
-	
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
 	p.packetCount++
 	if p.packetCount == 1 {
-		log.Printf("✅ First audio packet received from WhatsApp!")
+		p.logger.Info("✅ First audio packet received from WhatsApp")
 	}
 
-	// Send accumulated audio every 100ms (5 packets of 20ms each)
-	if len(p.audioBuffer) >= 9600 { // 100ms of audio at 48kHz, 16-bit mono = 9600 bytes
-		// Convert accumulated audio to base64
-		audioBase64 := base64.StdEncoding.EncodeToString(p.audioBuffer)
-		
-		// Send to OpenAI
-		if err := p.openAIClient.SendAudioToOpenAI([]byte(audioBase64)); err != nil {
-			if p.packetCount < 10 {
-				log.Printf("❌ Failed to send audio to OpenAI: %v", err)
-			}
-			return err
-		}
-		
-		// Commit the buffer
-		if err := p.openAIClient.CommitAudioBuffer(); err != nil {
-			log.Printf("❌ Failed to commit audio buffer: %v", err)
-		} else {
-			log.Printf("✅ Sent and committed %d bytes of audio to OpenAI", len(p.audioBuffer))
-		}
-		
-		// Clear the buffer for next batch
-		p.audioBuffer = p.audioBuffer[:0]
-		p.lastCommitTime = time.Now()
+	if p.firstPacket {
+		p.lastSequence = packet.SequenceNumber - 1
+		p.firstPacket = false
 	}
-	
-	if p.packetCount%100 == 0 {
-		log.Printf("📊 Processed %d packets from WhatsApp", p.packetCount)
+
+	// Signed distance from the next expected packet copes with wraparound
+	ahead := int16(packet.SequenceNumber - (p.lastSequence + 1))
+	switch {
+	case ahead < 0 && -ahead < jitterBufferResetGap:
+		p.lateCount++
+		return nil, nil // Already decoded or concealed
+	case ahead >= jitterBufferResetGap || -ahead >= jitterBufferResetGap:
+		p.logger.Warn("⚠️ RTP sequence jumped - resetting jitter buffer", "from", p.lastSequence, "to", packet.SequenceNumber)
+		p.jitter = make(map[uint16]*rtp.Packet)
+		p.lastSequence = packet.SequenceNumber - 1
 	}
-	
-	// After 3 seconds, trigger a manual response if we haven't heard anything
-	if p.packetCount == 150 { // 150 packets = 3 seconds at 20ms intervals
-		log.Printf("🎯 Triggering manual OpenAI response after 3 seconds")
-		go func() {
-			if err := p.openAIClient.TriggerResponse("Hallo? Bist du da? Are you there? Let's have a simple conversation. Tell me, wie heißt du? What's your name? You can say 'Ich heiße' and then your name."); err != nil {
-				log.Printf("❌ Failed to trigger manual response: %v", err)
+	p.jitter[packet.SequenceNumber] = packet
+
+	var pcm []int16
+	for {
+		next := p.lastSequence + 1
+		queued, ok := p.jitter[next]
+		if !ok && len(p.jitter) <= jitterBufferDepth {
+			break // Give the missing packet time to arrive
+		}
+
+		var frame []int16
+		var err error
+		if ok {
+			delete(p.jitter, next)
+			frame, err = p.decoder.Decode(queued.Payload)
+		} else {
+			p.lostCount++
+			if p.lostCount <= 10 || p.lostCount%100 == 0 {
+				p.logger.Warn("⚠️ Packet loss detected - concealing", "seq", next, "lost", p.lostCount)
 			}
-		}()
+			frame, err = p.decoder.Conceal()
+		}
+		p.lastSequence = next
+		if err != nil {
+			p.logger.Error("❌ Failed to decode Opus", "seq", next, "error", err)
+			continue
+		}
+		pcm = append(pcm, resamplePCM16(frame, opusSampleRate, p.outputRate)...)
 	}
 
-	return nil
-}
-
-// Start begins processing
-func (p *AudioProcessor) Start() {
-	p.mu.Lock()
-	if p.isProcessing {
-		p.mu.Unlock()
-		return
+	if p.packetCount%500 == 0 {
+		p.logger.Debug("📊 Processed packets from WhatsApp", "packets", p.packetCount, "lost", p.lostCount, "late", p.lateCount)
 	}
-	p.isProcessing = true
-	p.mu.Unlock()
-
-	log.Printf("🎙️ Audio processor started")
+	return pcm, nil
 }
 
-// Stop halts processing
-func (p *AudioProcessor) Stop() {
-	p.mu.Lock()
-	if !p.isProcessing {
-		p.mu.Unlock()
-		return
-	}
-	p.isProcessing = false
-	p.mu.Unlock()
+// EncodePCM16 encodes mono PCM16 at sampleRate into 20 ms Opus RTP packets.
+// A trailing partial frame is kept for the next call.
+func (p *AudioProcessor) EncodePCM16(pcm []int16, sampleRate int) ([]*rtp.Packet, error) {
+	resampled := resamplePCM16(pcm, sampleRate, opusSampleRate)
 
-	close(p.stopChan)
-	log.Printf("🛑 Audio processor stopped")
-}
-
-// Simple PCM16 silence generator for testing
-func generateSilencePCM16(durationMs int) []byte {
-	// 48kHz, 16-bit, mono
-	sampleRate := 48000
-	samples := (sampleRate * durationMs) / 1000
-	pcm := make([]byte, samples*2) // 2 bytes per sample
-	
-	// Fill with zeros (silence)
-	return pcm
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.encoder.Encode(resampled)
 }
 
-// min returns the minimum of two integers
-func min(a, b int) int {
-	if a < b {
-		return a
-	}
-	return b
+// Flush drops any partially encoded frame, e.g. when the speaker is interrupted
+func (p *AudioProcessor) Flush() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.encoder.Flush()
 }
-
-// Helper function to convert Opus to PCM16 (placeholder)
-// In production, you'd use an Opus decoder library
-func opusToPCM16(opusData []byte) ([]byte, error) {
-	// TODO: Implement actual Opus decoding
-	// For now, return silence
-	return generateSilencePCM16(20), nil // 20ms of silence
-}
\ No newline at end of file
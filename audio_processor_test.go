@@ -0,0 +1,121 @@
+package main
+
+import (
+	"log/slog"
+	"testing"
+
+	"github.com/pion/rtp"
+)
+
+// TestJitterBuffer feeds the processor packets in the orders a network
+// delivers them and checks how many 20 ms frames come out and what was
+// counted lost or late
+func TestJitterBuffer(t *testing.T) {
+	tests := []struct {
+		name       string
+		sequence   []uint16
+		wantFrames int
+		wantLost   int
+		wantLate   int
+	}{
+		{"in order across sequence wraparound", []uint16{65533, 65534, 65535, 0, 1}, 5, 0, 0},
+		{"reordered across sequence wraparound", []uint16{65534, 0, 65535, 1}, 4, 0, 0},
+		// 11 is concealed once the buffer holds more than jitterBufferDepth packets
+		{"late packet dropped after its slot played", []uint16{10, 12, 13, 14, 15, 11}, 6, 1, 1},
+		{"gap beyond the reset threshold starts a new stream", []uint16{10, 11, 12, 500, 501}, 5, 0, 0},
+		{"backward gap beyond the reset threshold starts a new stream", []uint16{1000, 1001, 10, 11}, 4, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := NewAudioProcessor(realtimeSampleRate, whatsappOpusPayloadType, slog.Default())
+			if err != nil {
+				t.Fatal(err)
+			}
+			payload := opusSilence(t)
+
+			samples := 0
+			for _, seq := range tt.sequence {
+				packet := &rtp.Packet{
+					Header:  rtp.Header{Version: 2, PayloadType: whatsappOpusPayloadType, SequenceNumber: seq, Timestamp: uint32(seq) * opusFrameSamples},
+					Payload: payload,
+				}
+				data, err := packet.Marshal()
+				if err != nil {
+					t.Fatal(err)
+				}
+				pcm, err := p.ProcessRTPPacket(data)
+				if err != nil {
+					t.Fatalf("seq %d: %v", seq, err)
+				}
+				samples += len(pcm)
+			}
+
+			frameSamples := realtimeSampleRate / 50
+			if got := samples / frameSamples; got != tt.wantFrames || samples%frameSamples != 0 {
+				t.Errorf("decoded %d samples (%d frames), want %d frames", samples, got, tt.wantFrames)
+			}
+			if p.lostCount != tt.wantLost {
+				t.Errorf("lost = %d, want %d", p.lostCount, tt.wantLost)
+			}
+			if p.lateCount != tt.wantLate {
+				t.Errorf("late = %d, want %d", p.lateCount, tt.wantLate)
+			}
+		})
+	}
+}
+
+// TestEncodePCM16 checks 24 kHz audio comes out as one 20 ms packet per
+// frame, with a partial frame held until the rest arrives
+func TestEncodePCM16(t *testing.T) {
+	p, err := NewAudioProcessor(realtimeSampleRate, whatsappOpusPayloadType, slog.Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+	frame := realtimeSampleRate / 50
+
+	tests := []struct {
+		name        string
+		samples     int
+		wantPackets int
+	}{
+		{"one frame", frame, 1},
+		{"half a frame is held", frame / 2, 0},
+		{"the other half completes it", frame / 2, 1},
+		{"three frames", 3 * frame, 3},
+	}
+	var last *rtp.Packet
+	for _, tt := range tests {
+		packets, err := p.EncodePCM16(make([]int16, tt.samples), realtimeSampleRate)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if len(packets) != tt.wantPackets {
+			t.Errorf("%s: got %d packets, want %d", tt.name, len(packets), tt.wantPackets)
+		}
+		for _, packet := range packets {
+			if packet.PayloadType != whatsappOpusPayloadType || len(packet.Payload) == 0 {
+				t.Errorf("%s: bad packet %v", tt.name, packet.Header)
+			}
+			if last != nil && (packet.SequenceNumber != last.SequenceNumber+1 || packet.Timestamp != last.Timestamp+opusFrameSamples) {
+				t.Errorf("%s: seq %d ts %d does not follow seq %d ts %d", tt.name,
+					packet.SequenceNumber, packet.Timestamp, last.SequenceNumber, last.Timestamp)
+			}
+			last = packet
+		}
+	}
+}
+
+// opusSilence returns the Opus payload of one 20 ms frame of silence
+func opusSilence(t *testing.T) []byte {
+	t.Helper()
+
+	enc, err := NewOpusRTPEncoder(whatsappOpusPayloadType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	packets, err := enc.Encode(make([]int16, opusFrameSamples))
+	if err != nil || len(packets) != 1 {
+		t.Fatalf("Encode = %d packets, %v", len(packets), err)
+	}
+	return packets[0].Payload
+}
@@ -2,10 +2,10 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
 	"mime/multipart"
 	"net/http"
 	"os"
@@ -14,9 +14,11 @@ import (
 
 // DownloadAudio downloads an audio file from WhatsApp
 // Returns the path to the downloaded temporary file
-func DownloadAudio(audioID, phoneNumberID, token string) (string, error) {
+func DownloadAudio(ctx context.Context, audioID, phoneNumberID, token string) (string, error) {
+	logger := loggerFrom(ctx).With("audio_id", audioID)
+
 	// Get media URL from WhatsApp API
-	url := fmt.Sprintf("https://graph.facebook.com/v18.0/%s", audioID)
+	url := graphURL(graphAPIVersion, audioID)
 
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
@@ -34,7 +36,8 @@ func DownloadAudio(audioID, phoneNumberID, token string) (string, error) {
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		log.Printf("❌ Error getting media info: %s - %s", resp.Status, string(body))
+		logger.Error("❌ Error getting media info", "status", resp.Status)
+		logger.Debug("❌ Media info response", "body", string(body))
 		return "", fmt.Errorf("failed to get media info: %s", resp.Status)
 	}
 
@@ -51,11 +54,12 @@ func DownloadAudio(audioID, phoneNumberID, token string) (string, error) {
 	}
 
 	if mediaInfo.URL == "" {
-		log.Printf("❌ No URL in media info")
+		logger.Error("❌ No URL in media info")
 		return "", fmt.Errorf("no URL in media info")
 	}
 
-	log.Printf("📥 Downloading audio from: %s", mediaInfo.URL)
+	logger.Info("📥 Downloading audio", "mime_type", mediaInfo.MimeType, "size", mediaInfo.Size)
+	logger.Debug("📥 Audio URL", "url", mediaInfo.URL)
 
 	// Download the actual audio file
 	audioReq, err := http.NewRequest("GET", mediaInfo.URL, nil)
@@ -72,7 +76,7 @@ func DownloadAudio(audioID, phoneNumberID, token string) (string, error) {
 	defer audioResp.Body.Close()
 
 	if audioResp.StatusCode != http.StatusOK {
-		log.Printf("❌ Error downloading audio: %s", audioResp.Status)
+		logger.Error("❌ Error downloading audio", "status", audioResp.Status)
 		return "", fmt.Errorf("failed to download audio: %s", audioResp.Status)
 	}
 
@@ -94,13 +98,15 @@ func DownloadAudio(audioID, phoneNumberID, token string) (string, error) {
 		return "", err
 	}
 
-	log.Printf("✅ Audio saved to temporary file: %s (size: %d bytes)", tmpFile.Name(), len(audioData))
+	logger.Info("✅ Audio saved to temporary file", "path", tmpFile.Name(), "bytes", len(audioData))
 	return tmpFile.Name(), nil
 }
 
 // TranscribeAudio transcribes an audio file using Azure GPT-4o
 // Returns the transcription text
-func TranscribeAudio(audioFilePath string) (string, error) {
+func TranscribeAudio(ctx context.Context, audioFilePath string) (string, error) {
+	logger := loggerFrom(ctx)
+
 	// Get Azure transcription endpoint and API key
 	endpoint := os.Getenv("AZURE_TRANSCRIBE_ENDPOINT")
 	apiKey := os.Getenv("AZURE_API_KEY")
@@ -111,7 +117,7 @@ func TranscribeAudio(audioFilePath string) (string, error) {
 	}
 
 	if endpoint == "" || apiKey == "" {
-		log.Printf("⚠️ Azure transcription not configured (AZURE_TRANSCRIBE_ENDPOINT or API key missing)")
+		logger.Warn("⚠️ Azure transcription not configured (AZURE_TRANSCRIBE_ENDPOINT or API key missing)")
 		return "", fmt.Errorf("transcription not configured")
 	}
 
@@ -164,7 +170,7 @@ func TranscribeAudio(audioFilePath string) (string, error) {
 	req.Header.Set("Authorization", "Bearer "+apiKey)
 	req.Header.Set("Content-Type", writer.FormDataContentType())
 
-	log.Printf("🎤 Sending audio for transcription to Azure (size: %d bytes)", len(audioData))
+	logger.Info("🎤 Sending audio for transcription to Azure", "bytes", len(audioData))
 
 	client := &http.Client{}
 	resp, err := client.Do(req)
@@ -176,7 +182,8 @@ func TranscribeAudio(audioFilePath string) (string, error) {
 	respBody, _ := io.ReadAll(resp.Body)
 
 	if resp.StatusCode != http.StatusOK {
-		log.Printf("❌ Transcription error: %s - %s", resp.Status, string(respBody))
+		logger.Error("❌ Transcription error", "status", resp.Status)
+		logger.Debug("❌ Transcription response", "body", string(respBody))
 		return "", fmt.Errorf("transcription failed: %s", resp.Status)
 	}
 
@@ -186,21 +193,21 @@ func TranscribeAudio(audioFilePath string) (string, error) {
 	}
 
 	if err := json.Unmarshal(respBody, &result); err != nil {
-		log.Printf("❌ Failed to parse transcription response: %v", err)
+		logger.Error("❌ Failed to parse transcription response", "error", err)
 		return "", err
 	}
 
 	if result.Text == "" {
-		log.Printf("⚠️ Empty transcription result")
+		logger.Warn("⚠️ Empty transcription result")
 		return "", fmt.Errorf("empty transcription")
 	}
 
-	log.Printf("✅ Transcription: %s", result.Text)
+	logger.Debug("✅ Transcription", "transcript", result.Text)
 	return result.Text, nil
 }
 
 // CleanupAudioFile removes a temporary audio file
-func CleanupAudioFile(filePath string) {
+func CleanupAudioFile(ctx context.Context, filePath string) {
 	if filePath == "" {
 		return
 	}
@@ -212,8 +219,8 @@ func CleanupAudioFile(filePath string) {
 
 	// Remove the file
 	if err := os.Remove(filePath); err != nil {
-		log.Printf("⚠️ Failed to cleanup audio file %s: %v", filePath, err)
+		loggerFrom(ctx).Warn("⚠️ Failed to cleanup audio file", "path", filePath, "error", err)
 	} else {
-		log.Printf("🧹 Cleaned up audio file: %s", filePath)
+		loggerFrom(ctx).Debug("🧹 Cleaned up audio file", "path", filePath)
 	}
 }
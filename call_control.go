@@ -0,0 +1,225 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/pion/webrtc/v4"
+)
+
+// HangupCall ends an active call: it sends the Graph API terminate action and
+// tears down local resources. Local teardown happens even if WhatsApp can't
+// be reached; the returned error reports the Graph API failure.
+func (b *WhatsAppBridge) HangupCall(callID, reason string) error {
+	b.mu.Lock()
+	call, exists := b.activeCalls[callID]
+	b.mu.Unlock()
+
+	if !exists {
+		return fmt.Errorf("call %s not found", callID)
+	}
+
+	logger := callLogger(callID)
+	logger.Info("📴 Hanging up call", "reason", reason)
+
+	var apiErr error
+	if call.Tenant != nil { // Local test calls have no WhatsApp side
+		if apiErr = b.sendTerminateCall(call.Tenant, callID); apiErr != nil {
+			logger.Error("❌ Failed to terminate call via WhatsApp API", "error", apiErr)
+		}
+	}
+
+	b.endCall(call, reason)
+	return apiErr
+}
+
+// RejectCall declines an inbound call we decided not to take and releases
+// anything already set up for it
+func (b *WhatsAppBridge) RejectCall(tenant *Tenant, callID, reason string) error {
+	logger := callLogger(callID)
+	logger.Info("🚫 Rejecting call", "reason", reason)
+
+	err := b.sendRejectCall(tenant, callID)
+	if err != nil {
+		logger.Error("❌ Failed to reject call via WhatsApp API", "error", err)
+	}
+
+	b.endCallByID(callID, "rejected: "+reason)
+	return err
+}
+
+// handleHangupCall ends an active call on request. The JSON body is optional
+// and may carry a "reason" for the logs and call outcome.
+func (b *WhatsAppBridge) handleHangupCall(w http.ResponseWriter, r *http.Request) {
+	callID := mux.Vars(r)["id"]
+
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	// Decode whatever arrives: chunked requests have no Content-Length, and
+	// io.EOF just means the body was empty
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Reason == "" {
+		req.Reason = "hung up via API"
+	}
+
+	b.hangupAndRespond(w, callID, req.Reason)
+}
+
+// hangupAndRespond hangs up callID and writes the JSON result
+func (b *WhatsAppBridge) hangupAndRespond(w http.ResponseWriter, callID, reason string) {
+	b.mu.Lock()
+	_, exists := b.activeCalls[callID]
+	b.mu.Unlock()
+	if !exists {
+		http.Error(w, "Call not found", http.StatusNotFound)
+		return
+	}
+
+	// The call is torn down locally either way; a Graph API failure is reported
+	// so the caller knows WhatsApp may still consider the call active
+	w.Header().Set("Content-Type", "application/json")
+	if err := b.HangupCall(callID, reason); err != nil {
+		w.WriteHeader(http.StatusBadGateway)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"call_id": callID,
+			"error":   err.Error(),
+			"message": "Call ended locally but WhatsApp terminate failed",
+		})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"call_id": callID,
+		"message": "Call ended",
+	})
+}
+
+// handleListCalls lists every active call
+func (b *WhatsAppBridge) handleListCalls(w http.ResponseWriter, r *http.Request) {
+	b.mu.Lock()
+	calls := make([]*Call, 0, len(b.activeCalls))
+	for _, call := range b.activeCalls {
+		calls = append(calls, call)
+	}
+	b.mu.Unlock()
+
+	sort.Slice(calls, func(i, j int) bool {
+		return calls[i].StartTime.Before(calls[j].StartTime)
+	})
+
+	summaries := make([]map[string]interface{}, 0, len(calls))
+	for _, call := range calls {
+		summaries = append(summaries, b.callSnapshot(call, false))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"count": len(summaries),
+		"calls": summaries,
+	})
+}
+
+// handleGetCall returns the details of one active call
+func (b *WhatsAppBridge) handleGetCall(w http.ResponseWriter, r *http.Request) {
+	callID := mux.Vars(r)["id"]
+
+	b.mu.Lock()
+	call, exists := b.activeCalls[callID]
+	b.mu.Unlock()
+	if !exists {
+		http.Error(w, "Call not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(b.callSnapshot(call, true))
+}
+
+// handleDeleteCall force-hangs-up an active call
+func (b *WhatsAppBridge) handleDeleteCall(w http.ResponseWriter, r *http.Request) {
+	b.hangupAndRespond(w, mux.Vars(r)["id"], "hung up via admin API")
+}
+
+// callSnapshot describes a call for the /calls API. detailed adds the
+// reminder, outbound answer-flow history and last-media time.
+func (b *WhatsAppBridge) callSnapshot(call *Call, detailed bool) map[string]interface{} {
+	b.mu.Lock()
+	pc := call.PeerConnection
+	agentName := ""
+	if call.Agent != nil {
+		agentName = call.Agent.Name()
+	}
+	b.mu.Unlock()
+
+	state, since := call.State()
+	lc := call.Lifecycle
+
+	tenantName := ""
+	if call.Tenant != nil {
+		tenantName = call.Tenant.Name
+	}
+
+	snapshot := map[string]interface{}{
+		"call_id":        call.ID,
+		"direction":      call.Direction(),
+		"peer":           call.Peer(),
+		"tenant":         tenantName,
+		"state":          state,
+		"state_since":    since.Format(time.RFC3339),
+		"started_at":     call.StartTime.Format(time.RFC3339),
+		"duration":       time.Since(call.StartTime).Round(time.Second).String(),
+		"agent_attached": agentName != "",
+		"agent":          agentName,
+		"packets": map[string]int64{
+			"received":       lc.packetsIn.Load(),
+			"bytes_received": lc.bytesIn.Load(),
+			"sent":           lc.packetsOut.Load(),
+			"bytes_sent":     lc.bytesOut.Load(),
+		},
+	}
+
+	if pc != nil {
+		snapshot["ice_state"] = pc.ICEConnectionState().String()
+		snapshot["connection_state"] = pc.ConnectionState().String()
+		snapshot["dtls_state"] = dtlsState(pc)
+	}
+
+	if detailed {
+		if last := call.lastMedia(); !last.IsZero() {
+			snapshot["last_media_at"] = last.Format(time.RFC3339Nano)
+		}
+		if call.ReminderID != "" {
+			snapshot["reminder_id"] = call.ReminderID
+			snapshot["reminder_text"] = call.ReminderText
+		}
+		if call.Outbound != nil {
+			snapshot["outbound"] = call.Outbound.Snapshot()
+		}
+	}
+	return snapshot
+}
+
+// dtlsState returns the state of the DTLS transport carrying the call's audio
+func dtlsState(pc *webrtc.PeerConnection) string {
+	for _, transceiver := range pc.GetTransceivers() {
+		if sender := transceiver.Sender(); sender != nil && sender.Transport() != nil {
+			return sender.Transport().State().String()
+		}
+		if receiver := transceiver.Receiver(); receiver != nil && receiver.Transport() != nil {
+			return receiver.Transport().State().String()
+		}
+	}
+	return "unknown"
+}
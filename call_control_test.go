@@ -0,0 +1,41 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+// TestHangupCallBody checks the optional hangup body: empty bodies of known
+// or unknown length are accepted, malformed JSON is rejected
+func TestHangupCallBody(t *testing.T) {
+	b := &WhatsAppBridge{activeCalls: make(map[string]*Call)}
+
+	tests := []struct {
+		name          string
+		body          io.Reader
+		contentLength int64
+		want          int
+	}{
+		{"no body", http.NoBody, 0, http.StatusNotFound},
+		{"empty chunked body", strings.NewReader(""), -1, http.StatusNotFound},
+		{"reason", strings.NewReader(`{"reason":"test"}`), -1, http.StatusNotFound},
+		{"malformed body", strings.NewReader(`{"reason":`), -1, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/calls/wacid.UNKNOWN/hangup", tt.body)
+			req.ContentLength = tt.contentLength
+			req = mux.SetURLVars(req, map[string]string{"id": "wacid.UNKNOWN"})
+			rec := httptest.NewRecorder()
+			b.handleHangupCall(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d (%s)", rec.Code, tt.want, strings.TrimSpace(rec.Body.String()))
+			}
+		})
+	}
+}
@@ -0,0 +1,420 @@
+package main
+
+import (
+	"log/slog"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// CallState is a step in a call's lifecycle
+type CallState string
+
+// Calls move strictly forward through these states. Inbound calls pass
+// through pre_accepted; outbound calls go from negotiating (ringing) to
+// accepted when the user picks up. Every exit path ends in terminating → ended.
+const (
+	CallStateReserved    CallState = "reserved"     // Call ID claimed, nothing set up yet
+	CallStateNegotiating CallState = "negotiating"  // Peer connection created, SDP being exchanged
+	CallStatePreAccepted CallState = "pre_accepted" // pre_accept sent for an inbound call
+	CallStateAccepted    CallState = "accepted"     // Call accepted, waiting for media
+	CallStateMediaActive CallState = "media_active" // RTP flowing from the WhatsApp user
+	CallStateTerminating CallState = "terminating"  // Teardown in progress
+	CallStateEnded       CallState = "ended"
+)
+
+// callStateOrder ranks states so transitions can only move forward
+var callStateOrder = map[CallState]int{
+	CallStateReserved:    0,
+	CallStateNegotiating: 1,
+	CallStatePreAccepted: 2,
+	CallStateAccepted:    3,
+	CallStateMediaActive: 4,
+	CallStateTerminating: 5,
+	CallStateEnded:       6,
+}
+
+// Watchdog defaults; override with CALL_SETUP_TIMEOUT, CALL_NO_MEDIA_TIMEOUT,
+// CALL_ICE_DISCONNECT_GRACE and CALL_MAX_DURATION (Go durations, e.g. "45s")
+const (
+	defaultCallSetupTimeout       = 30 * time.Second
+	defaultCallNoMediaTimeout     = 20 * time.Second
+	defaultCallICEDisconnectGrace = 10 * time.Second
+	defaultCallMaxDuration        = 30 * time.Minute
+	callWatchdogInterval          = time.Second
+	maxRecentCallOutcomes         = 50
+)
+
+// CallLimits are the watchdog thresholds applied to every call
+type CallLimits struct {
+	SetupTimeout       time.Duration // Inbound call must be accepted within this time
+	NoMediaTimeout     time.Duration // Accepted call must receive RTP within this time, and media may not stall longer
+	ICEDisconnectGrace time.Duration // How long ICE may stay disconnected before we give up
+	MaxDuration        time.Duration // Hard cap on call length
+}
+
+// callLimitsFromEnv reads the watchdog thresholds from the environment
+func callLimitsFromEnv() CallLimits {
+	return CallLimits{
+		SetupTimeout:       envDuration("CALL_SETUP_TIMEOUT", defaultCallSetupTimeout),
+		NoMediaTimeout:     envDuration("CALL_NO_MEDIA_TIMEOUT", defaultCallNoMediaTimeout),
+		ICEDisconnectGrace: envDuration("CALL_ICE_DISCONNECT_GRACE", defaultCallICEDisconnectGrace),
+		MaxDuration:        envDuration("CALL_MAX_DURATION", defaultCallMaxDuration),
+	}
+}
+
+// envDuration reads a positive duration environment variable, falling back to def
+func envDuration(name string, def time.Duration) time.Duration {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		slog.Warn("⚠️ Invalid setting, using default", "name", name, "value", v, "default", def)
+		return def
+	}
+	return d
+}
+
+// CallLifecycle holds a call's state and the bookkeeping needed for the
+// watchdog and the one-time teardown
+type CallLifecycle struct {
+	state             CallState
+	stateSince        time.Time
+	iceDisconnectedAt time.Time
+	endReason         string
+	endedAt           time.Time
+	lastMediaAt       atomic.Int64 // UnixNano of the last RTP packet from the user
+	packetsIn         atomic.Int64 // RTP packets received from the user
+	bytesIn           atomic.Int64
+	packetsOut        atomic.Int64 // RTP packets sent to the user
+	bytesOut          atomic.Int64
+	done              chan struct{}
+	teardownOnce      sync.Once
+	mu                sync.Mutex
+}
+
+// CallOutcome records how a call ended, for /status
+type CallOutcome struct {
+	CallID     string    `json:"call_id"`
+	Direction  string    `json:"direction"`
+	Tenant     string    `json:"tenant,omitempty"`
+	Peer       string    `json:"peer,omitempty"`
+	StartedAt  time.Time `json:"started_at"`
+	EndedAt    time.Time `json:"ended_at"`
+	Duration   string    `json:"duration"`
+	FinalState CallState `json:"final_state"` // Last state reached before teardown
+	MediaSeen  bool      `json:"media_seen"`
+	Reason     string    `json:"reason"`
+}
+
+// newCall creates a call in the reserved state
+func newCall(callID string, tenant *Tenant) *Call {
+	now := time.Now()
+	return &Call{
+		ID:        callID,
+		StartTime: now,
+		Tenant:    tenant,
+		// Only a tenant that discloses recording and takes staying on the call as
+		// consent records by default; outbound calls may opt in or out
+		RecordingConsent: tenant != nil && tenant.impliesRecordingConsent(),
+		CaptureRTP:       tenant != nil && tenant.CaptureRTP,
+		Lifecycle: &CallLifecycle{
+			state:      CallStateReserved,
+			stateSince: now,
+			done:       make(chan struct{}),
+		},
+	}
+}
+
+// ended reports whether endCall has started tearing the call down
+func (c *Call) ended() bool {
+	select {
+	case <-c.Lifecycle.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// State returns the call's current state and when it was entered
+func (c *Call) State() (CallState, time.Time) {
+	c.Lifecycle.mu.Lock()
+	defer c.Lifecycle.mu.Unlock()
+	return c.Lifecycle.state, c.Lifecycle.stateSince
+}
+
+// Direction reports whether the call was placed by the user or by us
+func (c *Call) Direction() string {
+	if c.Outbound != nil {
+		return CallDirectionBusinessInitiated
+	}
+	return CallDirectionUserInitiated
+}
+
+// Peer returns the WhatsApp user's number
+func (c *Call) Peer() string {
+	if c.Outbound != nil {
+		return c.Outbound.To
+	}
+	return c.Caller
+}
+
+// markMedia records that RTP arrived from the user
+func (c *Call) markMedia() {
+	c.Lifecycle.lastMediaAt.Store(time.Now().UnixNano())
+}
+
+// lastMedia returns when RTP last arrived from the user (zero if never)
+func (c *Call) lastMedia() time.Time {
+	n := c.Lifecycle.lastMediaAt.Load()
+	if n == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, n)
+}
+
+// setCallState moves call forward to state. Terminating and ended are only
+// reached through endCall.
+func (b *WhatsAppBridge) setCallState(call *Call, state CallState) bool {
+	if state == CallStateTerminating || state == CallStateEnded {
+		return false
+	}
+
+	lc := call.Lifecycle
+	lc.mu.Lock()
+	if callStateOrder[state] <= callStateOrder[lc.state] {
+		lc.mu.Unlock()
+		return false
+	}
+	from := lc.state
+	lc.state = state
+	lc.stateSince = time.Now()
+	lc.mu.Unlock()
+
+	callLogger(call.ID).Info("📞 Call state", "from", from, "to", state)
+	return true
+}
+
+// countSent records an RTP packet of n bytes sent to the user
+func (lc *CallLifecycle) countSent(n int) {
+	lc.packetsOut.Add(1)
+	lc.bytesOut.Add(int64(n))
+}
+
+// onCallMedia records an RTP packet of n bytes from the user and marks the call media-active
+func (b *WhatsAppBridge) onCallMedia(call *Call, n int) {
+	first := call.lastMedia().IsZero()
+	call.markMedia()
+	call.Lifecycle.packetsIn.Add(1)
+	call.Lifecycle.bytesIn.Add(int64(n))
+	if first {
+		b.setCallState(call, CallStateMediaActive)
+	}
+}
+
+// onCallICEState feeds ICE state changes into the watchdog
+func (b *WhatsAppBridge) onCallICEState(call *Call, state string) {
+	lc := call.Lifecycle
+	switch state {
+	case "disconnected":
+		lc.mu.Lock()
+		if lc.iceDisconnectedAt.IsZero() {
+			lc.iceDisconnectedAt = time.Now()
+		}
+		lc.mu.Unlock()
+		callLogger(call.ID).Warn("⚠️ ICE disconnected - ending the call unless it recovers", "grace", b.callLimits.ICEDisconnectGrace)
+	case "connected", "completed":
+		lc.mu.Lock()
+		lc.iceDisconnectedAt = time.Time{}
+		lc.mu.Unlock()
+	case "failed":
+		b.endCall(call, "ICE failed")
+	}
+}
+
+// startCallWatchdog enforces the setup, no-media, ICE and max-duration limits until the call ends
+func (b *WhatsAppBridge) startCallWatchdog(call *Call) {
+	go func() {
+		ticker := time.NewTicker(callWatchdogInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-call.Lifecycle.done:
+				return
+			case <-ticker.C:
+			}
+
+			if reason := b.checkCallLimits(call, time.Now()); reason != "" {
+				callLogger(call.ID).Warn("⏱️ Watchdog ending call", "reason", reason)
+				b.endCall(call, reason)
+				return
+			}
+		}
+	}()
+}
+
+// checkCallLimits returns why the call should be ended, or "" if it is healthy
+func (b *WhatsAppBridge) checkCallLimits(call *Call, now time.Time) string {
+	limits := b.callLimits
+
+	call.Lifecycle.mu.Lock()
+	state := call.Lifecycle.state
+	since := call.Lifecycle.stateSince
+	iceDisconnectedAt := call.Lifecycle.iceDisconnectedAt
+	call.Lifecycle.mu.Unlock()
+
+	if now.Sub(call.StartTime) > limits.MaxDuration {
+		return "max call duration reached"
+	}
+	if !iceDisconnectedAt.IsZero() && now.Sub(iceDisconnectedAt) > limits.ICEDisconnectGrace {
+		return "ICE disconnected"
+	}
+
+	switch state {
+	case CallStateReserved, CallStateNegotiating, CallStatePreAccepted:
+		// Outbound calls have their own per-step timeouts until media flows
+		if call.Outbound == nil && now.Sub(since) > limits.SetupTimeout {
+			return "setup timeout in " + string(state)
+		}
+	case CallStateAccepted:
+		if call.Outbound == nil && now.Sub(since) > limits.NoMediaTimeout {
+			return "no media received"
+		}
+	case CallStateMediaActive:
+		if now.Sub(call.lastMedia()) > limits.NoMediaTimeout {
+			return "media stalled"
+		}
+	}
+	return ""
+}
+
+// endCall is the single teardown path for every call. It is idempotent:
+// the first caller closes the peer connection and voice agent, removes the call and
+// records the outcome; later callers return immediately.
+func (b *WhatsAppBridge) endCall(call *Call, reason string) {
+	call.Lifecycle.teardownOnce.Do(func() {
+		lc := call.Lifecycle
+
+		lc.mu.Lock()
+		finalState := lc.state
+		lc.state = CallStateTerminating
+		lc.stateSince = time.Now()
+		lc.mu.Unlock()
+		close(lc.done)
+
+		logger := callLogger(call.ID)
+		logger.Info("📞 Call state", "from", finalState, "to", CallStateTerminating, "reason", reason)
+
+		if call.Outbound != nil {
+			call.Outbound.transition(OutboundStateEnded, reason)
+		}
+
+		// Remove the call, but only if the map still points at this call
+		b.mu.Lock()
+		if current, exists := b.activeCalls[call.ID]; exists && current == call {
+			delete(b.activeCalls, call.ID)
+		}
+		pc := call.PeerConnection
+		agent := call.Agent
+		recorder := call.Recorder
+		capture := call.Capture
+		b.mu.Unlock()
+
+		// Close WebRTC connection
+		if pc != nil {
+			pc.Close()
+		}
+		// Close the voice agent session (and its own connections)
+		if agent != nil {
+			agent.Close()
+			logger.Info("🤖 Closed voice agent", "backend", agent.Name())
+		}
+		// Finalize the recording after the media goroutines have lost their connections
+		if recorder != nil {
+			agentName := ""
+			if agent != nil {
+				agentName = agent.Name()
+			}
+			recorder.Close(agentName)
+		}
+		if capture != nil {
+			capture.Close()
+		}
+
+		now := time.Now()
+		lc.mu.Lock()
+		lc.state = CallStateEnded
+		lc.stateSince = now
+		lc.endReason = reason
+		lc.endedAt = now
+		lc.mu.Unlock()
+
+		tenantName := ""
+		if call.Tenant != nil {
+			tenantName = call.Tenant.Name
+		}
+		outcome := CallOutcome{
+			CallID:     call.ID,
+			Direction:  call.Direction(),
+			Tenant:     tenantName,
+			Peer:       call.Peer(),
+			StartedAt:  call.StartTime,
+			EndedAt:    now,
+			Duration:   now.Sub(call.StartTime).Round(time.Second).String(),
+			FinalState: finalState,
+			MediaSeen:  !call.lastMedia().IsZero(),
+			Reason:     reason,
+		}
+
+		b.mu.Lock()
+		b.recentCalls = append(b.recentCalls, outcome)
+		if len(b.recentCalls) > maxRecentCallOutcomes {
+			b.recentCalls = b.recentCalls[len(b.recentCalls)-maxRecentCallOutcomes:]
+		}
+		b.mu.Unlock()
+
+		// Log call duration
+		logger.Info("☎️ Call terminated and cleaned up", "reason", reason, "duration", now.Sub(call.StartTime))
+	})
+}
+
+// endCallByID ends the active call with callID, if there is one
+func (b *WhatsAppBridge) endCallByID(callID, reason string) bool {
+	b.mu.Lock()
+	call, exists := b.activeCalls[callID]
+	b.mu.Unlock()
+
+	if !exists {
+		return false
+	}
+	b.endCall(call, reason)
+	return true
+}
+
+// callStatus lists active call states and recent outcomes, for /status
+func (b *WhatsAppBridge) callStatus() (map[string]interface{}, []CallOutcome) {
+	b.mu.Lock()
+	calls := make([]*Call, 0, len(b.activeCalls))
+	for _, call := range b.activeCalls {
+		calls = append(calls, call)
+	}
+	recent := make([]CallOutcome, len(b.recentCalls))
+	copy(recent, b.recentCalls)
+	b.mu.Unlock()
+
+	active := make(map[string]interface{}, len(calls))
+	for _, call := range calls {
+		state, since := call.State()
+		active[call.ID] = map[string]interface{}{
+			"state":       state,
+			"state_since": since.Format(time.RFC3339),
+			"direction":   call.Direction(),
+			"peer":        call.Peer(),
+		}
+	}
+	return active, recent
+}
@@ -0,0 +1,464 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v4/pkg/media/oggwriter"
+)
+
+// Recording files written for each call. The per-leg files are the original
+// Opus packets; the mixed files put the caller on the left channel and the
+// voice agent on the right.
+const (
+	recordingCallerFile   = "caller.ogg"
+	recordingAgentFile    = "agent.ogg"
+	recordingMixedFile    = "mixed.ogg"
+	recordingMixedWAVFile = "mixed.wav"
+	recordingMetadataFile = "metadata.json"
+
+	defaultRecordingsDir = "data/recordings"
+
+	// recordingWAVSampleRate keeps the WAV small; WhatsApp audio is narrowband anyway
+	recordingWAVSampleRate = 16000
+
+	// recordingLegBuffer caps decoded audio waiting to be mixed per leg (1s at 48 kHz)
+	recordingLegBuffer = opusSampleRate
+)
+
+// RecordingFile describes one file in a call's recording directory
+type RecordingFile struct {
+	Name     string `json:"name"`
+	Leg      string `json:"leg"` // "caller", "agent" or "mixed"
+	Format   string `json:"format"`
+	Channels int    `json:"channels"`
+}
+
+// RecordingMetadata is the sidecar JSON written next to a call's recordings
+type RecordingMetadata struct {
+	CallID         string          `json:"call_id"`
+	Tenant         string          `json:"tenant,omitempty"`
+	Direction      string          `json:"direction"`
+	CallerNumber   string          `json:"caller_number"`   // The WhatsApp user
+	BusinessNumber string          `json:"business_number"` // The tenant's number
+	VoiceAgent     string          `json:"voice_agent,omitempty"`
+	StartedAt      time.Time       `json:"started_at"`
+	EndedAt        *time.Time      `json:"ended_at,omitempty"`
+	Duration       string          `json:"duration,omitempty"`
+	CallerPackets  int64           `json:"caller_packets"`
+	AgentPackets   int64           `json:"agent_packets"`
+	Files          []RecordingFile `json:"files"`
+}
+
+// recordingsDir is where call recordings are written (RECORDINGS_DIR)
+func recordingsDir() string {
+	if dir := os.Getenv("RECORDINGS_DIR"); dir != "" {
+		return dir
+	}
+	return defaultRecordingsDir
+}
+
+// shouldRecord reports whether a call may be recorded: the tenant must have
+// recording enabled and the call must carry consent
+func (c *Call) shouldRecord() bool {
+	return c.Tenant != nil && c.Tenant.RecordCalls && c.RecordingConsent
+}
+
+// recordingLeg is one direction of a call's audio
+type recordingLeg struct {
+	ogg     *oggwriter.OggWriter
+	decoder *OpusDecoder
+	pending []int16 // Decoded audio at 48 kHz waiting for the mixer
+	packets int64
+	logger  *slog.Logger
+}
+
+// write stores one packet in the leg's file and queues its audio for mixing
+func (l *recordingLeg) write(p *rtp.Packet) {
+	if err := l.ogg.WriteRTP(p); err != nil {
+		l.logger.Warn("⚠️ Failed to write recording packet", "error", err)
+	}
+	l.packets++
+
+	pcm, err := l.decoder.Decode(p.Payload)
+	if err != nil {
+		return
+	}
+	l.pending = append(l.pending, pcm...)
+	// The legs run on separate clocks; drop the oldest audio rather than drift
+	if over := len(l.pending) - recordingLegBuffer; over > 0 {
+		l.pending = append(l.pending[:0], l.pending[over:]...)
+	}
+}
+
+// take removes one 20 ms frame, padding with silence if the leg is behind
+func (l *recordingLeg) take() []int16 {
+	frame := make([]int16, opusFrameSamples)
+	n := copy(frame, l.pending)
+	l.pending = append(l.pending[:0], l.pending[n:]...)
+	return frame
+}
+
+// CallRecorder writes a call's audio to disk: each leg as received, plus a
+// stereo mix produced every 20 ms by a mixer goroutine
+type CallRecorder struct {
+	dir      string
+	meta     RecordingMetadata
+	caller   *recordingLeg
+	agent    *recordingLeg
+	mixedOgg *oggwriter.OggWriter
+	mixedWAV *wavWriter // nil unless RECORDING_WAV=true
+	mixer    *OpusRTPEncoder
+	stop     chan struct{}
+	stopped  chan struct{}
+	closed   bool
+	logger   *slog.Logger
+	mu       sync.Mutex
+}
+
+// NewCallRecorder creates the call's recording directory and starts recording
+func NewCallRecorder(call *Call) (*CallRecorder, error) {
+	dir := filepath.Join(recordingsDir(), filepath.Base(call.ID))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create recording directory: %w", err)
+	}
+
+	r := &CallRecorder{
+		dir: dir,
+		meta: RecordingMetadata{
+			CallID:         call.ID,
+			Tenant:         call.Tenant.Name,
+			Direction:      call.Direction(),
+			CallerNumber:   call.Peer(),
+			BusinessNumber: call.Tenant.DisplayPhoneNumber,
+			StartedAt:      time.Now(),
+		},
+		stop:    make(chan struct{}),
+		stopped: make(chan struct{}),
+		logger:  callLogger(call.ID),
+	}
+	if r.meta.BusinessNumber == "" {
+		r.meta.BusinessNumber = call.Tenant.PhoneNumberID
+	}
+
+	var err error
+	if r.caller, err = r.newLeg(recordingCallerFile, "caller"); err != nil {
+		r.closeFiles()
+		return nil, err
+	}
+	if r.agent, err = r.newLeg(recordingAgentFile, "agent"); err != nil {
+		r.closeFiles()
+		return nil, err
+	}
+	if r.mixedOgg, err = oggwriter.New(filepath.Join(dir, recordingMixedFile), opusSampleRate, 2); err != nil {
+		r.closeFiles()
+		return nil, fmt.Errorf("failed to create %s: %w", recordingMixedFile, err)
+	}
+	r.meta.Files = append(r.meta.Files, RecordingFile{Name: recordingMixedFile, Leg: "mixed", Format: "ogg/opus", Channels: 2})
+	if r.mixer, err = newOpusRTPEncoder(whatsappOpusPayloadType, 2); err != nil {
+		r.closeFiles()
+		return nil, err
+	}
+	if os.Getenv("RECORDING_WAV") == "true" {
+		if r.mixedWAV, err = newWAVWriter(filepath.Join(dir, recordingMixedWAVFile), recordingWAVSampleRate, 2); err != nil {
+			r.closeFiles()
+			return nil, err
+		}
+		r.meta.Files = append(r.meta.Files, RecordingFile{Name: recordingMixedWAVFile, Leg: "mixed", Format: "wav", Channels: 2})
+	}
+
+	if err := r.writeMetadata(); err != nil {
+		r.closeFiles()
+		return nil, err
+	}
+
+	go r.mix()
+	r.logger.Info("⏺️ Recording call", "dir", dir)
+	return r, nil
+}
+
+// newLeg opens the per-leg Ogg file
+func (r *CallRecorder) newLeg(name, leg string) (*recordingLeg, error) {
+	decoder, err := NewOpusDecoder()
+	if err != nil {
+		return nil, err
+	}
+	ogg, err := oggwriter.New(filepath.Join(r.dir, name), opusSampleRate, 1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", name, err)
+	}
+	r.meta.Files = append(r.meta.Files, RecordingFile{Name: name, Leg: leg, Format: "ogg/opus", Channels: 1})
+	return &recordingLeg{ogg: ogg, decoder: decoder, logger: r.logger.With("leg", leg)}, nil
+}
+
+// WriteCaller records one RTP packet from the WhatsApp user
+func (r *CallRecorder) WriteCaller(p *rtp.Packet) {
+	// Skip DTMF (telephone-event) and empty keep-alive packets
+	if p.PayloadType != whatsappOpusPayloadType || len(p.Payload) == 0 {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.closed {
+		r.caller.write(p)
+	}
+}
+
+// WriteAgent records one RTP packet the voice agent sent to the user
+func (r *CallRecorder) WriteAgent(p *rtp.Packet) {
+	if len(p.Payload) == 0 {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.closed {
+		r.agent.write(p)
+	}
+}
+
+// mix encodes a stereo frame from both legs every 20 ms until Close
+func (r *CallRecorder) mix() {
+	defer close(r.stopped)
+
+	ticker := time.NewTicker(20 * time.Millisecond)
+	defer ticker.Stop()
+
+	stereo := make([]int16, opusFrameSamples*2)
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+		}
+
+		r.mu.Lock()
+		left, right := r.caller.take(), r.agent.take()
+		for i := range left {
+			stereo[2*i] = left[i]
+			stereo[2*i+1] = right[i]
+		}
+
+		packets, err := r.mixer.Encode(stereo)
+		if err != nil {
+			r.logger.Warn("⚠️ Failed to encode mixed recording", "error", err)
+		}
+		for _, p := range packets {
+			if err := r.mixedOgg.WriteRTP(p); err != nil {
+				r.logger.Warn("⚠️ Failed to write mixed recording", "error", err)
+			}
+		}
+
+		if r.mixedWAV != nil {
+			l := resamplePCM16(left, opusSampleRate, recordingWAVSampleRate)
+			rr := resamplePCM16(right, opusSampleRate, recordingWAVSampleRate)
+			frame := make([]int16, len(l)*2)
+			for i := range l {
+				frame[2*i] = l[i]
+				frame[2*i+1] = rr[i]
+			}
+			if err := r.mixedWAV.Write(frame); err != nil {
+				r.logger.Warn("⚠️ Failed to write WAV recording", "error", err)
+			}
+		}
+		r.mu.Unlock()
+	}
+}
+
+// Close stops the mixer, finalizes every file and writes the end time and the
+// voice agent that spoke on the call to the sidecar
+func (r *CallRecorder) Close(voiceAgent string) {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return
+	}
+	r.closed = true
+	r.mu.Unlock()
+
+	close(r.stop)
+	<-r.stopped
+
+	now := time.Now()
+	r.meta.VoiceAgent = voiceAgent
+	r.meta.EndedAt = &now
+	r.meta.Duration = now.Sub(r.meta.StartedAt).Round(time.Second).String()
+	r.meta.CallerPackets = r.caller.packets
+	r.meta.AgentPackets = r.agent.packets
+
+	r.closeFiles()
+	if err := r.writeMetadata(); err != nil {
+		r.logger.Warn("⚠️ Failed to write recording metadata", "error", err)
+	}
+	r.logger.Info("⏹️ Recording saved", "duration", r.meta.Duration,
+		"caller_packets", r.meta.CallerPackets, "agent_packets", r.meta.AgentPackets)
+}
+
+// closeFiles closes whichever files were opened
+func (r *CallRecorder) closeFiles() {
+	for _, leg := range []*recordingLeg{r.caller, r.agent} {
+		if leg != nil {
+			leg.ogg.Close()
+		}
+	}
+	if r.mixedOgg != nil {
+		r.mixedOgg.Close()
+	}
+	if r.mixedWAV != nil {
+		if err := r.mixedWAV.Close(); err != nil {
+			r.logger.Warn("⚠️ Failed to finalize WAV recording", "error", err)
+		}
+	}
+}
+
+// writeMetadata writes the sidecar JSON atomically
+func (r *CallRecorder) writeMetadata() error {
+	data, err := json.MarshalIndent(r.meta, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(r.dir, recordingMetadataFile+".tmp")
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(r.dir, recordingMetadataFile))
+}
+
+// callRecorder returns the call's recorder, starting it on first use if the
+// call may be recorded. It returns nil once the call is ending.
+func (b *WhatsAppBridge) callRecorder(call *Call) *CallRecorder {
+	if !call.shouldRecord() {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if call.Recorder != nil || call.recordingStarted || call.ID == "" {
+		return call.Recorder
+	}
+	select {
+	case <-call.Lifecycle.done:
+		return nil // endCall has already collected the recorder
+	default:
+	}
+
+	call.recordingStarted = true
+	recorder, err := NewCallRecorder(call)
+	if err != nil {
+		callLogger(call.ID).Error("❌ Failed to start recording call", "error", err)
+		return nil
+	}
+	call.Recorder = recorder
+	return recorder
+}
+
+// wavWriter writes interleaved PCM16 to a WAV file, fixing up the header sizes on Close
+type wavWriter struct {
+	f         *os.File
+	dataBytes uint32
+}
+
+// newWAVWriter creates path and writes a header for PCM16 at sampleRate
+func newWAVWriter(path string, sampleRate, channels int) (*wavWriter, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", filepath.Base(path), err)
+	}
+
+	header := make([]byte, 44)
+	copy(header[0:], "RIFF")
+	copy(header[8:], "WAVEfmt ")
+	binary.LittleEndian.PutUint32(header[16:], 16) // fmt chunk size
+	binary.LittleEndian.PutUint16(header[20:], 1)  // PCM
+	binary.LittleEndian.PutUint16(header[22:], uint16(channels))
+	binary.LittleEndian.PutUint32(header[24:], uint32(sampleRate))
+	binary.LittleEndian.PutUint32(header[28:], uint32(sampleRate*channels*2)) // byte rate
+	binary.LittleEndian.PutUint16(header[32:], uint16(channels*2))            // block align
+	binary.LittleEndian.PutUint16(header[34:], 16)                            // bits per sample
+	copy(header[36:], "data")
+	if _, err := f.Write(header); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &wavWriter{f: f}, nil
+}
+
+// Write appends samples
+func (w *wavWriter) Write(samples []int16) error {
+	data := pcm16ToBytes(samples)
+	n, err := w.f.Write(data)
+	w.dataBytes += uint32(n)
+	return err
+}
+
+// Close writes the RIFF and data chunk sizes and closes the file
+func (w *wavWriter) Close() error {
+	sizes := make([]byte, 4)
+	binary.LittleEndian.PutUint32(sizes, 36+w.dataBytes)
+	if _, err := w.f.WriteAt(sizes, 4); err != nil {
+		w.f.Close()
+		return err
+	}
+	binary.LittleEndian.PutUint32(sizes, w.dataBytes)
+	if _, err := w.f.WriteAt(sizes, 40); err != nil {
+		w.f.Close()
+		return err
+	}
+	return w.f.Close()
+}
+
+// readRecordingMetadata loads the sidecar for callID
+func readRecordingMetadata(callID string) (*RecordingMetadata, error) {
+	if callID == "" || callID != filepath.Base(callID) || callID == "." || callID == ".." {
+		return nil, fmt.Errorf("invalid call ID")
+	}
+	data, err := os.ReadFile(filepath.Join(recordingsDir(), callID, recordingMetadataFile))
+	if err != nil {
+		return nil, err
+	}
+	var meta RecordingMetadata
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return nil, fmt.Errorf("invalid recording metadata: %w", err)
+	}
+	return &meta, nil
+}
+
+// handleGetRecording returns a call's recording metadata and file list
+func (b *WhatsAppBridge) handleGetRecording(w http.ResponseWriter, r *http.Request) {
+	meta, err := readRecordingMetadata(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Recording not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(meta)
+}
+
+// handleDownloadRecording serves one file listed in a call's recording metadata
+func (b *WhatsAppBridge) handleDownloadRecording(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	meta, err := readRecordingMetadata(vars["id"])
+	if err != nil {
+		http.Error(w, "Recording not found", http.StatusNotFound)
+		return
+	}
+
+	// Only files the recorder wrote can be downloaded
+	for _, file := range meta.Files {
+		if file.Name == vars["file"] {
+			w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", meta.CallID+"-"+file.Name))
+			http.ServeFile(w, r, filepath.Join(recordingsDir(), vars["id"], file.Name))
+			return
+		}
+	}
+	http.Error(w, "Recording file not found", http.StatusNotFound)
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestRecordingConsent checks a call is only recorded by default when the
+// tenant both records and takes staying on after the disclosure as consent
+func TestRecordingConsent(t *testing.T) {
+	tests := []struct {
+		name           string
+		recordCalls    bool
+		impliedConsent bool
+		want           bool
+	}{
+		{"not recording", false, false, false},
+		{"recording without implied consent", true, false, false},
+		{"implied consent without recording", false, true, false},
+		{"recording with implied consent", true, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tenant := &Tenant{Name: "test", RecordCalls: tt.recordCalls, ImpliedConsent: tt.impliedConsent}
+			call := newCall("wacid.CONSENT_TEST", tenant)
+			if call.RecordingConsent != tt.want || call.shouldRecord() != tt.want {
+				t.Errorf("consent = %v, records = %v, want %v", call.RecordingConsent, call.shouldRecord(), tt.want)
+			}
+		})
+	}
+}
+
+// TestRecordingDisclosure checks the agent is told to disclose recording
+// first, with the tenant's own wording when it has one
+func TestRecordingDisclosure(t *testing.T) {
+	tenant := &Tenant{Name: "test", RecordCalls: true, ImpliedConsent: true}
+	if got := tenant.recordingDisclosure(); got != defaultRecordingDisclosure {
+		t.Errorf("recordingDisclosure() = %q, want the default", got)
+	}
+	tenant.RecordingDisclosure = "Acme records this call."
+
+	c := &OpenAIRealtimeClient{tenant: tenant, phoneNumber: "15559876543", reminderText: "water the plants", recorded: true}
+	if got := c.getInstructions(); !strings.Contains(got, "Acme records this call.") {
+		t.Errorf("recorded call instructions lack the disclosure:\n%s", got)
+	}
+	c = &OpenAIRealtimeClient{tenant: tenant, phoneNumber: "15559876543", reminderText: "water the plants"}
+	if got := c.getInstructions(); strings.Contains(got, "Acme records this call.") {
+		t.Errorf("unrecorded call instructions carry the disclosure:\n%s", got)
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bytes"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Transcript speakers, matching the roles used for conversation history
+const (
+	speakerUser      = "user"
+	speakerAssistant = "assistant"
+)
+
+// CallTranscriptTurn is one speaker-labeled turn of a voice call
+type CallTranscriptTurn struct {
+	ID          string `json:"id,omitempty"`
+	CallID      string `json:"call_id"`
+	PhoneNumber string `json:"phone_number"`
+	Speaker     string `json:"speaker"` // "user" or "assistant"
+	Content     string `json:"content"`
+	ItemID      string `json:"item_id,omitempty"` // Realtime API conversation item
+	StartedAt   string `json:"started_at"`
+	EndedAt     string `json:"ended_at,omitempty"`
+}
+
+// SaveCallTranscriptTurn stores one call transcript turn
+func SaveCallTranscriptTurn(schema string, turn CallTranscriptTurn) error {
+	if !dataStore.Configured() {
+		slog.Warn("⚠️ Store not configured, transcript turn not saved", "call_id", turn.CallID)
+		return nil // Don't fail if the store isn't configured
+	}
+	return dataStore.SaveCallTranscriptTurn(schema, turn)
+}
+
+// ListCallTranscriptTurns retrieves the most recent call transcript turns for a
+// phone number across all calls, newest first
+func ListCallTranscriptTurns(schema string, phoneNumber string, limit int) ([]CallTranscriptTurn, error) {
+	return dataStore.ListCallTranscriptTurns(schema, phoneNumber, limit)
+}
+
+// callTranscript assembles a call's turns from Realtime API events and saves
+// each one as soon as it is complete. The assistant's words arrive as
+// transcript deltas; the caller's arrive whole once transcription finishes,
+// usually after the assistant has started answering, so turns are stamped
+// with when the speaker started rather than when the text arrived.
+type callTranscript struct {
+	schema      string
+	callID      string
+	phoneNumber string
+
+	speechStarted map[string]time.Time        // Caller item ID -> when VAD heard speech
+	pending       map[string]*pendingTurnText // Assistant item ID -> transcript so far
+	mu            sync.Mutex
+}
+
+// pendingTurnText is an assistant turn still receiving deltas
+type pendingTurnText struct {
+	startedAt time.Time
+	text      bytes.Buffer
+}
+
+// newCallTranscript creates the transcript for one call
+func newCallTranscript(schema, callID, phoneNumber string) *callTranscript {
+	return &callTranscript{
+		schema:        schema,
+		callID:        callID,
+		phoneNumber:   phoneNumber,
+		speechStarted: make(map[string]time.Time),
+		pending:       make(map[string]*pendingTurnText),
+	}
+}
+
+// userSpeechStarted notes when the caller started the turn that becomes itemID
+func (t *callTranscript) userSpeechStarted(itemID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.speechStarted[itemID] = time.Now()
+}
+
+// userTurn records the caller's transcribed turn
+func (t *callTranscript) userTurn(itemID, transcript string) {
+	t.mu.Lock()
+	startedAt, ok := t.speechStarted[itemID]
+	delete(t.speechStarted, itemID)
+	t.mu.Unlock()
+
+	if !ok {
+		startedAt = time.Now()
+	}
+	t.save(speakerUser, itemID, transcript, startedAt)
+}
+
+// assistantDelta appends to the assistant's turn in progress
+func (t *callTranscript) assistantDelta(itemID, delta string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	turn, ok := t.pending[itemID]
+	if !ok {
+		turn = &pendingTurnText{startedAt: time.Now()}
+		t.pending[itemID] = turn
+	}
+	turn.text.WriteString(delta)
+}
+
+// assistantDone completes the assistant's turn. The final transcript replaces
+// the accumulated deltas when the server sends one.
+func (t *callTranscript) assistantDone(itemID, transcript string) {
+	t.mu.Lock()
+	turn, ok := t.pending[itemID]
+	delete(t.pending, itemID)
+	t.mu.Unlock()
+
+	startedAt := time.Now()
+	if ok {
+		startedAt = turn.startedAt
+		if transcript == "" {
+			transcript = turn.text.String()
+		}
+	}
+	t.save(speakerAssistant, itemID, transcript, startedAt)
+}
+
+// flush saves assistant turns cut off before they finished, e.g. by hang-up
+func (t *callTranscript) flush() {
+	t.mu.Lock()
+	pending := t.pending
+	t.pending = make(map[string]*pendingTurnText)
+	t.mu.Unlock()
+
+	for itemID, turn := range pending {
+		t.save(speakerAssistant, itemID, turn.text.String(), turn.startedAt)
+	}
+}
+
+// save stores a finished turn in the background so event handling never waits on Supabase
+func (t *callTranscript) save(speaker, itemID, content string, startedAt time.Time) {
+	if content == "" {
+		return
+	}
+	logger := callLogger(t.callID)
+	logger.Info("📝 Transcript turn", "speaker", speaker, "length", len(content))
+	logger.Debug("📝 Transcript turn text", "speaker", speaker, "content", content)
+
+	turn := CallTranscriptTurn{
+		CallID:      t.callID,
+		PhoneNumber: t.phoneNumber,
+		Speaker:     speaker,
+		Content:     content,
+		ItemID:      itemID,
+		StartedAt:   startedAt.UTC().Format(time.RFC3339Nano),
+		EndedAt:     time.Now().UTC().Format(time.RFC3339Nano),
+	}
+	go func() {
+		if err := SaveCallTranscriptTurn(t.schema, turn); err != nil {
+			logger.Error("❌ Failed to save transcript turn", "error", err)
+		}
+	}()
+}
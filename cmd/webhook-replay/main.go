@@ -0,0 +1,216 @@
+// Command webhook-replay posts webhooks captured by the bridge's archive
+// (WEBHOOK_ARCHIVE_DIR) back at a bridge, in the order and with the spacing
+// they originally arrived, so a misbehaving call can be reproduced locally.
+//
+//	go run ./cmd/webhook-replay data/webhook-archive/*.jsonl
+//	REPLAY_MATCH=wacid.ABC REPLAY_SPEED=10 go run ./cmd/webhook-replay data/webhook-archive/*.jsonl
+//
+// Bodies are replayed byte for byte with their original headers. When
+// WHATSAPP_APP_SECRET is set the signature is recomputed with it, so captures
+// from production can be replayed against a bridge with a different secret.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config is read from the environment
+type Config struct {
+	URL       string    // REPLAY_URL, the bridge's webhook endpoint
+	Speed     float64   // REPLAY_SPEED: 1 keeps the original timing, 10 is ten times faster, 0 sends back to back
+	Match     string    // REPLAY_MATCH, only replay bodies containing this (a call ID, wamid or phone number)
+	From      time.Time // REPLAY_FROM (RFC 3339), skip captures received before this
+	To        time.Time // REPLAY_TO (RFC 3339), skip captures received after this
+	AppSecret string    // WHATSAPP_APP_SECRET, re-signs each body when set
+}
+
+// CapturedWebhook is one line of the archive, as written by the bridge
+type CapturedWebhook struct {
+	ReceivedAt time.Time   `json:"received_at"`
+	Method     string      `json:"method"`
+	Path       string      `json:"path"`
+	RemoteAddr string      `json:"remote_addr"`
+	Headers    http.Header `json:"headers"`
+	Body       string      `json:"body"`
+}
+
+// Headers that describe the original connection rather than the webhook
+var skipHeaders = map[string]bool{
+	"Host":              true,
+	"Content-Length":    true,
+	"Connection":        true,
+	"Accept-Encoding":   true,
+	"Transfer-Encoding": true,
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, "usage: webhook-replay <archive.jsonl>...")
+		os.Exit(2)
+	}
+	cfg := loadConfig()
+
+	var captures []CapturedWebhook
+	for _, path := range os.Args[1:] {
+		loaded, err := readArchive(path)
+		if err != nil {
+			log.Fatalf("❌ Failed to read %s: %v", path, err)
+		}
+		captures = append(captures, filterCaptures(loaded, cfg)...)
+	}
+	sort.SliceStable(captures, func(i, j int) bool {
+		return captures[i].ReceivedAt.Before(captures[j].ReceivedAt)
+	})
+	if len(captures) == 0 {
+		log.Fatal("❌ No captured webhooks matched")
+	}
+
+	first, last := captures[0].ReceivedAt, captures[len(captures)-1].ReceivedAt
+	log.Printf("🔁 Replaying %d webhooks (%s of traffic) to %s at speed %g",
+		len(captures), last.Sub(first).Round(time.Millisecond), cfg.URL, cfg.Speed)
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	start := time.Now()
+	failed := 0
+	for i, capture := range captures {
+		// Schedule against the start so slow deliveries don't add up
+		if cfg.Speed > 0 {
+			offset := time.Duration(float64(capture.ReceivedAt.Sub(first)) / cfg.Speed)
+			time.Sleep(time.Until(start.Add(offset)))
+		}
+		if err := replay(client, cfg, capture); err != nil {
+			failed++
+			log.Printf("❌ [%d/%d] %s: %v", i+1, len(captures), capture.ReceivedAt.Format(time.RFC3339Nano), err)
+			continue
+		}
+		log.Printf("📨 [%d/%d] Replayed webhook received at %s", i+1, len(captures), capture.ReceivedAt.Format(time.RFC3339Nano))
+	}
+
+	log.Printf("✅ Replay finished in %s: %d delivered, %d failed",
+		time.Since(start).Round(time.Millisecond), len(captures)-failed, failed)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// loadConfig reads the replay settings
+func loadConfig() Config {
+	cfg := Config{
+		URL:       getenv("REPLAY_URL", "http://localhost:3011/whatsapp-call"),
+		Speed:     1,
+		Match:     os.Getenv("REPLAY_MATCH"),
+		AppSecret: os.Getenv("WHATSAPP_APP_SECRET"),
+	}
+	if value := os.Getenv("REPLAY_SPEED"); value != "" {
+		speed, err := strconv.ParseFloat(value, 64)
+		if err != nil || speed < 0 {
+			log.Fatalf("Invalid REPLAY_SPEED %q", value)
+		}
+		cfg.Speed = speed
+	}
+	for name, dst := range map[string]*time.Time{"REPLAY_FROM": &cfg.From, "REPLAY_TO": &cfg.To} {
+		if value := os.Getenv(name); value != "" {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				log.Fatalf("Invalid %s %q: %v", name, value, err)
+			}
+			*dst = t
+		}
+	}
+	return cfg
+}
+
+// readArchive parses one JSONL archive file
+func readArchive(path string) ([]CapturedWebhook, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var captures []CapturedWebhook
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16<<20)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var capture CapturedWebhook
+		if err := json.Unmarshal(scanner.Bytes(), &capture); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		captures = append(captures, capture)
+	}
+	return captures, scanner.Err()
+}
+
+// filterCaptures keeps the POSTs that fall inside the configured session
+func filterCaptures(captures []CapturedWebhook, cfg Config) []CapturedWebhook {
+	var kept []CapturedWebhook
+	for _, capture := range captures {
+		switch {
+		case capture.Method != "" && capture.Method != http.MethodPost:
+		case cfg.Match != "" && !strings.Contains(capture.Body, cfg.Match):
+		case !cfg.From.IsZero() && capture.ReceivedAt.Before(cfg.From):
+		case !cfg.To.IsZero() && capture.ReceivedAt.After(cfg.To):
+		default:
+			kept = append(kept, capture)
+		}
+	}
+	return kept
+}
+
+// replay posts one capture and expects the bridge to acknowledge it
+func replay(client *http.Client, cfg Config, capture CapturedWebhook) error {
+	body := []byte(capture.Body)
+	req, err := http.NewRequest(http.MethodPost, cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for name, values := range capture.Headers {
+		if skipHeaders[http.CanonicalHeaderKey(name)] {
+			continue
+		}
+		for _, value := range values {
+			req.Header.Add(name, value)
+		}
+	}
+	if cfg.AppSecret != "" {
+		mac := hmac.New(sha256.New, []byte(cfg.AppSecret))
+		mac.Write(body)
+		req.Header.Set("X-Hub-Signature-256", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s - %s", resp.Status, strings.TrimSpace(string(respBody)))
+	}
+	return nil
+}
+
+// getenv returns the variable or def when it is unset
+func getenv(name, def string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return def
+}
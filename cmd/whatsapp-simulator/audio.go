@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"time"
+
+	"github.com/pion/webrtc/v4/pkg/media"
+	"github.com/thesyncim/gopus"
+)
+
+const (
+	sampleRate   = 48000
+	frameSamples = 960 // 20 ms at 48 kHz
+)
+
+// toneSource beeps at 440 Hz for a second every three seconds, so the
+// bridge's voice activity detection sees the user start and stop talking
+type toneSource struct {
+	enc    *gopus.Encoder
+	pcm    []int16
+	buf    []byte
+	sample int
+}
+
+// newToneSource creates a mono Opus encoder for the tone
+func newToneSource() (*toneSource, error) {
+	enc, err := gopus.NewEncoder(gopus.EncoderConfig{
+		SampleRate:  sampleRate,
+		Channels:    1,
+		Application: gopus.ApplicationVoIP,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Opus encoder: %w", err)
+	}
+	return &toneSource{enc: enc, pcm: make([]int16, frameSamples), buf: make([]byte, 1500)}, nil
+}
+
+// Next encodes the next 20 ms
+func (t *toneSource) Next() (media.Sample, error) {
+	for i := range t.pcm {
+		n := t.sample + i
+		if n%(3*sampleRate) < sampleRate {
+			t.pcm[i] = int16(8000 * math.Sin(2*math.Pi*440*float64(n)/sampleRate))
+		} else {
+			t.pcm[i] = 0
+		}
+	}
+	t.sample += frameSamples
+
+	n, err := t.enc.EncodeInt16(t.pcm, t.buf)
+	if err != nil {
+		return media.Sample{}, err
+	}
+	data := make([]byte, n)
+	copy(data, t.buf[:n])
+	return media.Sample{Data: data, Duration: 20 * time.Millisecond}, nil
+}
+
+// oggSource loops over the Opus packets of an Ogg file
+type oggSource struct {
+	packets [][]byte
+	next    int
+}
+
+// newOggSource reads every audio packet of an Ogg Opus file into memory
+func newOggSource(path string) (*oggSource, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	packets, err := oggPackets(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	// The first two packets are the OpusHead and OpusTags headers
+	if len(packets) <= 2 {
+		return nil, fmt.Errorf("%s has no audio", path)
+	}
+	return &oggSource{packets: packets[2:]}, nil
+}
+
+// Next returns the next packet, starting over at the end of the file
+func (o *oggSource) Next() (media.Sample, error) {
+	packet := o.packets[o.next]
+	o.next = (o.next + 1) % len(o.packets)
+	return media.Sample{Data: packet, Duration: opusPacketDuration(packet)}, nil
+}
+
+// oggPackets splits an Ogg stream into packets. A page's segment table gives
+// the length of each segment; a packet ends at the first segment shorter than
+// 255 bytes and may continue onto the next page.
+func oggPackets(data []byte) ([][]byte, error) {
+	var packets [][]byte
+	var packet []byte
+	for len(data) > 0 {
+		if len(data) < 27 || string(data[:4]) != "OggS" {
+			return nil, fmt.Errorf("not an Ogg stream")
+		}
+		segments := int(data[26])
+		if len(data) < 27+segments {
+			return nil, fmt.Errorf("truncated Ogg page")
+		}
+		table := data[27 : 27+segments]
+		body := data[27+segments:]
+		for _, size := range table {
+			if len(body) < int(size) {
+				return nil, fmt.Errorf("truncated Ogg page")
+			}
+			packet = append(packet, body[:size]...)
+			body = body[size:]
+			if size < 255 {
+				packets = append(packets, packet)
+				packet = nil
+			}
+		}
+		data = body
+	}
+	return packets, nil
+}
+
+// opusPacketDuration reads a packet's length from its TOC byte (RFC 6716 §3.1)
+func opusPacketDuration(packet []byte) time.Duration {
+	if len(packet) == 0 {
+		return 20 * time.Millisecond
+	}
+	config := packet[0] >> 3
+
+	var frame time.Duration
+	switch {
+	case config < 12: // SILK: 10, 20, 40, 60 ms
+		frame = []time.Duration{10, 20, 40, 60}[config%4] * time.Millisecond
+	case config < 16: // Hybrid: 10, 20 ms
+		frame = []time.Duration{10, 20}[config%2] * time.Millisecond
+	default: // CELT: 2.5, 5, 10, 20 ms
+		frame = []time.Duration{2500, 5000, 10000, 20000}[config%4] * time.Microsecond
+	}
+
+	frames := 1
+	switch packet[0] & 0x3 {
+	case 1, 2:
+		frames = 2
+	case 3:
+		if len(packet) > 1 {
+			frames = int(packet[1] & 0x3f)
+		}
+	}
+	return frame * time.Duration(frames)
+}
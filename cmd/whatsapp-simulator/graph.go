@@ -0,0 +1,260 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// graphError sends an error in the Graph API's shape
+func graphError(w http.ResponseWriter, status, code int, message string) {
+	writeJSON(w, status, map[string]interface{}{
+		"error": map[string]interface{}{
+			"message":    message,
+			"type":       "OAuthException",
+			"code":       code,
+			"fbtrace_id": newID("")[:12],
+		},
+	})
+}
+
+// authorized checks the bearer token. Any token is accepted unless
+// SIM_ACCESS_TOKEN is set.
+func (sim *Simulator) authorized(w http.ResponseWriter, r *http.Request) bool {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if token == "" || (sim.cfg.AccessToken != "" && token != sim.cfg.AccessToken) {
+		graphError(w, http.StatusUnauthorized, 190, "Invalid OAuth access token")
+		return false
+	}
+	return true
+}
+
+// handleMessages records a message the bridge sends and returns a wamid.
+// Read receipts and typing indicators are acknowledged without recording.
+func (sim *Simulator) handleMessages(w http.ResponseWriter, r *http.Request) {
+	if !sim.authorized(w, r) {
+		return
+	}
+	phoneNumberID := mux.Vars(r)["phone_number_id"]
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		graphError(w, http.StatusBadRequest, 100, "Invalid request body")
+		return
+	}
+	var req struct {
+		To     string `json:"to"`
+		Type   string `json:"type"`
+		Status string `json:"status"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		graphError(w, http.StatusBadRequest, 100, "Invalid JSON")
+		return
+	}
+
+	if req.Status == "read" {
+		writeJSON(w, http.StatusOK, map[string]bool{"success": true})
+		return
+	}
+	if req.To == "" {
+		graphError(w, http.StatusBadRequest, 100, "The parameter to is required.")
+		return
+	}
+	if req.Type == "" {
+		req.Type = "text"
+	}
+
+	message := SentMessage{
+		ID:            newID("wamid."),
+		PhoneNumberID: phoneNumberID,
+		To:            req.To,
+		Type:          req.Type,
+		Body:          json.RawMessage(body),
+		Time:          time.Now(),
+	}
+	sim.mu.Lock()
+	sim.outbox = append(sim.outbox, message)
+	sim.mu.Unlock()
+	log.Printf("💬 Bridge sent %s message %s to %s: %s", message.Type, message.ID, message.To, string(body))
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"messaging_product": "whatsapp",
+		"contacts":          []map[string]string{{"input": req.To, "wa_id": req.To}},
+		"messages":          []map[string]string{{"id": message.ID}},
+	})
+
+	if sim.cfg.StatusUpdates {
+		go func() {
+			business := sim.business(phoneNumberID)
+			for _, status := range []string{"sent", "delivered"} {
+				time.Sleep(200 * time.Millisecond)
+				sim.sendStatusWebhook(business, message.ID, message.To, "", status)
+			}
+		}()
+	}
+}
+
+// callsRequest is the body of POST /{phone_number_id}/calls
+type callsRequest struct {
+	To      string `json:"to"`
+	Action  string `json:"action"`
+	CallID  string `json:"call_id"`
+	Session *struct {
+		SDPType string `json:"sdp_type"`
+		SDP     string `json:"sdp"`
+	} `json:"session"`
+}
+
+// handleCalls implements the calling actions: connect starts a
+// business-initiated call; pre_accept, accept, reject and terminate act on
+// an existing one
+func (sim *Simulator) handleCalls(w http.ResponseWriter, r *http.Request) {
+	if !sim.authorized(w, r) {
+		return
+	}
+	phoneNumberID := mux.Vars(r)["phone_number_id"]
+
+	var req callsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		graphError(w, http.StatusBadRequest, 100, "Invalid JSON")
+		return
+	}
+	log.Printf("📞 Bridge sent %s for call %s", req.Action, req.CallID)
+
+	if req.Action == "connect" {
+		if req.To == "" || req.Session == nil || req.Session.SDPType != "offer" || req.Session.SDP == "" {
+			graphError(w, http.StatusBadRequest, 100, "connect requires to and an SDP offer")
+			return
+		}
+		call := sim.newCall(newID("wacid."), directionBusinessInitiated, req.To, sim.business(phoneNumberID))
+		log.Printf("📲 Business is calling %s (call %s)", req.To, call.ID)
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"messaging_product": "whatsapp",
+			"calls":             []map[string]string{{"id": call.ID}},
+		})
+		go sim.ringOutboundCall(call, req.Session.SDP)
+		return
+	}
+
+	call := sim.getCall(req.CallID)
+	if call == nil {
+		graphError(w, http.StatusBadRequest, 138000, fmt.Sprintf("Call %s not found", req.CallID))
+		return
+	}
+
+	switch req.Action {
+	case "pre_accept", "accept":
+		if call.Direction != directionUserInitiated {
+			graphError(w, http.StatusBadRequest, 100, req.Action+" is only valid for user-initiated calls")
+			return
+		}
+		if req.Session == nil || req.Session.SDPType != "answer" {
+			graphError(w, http.StatusBadRequest, 100, req.Action+" requires an SDP answer")
+			return
+		}
+		if err := sim.applyAnswer(call, req.Session.SDP); err != nil {
+			graphError(w, http.StatusBadRequest, 100, err.Error())
+			return
+		}
+		state := callStatePreAccepted
+		if req.Action == "accept" {
+			state = callStateAccepted
+		}
+		if call.Snapshot().State != callStateConnected {
+			call.setState(state)
+		}
+	case "reject":
+		sim.endCall(call, "REJECTED", "rejected by business", true)
+	case "terminate":
+		sim.endCall(call, "COMPLETED", "terminated by business", true)
+	default:
+		graphError(w, http.StatusBadRequest, 100, "Unknown action "+req.Action)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"messaging_product": "whatsapp", "success": true})
+}
+
+// storeMedia keeps data so it can be fetched through the media endpoints
+func (sim *Simulator) storeMedia(mimeType string, data []byte) *Media {
+	media := &Media{ID: newID("")[:16], MimeType: mimeType, Data: data}
+	sim.mu.Lock()
+	sim.media[media.ID] = media
+	sim.mu.Unlock()
+	return media
+}
+
+// handleMediaUpload stores a file sent as multipart form data
+func (sim *Simulator) handleMediaUpload(w http.ResponseWriter, r *http.Request) {
+	if !sim.authorized(w, r) {
+		return
+	}
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		graphError(w, http.StatusBadRequest, 100, "file is required")
+		return
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		graphError(w, http.StatusBadRequest, 100, "Failed to read file")
+		return
+	}
+
+	mimeType := r.FormValue("type")
+	if mimeType == "" {
+		mimeType = header.Header.Get("Content-Type")
+	}
+	media := sim.storeMedia(mimeType, data)
+	log.Printf("📎 Bridge uploaded %s (%d bytes) as media %s", mimeType, len(data), media.ID)
+	writeJSON(w, http.StatusOK, map[string]string{"id": media.ID})
+}
+
+// handleMediaInfo returns where to download a media object, like
+// GET /{media_id} on the Graph API
+func (sim *Simulator) handleMediaInfo(w http.ResponseWriter, r *http.Request) {
+	if !sim.authorized(w, r) {
+		return
+	}
+	id := mux.Vars(r)["media_id"]
+	sim.mu.Lock()
+	media := sim.media[id]
+	sim.mu.Unlock()
+	if media == nil {
+		graphError(w, http.StatusNotFound, 100, fmt.Sprintf("Unsupported get request. Object with ID '%s' does not exist", id))
+		return
+	}
+
+	sum := sha256.Sum256(media.Data)
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"messaging_product": "whatsapp",
+		"id":                media.ID,
+		"url":               "http://" + r.Host + "/media/" + media.ID,
+		"mime_type":         media.MimeType,
+		"sha256":            hex.EncodeToString(sum[:]),
+		"file_size":         len(media.Data),
+	})
+}
+
+// handleMediaDownload serves a media object's bytes
+func (sim *Simulator) handleMediaDownload(w http.ResponseWriter, r *http.Request) {
+	if !sim.authorized(w, r) {
+		return
+	}
+	sim.mu.Lock()
+	media := sim.media[mux.Vars(r)["id"]]
+	sim.mu.Unlock()
+	if media == nil {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", media.MimeType)
+	w.Write(media.Data)
+}
@@ -0,0 +1,360 @@
+// Command whatsapp-simulator stands in for the WhatsApp Cloud API so the
+// bridge can be exercised end to end without Meta. It serves the Graph
+// endpoints the bridge calls (/messages, /calls and media), plays a WhatsApp
+// user with a real Pion peer that offers ice-lite like WhatsApp does, and
+// fires the matching webhooks back at the bridge.
+//
+// Point the bridge at it with WHATSAPP_GRAPH_URL=http://localhost:3100, then
+// drive the fake user through the /sim endpoints:
+//
+//	curl -X POST localhost:3100/sim/calls                        # user calls the business
+//	curl -X DELETE localhost:3100/sim/calls/<call_id>            # user hangs up
+//	curl -X POST localhost:3100/sim/messages -d '{"text":"hi"}'  # user sends a message
+//	curl localhost:3100/sim/messages                             # what the bridge sent back
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/pion/webrtc/v4"
+)
+
+// Config is read from the environment
+type Config struct {
+	Addr          string        // SIM_ADDR, where the fake Graph API listens
+	WebhookURL    string        // SIM_WEBHOOK_URL, the bridge's webhook endpoint
+	AppSecret     string        // WHATSAPP_APP_SECRET, signs webhooks when set
+	AccessToken   string        // SIM_ACCESS_TOKEN, required bearer token when set
+	UserNumber    string        // SIM_USER_NUMBER, the fake user's WhatsApp number
+	UserName      string        // SIM_USER_NAME, profile name sent in contacts
+	Businesses    []Business    // From TENANTS_CONFIG, or PHONE_NUMBER_ID/DISPLAY_PHONE_NUMBER
+	AudioFile     string        // SIM_AUDIO_FILE, Ogg Opus the user plays; a test tone when empty
+	RecordDir     string        // SIM_RECORD_DIR, saves what the bridge sends as <call_id>.ogg
+	Outbound      string        // SIM_OUTBOUND: answer, reject or ignore business-initiated calls
+	RingDelay     time.Duration // SIM_RING_DELAY, how long outbound calls ring before the user acts
+	StatusUpdates bool          // SIM_MESSAGE_STATUSES, send sent/delivered statuses for messages
+}
+
+// Business is a WhatsApp number the bridge serves
+type Business struct {
+	PhoneNumberID      string `json:"phone_number_id"`
+	DisplayPhoneNumber string `json:"display_phone_number"`
+}
+
+// loadConfig reads the simulator settings
+func loadConfig() Config {
+	cfg := Config{
+		Addr:          getenv("SIM_ADDR", ":3100"),
+		WebhookURL:    getenv("SIM_WEBHOOK_URL", "http://localhost:3011/whatsapp-call"),
+		AppSecret:     os.Getenv("WHATSAPP_APP_SECRET"),
+		AccessToken:   os.Getenv("SIM_ACCESS_TOKEN"),
+		UserNumber:    getenv("SIM_USER_NUMBER", "15550001234"),
+		UserName:      getenv("SIM_USER_NAME", "Simulated User"),
+		AudioFile:     os.Getenv("SIM_AUDIO_FILE"),
+		RecordDir:     os.Getenv("SIM_RECORD_DIR"),
+		Outbound:      getenv("SIM_OUTBOUND", "answer"),
+		RingDelay:     2 * time.Second,
+		StatusUpdates: os.Getenv("SIM_MESSAGE_STATUSES") != "false",
+	}
+
+	if value := os.Getenv("SIM_RING_DELAY"); value != "" {
+		delay, err := time.ParseDuration(value)
+		if err != nil {
+			log.Fatalf("Invalid SIM_RING_DELAY %q: %v", value, err)
+		}
+		cfg.RingDelay = delay
+	}
+
+	switch cfg.Outbound {
+	case "answer", "reject", "ignore":
+	default:
+		log.Fatalf("Invalid SIM_OUTBOUND %q: want answer, reject or ignore", cfg.Outbound)
+	}
+
+	cfg.Businesses = loadBusinesses(getenv("TENANTS_CONFIG", "tenants.json"))
+	if len(cfg.Businesses) == 0 {
+		cfg.Businesses = []Business{{
+			PhoneNumberID:      getenv("PHONE_NUMBER_ID", "100000000000001"),
+			DisplayPhoneNumber: getenv("DISPLAY_PHONE_NUMBER", "15550000000"),
+		}}
+	}
+	return cfg
+}
+
+// loadBusinesses reads the numbers from the bridge's tenants file so webhooks
+// carry metadata the bridge will route. A missing file is not an error.
+func loadBusinesses(path string) []Business {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var file struct {
+		Tenants []Business `json:"tenants"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		log.Printf("⚠️ Ignoring %s: %v", path, err)
+		return nil
+	}
+	return file.Tenants
+}
+
+func getenv(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// Simulator is the fake Graph API and the user behind it
+type Simulator struct {
+	cfg        Config
+	api        *webrtc.API
+	httpClient *http.Client
+
+	mu     sync.Mutex
+	calls  map[string]*SimCall
+	outbox []SentMessage
+	media  map[string]*Media
+}
+
+// SentMessage is a message the bridge sent through /messages
+type SentMessage struct {
+	ID            string          `json:"id"`
+	PhoneNumberID string          `json:"phone_number_id"`
+	To            string          `json:"to"`
+	Type          string          `json:"type"`
+	Body          json.RawMessage `json:"body"`
+	Time          time.Time       `json:"time"`
+}
+
+// Media is an uploaded or simulated media object
+type Media struct {
+	ID       string
+	MimeType string
+	Data     []byte
+}
+
+// NewSimulator creates a simulator with a WhatsApp-like WebRTC stack
+func NewSimulator(cfg Config) (*Simulator, error) {
+	api, err := newWhatsAppAPI()
+	if err != nil {
+		return nil, err
+	}
+	return &Simulator{
+		cfg:        cfg,
+		api:        api,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+		calls:      make(map[string]*SimCall),
+		media:      make(map[string]*Media),
+	}, nil
+}
+
+// business returns the number with phoneNumberID, or the first one when empty
+func (sim *Simulator) business(phoneNumberID string) Business {
+	if phoneNumberID == "" {
+		return sim.cfg.Businesses[0]
+	}
+	for _, b := range sim.cfg.Businesses {
+		if b.PhoneNumberID == phoneNumberID {
+			return b
+		}
+	}
+	return Business{PhoneNumberID: phoneNumberID}
+}
+
+// Router serves the Graph API and the /sim control endpoints
+func (sim *Simulator) Router() *mux.Router {
+	router := mux.NewRouter()
+
+	// Control endpoints for driving the fake user
+	router.HandleFunc("/sim/calls", sim.handleStartCall).Methods("POST")
+	router.HandleFunc("/sim/calls", sim.handleListCalls).Methods("GET")
+	router.HandleFunc("/sim/calls/{id}", sim.handleHangup).Methods("DELETE")
+	router.HandleFunc("/sim/messages", sim.handleSendMessage).Methods("POST")
+	router.HandleFunc("/sim/messages", sim.handleListMessages).Methods("GET")
+
+	// Media downloads use the URL handed out by the media info endpoint
+	router.HandleFunc("/media/{id}", sim.handleMediaDownload).Methods("GET")
+
+	// Graph API
+	graph := router.PathPrefix("/{version:v[0-9.]+}").Subrouter()
+	graph.HandleFunc("/{phone_number_id}/messages", sim.handleMessages).Methods("POST")
+	graph.HandleFunc("/{phone_number_id}/calls", sim.handleCalls).Methods("POST")
+	graph.HandleFunc("/{phone_number_id}/media", sim.handleMediaUpload).Methods("POST")
+	graph.HandleFunc("/{media_id}", sim.handleMediaInfo).Methods("GET")
+
+	return router
+}
+
+// startCallRequest is the body of POST /sim/calls
+type startCallRequest struct {
+	From          string `json:"from"`
+	PhoneNumberID string `json:"phone_number_id"`
+}
+
+// handleStartCall has the user call the business
+func (sim *Simulator) handleStartCall(w http.ResponseWriter, r *http.Request) {
+	var req startCallRequest
+	if r.ContentLength > 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+	if req.From == "" {
+		req.From = sim.cfg.UserNumber
+	}
+
+	call, err := sim.startInboundCall(req.From, sim.business(req.PhoneNumberID))
+	if err != nil {
+		log.Printf("❌ Failed to start call: %v", err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	writeJSON(w, http.StatusOK, call.Snapshot())
+}
+
+// handleListCalls lists every call, newest first
+func (sim *Simulator) handleListCalls(w http.ResponseWriter, r *http.Request) {
+	sim.mu.Lock()
+	snapshots := make([]CallSnapshot, 0, len(sim.calls))
+	for _, call := range sim.calls {
+		snapshots = append(snapshots, call.Snapshot())
+	}
+	sim.mu.Unlock()
+
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].StartTime.After(snapshots[j].StartTime)
+	})
+	writeJSON(w, http.StatusOK, map[string]interface{}{"calls": snapshots})
+}
+
+// handleHangup has the user hang up
+func (sim *Simulator) handleHangup(w http.ResponseWriter, r *http.Request) {
+	call := sim.getCall(mux.Vars(r)["id"])
+	if call == nil {
+		http.Error(w, "Call not found", http.StatusNotFound)
+		return
+	}
+	sim.endCall(call, "COMPLETED", "hung up by user", true)
+	writeJSON(w, http.StatusOK, call.Snapshot())
+}
+
+// sendMessageRequest is the body of POST /sim/messages
+type sendMessageRequest struct {
+	From          string `json:"from"`
+	PhoneNumberID string `json:"phone_number_id"`
+	Text          string `json:"text"`
+	AudioFile     string `json:"audio_file"` // Sends a voice note instead of text
+}
+
+// handleSendMessage has the user send a text or voice message
+func (sim *Simulator) handleSendMessage(w http.ResponseWriter, r *http.Request) {
+	var req sendMessageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.From == "" {
+		req.From = sim.cfg.UserNumber
+	}
+	if req.Text == "" && req.AudioFile == "" {
+		http.Error(w, "text or audio_file is required", http.StatusBadRequest)
+		return
+	}
+
+	message := map[string]interface{}{
+		"from":      req.From,
+		"id":        newID("wamid."),
+		"timestamp": unixNow(),
+	}
+	if req.AudioFile != "" {
+		data, err := os.ReadFile(req.AudioFile)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		media := sim.storeMedia("audio/ogg; codecs=opus", data)
+		message["type"] = "audio"
+		message["audio"] = map[string]interface{}{
+			"id":        media.ID,
+			"mime_type": media.MimeType,
+			"voice":     true,
+		}
+	} else {
+		message["type"] = "text"
+		message["text"] = map[string]string{"body": req.Text}
+	}
+
+	business := sim.business(req.PhoneNumberID)
+	value := sim.webhookValue(business, req.From)
+	value["messages"] = []interface{}{message}
+	if err := sim.sendWebhook("messages", business, value); err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	writeJSON(w, http.StatusOK, message)
+}
+
+// handleListMessages lists what the bridge has sent
+func (sim *Simulator) handleListMessages(w http.ResponseWriter, r *http.Request) {
+	sim.mu.Lock()
+	messages := append([]SentMessage{}, sim.outbox...)
+	sim.mu.Unlock()
+	writeJSON(w, http.StatusOK, map[string]interface{}{"messages": messages})
+}
+
+// writeJSON sends v as a JSON response
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// newID returns prefix followed by random hex, like wamid.* and wacid.* ids
+func newID(prefix string) string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return prefix + strings.ToUpper(hex.EncodeToString(b))
+}
+
+// unixNow is the timestamp format WhatsApp uses in webhooks
+func unixNow() string {
+	return strconv.FormatInt(time.Now().Unix(), 10)
+}
+
+func main() {
+	cfg := loadConfig()
+
+	sim, err := NewSimulator(cfg)
+	if err != nil {
+		log.Fatal("Failed to create simulator:", err)
+	}
+
+	log.Printf("🧪 WhatsApp simulator listening on %s", cfg.Addr)
+	log.Printf("📡 Webhooks go to %s (signed: %v)", cfg.WebhookURL, cfg.AppSecret != "")
+	for _, b := range cfg.Businesses {
+		log.Printf("🏢 Business number %s (phone number ID %s)", b.DisplayPhoneNumber, b.PhoneNumberID)
+	}
+	log.Printf("👤 Fake user %s, outbound calls: %s after %v", cfg.UserNumber, cfg.Outbound, cfg.RingDelay)
+	if cfg.AudioFile != "" {
+		log.Printf("🔊 User plays %s", cfg.AudioFile)
+	} else {
+		log.Printf("🔊 User plays a test tone")
+	}
+
+	if err := http.ListenAndServe(cfg.Addr, sim.Router()); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,450 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pion/webrtc/v4"
+	"github.com/pion/webrtc/v4/pkg/media"
+	"github.com/pion/webrtc/v4/pkg/media/oggwriter"
+)
+
+// Call directions used in calls[].direction
+const (
+	directionUserInitiated     = "USER_INITIATED"
+	directionBusinessInitiated = "BUSINESS_INITIATED"
+)
+
+// Call states as the simulator sees them
+const (
+	callStateRinging     = "ringing"
+	callStatePreAccepted = "pre_accepted"
+	callStateAccepted    = "accepted"
+	callStateConnected   = "connected"
+	callStateEnded       = "ended"
+)
+
+// SimCall is one call between the fake user and the bridge
+type SimCall struct {
+	ID        string
+	Direction string
+	User      string
+	Business  Business
+	StartTime time.Time
+
+	pc    *webrtc.PeerConnection
+	track *webrtc.TrackLocalStaticSample
+
+	mu          sync.Mutex
+	state       string
+	answerSet   bool // The bridge's SDP answer has been applied (inbound calls)
+	connectTime time.Time
+	endTime     time.Time
+	endStatus   string
+	endReason   string
+
+	packetsSent     atomic.Int64
+	packetsReceived atomic.Int64
+	done            chan struct{}
+	endOnce         sync.Once
+}
+
+// CallSnapshot is a call as listed by GET /sim/calls
+type CallSnapshot struct {
+	ID              string     `json:"call_id"`
+	Direction       string     `json:"direction"`
+	User            string     `json:"user"`
+	PhoneNumberID   string     `json:"phone_number_id"`
+	State           string     `json:"state"`
+	StartTime       time.Time  `json:"start_time"`
+	ConnectTime     *time.Time `json:"connect_time,omitempty"`
+	EndTime         *time.Time `json:"end_time,omitempty"`
+	EndStatus       string     `json:"end_status,omitempty"`
+	EndReason       string     `json:"end_reason,omitempty"`
+	PacketsSent     int64      `json:"packets_sent"`
+	PacketsReceived int64      `json:"packets_received"`
+}
+
+// Snapshot returns the call's current state
+func (c *SimCall) Snapshot() CallSnapshot {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	snapshot := CallSnapshot{
+		ID:              c.ID,
+		Direction:       c.Direction,
+		User:            c.User,
+		PhoneNumberID:   c.Business.PhoneNumberID,
+		State:           c.state,
+		StartTime:       c.StartTime,
+		EndStatus:       c.endStatus,
+		EndReason:       c.endReason,
+		PacketsSent:     c.packetsSent.Load(),
+		PacketsReceived: c.packetsReceived.Load(),
+	}
+	if !c.connectTime.IsZero() {
+		connectTime := c.connectTime
+		snapshot.ConnectTime = &connectTime
+	}
+	if !c.endTime.IsZero() {
+		endTime := c.endTime
+		snapshot.EndTime = &endTime
+	}
+	return snapshot
+}
+
+// setState moves the call forward unless it has already ended
+func (c *SimCall) setState(state string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.state == callStateEnded {
+		return false
+	}
+	c.state = state
+	if state == callStateConnected && c.connectTime.IsZero() {
+		c.connectTime = time.Now()
+	}
+	return true
+}
+
+// newWhatsAppAPI builds a WebRTC stack that looks like WhatsApp's: Opus on
+// payload type 111 with WhatsApp's fmtp line, telephone-event on 126, the same
+// header extensions, and ice-lite so offers carry a=ice-lite
+func newWhatsAppAPI() (*webrtc.API, error) {
+	m := &webrtc.MediaEngine{}
+
+	opus := webrtc.RTPCodecParameters{
+		RTPCodecCapability: webrtc.RTPCodecCapability{
+			MimeType:     webrtc.MimeTypeOpus,
+			ClockRate:    48000,
+			Channels:     2,
+			SDPFmtpLine:  "maxaveragebitrate=20000;maxplaybackrate=16000;minptime=20;sprop-maxcapturerate=16000;useinbandfec=1",
+			RTCPFeedback: []webrtc.RTCPFeedback{{Type: "transport-cc"}},
+		},
+		PayloadType: 111,
+	}
+	if err := m.RegisterCodec(opus, webrtc.RTPCodecTypeAudio); err != nil {
+		return nil, fmt.Errorf("failed to register Opus: %w", err)
+	}
+
+	telephoneEvent := webrtc.RTPCodecParameters{
+		RTPCodecCapability: webrtc.RTPCodecCapability{MimeType: "audio/telephone-event", ClockRate: 8000},
+		PayloadType:        126,
+	}
+	if err := m.RegisterCodec(telephoneEvent, webrtc.RTPCodecTypeAudio); err != nil {
+		return nil, fmt.Errorf("failed to register telephone-event: %w", err)
+	}
+
+	for _, uri := range []string{
+		"urn:ietf:params:rtp-hdrext:ssrc-audio-level",
+		"http://www.webrtc.org/experiments/rtp-hdrext/abs-send-time",
+		"http://www.ietf.org/id/draft-holmer-rmcat-transport-wide-cc-extensions-01",
+	} {
+		if err := m.RegisterHeaderExtension(webrtc.RTPHeaderExtensionCapability{URI: uri}, webrtc.RTPCodecTypeAudio); err != nil {
+			return nil, fmt.Errorf("failed to register extension %s: %w", uri, err)
+		}
+	}
+
+	s := webrtc.SettingEngine{}
+	s.SetLite(true)
+	s.SetNetworkTypes([]webrtc.NetworkType{webrtc.NetworkTypeUDP4})
+	// The bridge usually runs on the same machine during tests
+	s.SetIncludeLoopbackCandidate(true)
+
+	return webrtc.NewAPI(webrtc.WithMediaEngine(m), webrtc.WithSettingEngine(s)), nil
+}
+
+// newCall registers a call in the ringing state
+func (sim *Simulator) newCall(id, direction, user string, business Business) *SimCall {
+	call := &SimCall{
+		ID:        id,
+		Direction: direction,
+		User:      user,
+		Business:  business,
+		StartTime: time.Now(),
+		state:     callStateRinging,
+		done:      make(chan struct{}),
+	}
+	sim.mu.Lock()
+	sim.calls[id] = call
+	sim.mu.Unlock()
+	return call
+}
+
+// getCall returns a call by ID, or nil
+func (sim *Simulator) getCall(id string) *SimCall {
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+	return sim.calls[id]
+}
+
+// newPeer gives the user a peer connection with one audio track for call
+func (sim *Simulator) newPeer(call *SimCall) error {
+	pc, err := sim.api.NewPeerConnection(webrtc.Configuration{})
+	if err != nil {
+		return fmt.Errorf("failed to create peer connection: %w", err)
+	}
+
+	track, err := webrtc.NewTrackLocalStaticSample(
+		webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeOpus, ClockRate: 48000, Channels: 2},
+		"audio", "whatsapp-user")
+	if err != nil {
+		pc.Close()
+		return fmt.Errorf("failed to create audio track: %w", err)
+	}
+	sender, err := pc.AddTrack(track)
+	if err != nil {
+		pc.Close()
+		return fmt.Errorf("failed to add audio track: %w", err)
+	}
+
+	// Read RTCP so the interceptors keep working
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			if _, _, err := sender.Read(buf); err != nil {
+				return
+			}
+		}
+	}()
+
+	pc.OnTrack(func(remote *webrtc.TrackRemote, _ *webrtc.RTPReceiver) {
+		log.Printf("🎧 Call %s: receiving %s from the bridge", call.ID, remote.Codec().MimeType)
+		sim.receiveAudio(call, remote)
+	})
+
+	pc.OnConnectionStateChange(func(state webrtc.PeerConnectionState) {
+		log.Printf("🔗 Call %s: connection %s", call.ID, state)
+		switch state {
+		case webrtc.PeerConnectionStateConnected:
+			if call.setState(callStateConnected) {
+				go sim.playAudio(call)
+			}
+		case webrtc.PeerConnectionStateFailed:
+			go sim.endCall(call, "FAILED", "media connection failed", true)
+		}
+	})
+
+	call.mu.Lock()
+	call.pc = pc
+	call.track = track
+	call.mu.Unlock()
+	return nil
+}
+
+// startInboundCall has user call business: it offers ice-lite SDP the way
+// WhatsApp does and sends the connect webhook carrying the offer
+func (sim *Simulator) startInboundCall(user string, business Business) (*SimCall, error) {
+	call := sim.newCall(newID("wacid."), directionUserInitiated, user, business)
+	if err := sim.newPeer(call); err != nil {
+		sim.endCall(call, "FAILED", err.Error(), false)
+		return nil, err
+	}
+
+	offer, err := call.pc.CreateOffer(nil)
+	if err != nil {
+		sim.endCall(call, "FAILED", "offer failed", false)
+		return nil, fmt.Errorf("failed to create offer: %w", err)
+	}
+	gatherComplete := webrtc.GatheringCompletePromise(call.pc)
+	if err := call.pc.SetLocalDescription(offer); err != nil {
+		sim.endCall(call, "FAILED", "offer failed", false)
+		return nil, fmt.Errorf("failed to set local description: %w", err)
+	}
+	<-gatherComplete
+
+	log.Printf("📞 %s is calling %s (call %s)", user, business.DisplayPhoneNumber, call.ID)
+	err = sim.sendCallWebhook(call, "connect", map[string]interface{}{
+		"session": map[string]string{
+			"sdp_type": "offer",
+			"sdp":      call.pc.LocalDescription().SDP,
+		},
+	})
+	if err != nil {
+		sim.endCall(call, "FAILED", "bridge didn't take the call", false)
+		return nil, err
+	}
+	return call, nil
+}
+
+// applyAnswer sets the bridge's SDP answer on an inbound call. WhatsApp gets
+// the same answer with pre_accept and accept; only the first is applied.
+func (sim *Simulator) applyAnswer(call *SimCall, sdp string) error {
+	call.mu.Lock()
+	defer call.mu.Unlock()
+	if call.answerSet || sdp == "" {
+		return nil
+	}
+	if err := call.pc.SetRemoteDescription(webrtc.SessionDescription{Type: webrtc.SDPTypeAnswer, SDP: sdp}); err != nil {
+		return fmt.Errorf("failed to set SDP answer: %w", err)
+	}
+	call.answerSet = true
+	return nil
+}
+
+// ringOutboundCall plays the user's side of a business-initiated call: the
+// phone rings, then the user answers, declines or ignores it per SIM_OUTBOUND
+func (sim *Simulator) ringOutboundCall(call *SimCall, offer string) {
+	sim.sendStatusWebhook(call.Business, call.ID, call.User, "call", "RINGING")
+
+	select {
+	case <-time.After(sim.cfg.RingDelay):
+	case <-call.done:
+		return
+	}
+
+	switch sim.cfg.Outbound {
+	case "ignore":
+		log.Printf("🔕 Call %s: user isn't picking up", call.ID)
+		return
+	case "reject":
+		log.Printf("🙅 Call %s: user declined", call.ID)
+		sim.sendStatusWebhook(call.Business, call.ID, call.User, "call", "REJECTED")
+		sim.endCall(call, "REJECTED", "declined by user", true)
+		return
+	}
+
+	if err := sim.answerOutboundCall(call, offer); err != nil {
+		log.Printf("❌ Call %s: %v", call.ID, err)
+		sim.endCall(call, "FAILED", err.Error(), true)
+	}
+}
+
+// answerOutboundCall answers the bridge's offer and sends ACCEPTED followed
+// by the connect webhook carrying the SDP answer
+func (sim *Simulator) answerOutboundCall(call *SimCall, offer string) error {
+	if err := sim.newPeer(call); err != nil {
+		return err
+	}
+	if err := call.pc.SetRemoteDescription(webrtc.SessionDescription{Type: webrtc.SDPTypeOffer, SDP: offer}); err != nil {
+		return fmt.Errorf("failed to set SDP offer: %w", err)
+	}
+	answer, err := call.pc.CreateAnswer(nil)
+	if err != nil {
+		return fmt.Errorf("failed to create answer: %w", err)
+	}
+	gatherComplete := webrtc.GatheringCompletePromise(call.pc)
+	if err := call.pc.SetLocalDescription(answer); err != nil {
+		return fmt.Errorf("failed to set local description: %w", err)
+	}
+	<-gatherComplete
+
+	if !call.setState(callStateAccepted) {
+		return nil
+	}
+	log.Printf("✅ Call %s: user answered", call.ID)
+	sim.sendStatusWebhook(call.Business, call.ID, call.User, "call", "ACCEPTED")
+	return sim.sendCallWebhook(call, "connect", map[string]interface{}{
+		"session": map[string]string{
+			"sdp_type": "answer",
+			"sdp":      call.pc.LocalDescription().SDP,
+		},
+	})
+}
+
+// endCall tears the call down once. With notify, the bridge gets the
+// terminate webhook WhatsApp would send.
+func (sim *Simulator) endCall(call *SimCall, status, reason string, notify bool) {
+	call.endOnce.Do(func() {
+		call.mu.Lock()
+		connectTime := call.connectTime
+		pc := call.pc
+		call.state = callStateEnded
+		call.endTime = time.Now()
+		call.endStatus = status
+		call.endReason = reason
+		call.mu.Unlock()
+
+		close(call.done)
+		if pc != nil {
+			pc.Close()
+		}
+		log.Printf("☎️ Call %s ended (%s): %s - sent %d packets, received %d",
+			call.ID, status, reason, call.packetsSent.Load(), call.packetsReceived.Load())
+
+		if !notify {
+			return
+		}
+		extra := map[string]interface{}{"status": status}
+		if !connectTime.IsZero() {
+			extra["start_time"] = fmt.Sprint(connectTime.Unix())
+			extra["end_time"] = fmt.Sprint(time.Now().Unix())
+			extra["duration"] = int(time.Since(connectTime).Seconds())
+		}
+		sim.sendCallWebhook(call, "terminate", extra)
+	})
+}
+
+// playAudio sends the user's audio until the call ends
+func (sim *Simulator) playAudio(call *SimCall) {
+	source, err := sim.newAudioSource()
+	if err != nil {
+		log.Printf("❌ Call %s: no audio to play: %v", call.ID, err)
+		return
+	}
+
+	next := time.Now()
+	for {
+		sample, err := source.Next()
+		if err != nil {
+			log.Printf("❌ Call %s: audio source failed: %v", call.ID, err)
+			return
+		}
+		if err := call.track.WriteSample(sample); err != nil {
+			return
+		}
+		call.packetsSent.Add(1)
+
+		next = next.Add(sample.Duration)
+		select {
+		case <-time.After(time.Until(next)):
+		case <-call.done:
+			return
+		}
+	}
+}
+
+// receiveAudio counts the bridge's packets and records them when SIM_RECORD_DIR is set
+func (sim *Simulator) receiveAudio(call *SimCall, remote *webrtc.TrackRemote) {
+	var recorder *oggwriter.OggWriter
+	if sim.cfg.RecordDir != "" {
+		if err := os.MkdirAll(sim.cfg.RecordDir, 0755); err != nil {
+			log.Printf("⚠️ Call %s: can't record: %v", call.ID, err)
+		} else if recorder, err = oggwriter.New(filepath.Join(sim.cfg.RecordDir, call.ID+".ogg"), 48000, 2); err != nil {
+			log.Printf("⚠️ Call %s: can't record: %v", call.ID, err)
+		}
+	}
+	if recorder != nil {
+		defer recorder.Close()
+	}
+
+	for {
+		packet, _, err := remote.ReadRTP()
+		if err != nil {
+			return
+		}
+		if call.packetsReceived.Add(1) == 1 {
+			log.Printf("🔊 Call %s: first audio packet from the bridge", call.ID)
+		}
+		if recorder != nil {
+			recorder.WriteRTP(packet)
+		}
+	}
+}
+
+// audioSource produces the user's side of the conversation as Opus samples
+type audioSource interface {
+	Next() (media.Sample, error)
+}
+
+// newAudioSource plays SIM_AUDIO_FILE on a loop, or a test tone
+func (sim *Simulator) newAudioSource() (audioSource, error) {
+	if sim.cfg.AudioFile != "" {
+		return newOggSource(sim.cfg.AudioFile)
+	}
+	return newToneSource()
+}
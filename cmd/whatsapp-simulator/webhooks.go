@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+)
+
+// webhookValue starts a change value from business with the user's contact
+func (sim *Simulator) webhookValue(business Business, user string) map[string]interface{} {
+	return map[string]interface{}{
+		"messaging_product": "whatsapp",
+		"metadata": map[string]string{
+			"display_phone_number": business.DisplayPhoneNumber,
+			"phone_number_id":      business.PhoneNumberID,
+		},
+		"contacts": []interface{}{
+			map[string]interface{}{
+				"wa_id":   user,
+				"profile": map[string]string{"name": sim.cfg.UserName},
+			},
+		},
+	}
+}
+
+// sendCallWebhook fires a calls[] event for call; extra fields (session,
+// status, duration, ...) are merged into the call object
+func (sim *Simulator) sendCallWebhook(call *SimCall, event string, extra map[string]interface{}) error {
+	from, to := call.User, call.Business.DisplayPhoneNumber
+	if call.Direction == directionBusinessInitiated {
+		from, to = to, from
+	}
+
+	entry := map[string]interface{}{
+		"id":        call.ID,
+		"from":      from,
+		"to":        to,
+		"event":     event,
+		"timestamp": unixNow(),
+		"direction": call.Direction,
+	}
+	for k, v := range extra {
+		entry[k] = v
+	}
+
+	value := sim.webhookValue(call.Business, call.User)
+	value["calls"] = []interface{}{entry}
+	return sim.sendWebhook("calls", call.Business, value)
+}
+
+// sendStatusWebhook fires a statuses[] update; statusType is "call" for call
+// statuses and empty for message statuses
+func (sim *Simulator) sendStatusWebhook(business Business, id, recipient, statusType, status string) error {
+	entry := map[string]interface{}{
+		"id":           id,
+		"status":       status,
+		"timestamp":    unixNow(),
+		"recipient_id": recipient,
+	}
+	if statusType != "" {
+		entry["type"] = statusType
+	}
+
+	value := sim.webhookValue(business, recipient)
+	value["statuses"] = []interface{}{entry}
+	field := "messages"
+	if statusType == "call" {
+		field = "calls"
+	}
+	return sim.sendWebhook(field, business, value)
+}
+
+// sendWebhook wraps value in the whatsapp_business_account envelope and POSTs
+// it to the bridge, signed the way Meta signs it when an app secret is set
+func (sim *Simulator) sendWebhook(field string, business Business, value map[string]interface{}) error {
+	payload := map[string]interface{}{
+		"object": "whatsapp_business_account",
+		"entry": []interface{}{
+			map[string]interface{}{
+				"id": business.PhoneNumberID,
+				"changes": []interface{}{
+					map[string]interface{}{"field": field, "value": value},
+				},
+			},
+		},
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", sim.cfg.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if sim.cfg.AppSecret != "" {
+		mac := hmac.New(sha256.New, []byte(sim.cfg.AppSecret))
+		mac.Write(body)
+		req.Header.Set("X-Hub-Signature-256", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := sim.httpClient.Do(req)
+	if err != nil {
+		log.Printf("❌ Webhook (%s) failed: %v", field, err)
+		return fmt.Errorf("webhook delivery failed: %w", err)
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		log.Printf("❌ Webhook (%s) rejected: %s - %s", field, resp.Status, string(respBody))
+		return fmt.Errorf("webhook rejected: %s", resp.Status)
+	}
+	log.Printf("📨 Webhook (%s) delivered to bridge", field)
+	return nil
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+const (
+	// memoryFetchTimeout bounds how long building the memory may delay a call
+	// or a reply; sections that aren't back in time are left out
+	memoryFetchTimeout = 3 * time.Second
+
+	memoryMessages  = 8   // Recent WhatsApp texts
+	memoryCallTurns = 8   // Recent turns from earlier calls
+	memoryTasks     = 10  // Open tasks
+	memoryReminders = 5   // Upcoming reminders
+	memoryLineChars = 200 // Longer messages are cut short
+)
+
+// ConversationMemory summarizes what Ziggy already knows about one user -
+// recent texts, earlier calls, open tasks and upcoming reminders - so voice
+// calls and text chat pick up where the other left off
+type ConversationMemory struct {
+	tenant      *Tenant
+	phoneNumber string
+	logger      *slog.Logger
+}
+
+// NewConversationMemory creates the memory for a user on a tenant's number,
+// logging to the caller's logger
+func NewConversationMemory(tenant *Tenant, phoneNumber string, logger *slog.Logger) *ConversationMemory {
+	return &ConversationMemory{
+		tenant:      tenant,
+		phoneNumber: phoneNumber,
+		logger:      logger,
+	}
+}
+
+// memorySection is one rendered part of the context block
+type memorySection struct {
+	index int
+	text  string
+}
+
+// ContextBlock returns the memory as a compact block for a system prompt, or ""
+// when there is nothing to remember. Callers that already pass the chat history
+// as messages (the text model) set includeConversation=false so only tasks and
+// reminders are added.
+func (m *ConversationMemory) ContextBlock(includeConversation bool) string {
+	if !dataStore.Configured() {
+		return ""
+	}
+
+	loc := m.location()
+	builders := []func(*time.Location) (string, error){m.openTasks, m.upcomingReminders}
+	if includeConversation {
+		builders = append([]func(*time.Location) (string, error){m.recentMessages, m.recentCalls}, builders...)
+	}
+
+	// Fetch every section in parallel; a call is waiting on this
+	results := make(chan memorySection, len(builders))
+	for i, build := range builders {
+		go func(i int, build func(*time.Location) (string, error)) {
+			text, err := build(loc)
+			if err != nil {
+				m.logger.Warn("⚠️ Conversation memory section failed", "phone", m.phoneNumber, "error", err)
+			}
+			results <- memorySection{index: i, text: text}
+		}(i, build)
+	}
+
+	sections := make([]string, len(builders))
+	timeout := time.After(memoryFetchTimeout)
+collect:
+	for range builders {
+		select {
+		case s := <-results:
+			sections[s.index] = s.text
+		case <-timeout:
+			m.logger.Warn("⚠️ Conversation memory timed out - using what arrived", "phone", m.phoneNumber)
+			break collect
+		}
+	}
+
+	var parts []string
+	for _, s := range sections {
+		if s != "" {
+			parts = append(parts, s)
+		}
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+
+	m.logger.Info("🧠 Conversation memory loaded", "phone", m.phoneNumber, "sections", len(parts))
+	return "CONVERSATION MEMORY (what you already know about this user from earlier texts and calls - use it naturally, don't read it out):\n" +
+		strings.Join(parts, "\n")
+}
+
+// location is the user's timezone, used to show times the way they'd say them
+func (m *ConversationMemory) location() *time.Location {
+	timezone, _ := GetTimezoneFromPhoneNumber(m.phoneNumber)
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// recentMessages renders the latest WhatsApp texts, oldest first
+func (m *ConversationMemory) recentMessages(loc *time.Location) (string, error) {
+	messages, err := listRecentMessages(m.tenant.SupabaseSchema, m.phoneNumber, memoryMessages)
+	if err != nil || len(messages) == 0 {
+		return "", err
+	}
+
+	var b strings.Builder
+	b.WriteString("Recent WhatsApp messages:\n")
+	for i := len(messages) - 1; i >= 0; i-- {
+		msg := messages[i]
+		speaker := "User"
+		if msg.Direction == "outbound" {
+			speaker = "Ziggy"
+		}
+		fmt.Fprintf(&b, "- %s (%s): %s\n", speaker, memoryTime(msg.Timestamp, loc), memoryLine(msg.MessageContent))
+	}
+	return strings.TrimSuffix(b.String(), "\n"), nil
+}
+
+// recentCalls renders the latest turns from earlier voice calls, oldest first
+func (m *ConversationMemory) recentCalls(loc *time.Location) (string, error) {
+	turns, err := ListCallTranscriptTurns(m.tenant.SupabaseSchema, m.phoneNumber, memoryCallTurns)
+	if err != nil || len(turns) == 0 {
+		return "", err
+	}
+
+	var b strings.Builder
+	b.WriteString("Earlier voice calls:\n")
+	for i := len(turns) - 1; i >= 0; i-- {
+		turn := turns[i]
+		speaker := "User"
+		if turn.Speaker == speakerAssistant {
+			speaker = "Ziggy"
+		}
+		fmt.Fprintf(&b, "- %s (%s): %s\n", speaker, memoryTime(turn.StartedAt, loc), memoryLine(turn.Content))
+	}
+	return strings.TrimSuffix(b.String(), "\n"), nil
+}
+
+// openTasks renders tasks that are pending or in progress
+func (m *ConversationMemory) openTasks(loc *time.Location) (string, error) {
+	tasks, err := ListTasks(m.tenant.SupabaseSchema, m.phoneNumber, "")
+	if err != nil {
+		return "", err
+	}
+
+	var lines []string
+	for _, task := range tasks {
+		if task.Status != "pending" && task.Status != "in_progress" {
+			continue
+		}
+		line := "- " + memoryLine(task.Title)
+		if task.Priority != "" && task.Priority != "medium" {
+			line += fmt.Sprintf(" (%s priority)", task.Priority)
+		}
+		if task.Status == "in_progress" {
+			line += " [in progress]"
+		}
+		lines = append(lines, line)
+		if len(lines) == memoryTasks {
+			break
+		}
+	}
+	if len(lines) == 0 {
+		return "", nil
+	}
+	return "Open tasks:\n" + strings.Join(lines, "\n"), nil
+}
+
+// upcomingReminders renders pending reminders that are still ahead
+func (m *ConversationMemory) upcomingReminders(loc *time.Location) (string, error) {
+	reminders, err := ListReminders(m.tenant.SupabaseSchema, m.phoneNumber, "pending")
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	var lines []string
+	for _, reminder := range reminders {
+		at, err := parseSupabaseTime(reminder.ReminderTime)
+		if err != nil || at.Before(now) {
+			continue
+		}
+		line := fmt.Sprintf("- %s - %s", memoryLine(reminder.ReminderText), at.In(loc).Format("Mon Jan 2 3:04 PM"))
+		if reminder.RecurrencePattern != "" && reminder.RecurrencePattern != "once" {
+			line += " (" + reminder.RecurrencePattern + ")"
+		}
+		lines = append(lines, line)
+		if len(lines) == memoryReminders {
+			break
+		}
+	}
+	if len(lines) == 0 {
+		return "", nil
+	}
+	return "Upcoming reminders:\n" + strings.Join(lines, "\n"), nil
+}
+
+// listRecentMessages retrieves the latest WhatsApp texts for a phone number, newest first
+func listRecentMessages(schema string, phoneNumber string, limit int) ([]TextMessage, error) {
+	return dataStore.ListMessages(schema, phoneNumber, limit, false)
+}
+
+// parseSupabaseTime parses a PostgREST timestamptz
+func parseSupabaseTime(value string) (time.Time, error) {
+	return time.Parse(time.RFC3339Nano, value)
+}
+
+// memoryTime formats a stored timestamp in the user's timezone
+func memoryTime(value string, loc *time.Location) string {
+	at, err := parseSupabaseTime(value)
+	if err != nil {
+		return "earlier"
+	}
+	return at.In(loc).Format("Mon Jan 2 3:04 PM")
+}
+
+// memoryLine flattens text onto one line and cuts it to memoryLineChars
+func memoryLine(text string) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if runes := []rune(text); len(runes) > memoryLineChars {
+		text = string(runes[:memoryLineChars]) + "…"
+	}
+	return text
+}
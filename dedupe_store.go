@@ -0,0 +1,235 @@
+package main
+
+import (
+	"container/list"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Defaults for the dedupe store; override with DEDUPE_TTL and DEDUPE_MAX_ENTRIES
+const (
+	defaultDedupeTTL        = 24 * time.Hour // Meta retries failed deliveries for up to a day
+	defaultDedupeMaxEntries = 10000
+)
+
+// DedupeStore remembers which webhook events have already been handled so
+// Meta's retries don't trigger a second AI reply or a second call accept
+type DedupeStore interface {
+	// CheckAndMark records key as processed and reports whether it had
+	// already been processed within the store's TTL
+	CheckAndMark(key string) bool
+
+	// Forget releases key after a failed attempt so a retry can process it again
+	Forget(key string)
+}
+
+// messageDedupeKey is the dedupe key for an inbound message
+func messageDedupeKey(messageID string) string {
+	return "msg:" + messageID
+}
+
+// callDedupeKey is the dedupe key for one event on a call
+func callDedupeKey(callID, event string) string {
+	return "call:" + callID + ":" + event
+}
+
+// NewDedupeStoreFromEnv builds the store selected by DEDUPE_STORE ("memory"
+// or "supabase", default "memory"), sized by DEDUPE_TTL and DEDUPE_MAX_ENTRIES
+func NewDedupeStoreFromEnv() DedupeStore {
+	ttl := defaultDedupeTTL
+	if v := os.Getenv("DEDUPE_TTL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			ttl = d
+		} else {
+			slog.Warn("⚠️ Invalid DEDUPE_TTL, using default", "value", v, "default", ttl)
+		}
+	}
+
+	maxEntries := defaultDedupeMaxEntries
+	if v := os.Getenv("DEDUPE_MAX_ENTRIES"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			maxEntries = n
+		} else {
+			slog.Warn("⚠️ Invalid DEDUPE_MAX_ENTRIES, using default", "value", v, "default", maxEntries)
+		}
+	}
+
+	memory := NewMemoryDedupeStore(ttl, maxEntries)
+
+	switch backend := os.Getenv("DEDUPE_STORE"); backend {
+	case "", "memory":
+		slog.Info("🔁 Dedupe store: memory", "ttl", ttl, "max_entries", maxEntries)
+		return memory
+	case "supabase":
+		store, err := NewSupabaseDedupeStore(ttl, memory)
+		if err != nil {
+			slog.Warn("⚠️ Falling back to in-memory dedupe", "error", err)
+			return memory
+		}
+		slog.Info("🔁 Dedupe store: supabase", "ttl", ttl)
+		return store
+	default:
+		slog.Warn("⚠️ Unknown DEDUPE_STORE - using in-memory dedupe", "backend", backend)
+		return memory
+	}
+}
+
+// MemoryDedupeStore is an in-process LRU of processed keys. Entries expire
+// after ttl and the least recently seen keys are evicted beyond maxEntries,
+// so memory stays bounded no matter how long the bridge runs.
+type MemoryDedupeStore struct {
+	ttl        time.Duration
+	maxEntries int
+	order      *list.List // Front = most recently seen
+	entries    map[string]*list.Element
+	mu         sync.Mutex
+}
+
+// dedupeEntry is one key in the memory store
+type dedupeEntry struct {
+	key     string
+	expires time.Time
+}
+
+// NewMemoryDedupeStore creates an in-memory dedupe store
+func NewMemoryDedupeStore(ttl time.Duration, maxEntries int) *MemoryDedupeStore {
+	return &MemoryDedupeStore{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		order:      list.New(),
+		entries:    make(map[string]*list.Element),
+	}
+}
+
+// CheckAndMark implements DedupeStore
+func (s *MemoryDedupeStore) CheckAndMark(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+
+	if elem, ok := s.entries[key]; ok {
+		entry := elem.Value.(*dedupeEntry)
+		duplicate := now.Before(entry.expires)
+		entry.expires = now.Add(s.ttl)
+		s.order.MoveToFront(elem)
+		return duplicate
+	}
+
+	s.entries[key] = s.order.PushFront(&dedupeEntry{key: key, expires: now.Add(s.ttl)})
+
+	// Drop expired keys from the tail, then enforce the size bound
+	for s.order.Len() > 0 {
+		oldest := s.order.Back()
+		entry := oldest.Value.(*dedupeEntry)
+		if s.order.Len() <= s.maxEntries && now.Before(entry.expires) {
+			break
+		}
+		s.order.Remove(oldest)
+		delete(s.entries, entry.key)
+	}
+
+	return false
+}
+
+// Forget implements DedupeStore
+func (s *MemoryDedupeStore) Forget(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if elem, ok := s.entries[key]; ok {
+		s.order.Remove(elem)
+		delete(s.entries, key)
+	}
+}
+
+// Len returns the number of keys currently held
+func (s *MemoryDedupeStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.order.Len()
+}
+
+// SupabaseDedupeStore records processed keys in the ziggy_processed_events
+// table so retries are caught across restarts and across bridge instances.
+// If Supabase can't be reached it falls back to the in-memory store.
+type SupabaseDedupeStore struct {
+	client   *SupabaseClient
+	ttl      time.Duration
+	fallback *MemoryDedupeStore
+}
+
+// processedEvent is a row in ziggy_processed_events
+type processedEvent struct {
+	EventKey  string `json:"event_key"`
+	ExpiresAt string `json:"expires_at"`
+}
+
+// NewSupabaseDedupeStore creates a Supabase-backed dedupe store
+func NewSupabaseDedupeStore(ttl time.Duration, fallback *MemoryDedupeStore) (*SupabaseDedupeStore, error) {
+	client := NewSupabaseClient(5 * time.Second)
+	if !client.Configured() {
+		return nil, fmt.Errorf("Supabase credentials not configured")
+	}
+
+	return &SupabaseDedupeStore{
+		client:   client,
+		ttl:      ttl,
+		fallback: fallback,
+	}, nil
+}
+
+// CheckAndMark implements DedupeStore
+func (s *SupabaseDedupeStore) CheckAndMark(key string) bool {
+	duplicate, err := s.insert(key)
+	if err != nil {
+		slog.Warn("⚠️ Supabase dedupe failed, using in-memory", "key", key, "error", err)
+		return s.fallback.CheckAndMark(key)
+	}
+	// Keep the local copy warm so a later Supabase outage doesn't forget recent keys
+	s.fallback.CheckAndMark(key)
+	return duplicate
+}
+
+// Forget implements DedupeStore
+func (s *SupabaseDedupeStore) Forget(key string) {
+	s.fallback.Forget(key)
+
+	q := From("ziggy_processed_events").Eq("event_key", key)
+	if _, err := s.client.Do("", "DELETE", q, nil, ""); err != nil {
+		slog.Warn("⚠️ Supabase dedupe failed to forget key", "key", key, "error", err)
+	}
+}
+
+// insert adds key to ziggy_processed_events. The primary key on event_key
+// makes concurrent deliveries race safely: only one insert wins.
+func (s *SupabaseDedupeStore) insert(key string) (bool, error) {
+	now := time.Now().UTC()
+
+	// Clear an expired row for this key first so it can be claimed again
+	expired := From("ziggy_processed_events").Eq("event_key", key).Lt("expires_at", now)
+	if _, err := s.client.Do("", "DELETE", expired, nil, ""); err != nil {
+		return false, err
+	}
+
+	row := processedEvent{
+		EventKey:  key,
+		ExpiresAt: now.Add(s.ttl).Format(time.RFC3339),
+	}
+	resp, err := s.client.Do("", "POST", From("ziggy_processed_events"), row, "resolution=ignore-duplicates,return=representation")
+	if err != nil {
+		return false, err
+	}
+
+	// ignore-duplicates returns an empty array when the key already existed
+	var inserted []processedEvent
+	if err := json.Unmarshal(resp.Body, &inserted); err != nil {
+		return false, err
+	}
+	return len(inserted) == 0, nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// TestMemoryDedupeStoreExpiry checks a key is a duplicate until its TTL
+// passes, and that expired keys are dropped when new ones arrive
+func TestMemoryDedupeStoreExpiry(t *testing.T) {
+	const ttl = 50 * time.Millisecond
+	s := NewMemoryDedupeStore(ttl, 100)
+
+	if s.CheckAndMark("wamid.1") {
+		t.Fatal("first delivery reported as a duplicate")
+	}
+	if !s.CheckAndMark("wamid.1") {
+		t.Fatal("retry within the TTL not reported as a duplicate")
+	}
+
+	time.Sleep(2 * ttl)
+	if s.CheckAndMark("wamid.1") {
+		t.Error("delivery after the TTL reported as a duplicate")
+	}
+
+	time.Sleep(2 * ttl)
+	s.CheckAndMark("wamid.2")
+	if n := s.Len(); n != 1 {
+		t.Errorf("Len() = %d after wamid.1 expired, want 1", n)
+	}
+}
+
+// TestMemoryDedupeStoreEviction checks the store holds at most maxEntries
+// keys, evicting the least recently seen
+func TestMemoryDedupeStoreEviction(t *testing.T) {
+	s := NewMemoryDedupeStore(time.Hour, 3)
+
+	for _, key := range []string{"a", "b", "c"} {
+		s.CheckAndMark(key)
+	}
+	s.CheckAndMark("a") // Seen again, so "b" is now the oldest
+	s.CheckAndMark("d")
+
+	if n := s.Len(); n != 3 {
+		t.Errorf("Len() = %d, want 3", n)
+	}
+	for key, want := range map[string]bool{"a": true, "c": true, "d": true} {
+		if got := s.CheckAndMark(key); got != want {
+			t.Errorf("CheckAndMark(%q) = %v, want %v", key, got, want)
+		}
+	}
+	if s.CheckAndMark("b") {
+		t.Error("evicted key b still reported as a duplicate")
+	}
+}
+
+// TestWhatsAppClientProcessedWrappers checks the deprecated IsProcessed and
+// MarkProcessed still behave as before on top of CheckAndMarkProcessed
+func TestWhatsAppClientProcessedWrappers(t *testing.T) {
+	c := NewWhatsAppClient(&Config{Token: "token", PhoneID: testPhoneNumberID})
+
+	if c.IsProcessed("wamid.1") {
+		t.Fatal("unseen message reported as processed")
+	}
+	if c.IsProcessed("wamid.1") {
+		t.Fatal("IsProcessed marked the message")
+	}
+	c.MarkProcessed("wamid.1")
+	if !c.IsProcessed("wamid.1") {
+		t.Error("marked message not reported as processed")
+	}
+	if !c.CheckAndMarkProcessed("wamid.1") {
+		t.Error("CheckAndMarkProcessed does not see MarkProcessed")
+	}
+}
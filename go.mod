@@ -1,15 +1,18 @@
 module github.com/user/pion-whatsapp-bridge
 
-go 1.23.0
-
-toolchain go1.24.5
+go 1.25.0
 
 require (
 	github.com/gorilla/mux v1.8.0
+	github.com/gorilla/websocket v1.5.3
 	github.com/joho/godotenv v1.5.1
+	github.com/lib/pq v1.12.3
+	github.com/mattn/go-sqlite3 v1.14.33
 	github.com/nyaruka/phonenumbers v1.6.6
+	github.com/pion/interceptor v0.1.41
 	github.com/pion/rtp v1.8.23
 	github.com/pion/webrtc/v4 v4.1.6
+	github.com/thesyncim/gopus v0.1.2
 )
 
 require (
@@ -17,7 +20,6 @@ require (
 	github.com/pion/datachannel v1.5.10 // indirect
 	github.com/pion/dtls/v3 v3.0.7 // indirect
 	github.com/pion/ice/v4 v4.0.10 // indirect
-	github.com/pion/interceptor v0.1.41 // indirect
 	github.com/pion/logging v0.2.4 // indirect
 	github.com/pion/mdns/v2 v2.0.7 // indirect
 	github.com/pion/randutil v0.1.0 // indirect
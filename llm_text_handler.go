@@ -2,12 +2,14 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
+	"sort"
 	"strings"
 	"time"
 )
@@ -17,10 +19,12 @@ type LLMTextHandler struct {
 	phoneNumber string
 	apiKey      string
 	endpoint    string
+	tenant      *Tenant      // Business number the conversation is happening on
+	logger      *slog.Logger // Carries the webhook job and message the reply is for
 }
 
-// NewLLMTextHandler creates a new LLM text handler
-func NewLLMTextHandler(phoneNumber string) *LLMTextHandler {
+// NewLLMTextHandler creates a new LLM text handler that logs through ctx's logger
+func NewLLMTextHandler(ctx context.Context, tenant *Tenant, phoneNumber string) *LLMTextHandler {
 	// Primary: Azure OpenAI env vars (matching your .env)
 	apiKey := os.Getenv("AZURE_OPENAI_API_KEY")
 	endpoint := os.Getenv("AZURE_OPENAI_ENDPOINT")
@@ -49,6 +53,8 @@ func NewLLMTextHandler(phoneNumber string) *LLMTextHandler {
 		phoneNumber: phoneNumber,
 		apiKey:      apiKey,
 		endpoint:    endpoint,
+		tenant:      tenant,
+		logger:      loggerFrom(ctx),
 	}
 }
 
@@ -80,14 +86,14 @@ func (h *LLMTextHandler) GetSystemPrompt() string {
 	// Get current date and time in user's timezone
 	loc, err := time.LoadLocation(timezone)
 	if err != nil {
-		log.Printf("⚠️ Failed to load timezone %s, falling back to UTC: %v", timezone, err)
+		h.logger.Warn("⚠️ Failed to load timezone, falling back to UTC", "timezone", timezone, "error", err)
 		loc = time.UTC
 	}
 	currentTime := time.Now().In(loc)
 	currentDateTimeStr := currentTime.Format("Monday, January 2, 2006 at 3:04 PM MST")
 
 	// Same system prompt as voice assistant - consistent experience
-	return fmt.Sprintf(`You are Ziggy, a helpful assistant for task management, notes, and reminders via TEXT MESSAGE (WhatsApp).
+	prompt := fmt.Sprintf(`You are Ziggy, a helpful assistant for task management, notes, and reminders via TEXT MESSAGE (WhatsApp).
 
 COMMUNICATION STYLE:
 - Keep responses SHORT (1-2 sentences max)
@@ -130,16 +136,25 @@ CONTEXT:
 - When setting reminders, convert user's time to format: YYYY-MM-DD HH:MM (24-hour format)
 
 Remember: Be helpful by DOING things quickly, not by asking endless questions!`, currentDateTimeStr, timezone)
+
+	// Tenant-specific persona on top of the shared instructions
+	if h.tenant.Persona != "" {
+		prompt += "\n\nPERSONA:\n" + h.tenant.Persona
+	}
+
+	// Open tasks and upcoming reminders; the chat history itself goes in as messages
+	if memory := NewConversationMemory(h.tenant, h.phoneNumber, h.logger).ContextBlock(false); memory != "" {
+		prompt += "\n\n" + memory
+	}
+
+	return prompt
 }
 
-// SaveMessage saves a text message to Supabase
+// SaveMessage saves a text message to the store
 func (h *LLMTextHandler) SaveMessage(message, direction, messageID, contactName string) error {
-	supabaseURL := os.Getenv("SUPABASE_URL")
-	supabaseKey := os.Getenv("SUPABASE_ANON_KEY")
-
-	if supabaseURL == "" || supabaseKey == "" {
-		log.Println("⚠️ Supabase not configured, message not saved")
-		return nil // Don't fail if Supabase isn't configured
+	if !dataStore.Configured() {
+		h.logger.Warn("⚠️ Store not configured, message not saved")
+		return nil // Don't fail if the store isn't configured
 	}
 
 	msg := TextMessage{
@@ -153,187 +168,130 @@ func (h *LLMTextHandler) SaveMessage(message, direction, messageID, contactName
 		ContactName:    contactName,
 	}
 
-	jsonData, err := json.Marshal(msg)
-	if err != nil {
-		return err
-	}
-
-	url := fmt.Sprintf("%s/rest/v1/ziggy_messages", supabaseURL)
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return err
-	}
-
-	req.Header.Set("apikey", supabaseKey)
-	req.Header.Set("Authorization", "Bearer "+supabaseKey)
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Prefer", "return=representation")
-
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
+	if err := dataStore.SaveMessage(h.tenant.SupabaseSchema, msg); err != nil {
 		return err
 	}
-	defer resp.Body.Close()
-
-	body, _ := io.ReadAll(resp.Body)
-
-	if resp.StatusCode == http.StatusConflict {
-		// Duplicate message ID - already saved
-		log.Printf("🔁 Message already saved (duplicate ID)")
-		return nil
-	}
-
-	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("Supabase error: %s - %s", resp.Status, string(body))
-	}
 
-	log.Printf("✅ Saved %s message: %s", direction, message)
+	h.logger.Info("✅ Saved message", "direction", direction)
+	h.logger.Debug("✅ Saved message text", "text", message)
 	return nil
 }
 
-// GetConversationHistory retrieves recent conversation history from Supabase
-// Implements the same pattern as horizon bot: first 20 + last 20 messages
+// GetConversationHistory retrieves recent conversation history from the store
+// Implements the same pattern as horizon bot: first 20 + last 20 messages.
+// Turns from the user's voice calls are merged into the recent window by time.
 func (h *LLMTextHandler) GetConversationHistory() ([]ChatMessage, error) {
-	supabaseURL := os.Getenv("SUPABASE_URL")
-	supabaseKey := os.Getenv("SUPABASE_ANON_KEY")
-
-	if supabaseURL == "" || supabaseKey == "" {
-		log.Println("⚠️ Supabase not configured, no history available")
+	if !dataStore.Configured() {
+		h.logger.Warn("⚠️ Store not configured, no history available")
 		return []ChatMessage{}, nil
 	}
 
 	// Get total message count
 	totalCount, err := h.getMessageCount()
 	if err != nil {
-		log.Printf("⚠️ Failed to get message count: %v", err)
+		h.logger.Warn("⚠️ Failed to get message count", "error", err)
 		return []ChatMessage{}, nil
 	}
 
-	if totalCount == 0 {
-		return []ChatMessage{}, nil
-	}
-
-	var messages []ChatMessage
+	var foundation, recent []historyMessage
 
 	if totalCount <= 20 {
 		// If 20 or fewer messages, return all in chronological order
-		messages, err = h.fetchMessages(totalCount, true)
-		if err != nil {
-			return []ChatMessage{}, err
+		if totalCount > 0 {
+			recent, err = h.fetchMessages(totalCount, true)
+			if err != nil {
+				return []ChatMessage{}, err
+			}
 		}
-		log.Printf("💬 Context: %d messages (all)", totalCount)
+		h.logger.Debug("💬 Context: all messages", "messages", totalCount)
 	} else {
 		// Get first 20 messages (permanent foundation)
-		first20, err := h.fetchMessages(20, true)
+		foundation, err = h.fetchMessages(20, true)
 		if err != nil {
 			return []ChatMessage{}, err
 		}
 
 		// Get last 20 messages (rolling window)
-		last20, err := h.fetchMessages(20, false)
+		recent, err = h.fetchMessages(20, false)
 		if err != nil {
 			return []ChatMessage{}, err
 		}
+		h.logger.Debug("💬 Context: 40 messages (20 foundation + 20 recent)", "total", totalCount)
+	}
 
-		// Combine: foundation + recent
-		messages = append(first20, last20...)
-		log.Printf("💬 Context: 40 messages (20 foundation + 20 recent) from total %d", totalCount)
+	recent = h.withCallTranscripts(recent, 20)
+
+	// Combine: foundation + recent
+	messages := make([]ChatMessage, 0, len(foundation)+len(recent))
+	for _, msg := range append(foundation, recent...) {
+		messages = append(messages, msg.ChatMessage)
 	}
 
 	return messages, nil
 }
 
-// getMessageCount gets the total message count for this phone number
-func (h *LLMTextHandler) getMessageCount() (int, error) {
-	supabaseURL := os.Getenv("SUPABASE_URL")
-	supabaseKey := os.Getenv("SUPABASE_ANON_KEY")
-
-	url := fmt.Sprintf("%s/rest/v1/ziggy_messages?phone_number=eq.%s&select=id",
-		supabaseURL, h.phoneNumber)
+// historyMessage is a chat message with the time it was sent or spoken
+type historyMessage struct {
+	ChatMessage
+	at time.Time
+}
 
-	req, err := http.NewRequest("GET", url, nil)
+// withCallTranscripts merges the user's most recent call transcript turns into
+// the recent messages in time order, keeping the last limit entries. Voice turns
+// are labeled so the model can tell what was said on a call.
+func (h *LLMTextHandler) withCallTranscripts(recent []historyMessage, limit int) []historyMessage {
+	turns, err := ListCallTranscriptTurns(h.tenant.SupabaseSchema, h.phoneNumber, limit)
 	if err != nil {
-		return 0, err
+		h.logger.Warn("⚠️ Failed to get call transcripts", "error", err)
+		return recent
 	}
-
-	req.Header.Set("apikey", supabaseKey)
-	req.Header.Set("Authorization", "Bearer "+supabaseKey)
-	req.Header.Set("Prefer", "count=exact")
-
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		return 0, err
+	if len(turns) == 0 {
+		return recent
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return 0, fmt.Errorf("failed to get count: %s", resp.Status)
+	merged := append([]historyMessage{}, recent...)
+	for _, turn := range turns {
+		at, _ := parseSupabaseTime(turn.StartedAt)
+		merged = append(merged, historyMessage{
+			ChatMessage: ChatMessage{Role: turn.Speaker, Content: "(on a call) " + turn.Content},
+			at:          at,
+		})
 	}
+	sort.SliceStable(merged, func(i, j int) bool { return merged[i].at.Before(merged[j].at) })
 
-	// Count is in Content-Range header: "0-9/10" means 10 total
-	contentRange := resp.Header.Get("Content-Range")
-	var total int
-	fmt.Sscanf(contentRange, "%*d-%*d/%d", &total)
+	if len(merged) > limit {
+		merged = merged[len(merged)-limit:]
+	}
+	h.logger.Debug("📞 Context: merged call transcript turns", "turns", len(turns))
+	return merged
+}
 
-	return total, nil
+// getMessageCount gets the total message count for this phone number
+func (h *LLMTextHandler) getMessageCount() (int, error) {
+	return dataStore.CountMessages(h.tenant.SupabaseSchema, h.phoneNumber)
 }
 
 // fetchMessages fetches messages in specified order
-func (h *LLMTextHandler) fetchMessages(limit int, ascending bool) ([]ChatMessage, error) {
-	supabaseURL := os.Getenv("SUPABASE_URL")
-	supabaseKey := os.Getenv("SUPABASE_ANON_KEY")
-
-	order := "timestamp.asc"
-	if !ascending {
-		order = "timestamp.desc"
-	}
-
-	url := fmt.Sprintf("%s/rest/v1/ziggy_messages?phone_number=eq.%s&order=%s&limit=%d&select=message_content,direction,timestamp",
-		supabaseURL, h.phoneNumber, order, limit)
-
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, err
-	}
-
-	req.Header.Set("apikey", supabaseKey)
-	req.Header.Set("Authorization", "Bearer "+supabaseKey)
-
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(req)
+func (h *LLMTextHandler) fetchMessages(limit int, ascending bool) ([]historyMessage, error) {
+	dbMessages, err := dataStore.ListMessages(h.tenant.SupabaseSchema, h.phoneNumber, limit, ascending)
 	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("failed to fetch messages: %s - %s", resp.Status, string(body))
-	}
-
-	var dbMessages []struct {
-		MessageContent string `json:"message_content"`
-		Direction      string `json:"direction"`
-		Timestamp      string `json:"timestamp"`
-	}
-
-	body, _ := io.ReadAll(resp.Body)
-	if err := json.Unmarshal(body, &dbMessages); err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to fetch messages: %w", err)
 	}
 
 	// Convert to chat messages
-	var messages []ChatMessage
+	var messages []historyMessage
 	for _, msg := range dbMessages {
 		role := "user"
 		if msg.Direction == "outbound" {
 			role = "assistant"
 		}
-		messages = append(messages, ChatMessage{
-			Role:    role,
-			Content: msg.MessageContent,
+		at, _ := parseSupabaseTime(msg.Timestamp)
+		messages = append(messages, historyMessage{
+			ChatMessage: ChatMessage{
+				Role:    role,
+				Content: msg.MessageContent,
+			},
+			at: at,
 		})
 	}
 
@@ -347,207 +305,18 @@ func (h *LLMTextHandler) fetchMessages(limit int, ascending bool) ([]ChatMessage
 	return messages, nil
 }
 
-// GetTools returns the tool definitions for Ziggy
-// Note: Cannot use GetZiggyTools() because Azure AI Foundry with strict:true requires
-// different schema format (nullable types) than OpenAI Realtime API
-func (h *LLMTextHandler) GetTools() []map[string]interface{} {
-	return h.GetToolsOLD() // Use Azure-compatible format
+// toolCaller identifies this conversation to the tool registry
+func (h *LLMTextHandler) toolCaller() *ToolCaller {
+	return &ToolCaller{
+		Tenant:      h.tenant,
+		PhoneNumber: h.phoneNumber,
+		Channel:     toolChannelText,
+	}
 }
 
-// GetToolsOLD is the old hardcoded version - kept for reference, can be deleted
-func (h *LLMTextHandler) GetToolsOLD() []map[string]interface{} {
-	return []map[string]interface{}{
-		{
-			"type":        "function",
-			"name":        "add_task",
-			"description": "Create a new task for the caller. Use this when they ask to add, create, or remember a task or todo item.",
-			"parameters": map[string]interface{}{
-				"type": "object",
-				"properties": map[string]interface{}{
-					"title": map[string]interface{}{
-						"type":        "string",
-						"description": "Brief title of the task",
-					},
-					"description": map[string]interface{}{
-						"type":        []string{"string", "null"},
-						"description": "Detailed description of the task (optional)",
-					},
-					"priority": map[string]interface{}{
-						"type":        []string{"string", "null"},
-						"description": "Priority level: low, medium, high, or urgent",
-						"enum":        []interface{}{"low", "medium", "high", "urgent", nil},
-					},
-				},
-				"required":             []string{"title", "description", "priority"},
-				"additionalProperties": false,
-			},
-			"strict": true,
-		},
-		{
-			"type":        "function",
-			"name":        "list_tasks",
-			"description": "List all tasks for the caller. Can optionally filter by status.",
-			"parameters": map[string]interface{}{
-				"type": "object",
-				"properties": map[string]interface{}{
-					"status": map[string]interface{}{
-						"type":        []string{"string", "null"},
-						"description": "Filter by status: pending, in_progress, completed, or cancelled (optional)",
-						"enum":        []interface{}{"pending", "in_progress", "completed", "cancelled", nil},
-					},
-				},
-				"required":             []string{"status"},
-				"additionalProperties": false,
-			},
-			"strict": true,
-		},
-		{
-			"type":        "function",
-			"name":        "update_task_status",
-			"description": "Update the status of a task. Use when user wants to mark task as done, complete, in progress, etc.",
-			"parameters": map[string]interface{}{
-				"type": "object",
-				"properties": map[string]interface{}{
-					"task_id": map[string]interface{}{
-						"type":        "string",
-						"description": "The ID of the task to update",
-					},
-					"status": map[string]interface{}{
-						"type":        "string",
-						"description": "New status: pending, in_progress, completed, or cancelled",
-						"enum":        []interface{}{"pending", "in_progress", "completed", "cancelled"},
-					},
-				},
-				"required":             []string{"task_id", "status"},
-				"additionalProperties": false,
-			},
-			"strict": true,
-		},
-		{
-			"type":        "function",
-			"name":        "add_reminder",
-			"description": "Set a reminder for the caller. Supports one-time and recurring reminders. When the reminder time comes, Ziggy will call them back.",
-			"parameters": map[string]interface{}{
-				"type": "object",
-				"properties": map[string]interface{}{
-					"reminder_text": map[string]interface{}{
-						"type":        "string",
-						"description": "What to remind the user about",
-					},
-					"reminder_time": map[string]interface{}{
-						"type":        "string",
-						"description": "When to send the reminder in local timezone using format YYYY-MM-DD HH:MM (e.g., 2025-11-09 14:30 for 2:30 PM). Use 24-hour format. Ask the user for the exact date and time if not provided.",
-					},
-					"recurrence": map[string]interface{}{
-						"type":        []string{"string", "null"},
-						"description": "Recurrence pattern: 'once' (default, one-time), 'daily', 'weekly', 'monthly', 'yearly'. Only specify if user wants recurring reminder.",
-						"enum":        []interface{}{"once", "daily", "weekly", "monthly", "yearly", nil},
-					},
-				},
-				"required":             []string{"reminder_text", "reminder_time", "recurrence"},
-				"additionalProperties": false,
-			},
-			"strict": true,
-		},
-		{
-			"type":        "function",
-			"name":        "list_reminders",
-			"description": "List all reminders for the caller. Can filter by status (pending, called, completed, cancelled).",
-			"parameters": map[string]interface{}{
-				"type": "object",
-				"properties": map[string]interface{}{
-					"status": map[string]interface{}{
-						"type":        []string{"string", "null"},
-						"description": "Optional filter by status: 'pending', 'called', 'completed', 'cancelled'. If not provided, shows all reminders.",
-						"enum":        []interface{}{"pending", "called", "completed", "cancelled", nil},
-					},
-				},
-				"required":             []string{"status"},
-				"additionalProperties": false,
-			},
-			"strict": true,
-		},
-		{
-			"type":        "function",
-			"name":        "cancel_reminder",
-			"description": "Cancel a reminder. Use this when user wants to stop or delete a reminder.",
-			"parameters": map[string]interface{}{
-				"type": "object",
-				"properties": map[string]interface{}{
-					"reminder_id": map[string]interface{}{
-						"type":        "string",
-						"description": "The ID of the reminder to cancel. Get this from list_reminders.",
-					},
-				},
-				"required":             []string{"reminder_id"},
-				"additionalProperties": false,
-			},
-			"strict": true,
-		},
-		{
-			"type":        "function",
-			"name":        "add_note",
-			"description": "Create a note for the caller. Use this when they ask to note something, write something down, remember something, or save information. Examples: 'Note that...', 'Write this down', 'Remember that...'",
-			"parameters": map[string]interface{}{
-				"type": "object",
-				"properties": map[string]interface{}{
-					"note_content": map[string]interface{}{
-						"type":        "string",
-						"description": "The content of the note to save",
-					},
-				},
-				"required":             []string{"note_content"},
-				"additionalProperties": false,
-			},
-			"strict": true,
-		},
-		{
-			"type":        "function",
-			"name":        "list_notes",
-			"description": "List all notes for the caller.",
-			"parameters": map[string]interface{}{
-				"type":                 "object",
-				"properties":           map[string]interface{}{},
-				"required":             []string{},
-				"additionalProperties": false,
-			},
-			"strict": true,
-		},
-		{
-			"type":        "function",
-			"name":        "search_notes",
-			"description": "Search through the caller's notes using keywords. Use this when they want to find specific notes.",
-			"parameters": map[string]interface{}{
-				"type": "object",
-				"properties": map[string]interface{}{
-					"search_query": map[string]interface{}{
-						"type":        "string",
-						"description": "Keywords to search for in notes",
-					},
-				},
-				"required":             []string{"search_query"},
-				"additionalProperties": false,
-			},
-			"strict": true,
-		},
-		{
-			"type":        "function",
-			"name":        "delete_note",
-			"description": "Delete a note. Use this when user wants to remove or delete a note.",
-			"parameters": map[string]interface{}{
-				"type": "object",
-				"properties": map[string]interface{}{
-					"note_id": map[string]interface{}{
-						"type":        "string",
-						"description": "The ID of the note to delete. Get this from list_notes.",
-					},
-				},
-				"required":             []string{"note_id"},
-				"additionalProperties": false,
-			},
-			"strict": true,
-		},
-	}
+// GetTools returns the tool definitions for Ziggy in the strict Responses API format
+func (h *LLMTextHandler) GetTools() []map[string]interface{} {
+	return toolRegistry.ResponsesTools(h.toolCaller())
 }
 
 // GetAIResponse gets an AI response from Azure OpenAI or OpenAI
@@ -559,7 +328,7 @@ func (h *LLMTextHandler) GetAIResponse(userMessage string) (string, error) {
 	// Get conversation history
 	history, err := h.GetConversationHistory()
 	if err != nil {
-		log.Printf("⚠️ Failed to get history: %v", err)
+		h.logger.Warn("⚠️ Failed to get history", "error", err)
 		history = []ChatMessage{} // Continue with empty history
 	}
 
@@ -586,18 +355,18 @@ func (h *LLMTextHandler) GetAIResponse(userMessage string) (string, error) {
 	})
 
 	// Log conversation context for debugging
-	log.Printf("💭 Sending to LLM: %d history messages + current message", len(history))
+	h.logger.Info("💭 Sending to LLM", "history_messages", len(history))
 	if len(history) > 0 {
-		log.Printf("📝 Last 3 messages in history:")
+		h.logger.Debug("📝 Last 3 messages in history")
 		start := len(history) - 3
 		if start < 0 {
 			start = 0
 		}
 		for i := start; i < len(history); i++ {
-			log.Printf("   [%s]: %s", history[i].Role, history[i].Content)
+			h.logger.Debug("   History message", "role", history[i].Role, "content", history[i].Content)
 		}
 	}
-	log.Printf("   [user]: %s", userMessage)
+	h.logger.Debug("   Current message", "role", "user", "content", userMessage)
 
 	// Make initial request with tools
 	return h.makeRequestWithTools(messages)
@@ -619,8 +388,7 @@ func (h *LLMTextHandler) makeRequestWithTools(input []interface{}) (string, erro
 		return "", err
 	}
 
-	log.Printf("🤖 Calling LLM API: %s (model: gpt-5-mini, max_tokens: 3000)", h.endpoint)
-	// Only log full request body if there's an error (too verbose otherwise)
+	h.logger.Info("🤖 Calling LLM API", "endpoint", h.endpoint, "model", "gpt-5-mini", "max_tokens", 3000)
 
 	req, err := http.NewRequest("POST", h.endpoint, bytes.NewBuffer(jsonData))
 	if err != nil {
@@ -631,12 +399,10 @@ func (h *LLMTextHandler) makeRequestWithTools(input []interface{}) (string, erro
 	req.Header.Set("Authorization", "Bearer "+h.apiKey)
 	req.Header.Set("Content-Type", "application/json")
 
-	log.Printf("🔑 Auth header: Bearer %s...", h.apiKey[:20])
-
 	client := &http.Client{Timeout: 30 * time.Second}
 	resp, err := client.Do(req)
 	if err != nil {
-		log.Printf("❌ LLM API request failed: %v", err)
+		h.logger.Error("❌ LLM API request failed", "error", err)
 		return "I'm having trouble thinking right now. Can you try again?", err
 	}
 	defer resp.Body.Close()
@@ -644,12 +410,12 @@ func (h *LLMTextHandler) makeRequestWithTools(input []interface{}) (string, erro
 	body, _ := io.ReadAll(resp.Body)
 
 	if resp.StatusCode != http.StatusOK {
-		log.Printf("❌ LLM API error: Status=%s, Body=%s", resp.Status, string(body))
-		log.Printf("📤 Request that failed: %s", string(jsonData))
+		h.logger.Error("❌ LLM API error", "status", resp.Status, "response", string(body))
+		h.logger.Debug("📤 Request that failed", "request", string(jsonData))
 		return "I'm having trouble thinking right now. Can you try again? 🤔", fmt.Errorf("API error: %s", resp.Status)
 	}
 
-	log.Printf("✅ LLM API response received successfully")
+	h.logger.Debug("✅ LLM API response received successfully")
 
 	// Parse response to check for function calls
 	var result struct {
@@ -657,8 +423,8 @@ func (h *LLMTextHandler) makeRequestWithTools(input []interface{}) (string, erro
 	}
 
 	if err := json.Unmarshal(body, &result); err != nil {
-		log.Printf("❌ Failed to parse JSON response: %v", err)
-		log.Printf("📥 Raw response: %s", string(body))
+		h.logger.Error("❌ Failed to parse JSON response", "error", err)
+		h.logger.Debug("📥 Raw response", "body", string(body))
 		return "", err
 	}
 
@@ -699,17 +465,18 @@ func (h *LLMTextHandler) makeRequestWithTools(input []interface{}) (string, erro
 	}
 
 	if aiResponse == "" {
-		log.Printf("❌ No text found in response output")
+		h.logger.Error("❌ No text found in response output")
 		return "", fmt.Errorf("no response from LLM")
 	}
 
-	log.Printf("🤖 AI response: %s", aiResponse)
+	h.logger.Info("🤖 Got AI response")
+	h.logger.Debug("🤖 AI response", "text", aiResponse)
 	return aiResponse, nil
 }
 
 // handleFunctionCalls processes function calls and makes a second request
 func (h *LLMTextHandler) handleFunctionCalls(input []interface{}, output []map[string]interface{}) (string, error) {
-	log.Printf("🔧 Processing function calls...")
+	h.logger.Info("🔧 Processing function calls...")
 
 	// Add all output items to input (including reasoning)
 	for _, item := range output {
@@ -723,10 +490,8 @@ func (h *LLMTextHandler) handleFunctionCalls(input []interface{}, output []map[s
 			name, _ := item["name"].(string)
 			arguments, _ := item["arguments"].(string)
 
-			log.Printf("📞 Function call: %s(%s)", name, arguments)
-
-			// Execute the function
-			result := h.executeFunction(name, arguments)
+			// Execute the function; the registry logs the call, its arguments and its result
+			result := toolRegistry.Execute(withLogger(context.Background(), h.logger), h.toolCaller(), name, arguments)
 
 			// Add function call output to input
 			input = append(input, map[string]interface{}{
@@ -734,235 +499,10 @@ func (h *LLMTextHandler) handleFunctionCalls(input []interface{}, output []map[s
 				"call_id": callID,
 				"output":  result,
 			})
-
-			log.Printf("✅ Function %s executed: %s", name, result)
 		}
 	}
 
 	// Make second request with function results
-	log.Printf("🔄 Making second request with function results...")
+	h.logger.Debug("🔄 Making second request with function results...")
 	return h.makeRequestWithTools(input)
 }
-
-// executeFunction executes a function and returns the result as JSON string
-func (h *LLMTextHandler) executeFunction(name, arguments string) string {
-	var args map[string]interface{}
-	if err := json.Unmarshal([]byte(arguments), &args); err != nil {
-		return `{"status": "error", "message": "Invalid arguments"}`
-	}
-
-	switch name {
-	case "add_task":
-		title, _ := args["title"].(string)
-		description, _ := args["description"].(string)
-		priority, _ := args["priority"].(string)
-		if priority == "" {
-			priority = "medium"
-		}
-
-		task, err := AddTask(title, description, priority, h.phoneNumber)
-		if err != nil {
-			return fmt.Sprintf(`{"status": "error", "message": "%s"}`, err.Error())
-		}
-
-		result, _ := json.Marshal(map[string]interface{}{
-			"status":  "success",
-			"message": fmt.Sprintf("Task '%s' created successfully", title),
-			"task_id": task.ID,
-			"title":   task.Title,
-		})
-		return string(result)
-
-	case "list_tasks":
-		status, _ := args["status"].(string)
-
-		tasks, err := ListTasks(h.phoneNumber, status)
-		if err != nil {
-			return fmt.Sprintf(`{"status": "error", "message": "%s"}`, err.Error())
-		}
-
-		taskList := make([]map[string]interface{}, len(tasks))
-		for i, task := range tasks {
-			taskList[i] = map[string]interface{}{
-				"id":          task.ID,
-				"title":       task.Title,
-				"description": task.Description,
-				"status":      task.Status,
-				"priority":    task.Priority,
-			}
-		}
-
-		result, _ := json.Marshal(map[string]interface{}{
-			"status": "success",
-			"count":  len(tasks),
-			"tasks":  taskList,
-		})
-		return string(result)
-
-	case "update_task_status":
-		taskID, _ := args["task_id"].(string)
-		newStatus, _ := args["status"].(string)
-
-		err := UpdateTaskStatus(taskID, newStatus)
-		if err != nil {
-			return fmt.Sprintf(`{"status": "error", "message": "%s"}`, err.Error())
-		}
-
-		result, _ := json.Marshal(map[string]interface{}{
-			"status":  "success",
-			"message": fmt.Sprintf("Task updated to %s", newStatus),
-			"task_id": taskID,
-		})
-		return string(result)
-
-	case "add_reminder":
-		reminderText, _ := args["reminder_text"].(string)
-		reminderTime, _ := args["reminder_time"].(string)
-		recurrence, _ := args["recurrence"].(string)
-		if recurrence == "" {
-			recurrence = "once"
-		}
-
-		reminder, err := AddReminder(reminderText, reminderTime, h.phoneNumber, recurrence)
-		if err != nil {
-			return fmt.Sprintf(`{"status": "error", "message": "%s"}`, err.Error())
-		}
-
-		var message string
-		if recurrence == "once" {
-			message = fmt.Sprintf("Reminder set for %s. I'll call you back at that time.", reminderTime)
-		} else {
-			message = fmt.Sprintf("%s reminder set for %s. I'll call you back %s.", recurrence, reminderTime, recurrence)
-		}
-
-		result, _ := json.Marshal(map[string]interface{}{
-			"status":        "success",
-			"message":       message,
-			"reminder_id":   reminder.ID,
-			"reminder_text": reminder.ReminderText,
-			"reminder_time": reminder.ReminderTime,
-			"recurrence":    reminder.RecurrencePattern,
-		})
-		return string(result)
-
-	case "list_reminders":
-		status, _ := args["status"].(string)
-
-		reminders, err := ListReminders(h.phoneNumber, status)
-		if err != nil {
-			return fmt.Sprintf(`{"status": "error", "message": "%s"}`, err.Error())
-		}
-
-		var reminderList []map[string]interface{}
-		for _, r := range reminders {
-			reminderList = append(reminderList, map[string]interface{}{
-				"id":         r.ID,
-				"text":       r.ReminderText,
-				"time":       r.ReminderTime,
-				"recurrence": r.RecurrencePattern,
-				"status":     r.Status,
-			})
-		}
-
-		result, _ := json.Marshal(map[string]interface{}{
-			"status":    "success",
-			"count":     len(reminders),
-			"reminders": reminderList,
-		})
-		return string(result)
-
-	case "cancel_reminder":
-		reminderID, _ := args["reminder_id"].(string)
-
-		err := CancelReminder(reminderID)
-		if err != nil {
-			return fmt.Sprintf(`{"status": "error", "message": "%s"}`, err.Error())
-		}
-
-		result, _ := json.Marshal(map[string]interface{}{
-			"status":      "success",
-			"message":     "Reminder cancelled successfully",
-			"reminder_id": reminderID,
-		})
-		return string(result)
-
-	case "add_note":
-		noteContent, _ := args["note_content"].(string)
-
-		note, err := AddNote(noteContent, h.phoneNumber)
-		if err != nil {
-			return fmt.Sprintf(`{"status": "error", "message": "%s"}`, err.Error())
-		}
-
-		result, _ := json.Marshal(map[string]interface{}{
-			"status":  "success",
-			"message": "Note saved successfully",
-			"note_id": note.ID,
-			"content": note.NoteContent,
-		})
-		return string(result)
-
-	case "list_notes":
-		notes, err := ListNotes(h.phoneNumber, 0)
-		if err != nil {
-			return fmt.Sprintf(`{"status": "error", "message": "%s"}`, err.Error())
-		}
-
-		noteList := make([]map[string]interface{}, len(notes))
-		for i, note := range notes {
-			noteList[i] = map[string]interface{}{
-				"id":      note.ID,
-				"content": note.NoteContent,
-			}
-		}
-
-		result, _ := json.Marshal(map[string]interface{}{
-			"status": "success",
-			"count":  len(notes),
-			"notes":  noteList,
-		})
-		return string(result)
-
-	case "search_notes":
-		searchQuery, _ := args["search_query"].(string)
-
-		notes, err := SearchNotes(h.phoneNumber, searchQuery)
-		if err != nil {
-			return fmt.Sprintf(`{"status": "error", "message": "%s"}`, err.Error())
-		}
-
-		noteList := make([]map[string]interface{}, len(notes))
-		for i, note := range notes {
-			noteList[i] = map[string]interface{}{
-				"id":      note.ID,
-				"content": note.NoteContent,
-			}
-		}
-
-		result, _ := json.Marshal(map[string]interface{}{
-			"status": "success",
-			"count":  len(notes),
-			"query":  searchQuery,
-			"notes":  noteList,
-		})
-		return string(result)
-
-	case "delete_note":
-		noteID, _ := args["note_id"].(string)
-
-		err := DeleteNote(noteID)
-		if err != nil {
-			return fmt.Sprintf(`{"status": "error", "message": "%s"}`, err.Error())
-		}
-
-		result, _ := json.Marshal(map[string]interface{}{
-			"status":  "success",
-			"message": "Note deleted successfully",
-			"note_id": noteID,
-		})
-		return string(result)
-
-	default:
-		return fmt.Sprintf(`{"status": "error", "message": "Unknown function: %s"}`, name)
-	}
-}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"log/slog"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/nyaruka/phonenumbers"
+)
+
+// setupLogging installs the process-wide slog logger. LOG_LEVEL picks the
+// minimum level (debug, info, warn, error; default info) and LOG_FORMAT the
+// output (text or json). Everything still written with the standard log
+// package goes through the same handler at info level.
+//
+// Phone numbers, message content, tokens and ICE credentials are masked in
+// every record unless LOG_LEVEL=debug.
+func setupLogging() {
+	var level slog.Level
+	levelName := strings.ToLower(os.Getenv("LOG_LEVEL"))
+	if levelName == "" {
+		levelName = "info"
+	}
+	if err := level.UnmarshalText([]byte(levelName)); err != nil {
+		log.Printf("⚠️ Invalid LOG_LEVEL %q, using info", levelName)
+		level = slog.LevelInfo
+	}
+
+	opts := &slog.HandlerOptions{Level: level}
+	var handler slog.Handler
+	switch format := strings.ToLower(os.Getenv("LOG_FORMAT")); format {
+	case "json":
+		handler = slog.NewJSONHandler(os.Stderr, opts)
+	default:
+		if format != "" && format != "text" {
+			log.Printf("⚠️ Invalid LOG_FORMAT %q, using text", format)
+		}
+		handler = slog.NewTextHandler(os.Stderr, opts)
+	}
+
+	if level > slog.LevelDebug {
+		handler = &redactHandler{next: handler}
+	}
+	slog.SetDefault(slog.New(handler))
+}
+
+// loggerKey is the context key for a request- or job-scoped logger
+type loggerKey struct{}
+
+// withLogger returns ctx carrying logger
+func withLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// loggerFrom returns the logger carried by ctx, or the default logger
+func loggerFrom(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+// callLogger returns a logger that tags every record with the call's ID
+func callLogger(callID string) *slog.Logger {
+	return slog.With("call_id", callID)
+}
+
+// newRequestID returns a short random ID to correlate one HTTP request's logs
+func newRequestID() string {
+	buf := make([]byte, 8)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+// Attribute keys whose values are always masked, whatever they contain
+var redactedKeys = map[string]bool{
+	"text":          true,
+	"body":          true,
+	"content":       true,
+	"caption":       true,
+	"transcript":    true,
+	"arguments":     true,
+	"result":        true,
+	"reminder":      true,
+	"instructions":  true,
+	"session":       true,
+	"event":         true,
+	"delta":         true,
+	"request":       true,
+	"sdp":           true,
+	"token":         true,
+	"access_token":  true,
+	"api_key":       true,
+	"authorization": true,
+	"secret":        true,
+}
+
+// Patterns masked inside free text. Phone numbers are handled separately.
+var redactPatterns = []struct {
+	re   *regexp.Regexp
+	repl string
+}{
+	// JSON string fields carrying message content or credentials
+	{regexp.MustCompile(`"(body|text|caption|transcript|content|delta|arguments|reminder_text|access_token|token|client_secret|api_key|value|verify_token)"(\s*:\s*)"(?:[^"\\]|\\.)*"`), `"$1"$2"[redacted]"`},
+	// Bearer tokens and API keys
+	{regexp.MustCompile(`(?i)(bearer\s+)[A-Za-z0-9._~+/=-]+`), `${1}[redacted]`},
+	{regexp.MustCompile(`\b(sk|ek)[-_][A-Za-z0-9_-]{8,}`), `[redacted]`},
+	{regexp.MustCompile(`(?i)((?:api-key|hub\.verify_token|access_token)[=:]\s*)[^\s&",]+`), `${1}[redacted]`},
+	// ICE credentials in SDPs, raw or JSON-escaped
+	{regexp.MustCompile(`(a=ice-(?:ufrag|pwd):)[A-Za-z0-9+/]+`), `${1}[redacted]`},
+}
+
+// phoneCandidate matches digit runs long enough to be an international number
+var phoneCandidate = regexp.MustCompile(`\+?\b\d{8,15}\b`)
+
+// redact masks credentials, message content and phone numbers in s. Phone
+// numbers keep their last four digits so calls can still be told apart.
+func redact(s string) string {
+	for _, p := range redactPatterns {
+		s = p.re.ReplaceAllString(s, p.repl)
+	}
+	return phoneCandidate.ReplaceAllStringFunc(s, func(candidate string) string {
+		digits := strings.TrimPrefix(candidate, "+")
+		num, err := phonenumbers.Parse("+"+digits, "")
+		if err != nil || !phonenumbers.IsPossibleNumber(num) {
+			return candidate // IDs, timestamps and SSRCs
+		}
+		return "***" + digits[len(digits)-4:]
+	})
+}
+
+// redactHandler masks sensitive data in the message and attributes of every
+// record before passing it on
+type redactHandler struct {
+	next slog.Handler
+}
+
+// Enabled implements slog.Handler
+func (h *redactHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+// Handle implements slog.Handler
+func (h *redactHandler) Handle(ctx context.Context, record slog.Record) error {
+	redacted := slog.NewRecord(record.Time, record.Level, redact(record.Message), record.PC)
+	record.Attrs(func(attr slog.Attr) bool {
+		redacted.AddAttrs(redactAttr(attr))
+		return true
+	})
+	return h.next.Handle(ctx, redacted)
+}
+
+// WithAttrs implements slog.Handler
+func (h *redactHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	masked := make([]slog.Attr, len(attrs))
+	for i, attr := range attrs {
+		masked[i] = redactAttr(attr)
+	}
+	return &redactHandler{next: h.next.WithAttrs(masked)}
+}
+
+// WithGroup implements slog.Handler
+func (h *redactHandler) WithGroup(name string) slog.Handler {
+	return &redactHandler{next: h.next.WithGroup(name)}
+}
+
+// redactAttr masks one attribute, recursing into groups
+func redactAttr(attr slog.Attr) slog.Attr {
+	value := attr.Value.Resolve()
+	if redactedKeys[strings.ToLower(attr.Key)] {
+		return slog.String(attr.Key, "[redacted]")
+	}
+	switch value.Kind() {
+	case slog.KindGroup:
+		group := value.Group()
+		masked := make([]any, len(group))
+		for i, a := range group {
+			masked[i] = redactAttr(a)
+		}
+		return slog.Group(attr.Key, masked...)
+	case slog.KindString:
+		return slog.String(attr.Key, redact(value.String()))
+	case slog.KindAny:
+		return slog.String(attr.Key, redact(fmt.Sprint(value.Any())))
+	default:
+		return slog.Attr{Key: attr.Key, Value: value}
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// logBuffer collects log output that background goroutines may still be writing
+type logBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *logBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *logBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// captureLogs points the default logger at a buffer, with redaction as
+// setupLogging installs it for level
+func captureLogs(t *testing.T, level slog.Level) *logBuffer {
+	t.Helper()
+
+	buf := &logBuffer{}
+	var handler slog.Handler = slog.NewTextHandler(buf, &slog.HandlerOptions{Level: level})
+	if level > slog.LevelDebug {
+		handler = &redactHandler{next: handler}
+	}
+	previous := slog.Default()
+	slog.SetDefault(slog.New(handler))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+	return buf
+}
+
+// TestRealtimeEventsKeepPayloadsOutOfInfoLogs feeds the client the session
+// and response events that carry the instructions, memory and assistant text,
+// and checks none of it reaches an info-level log while the records still
+// carry the call ID
+func TestRealtimeEventsKeepPayloadsOutOfInfoLogs(t *testing.T) {
+	logs := captureLogs(t, slog.LevelInfo)
+
+	c := &OpenAIRealtimeClient{callID: "wacid.logging-test"}
+	for _, event := range []string{
+		`{"type":"session.created","session":{"instructions":"MEMORY: likes jazz"}}`,
+		`{"type":"session.updated","session":{"instructions":"MEMORY: likes jazz","voice":"shimmer"}}`,
+		`{"type":"response.output_text.delta","delta":"Your PIN is 4321"}`,
+		`{"type":"response.done","response":{"output":[{"content":[{"text":"Your PIN is 4321"}]}]}}`,
+		`{"type":"error","error":{"code":"bad_request","message":"nope"},"event_id":"MEMORY: likes jazz"}`,
+	} {
+		c.handleEvent([]byte(event))
+	}
+
+	out := logs.String()
+	for _, secret := range []string{"likes jazz", "4321"} {
+		if strings.Contains(out, secret) {
+			t.Errorf("info logs contain %q:\n%s", secret, out)
+		}
+	}
+	if !strings.Contains(out, "call_id=wacid.logging-test") {
+		t.Errorf("records are not tagged with the call ID:\n%s", out)
+	}
+	if !strings.Contains(out, "code=bad_request") {
+		t.Errorf("error event code was not logged:\n%s", out)
+	}
+}
+
+// TestRedactHandlerMasksPayloadKeys checks payload attributes are masked
+// whatever they hold, should one be logged above debug
+func TestRedactHandlerMasksPayloadKeys(t *testing.T) {
+	logs := captureLogs(t, slog.LevelInfo)
+
+	slog.Info("payloads",
+		"instructions", "MEMORY: likes jazz",
+		"session", map[string]string{"instructions": "likes jazz"},
+		"sdp", "a=ice-ufrag:abcd\r\na=ice-pwd:efgh",
+		"call_event", "connect",
+	)
+
+	out := logs.String()
+	for _, secret := range []string{"likes jazz", "abcd", "efgh"} {
+		if strings.Contains(out, secret) {
+			t.Errorf("logs contain %q:\n%s", secret, out)
+		}
+	}
+	if !strings.Contains(out, "call_event=connect") {
+		t.Errorf("non-sensitive attribute was masked:\n%s", out)
+	}
+}
+
+// TestTranscriptTurnsKeepTextOutOfInfoLogs checks a saved turn logs only its
+// speaker and length at info level
+func TestTranscriptTurnsKeepTextOutOfInfoLogs(t *testing.T) {
+	logs := captureLogs(t, slog.LevelInfo)
+
+	newCallTranscript("", "wacid.transcript-test", "15559876543").userTurn("item_1", "My PIN is 4321")
+
+	out := logs.String()
+	if strings.Contains(out, "content=") || strings.Contains(out, "4321") {
+		t.Errorf("info logs carry the turn text:\n%s", out)
+	}
+	if !strings.Contains(out, "speaker=user") || !strings.Contains(out, "length=14") {
+		t.Errorf("turn speaker and length were not logged:\n%s", out)
+	}
+}
+
+func TestUnparseableToolArgumentsStayOutOfErrorLogs(t *testing.T) {
+	logs := captureLogs(t, slog.LevelInfo)
+
+	registry := NewToolRegistry()
+	registry.Register(&Tool{Name: "get_tasks", Handler: func(ctx context.Context, caller *ToolCaller, args ToolArgs) (map[string]interface{}, error) {
+		return nil, nil
+	}})
+	registry.Execute(context.Background(), &ToolCaller{Channel: toolChannelVoice}, "get_tasks", `{"pin": 4321`)
+
+	out := logs.String()
+	if strings.Contains(out, "arguments=") || strings.Contains(out, "4321") {
+		t.Errorf("non-debug logs carry the tool arguments:\n%s", out)
+	}
+	if !strings.Contains(out, "Failed to parse tool arguments") {
+		t.Errorf("parse failure was not logged:\n%s", out)
+	}
+}
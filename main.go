@@ -2,14 +2,18 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/mux"
@@ -28,10 +32,17 @@ type WhatsAppBridge struct {
 	config              webrtc.Configuration
 	activeCalls         map[string]*Call
 	mu                  sync.Mutex
-	verifyToken         string
-	accessToken         string
-	phoneNumberID       string
-	allowedPhoneNumber  string // Only process webhooks from this display phone number
+	tenants             *TenantRegistry // WhatsApp numbers we serve, keyed by phone_number_id
+	appSecret           string // Meta app secret used to verify X-Hub-Signature-256
+	skipSignature       bool   // WEBHOOK_SKIP_SIGNATURE: accept unsigned webhooks (local development only)
+	rejectedWebhooks    atomic.Int64
+	dedupe              DedupeStore // Shared across webhooks so Meta's retries are ignored
+	duplicateEvents     atomic.Int64
+	queue               *WebhookQueue // Durable queue every webhook passes through before processing
+	archive             *WebhookArchive // Raw capture of every webhook request; nil unless WEBHOOK_ARCHIVE_DIR is set
+	adminAPIKey         string        // Required by /admin endpoints; they are disabled when empty
+	callLimits          CallLimits    // Watchdog thresholds applied to every call
+	recentCalls         []CallOutcome // How the most recent calls ended, newest last
 }
 
 // Call represents an active WhatsApp call session
@@ -40,9 +51,19 @@ type Call struct {
 	PeerConnection *webrtc.PeerConnection
 	AudioTrack     *webrtc.TrackLocalStaticRTP
 	StartTime      time.Time
-	OpenAIClient   *OpenAIRealtimeClient
+	Agent          VoiceAgent // Voice backend talking to the user, once connected
+	Tenant         *Tenant // Business number this call belongs to
 	ReminderID     string // If this is a reminder call
 	ReminderText   string // What to remind the user about
+	Caller         string // WhatsApp number that placed an inbound call
+	Outbound       *OutboundCall // Answer-flow state for business-initiated calls (nil for inbound)
+	Lifecycle      *CallLifecycle // State, watchdog and one-time teardown shared by every call
+	RecordingConsent bool          // Whether this call may be recorded; the tenant must also enable record_calls
+	Recorder         *CallRecorder // Writes the call's audio to disk once media flows (nil when not recording)
+	recordingStarted bool          // Set once a recorder was attempted so failures aren't retried per packet
+	CaptureRTP       bool          // Whether this call's packets are captured to RTP_CAPTURE_DIR
+	Capture          *CallCapture  // rtpdump capture of both legs once packets flow (nil when not capturing)
+	captureStarted   bool          // Set once a capture was attempted so failures aren't retried per packet
 }
 
 // NewWhatsAppBridge creates a new bridge instance
@@ -91,7 +112,7 @@ func NewWhatsAppBridge() *WhatsAppBridge {
 	for _, uri := range extensions {
 		ext := webrtc.RTPHeaderExtensionCapability{URI: uri}
 		if err := m.RegisterHeaderExtension(ext, webrtc.RTPCodecTypeAudio); err != nil {
-			log.Printf("Warning: Failed to register extension %s: %v", uri, err)
+			slog.Warn("⚠️ Failed to register header extension", "uri", uri, "error", err)
 		}
 	}
 	
@@ -121,39 +142,79 @@ func NewWhatsAppBridge() *WhatsAppBridge {
 		},
 	}
 	
-	verifyToken := os.Getenv("VERIFY_TOKEN")
-	if verifyToken == "" {
-		verifyToken = DEFAULT_VERIFY_TOKEN
+	// Load the business numbers we serve (falls back to env vars for a single number)
+	tenantsPath := os.Getenv("TENANTS_CONFIG")
+	if tenantsPath == "" {
+		tenantsPath = "tenants.json"
 	}
-	
-	accessToken := os.Getenv("WHATSAPP_TOKEN")
-	if accessToken == "" {
-		log.Println("⚠️  WHATSAPP_TOKEN not set - API calls will fail")
+	tenants, err := LoadTenantRegistry(tenantsPath)
+	if err != nil {
+		log.Fatal("Failed to load tenants:", err)
 	}
-	
-	phoneNumberID := os.Getenv("PHONE_NUMBER_ID")
-	if phoneNumberID == "" {
-		log.Println("⚠️  PHONE_NUMBER_ID not set - API calls will fail")
+
+	// Where tasks, reminders, notes, messages and call permissions are kept
+	store, err := NewStoreFromEnv()
+	if err != nil {
+		log.Fatal("Failed to open store:", err)
 	}
+	dataStore = store
 
-	// Get allowed phone number for security filtering (only process webhooks from this number)
-	allowedPhoneNumber := os.Getenv("ALLOWED_DISPLAY_PHONE_NUMBER")
-	if allowedPhoneNumber == "" {
-		allowedPhoneNumber = "917306356514" // Default to your phone number
-		log.Printf("ℹ️  ALLOWED_DISPLAY_PHONE_NUMBER not set, defaulting to: %s", allowedPhoneNumber)
-	} else {
-		log.Printf("🔒 Only processing webhooks from display phone number: %s", allowedPhoneNumber)
+	// Scripted stand-in for the Realtime API so calls can be tested offline
+	fake, err := NewFakeRealtimeServerFromEnv()
+	if err != nil {
+		log.Fatal("Failed to start fake Realtime endpoint:", err)
 	}
+	fakeRealtime = fake
 
-	return &WhatsAppBridge{
+	// External tools (CRM, calendar, ...) offered to tenants that allow them
+	toolPluginsPath := os.Getenv("TOOL_PLUGINS_CONFIG")
+	if toolPluginsPath == "" {
+		toolPluginsPath = "tool_plugins.json"
+	}
+	if err := LoadToolPlugins(toolRegistry, toolPluginsPath); err != nil {
+		log.Fatal("Failed to load tool plugins:", err)
+	}
+
+	// App secret for webhook signature verification (Meta App Dashboard → Settings → Basic)
+	// Unsigned webhooks are only accepted when WEBHOOK_SKIP_SIGNATURE=true is set explicitly
+	appSecret := os.Getenv("WHATSAPP_APP_SECRET")
+	skipSignature := os.Getenv("WEBHOOK_SKIP_SIGNATURE") == "true"
+	if skipSignature {
+		slog.Warn("⚠️ WEBHOOK_SKIP_SIGNATURE=true - webhook signatures will NOT be verified, use for local development only")
+	} else if appSecret == "" {
+		log.Fatal("WHATSAPP_APP_SECRET is required to verify webhook signatures (set WEBHOOK_SKIP_SIGNATURE=true to accept unsigned webhooks in local development)")
+	}
+
+	adminAPIKey := os.Getenv("ADMIN_API_KEY")
+	if adminAPIKey == "" {
+		slog.Warn("⚠️ ADMIN_API_KEY not set - admin endpoints are disabled")
+	}
+
+	bridge := &WhatsAppBridge{
 		api:                api,
 		config:             config,
 		activeCalls:        make(map[string]*Call),
-		verifyToken:        verifyToken,
-		accessToken:        accessToken,
-		phoneNumberID:      phoneNumberID,
-		allowedPhoneNumber: allowedPhoneNumber,
+		tenants:            tenants,
+		appSecret:          appSecret,
+		skipSignature:      skipSignature,
+		dedupe:             NewDedupeStoreFromEnv(),
+		adminAPIKey:        adminAPIKey,
+		callLimits:         callLimitsFromEnv(),
+	}
+
+	queue, err := NewWebhookQueueFromEnv(bridge.processWebhookJob, webhookOrderKeys)
+	if err != nil {
+		log.Fatal("Failed to open webhook queue:", err)
+	}
+	bridge.queue = queue
+
+	archive, err := NewWebhookArchiveFromEnv()
+	if err != nil {
+		log.Fatal("Failed to open webhook archive:", err)
 	}
+	bridge.archive = archive
+
+	return bridge
 }
 
 // Start begins the HTTP server
@@ -181,6 +242,28 @@ func (b *WhatsAppBridge) Start() {
 	// Reminders cron endpoint - called by Supabase cron job
 	router.HandleFunc("/check-reminders", b.handleCheckReminders).Methods("POST", "GET")
 
+	// Admin endpoints - require ADMIN_API_KEY
+	router.HandleFunc("/admin/webhooks/dead", b.requireAdmin(b.handleListDeadWebhooks)).Methods("GET")
+	router.HandleFunc("/admin/webhooks/dead/{id}/replay", b.requireAdmin(b.handleReplayDeadWebhook)).Methods("POST")
+
+	// Call control (protected by ADMIN_API_KEY)
+	router.HandleFunc("/calls", b.requireAdmin(b.handleListCalls)).Methods("GET")
+	router.HandleFunc("/calls/{id}", b.requireAdmin(b.handleGetCall)).Methods("GET")
+	router.HandleFunc("/calls/{id}", b.requireAdmin(b.handleDeleteCall)).Methods("DELETE")
+	router.HandleFunc("/calls/{id}/hangup", b.requireAdmin(b.handleHangupCall)).Methods("POST")
+	router.HandleFunc("/calls/{id}/capture", b.requireAdmin(b.handleStartCapture)).Methods("POST")
+
+	// Call recordings (protected by ADMIN_API_KEY)
+	router.HandleFunc("/recordings/{id}", b.requireAdmin(b.handleGetRecording)).Methods("GET")
+	router.HandleFunc("/recordings/{id}/{file}", b.requireAdmin(b.handleDownloadRecording)).Methods("GET")
+
+	// RTP packet captures (protected by ADMIN_API_KEY)
+	router.HandleFunc("/captures/{id}", b.requireAdmin(b.handleGetCapture)).Methods("GET")
+	router.HandleFunc("/captures/{id}/{file}", b.requireAdmin(b.handleDownloadCapture)).Methods("GET")
+
+	// Start processing queued webhooks (including any left over from the last run)
+	b.queue.Start()
+
 	// Get port from environment or default
 	port := os.Getenv("PORT")
 	if port == "" {
@@ -188,26 +271,36 @@ func (b *WhatsAppBridge) Start() {
 	}
 	
 	// Start server
-	log.Printf("🚀 Pion WhatsApp Bridge starting on port %s", port)
-	log.Printf("📡 Webhook endpoint: /whatsapp-call")
-	log.Printf("🧪 Test endpoint: /test-call")
-	log.Printf("📊 Status endpoint: /status")
-	log.Printf("🔐 Verify token configured: %v", b.verifyToken != "")
-	log.Printf("🔑 Access token configured: %v", b.accessToken != "")
-	log.Printf("📱 Phone number ID: %s", b.phoneNumberID)
-	log.Printf("🔊 Echo mode: %v", os.Getenv("ENABLE_ECHO") == "true")
+	slog.Info("🚀 Pion WhatsApp Bridge starting", "port", port)
+	slog.Info("📡 Webhook endpoint: /whatsapp-call")
+	slog.Info("🧪 Test endpoint: /test-call")
+	slog.Info("📊 Status endpoint: /status")
+	for _, tenant := range b.tenants.All() {
+		slog.Info("🏢 Tenant", "tenant", tenant.Name, "phone_number_id", tenant.PhoneNumberID,
+			"access_token_configured", tenant.AccessToken != "", "verify_token_configured", tenant.VerifyToken != "")
+	}
+	slog.Info("🔏 Webhook signature verification", "enabled", !b.skipSignature)
+	slog.Info("🔊 Echo mode", "enabled", os.Getenv("ENABLE_ECHO") == "true")
 	
 	if err := http.ListenAndServe(":"+port, router); err != nil {
 		log.Fatal(err)
 	}
 }
 
-// loggingMiddleware logs all incoming requests
+// loggingMiddleware logs all incoming requests and gives each a request ID
+// (X-Request-ID when the caller sent one) that tags the handler's logs
 func (b *WhatsAppBridge) loggingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		log.Printf("🌐 %s %s from %s", r.Method, r.URL.Path, r.RemoteAddr)
-		log.Printf("📋 Headers: %v", r.Header)
-		next.ServeHTTP(w, r)
+		requestID := r.Header.Get("X-Request-ID")
+		if requestID == "" {
+			requestID = newRequestID()
+		}
+		w.Header().Set("X-Request-ID", requestID)
+
+		logger := slog.With("request_id", requestID)
+		logger.Info("🌐 HTTP request", "method", r.Method, "path", r.URL.Path, "remote_addr", r.RemoteAddr)
+		logger.Debug("📋 Headers", "headers", r.Header)
+		next.ServeHTTP(w, r.WithContext(withLogger(r.Context(), logger)))
 	})
 }
 
@@ -217,339 +310,397 @@ func (b *WhatsAppBridge) handleWebhookVerification(w http.ResponseWriter, r *htt
 	token := r.URL.Query().Get("hub.verify_token")
 	challenge := r.URL.Query().Get("hub.challenge")
 
-	if mode == "subscribe" && token == b.verifyToken {
-		log.Println("✅ WhatsApp webhook verified")
+	if mode == "subscribe" && b.tenants.MatchesVerifyToken(token) {
+		loggerFrom(r.Context()).Info("✅ WhatsApp webhook verified")
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte(challenge))
 		return
 	}
 
-	log.Println("❌ WhatsApp webhook verification failed")
+	loggerFrom(r.Context()).Warn("❌ WhatsApp webhook verification failed", "mode", mode)
 	w.WriteHeader(http.StatusForbidden)
 	json.NewEncoder(w).Encode(map[string]string{"error": "Verification failed"})
 }
 
 // handleWebhookEvent handles incoming WhatsApp webhook events
 func (b *WhatsAppBridge) handleWebhookEvent(w http.ResponseWriter, r *http.Request) {
-	log.Println("📨 POST /whatsapp-call webhook received")
+	logger := loggerFrom(r.Context())
+	logger.Info("📨 POST /whatsapp-call webhook received")
+	receivedAt := time.Now()
 	
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
-		log.Printf("❌ Failed to read body: %v", err)
+		logger.Error("❌ Failed to read body", "error", err)
 		http.Error(w, "Failed to read body", http.StatusBadRequest)
 		return
 	}
+
+	// Capture before verifying so rejected deliveries can be replayed too
+	if b.archive != nil {
+		b.archive.Capture(r, body, receivedAt)
+	}
 	
-	log.Printf("📦 Raw webhook body: %s", string(body))
-	
-	// WhatsApp Calling API doesn't require signature verification
-	// Just log if signature header is present
-	signature := r.Header.Get("X-Hub-Signature-256")
-	if signature != "" {
-		log.Printf("🔏 Signature header present but not required for WhatsApp Calling API")
+	// Verify the payload was signed by Meta before trusting any of it. Without
+	// an app secret verifyWebhookSignature rejects everything, so the bridge
+	// fails closed unless verification was explicitly switched off.
+	if !b.skipSignature {
+		signature := r.Header.Get("X-Hub-Signature-256")
+		if !verifyWebhookSignature(b.appSecret, body, signature) {
+			rejected := b.rejectedWebhooks.Add(1)
+			if signature == "" {
+				logger.Warn("🚫 Rejected unsigned webhook", "remote_addr", r.RemoteAddr, "total_rejected", rejected)
+			} else {
+				logger.Warn("🚫 Rejected webhook with invalid signature", "remote_addr", r.RemoteAddr, "total_rejected", rejected)
+			}
+			http.Error(w, "Invalid signature", http.StatusUnauthorized)
+			return
+		}
+		logger.Debug("🔏 Webhook signature verified")
 	}
+
+	logger.Debug("📦 Raw webhook body", "body", string(body))
 	
 	// Parse webhook data
-	var webhook map[string]interface{}
+	var webhook WebhookData
 	if err := json.Unmarshal(body, &webhook); err != nil {
-		log.Printf("❌ Failed to parse JSON: %v", err)
+		logger.Warn("❌ Failed to parse JSON", "error", err)
 		http.Error(w, "Invalid JSON", http.StatusBadRequest)
 		return
 	}
 	
-	// Log parsed webhook structure
-	prettyJSON, _ := json.MarshalIndent(webhook, "", "  ")
-	log.Printf("📱 WhatsApp webhook parsed:\n%s", string(prettyJSON))
-	
-	// Process the webhook asynchronously to return 200 OK immediately
-	go b.processWebhook(webhook)
+	// Persist the webhook before acknowledging it - if this fails Meta will retry
+	job, err := b.queue.Enqueue(body)
+	if err != nil {
+		logger.Error("❌ Failed to queue webhook", "error", err)
+		http.Error(w, "Failed to queue webhook", http.StatusInternalServerError)
+		return
+	}
+	logger.Info("📥 Queued webhook", "webhook_job", job.ID, "entries", len(webhook.Entry))
 	
 	// WhatsApp expects a 200 OK response immediately
 	w.WriteHeader(http.StatusOK)
-	log.Printf("✉️ Sent 200 OK response to WhatsApp")
+	logger.Debug("✉️ Sent 200 OK response to WhatsApp")
 }
 
 
-// processWebhook processes incoming webhook data
-func (b *WhatsAppBridge) processWebhook(webhook map[string]interface{}) {
-	log.Println("🔍 Processing webhook data...")
-
-	// Log the complete raw webhook for debugging
-	webhookJSON, _ := json.MarshalIndent(webhook, "", "  ")
-	log.Printf("📦 COMPLETE WEBHOOK BODY:\n%s\n", string(webhookJSON))
+// processWebhookJob processes one queued webhook body
+func (b *WhatsAppBridge) processWebhookJob(ctx context.Context, body []byte) error {
+	var webhook WebhookData
+	if err := json.Unmarshal(body, &webhook); err != nil {
+		return fmt.Errorf("failed to parse webhook: %w", err)
+	}
+	return b.processWebhook(ctx, &webhook)
+}
 
-	// Parse WhatsApp webhook structure
-	entry, ok := webhook["entry"].([]interface{})
-	if !ok || len(entry) == 0 {
-		log.Println("⚠️ No entry found in webhook")
-		return
+// webhookOrderKeys keys a queued webhook by the calls and phone numbers it
+// carries events for, so the queue handles a call's events (connect, status,
+// terminate) and a user's messages in the order Meta sent them
+func webhookOrderKeys(body []byte) []string {
+	var webhook WebhookData
+	if err := json.Unmarshal(body, &webhook); err != nil {
+		return nil
 	}
 
-	log.Printf("📊 Found %d entries", len(entry))
-	
-	// Get first entry
-	firstEntry, ok := entry[0].(map[string]interface{})
-	if !ok {
-		log.Println("⚠️ Invalid entry format")
-		return
+	var keys []string
+	seen := make(map[string]bool)
+	add := func(key string) {
+		if !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
 	}
-	
-	// Get changes
-	changes, ok := firstEntry["changes"].([]interface{})
-	if !ok || len(changes) == 0 {
-		log.Println("⚠️ No changes found in entry")
-		return
+	for e := range webhook.Entry {
+		for c := range webhook.Entry[e].Changes {
+			for _, event := range webhook.Entry[e].Changes[c].Value.Events() {
+				switch ev := event.(type) {
+				case *CallConnectEvent:
+					add("call:" + ev.CallID)
+				case *CallEvent:
+					add("call:" + ev.Call.ID)
+				case *MessageEvent:
+					add("phone:" + ev.Message.From)
+				case *StatusEvent:
+					if ev.Status.IsCall() {
+						add("call:" + ev.Status.ID)
+					} else {
+						add("phone:" + ev.Status.RecipientID)
+					}
+				}
+			}
+		}
 	}
-	
-	log.Printf("📊 Found %d changes", len(changes))
-	
-	// Process each change
-	for i, change := range changes {
-		log.Printf("🔄 Processing change %d", i+1)
-		
-		changeData, ok := change.(map[string]interface{})
-		if !ok {
-			log.Printf("⚠️ Invalid change format at index %d", i)
+	return keys
+}
+
+// processWebhook processes incoming webhook data. The returned error joins
+// the failures of every change so the queue retries the delivery.
+func (b *WhatsAppBridge) processWebhook(ctx context.Context, webhook *WebhookData) error {
+	logger := loggerFrom(ctx)
+	logger.Debug("🔍 Processing webhook data...", "entries", len(webhook.Entry))
+
+	if len(webhook.Entry) == 0 {
+		logger.Warn("⚠️ No entry found in webhook")
+		return nil
+	}
+
+	var errs []error
+
+	// Meta batches several entries (and several changes per entry) into one
+	// delivery - process every one of them in the order they were sent
+	for e := range webhook.Entry {
+		entry := &webhook.Entry[e]
+		if len(entry.Changes) == 0 {
+			logger.Warn("⚠️ No changes found in entry", "entry", e+1)
 			continue
 		}
-		
-		// Get the value directly - WhatsApp webhook structure has the field type inside value
-		if value, ok := changeData["value"].(map[string]interface{}); ok {
-			// Security check: Only process webhooks from our specific phone number
-			if metadata, ok := value["metadata"].(map[string]interface{}); ok {
-				displayPhoneNumber, _ := metadata["display_phone_number"].(string)
-				allowedPhoneNumber := "917306356514"
-
-				if displayPhoneNumber != allowedPhoneNumber {
-					log.Printf("🚫 Ignoring webhook from unauthorized phone number: %s (expected: %s)",
-						displayPhoneNumber, allowedPhoneNumber)
-					return
-				}
-				log.Printf("✅ Phone number verified: %s", displayPhoneNumber)
-			} else {
-				log.Printf("⚠️ No metadata found in webhook value, skipping security check")
-			}
 
-			// Check for event_type field (used for outbound calls)
-			if eventType, ok := value["event_type"].(string); ok {
-				log.Printf("📞 Found event_type: %s", eventType)
-				if eventType == "call.connect" {
-					log.Printf("📞 Processing call.connect event (outbound call answer)")
-					// This is the webhook with SDP answer for outbound calls
-					callID, _ := value["call_id"].(string)
-					from, _ := value["from"].(string)
-
-					if session, ok := value["session"].(map[string]interface{}); ok {
-						sdpAnswer, _ := session["sdp"].(string)
-						sdpType, _ := session["sdp_type"].(string)
-
-						log.Printf("🔍 Session data: sdp_type=%s, sdp_length=%d", sdpType, len(sdpAnswer))
-
-						if sdpType == "answer" && sdpAnswer != "" {
-							log.Printf("📥 Received SDP answer for outbound call %s", callID)
-							log.Printf("📄 SDP Answer:\n%s", sdpAnswer)
-							// Process the answer asynchronously
-							go b.handleOutboundCallAnswer(callID, sdpAnswer, from)
-						}
-					}
-				}
+		for i := range entry.Changes {
+			logger.Info("🔄 Processing webhook change", "entry", e+1, "change", i+1, "field", entry.Changes[i].Field)
+			if err := b.processChange(ctx, &entry.Changes[i]); err != nil {
+				logger.Warn("❌ Webhook change failed", "entry", e+1, "change", i+1, "error", err)
+				errs = append(errs, err)
 			}
+		}
+	}
+	return errors.Join(errs...)
+}
 
-			// Check for calls array directly in value (used for inbound calls)
-			if calls, ok := value["calls"].([]interface{}); ok && len(calls) > 0 {
-				log.Printf("📞 Found %d call events", len(calls))
-				for _, call := range calls {
-					if callData, ok := call.(map[string]interface{}); ok {
-						b.handleCallEvent(callData)
-					}
-				}
-			} else if messages, ok := value["messages"].([]interface{}); ok && len(messages) > 0 {
-				log.Printf("💬 Found %d message events", len(messages))
-				// Handle messages using the messaging SDK
-				go b.handleMessageEvents(webhook)
-			} else if statuses, ok := value["statuses"].([]interface{}); ok && len(statuses) > 0 {
-				log.Printf("📊 Found %d status events", len(statuses))
-				// Check if any are call statuses
-				for _, status := range statuses {
-					if statusData, ok := status.(map[string]interface{}); ok {
-						if statusType, ok := statusData["type"].(string); ok && statusType == "call" {
-							log.Printf("📞 Found call status event")
-							// Log full status data for outbound calls
-							statusJSON, _ := json.MarshalIndent(statusData, "", "  ")
-							log.Printf("📋 Full status data:\n%s", string(statusJSON))
-
-							// When we get ACCEPTED, we should expect a connect webhook next
-							if callStatus, ok := statusData["status"].(string); ok && callStatus == "ACCEPTED" {
-								log.Printf("✅ Call ACCEPTED by user - waiting for connect webhook with SDP answer...")
-							}
-						}
-					}
-				}
-			} else {
-				// Log all fields present in value for debugging
-				log.Printf("📋 Value contains fields: %v", func() []string {
-					var fields []string
-					for k := range value {
-						fields = append(fields, k)
-					}
-					return fields
-				}())
+// processChange routes one change to its tenant and handles every call,
+// message and status it contains
+func (b *WhatsAppBridge) processChange(ctx context.Context, change *WebhookChange) error {
+	logger := loggerFrom(ctx)
+	value := &change.Value
+
+	// Route the change to the tenant that owns the receiving number
+	phoneNumberID := value.Metadata.PhoneNumberID
+	displayPhoneNumber := value.Metadata.DisplayPhoneNumber
+
+	tenant := b.tenants.Get(phoneNumberID)
+	if tenant == nil {
+		logger.Warn("🚫 Ignoring change for unknown phone number ID", "phone_number_id", phoneNumberID, "display_number", displayPhoneNumber)
+		return nil
+	}
+	if tenant.DisplayPhoneNumber != "" && displayPhoneNumber != tenant.DisplayPhoneNumber {
+		logger.Warn("🚫 Ignoring change: display number doesn't match the tenant's",
+			"tenant", tenant.Name, "display_number", displayPhoneNumber, "tenant_number", tenant.DisplayPhoneNumber)
+		return nil
+	}
+	logger = logger.With("tenant", tenant.Name)
+	ctx = withLogger(ctx, logger)
+	logger.Debug("✅ Routed change to tenant", "display_number", displayPhoneNumber)
+
+	for _, werr := range value.Errors {
+		logger.Error("❌ Webhook error", "error", werr)
+	}
+
+	events := value.Events()
+	if len(events) == 0 {
+		logger.Info("📋 Change contained no call, message or status events", "field", change.Field)
+		return nil
+	}
+
+	// Messages are answered through the messaging SDK with the tenant's credentials
+	wa := NewWhatsAppSDK(tenant.AccessToken, tenant.PhoneNumberID)
+	wa.Client.SetDedupeStore(b.dedupe)
+	messages := wa.WebhookHandler()
+
+	// Handle events one at a time so replies go out in the order they arrived
+	var errs []error
+	for _, event := range events {
+		switch ev := event.(type) {
+		case *CallConnectEvent:
+			b.handleCallConnectEvent(ctx, ev)
+		case *CallEvent:
+			b.handleCallEvent(ctx, tenant, ev.Call)
+		case *MessageEvent:
+			if err := b.handleMessage(ctx, tenant, messages.ForMessage(ev.Value, ev.Message)); err != nil {
+				errs = append(errs, err)
 			}
+		case *StatusEvent:
+			b.handleStatusEvent(ctx, ev.Status)
 		}
-		
-		// Also check for field attribute (some webhooks have it)
-		if field, ok := changeData["field"].(string); ok {
-			log.Printf("📌 Field attribute: %s", field)
+	}
+	return errors.Join(errs...)
+}
+
+// handleCallConnectEvent processes the value-level event_type webhook that
+// carries the SDP answer for outbound calls
+func (b *WhatsAppBridge) handleCallConnectEvent(ctx context.Context, ev *CallConnectEvent) {
+	logger := loggerFrom(ctx).With("call_id", ev.CallID)
+	logger.Debug("📞 Found event_type", "event_type", ev.EventType)
+	if ev.EventType != EventTypeCallConnect {
+		return
+	}
+	if b.isDuplicateEvent(callDedupeKey(ev.CallID, ev.EventType)) {
+		logger.Info("🔁 Duplicate event ignored", "event_type", ev.EventType)
+		return
+	}
+
+	logger.Info("📞 Processing call.connect event (outbound call answer)")
+	if ev.Session == nil {
+		logger.Warn("⚠️ No session data in call.connect event")
+		return
+	}
+
+	logger.Debug("🔍 Session data", "sdp_type", ev.Session.SDPType, "sdp_length", len(ev.Session.SDP))
+
+	if ev.Session.SDPType == "answer" && ev.Session.SDP != "" {
+		logger.Info("📥 Received SDP answer for outbound call")
+		logger.Debug("📄 SDP answer", "sdp", ev.Session.SDP)
+		// Applying the answer is local, so it finishes before a terminate queued behind it runs
+		b.handleOutboundCallAnswer(ctx, ev.CallID, ev.Session.SDP, ev.From)
+	}
+}
+
+// isDuplicateEvent marks key as processed and reports whether it already was
+func (b *WhatsAppBridge) isDuplicateEvent(key string) bool {
+	if b.dedupe.CheckAndMark(key) {
+		b.duplicateEvents.Add(1)
+		return true
+	}
+	return false
+}
+
+// handleStatusEvent processes message and call status updates
+func (b *WhatsAppBridge) handleStatusEvent(ctx context.Context, status *WebhookStatus) {
+	logger := loggerFrom(ctx)
+	if !status.IsCall() {
+		logger = logger.With("message_id", status.ID)
+		logger.Info("📊 Message status", "to", status.RecipientID, "status", status.Status)
+		for _, werr := range status.Errors {
+			logger.Error("❌ Message error", "error", werr)
+		}
+		return
+	}
+
+	logger = logger.With("call_id", status.ID)
+	logger.Info("📞 Call status event", "status", status.Status, "recipient", status.RecipientID)
+	for _, werr := range status.Errors {
+		logger.Error("❌ Call error", "error", werr)
+	}
+
+	// Drive the outbound call state machine
+	switch status.Status {
+	case "RINGING":
+		b.advanceOutboundCall(status.ID, OutboundStateRinging, "RINGING status")
+	case "ACCEPTED":
+		// When we get ACCEPTED, we should expect a connect webhook next
+		logger.Info("✅ Call ACCEPTED by user - waiting for connect webhook with SDP answer...")
+		b.advanceOutboundCall(status.ID, OutboundStateAccepted, "ACCEPTED status")
+	case "REJECTED", "FAILED", "COMPLETED":
+		b.mu.Lock()
+		call, exists := b.activeCalls[status.ID]
+		b.mu.Unlock()
+		if exists && call.Outbound != nil {
+			b.endOutboundCall(call, status.Status+" status")
 		}
 	}
 }
 
 // handleCallEvent processes individual call events from webhooks
-func (b *WhatsAppBridge) handleCallEvent(callData map[string]interface{}) {
-	// Extract call information
-	callID, _ := callData["id"].(string)
-	event, _ := callData["event"].(string)
-	direction, _ := callData["direction"].(string)
-	from, _ := callData["from"].(string)
-	to, _ := callData["to"].(string)
-
-	log.Printf("📞 Call event: %s (ID: %s, Direction: %s, From: %s, To: %s)", event, callID, direction, from, to)
-
-	// For debugging, log all fields in the call data
-	log.Printf("🔍 Call data fields: %v", func() []string {
-		var fields []string
-		for k := range callData {
-			fields = append(fields, k)
-		}
-		return fields
-	}())
-	
+func (b *WhatsAppBridge) handleCallEvent(ctx context.Context, tenant *Tenant, call *WebhookCall) {
+	callID := call.ID
+	logger := loggerFrom(ctx).With("call_id", callID)
+
+	logger.Info("📞 Call event", "call_event", call.Event, "direction", call.Direction, "from", call.From, "to", call.To)
+
+	// Meta retries undelivered webhooks - never accept or tear down the same call twice
+	if b.isDuplicateEvent(callDedupeKey(callID, call.Event)) {
+		logger.Info("🔁 Duplicate event ignored", "call_event", call.Event)
+		return
+	}
+
 	// Log additional call data for debugging
-	if status, ok := callData["status"].(string); ok {
-		log.Printf("📞 Call status: %s", status)
+	if call.Status != "" {
+		logger.Info("📞 Call status", "status", call.Status)
 
 		// If status is FAILED, log the errors
-		if status == "FAILED" {
-			if errors, ok := callData["errors"].([]interface{}); ok && len(errors) > 0 {
-				log.Printf("❌ Call FAILED with %d errors:", len(errors))
-				for i, errObj := range errors {
-					if errData, ok := errObj.(map[string]interface{}); ok {
-						errJSON, _ := json.MarshalIndent(errData, "  ", "  ")
-						log.Printf("❌ Error %d:\n  %s", i+1, string(errJSON))
-					}
+		if call.Status == "FAILED" {
+			if len(call.Errors) > 0 {
+				for i, werr := range call.Errors {
+					logger.Error("❌ Call FAILED", "error_index", i+1, "errors", len(call.Errors), "error", werr)
 				}
 			} else {
-				log.Printf("❌ Call FAILED but no error details available")
+				logger.Error("❌ Call FAILED but no error details available")
 			}
 		}
 	}
-	if timestamp, ok := callData["timestamp"].(string); ok {
-		log.Printf("📞 Call timestamp: %s", timestamp)
+	if call.Timestamp != "" {
+		logger.Debug("📞 Call timestamp", "timestamp", call.Timestamp)
 	}
 	
-	switch event {
-	case "connect":
+	switch call.Event {
+	case CallEventConnect:
 		// Handle incoming call with SDP offer
-		if direction == "USER_INITIATED" {
+		if call.Direction == CallDirectionUserInitiated {
 			// Extract SDP from session
-			if session, ok := callData["session"].(map[string]interface{}); ok {
-				sdpOffer, _ := session["sdp"].(string)
-				sdpType, _ := session["sdp_type"].(string)
-
-				if sdpType == "offer" && sdpOffer != "" {
-					log.Printf("📥 Received SDP offer for inbound call %s", callID)
-					// Process the call asynchronously
-					go b.acceptIncomingCall(callID, sdpOffer, from)
+			if call.Session != nil && call.Session.SDPType == "offer" && call.Session.SDP != "" {
+				logger.Info("📥 Received SDP offer for inbound call")
+				// Reserve the call before the job returns, so a terminate queued
+				// behind this connect finds it; the rest runs in the background
+				if active := b.reserveIncomingCall(ctx, tenant, callID, call.From); active != nil {
+					go b.acceptIncomingCall(ctx, active, call.Session.SDP)
 				}
 			}
-		} else if direction == "BUSINESS_INITIATED" {
+		} else if call.Direction == CallDirectionBusinessInitiated {
 			// Handle outbound call - user answered with SDP answer
-			log.Printf("📥 User answered outbound call %s", callID)
-			if session, ok := callData["session"].(map[string]interface{}); ok {
-				sdpAnswer, _ := session["sdp"].(string)
-				sdpType, _ := session["sdp_type"].(string)
-
-				log.Printf("🔍 Session data: sdp_type=%s, sdp_length=%d", sdpType, len(sdpAnswer))
-
-				if sdpType == "answer" && sdpAnswer != "" {
-					log.Printf("📥 Received SDP answer for outbound call %s", callID)
-					log.Printf("📄 SDP Answer:\n%s", sdpAnswer)
-					// Process the answer asynchronously
-					go b.handleOutboundCallAnswer(callID, sdpAnswer, from)
+			logger.Info("📥 User answered outbound call")
+			if session := call.Session; session != nil {
+				logger.Debug("🔍 Session data", "sdp_type", session.SDPType, "sdp_length", len(session.SDP))
+
+				if session.SDPType == "answer" && session.SDP != "" {
+					logger.Info("📥 Received SDP answer for outbound call")
+					logger.Debug("📄 SDP answer", "sdp", session.SDP)
+					// Applying the answer is local, so it finishes before a terminate queued behind it runs
+					b.handleOutboundCallAnswer(ctx, callID, session.SDP, call.From)
 				} else {
-					log.Printf("⚠️ Invalid or missing SDP answer: type=%s, present=%v", sdpType, sdpAnswer != "")
+					logger.Warn("⚠️ Invalid or missing SDP answer", "sdp_type", session.SDPType, "sdp_present", session.SDP != "")
 				}
 			} else {
-				log.Printf("⚠️ No session data in connect event for outbound call")
+				logger.Warn("⚠️ No session data in connect event for outbound call")
 			}
 		}
 		
-	case "terminate":
+	case CallEventTerminate:
 		// Handle call termination
 		b.mu.Lock()
-		if call, exists := b.activeCalls[callID]; exists {
-			// Close WebRTC connection
-			if call.PeerConnection != nil {
-				call.PeerConnection.Close()
-			}
-			// Close OpenAI connection
-			if call.OpenAIClient != nil {
-				call.OpenAIClient.Close()
-				log.Printf("🤖 Closed OpenAI connection for call %s", callID)
-			}
-			// Log call duration
-			callDuration := time.Since(call.StartTime)
-			log.Printf("📊 Call %s lasted %v", callID, callDuration)
-			
-			delete(b.activeCalls, callID)
-			log.Printf("☎️ Call terminated and cleaned up: %s", callID)
+		active, exists := b.activeCalls[callID]
+		b.mu.Unlock()
+
+		if !exists {
+			logger.Info("☎️ Terminate event for unknown call")
 		} else {
-			log.Printf("☎️ Terminate event for unknown call: %s", callID)
+			b.endCall(active, "terminated by WhatsApp")
 		}
-		b.mu.Unlock()
 		
 	case "ringing":
-		log.Printf("🔔 Call ringing: %s", callID)
+		logger.Info("🔔 Call ringing")
 		// WhatsApp is notifying us that the call is ringing
 		// We don't need to do anything here, just log it
 		
 	case "answered":
-		log.Printf("📞 Call answered: %s", callID)
+		logger.Info("📞 Call answered")
 		// The call was answered (might be on another device)
 		
 	default:
-		log.Printf("📋 Unhandled call event: %s", event)
+		logger.Warn("📋 Unhandled call event", "call_event", call.Event)
 		// Log the entire call data for unknown events
-		callJSON, _ := json.MarshalIndent(callData, "", "  ")
-		log.Printf("📋 Full call data:\n%s", string(callJSON))
+		callJSON, _ := json.MarshalIndent(call, "", "  ")
+		logger.Debug("📋 Full call data", "body", string(callJSON))
 	}
 }
 
-// handleMessageEvents processes incoming WhatsApp messages
-func (b *WhatsAppBridge) handleMessageEvents(webhook map[string]interface{}) {
-	log.Println("💬 Processing message events...")
-
-	// Initialize messaging SDK
-	wa := NewWhatsAppSDK("", "")
-	handler := wa.WebhookHandler()
-
-	// Convert webhook to JSON bytes for parsing
-	webhookJSON, err := json.Marshal(webhook)
-	if err != nil {
-		log.Printf("❌ Failed to marshal webhook: %v", err)
-		return
-	}
-
-	// Parse webhook data
-	if err := handler.Parse(webhookJSON); err != nil {
-		log.Printf("❌ Failed to parse message webhook: %v", err)
-		return
-	}
+// handleMessage dispatches a single inbound message by type. On failure the
+// message is released from the dedupe store so the queued retry handles it again.
+func (b *WhatsAppBridge) handleMessage(ctx context.Context, tenant *Tenant, handler *WebhookHandler) error {
+	logger := loggerFrom(ctx).With("message_id", handler.MessageID())
+	ctx = withLogger(ctx, logger)
 
 	// Check for duplicate messages
 	if handler.IsDuplicate() {
-		log.Printf("🔁 Duplicate message ignored")
-		return
+		b.duplicateEvents.Add(1)
+		logger.Info("🔁 Duplicate message ignored")
+		return nil
 	}
 
 	// Get message details
@@ -558,89 +709,107 @@ func (b *WhatsAppBridge) handleMessageEvents(webhook map[string]interface{}) {
 	msgType := handler.MessageType()
 	contactName := handler.ContactName()
 
-	log.Printf("📨 Message from %s (%s): Type=%s, Text=%s", contactName, sender, msgType, text)
+	logger.Info("📨 Message received", "from", sender, "contact", contactName, "type", msgType)
+	logger.Debug("📨 Message text", "text", text)
 
 	// Handle different message types
+	var err error
 	switch msgType {
 	case "text":
-		b.handleTextMessage(handler, text, sender)
+		err = b.handleTextMessage(ctx, tenant, handler, text, sender)
 	case "interactive":
-		b.handleInteractiveMessage(handler, text, sender)
+		err = b.handleInteractiveMessage(ctx, tenant, handler, text, sender)
 	case "audio":
-		b.handleAudioMessage(handler, sender)
+		err = b.handleAudioMessage(ctx, tenant, handler, sender)
 	case "image":
-		b.handleImageMessage(handler, sender)
+		b.handleImageMessage(ctx, handler, sender)
 	case "video":
-		b.handleVideoMessage(handler, sender)
+		b.handleVideoMessage(ctx, handler, sender)
 	default:
-		log.Printf("⚠️ Unknown message type: %s", msgType)
+		logger.Warn("⚠️ Unknown message type", "type", msgType)
+	}
+
+	if err != nil {
+		b.dedupe.Forget(messageDedupeKey(handler.MessageID()))
+		return fmt.Errorf("message %s from %s: %w", handler.MessageID(), sender, err)
 	}
+	return nil
 }
 
 // handleTextMessage handles incoming text messages using LLM
-func (b *WhatsAppBridge) handleTextMessage(handler *WebhookHandler, text, sender string) {
-	log.Printf("💬 Handling text message: %s", text)
+func (b *WhatsAppBridge) handleTextMessage(ctx context.Context, tenant *Tenant, handler *WebhookHandler, text, sender string) error {
+	logger := loggerFrom(ctx)
+	logger.Debug("💬 Handling text message", "text", text)
 
 	// Create LLM handler for this user
-	llmHandler := NewLLMTextHandler(sender)
+	llmHandler := NewLLMTextHandler(ctx, tenant, sender)
 
 	// Save incoming message to Supabase
 	messageID := handler.MessageID()
 	contactName := handler.ContactName()
 	if err := llmHandler.SaveMessage(text, "inbound", messageID, contactName); err != nil {
-		log.Printf("⚠️ Failed to save incoming message: %v", err)
+		logger.Warn("⚠️ Failed to save incoming message", "error", err)
 	}
 
-	// Get AI response
+	// Get AI response - on failure the queue retries the message with backoff
 	aiResponse, err := llmHandler.GetAIResponse(text)
 	if err != nil {
-		log.Printf("❌ Failed to get AI response: %v", err)
-		// Fallback response if AI fails
-		aiResponse = "I'm having trouble thinking right now. Can you try again? 🤔"
+		logger.Error("❌ Failed to get AI response", "error", err)
+		return fmt.Errorf("AI response failed: %w", err)
 	}
 
 	// Send response
 	if _, err := handler.ReplyText(aiResponse); err != nil {
-		log.Printf("❌ Failed to send response: %v", err)
-		return
+		logger.Error("❌ Failed to send response", "error", err)
+		return fmt.Errorf("failed to send response: %w", err)
 	}
 
 	// Save outbound message to Supabase
 	if err := llmHandler.SaveMessage(aiResponse, "outbound", "", contactName); err != nil {
-		log.Printf("⚠️ Failed to save outbound message: %v", err)
+		logger.Warn("⚠️ Failed to save outbound message", "error", err)
 	}
 
-	log.Printf("✅ AI conversation completed for %s", sender)
+	logger.Info("✅ AI conversation completed", "to", sender)
+	return nil
 }
 
 // handleInteractiveMessage handles button/list replies
-func (b *WhatsAppBridge) handleInteractiveMessage(handler *WebhookHandler, selection, sender string) {
-	log.Printf("🔘 User selected: %s", selection)
+func (b *WhatsAppBridge) handleInteractiveMessage(ctx context.Context, tenant *Tenant, handler *WebhookHandler, selection, sender string) error {
+	logger := loggerFrom(ctx)
+	logger.Info("🔘 User selected a button", "selection", selection)
 
 	switch selection {
 	case "Call Me":
 		handler.ReplyText("📞 Initiating voice call...")
-		// Initiate an outbound call
+		// Place the call like /initiate-call does, in the background since
+		// building the offer waits on ICE gathering
 		go func() {
-			callID, err := b.initiateWhatsAppCall(sender, "")
-			if err != nil {
-				log.Printf("❌ Failed to initiate call: %v", err)
-			} else {
-				log.Printf("✅ Initiated call: %s", callID)
+			callID, err := b.startOutboundCall(ctx, tenant, sender, "", "", "", tenant.impliesRecordingConsent(), false)
+			switch {
+			case errors.Is(err, errNoCallPermission):
+				// Ask for permission instead; this enforces Meta's request limits
+				logger.Info("🚫 No call permission - sending a permission request", "to", sender)
+				if err := SendCallPermissionRequest(ctx, tenant, sender); err != nil {
+					logger.Error("❌ Failed to send permission request", "error", err)
+				}
+			case err != nil:
+				logger.Error("❌ Failed to initiate call", "error", err)
+			default:
+				logger.Info("✅ Initiated call", "call_id", callID)
 			}
 		}()
 
 	case "Check Status":
-		b.handleTextMessage(handler, "status", sender)
+		return b.handleTextMessage(ctx, tenant, handler, "status", sender)
 
 	case "Help":
-		b.handleTextMessage(handler, "help", sender)
+		return b.handleTextMessage(ctx, tenant, handler, "help", sender)
 
 	case "approve_call_permission":
 		// User approved call permission
-		log.Printf("✅ User %s approved call permission", sender)
-		if err := ApproveCallPermission(sender, "express_request"); err != nil {
-			log.Printf("❌ Failed to approve call permission: %v", err)
+		logger.Info("✅ User approved call permission", "from", sender)
+		if err := ApproveCallPermission(tenant.SupabaseSchema, sender, "express_request"); err != nil {
+			logger.Error("❌ Failed to approve call permission", "error", err)
 			handler.ReplyText("❌ Sorry, there was an error processing your response. Please try again.")
 		} else {
 			handler.ReplyText("✅ Thank you! You've granted permission for us to call you. We can now contact you by phone when needed. This permission is valid for 72 hours.")
@@ -648,111 +817,109 @@ func (b *WhatsAppBridge) handleInteractiveMessage(handler *WebhookHandler, selec
 
 	case "deny_call_permission":
 		// User denied call permission
-		log.Printf("🚫 User %s denied call permission", sender)
+		logger.Info("🚫 User denied call permission", "from", sender)
 		handler.ReplyText("👍 No problem! We won't call you. You can change your mind anytime by typing 'allow calls'.")
 
 	default:
 		// Unknown button selection - just log it
-		log.Printf("⚠️ Unknown button selection: %s", selection)
+		logger.Warn("⚠️ Unknown button selection", "selection", selection)
 	}
+	return nil
 }
 
 // handleAudioMessage handles incoming audio messages with transcription
-func (b *WhatsAppBridge) handleAudioMessage(handler *WebhookHandler, sender string) {
+func (b *WhatsAppBridge) handleAudioMessage(ctx context.Context, tenant *Tenant, handler *WebhookHandler, sender string) error {
+	logger := loggerFrom(ctx)
 	audioID := handler.AudioID()
 	if audioID == "" {
-		log.Printf("⚠️ No audio ID found in message")
-		return
+		logger.Warn("⚠️ No audio ID found in message")
+		return nil
 	}
 
-	log.Printf("🎤 Received audio message: %s from %s", audioID, sender)
+	logger.Info("🎤 Received audio message", "audio_id", audioID, "from", sender)
 
-	// Get WhatsApp credentials
-	token := os.Getenv("WHATSAPP_TOKEN")
-	phoneNumberID := os.Getenv("PHONE_NUMBER_ID")
-
-	// Download the audio file
-	audioFilePath, err := DownloadAudio(audioID, phoneNumberID, token)
+	// Download the audio file with the tenant's credentials
+	audioFilePath, err := DownloadAudio(ctx, audioID, tenant.PhoneNumberID, tenant.AccessToken)
 	if err != nil {
-		log.Printf("❌ Error downloading audio: %v", err)
-		handler.ReplyText("Sorry, I couldn't download your audio message. 🤔")
-		return
+		logger.Error("❌ Error downloading audio", "error", err)
+		return fmt.Errorf("failed to download audio: %w", err)
 	}
 
 	// Ensure cleanup
-	defer CleanupAudioFile(audioFilePath)
+	defer CleanupAudioFile(ctx, audioFilePath)
 
 	// Transcribe the audio
-	transcription, err := TranscribeAudio(audioFilePath)
+	transcription, err := TranscribeAudio(ctx, audioFilePath)
 	if err != nil {
-		log.Printf("❌ Error transcribing audio: %v", err)
-		handler.ReplyText("Sorry, I couldn't understand your audio message. Can you try again? 🎤")
-		return
+		logger.Error("❌ Error transcribing audio", "error", err)
+		return fmt.Errorf("failed to transcribe audio: %w", err)
 	}
 
 	if transcription == "" {
-		log.Printf("⚠️ Empty transcription result")
+		logger.Warn("⚠️ Empty transcription result")
 		handler.ReplyText("I couldn't hear anything in your audio. Can you try again? 🎤")
-		return
+		return nil
 	}
 
-	log.Printf("✅ Transcribed audio: %s", transcription)
+	logger.Info("✅ Transcribed audio")
+	logger.Debug("✅ Transcription", "transcript", transcription)
 
 	// Get message metadata
 	messageID := handler.MessageID()
 	contactName := handler.ContactName()
 
 	// Create LLM handler for this user
-	llmHandler := NewLLMTextHandler(sender)
+	llmHandler := NewLLMTextHandler(ctx, tenant, sender)
 
 	// Save the transcribed message with [Voice] prefix
 	voiceMessage := fmt.Sprintf("[Voice]: %s", transcription)
 	if err := llmHandler.SaveMessage(voiceMessage, "inbound", messageID, contactName); err != nil {
-		log.Printf("⚠️ Failed to save voice message: %v", err)
+		logger.Warn("⚠️ Failed to save voice message", "error", err)
 	}
 
 	// Get AI response for the transcribed text
 	aiResponse, err := llmHandler.GetAIResponse(transcription)
 	if err != nil {
-		log.Printf("❌ Failed to get AI response: %v", err)
-		handler.ReplyText("I'm having trouble thinking right now. Can you try again? 🤔")
-		return
+		logger.Error("❌ Failed to get AI response", "error", err)
+		return fmt.Errorf("AI response failed: %w", err)
 	}
 
 	// Send the AI response
 	if _, err := handler.ReplyText(aiResponse); err != nil {
-		log.Printf("❌ Failed to send response: %v", err)
-		return
+		logger.Error("❌ Failed to send response", "error", err)
+		return fmt.Errorf("failed to send response: %w", err)
 	}
 
 	// Save the outbound response
 	if err := llmHandler.SaveMessage(aiResponse, "outbound", "", contactName); err != nil {
-		log.Printf("⚠️ Failed to save outbound message: %v", err)
+		logger.Warn("⚠️ Failed to save outbound message", "error", err)
 	}
 
-	log.Printf("✅ Voice message processed and response sent")
+	logger.Info("✅ Voice message processed and response sent")
+	return nil
 }
 
 // handleImageMessage handles incoming image messages
-func (b *WhatsAppBridge) handleImageMessage(handler *WebhookHandler, sender string) {
+func (b *WhatsAppBridge) handleImageMessage(ctx context.Context, handler *WebhookHandler, sender string) {
+	logger := loggerFrom(ctx)
 	imageID := handler.ImageID()
 	if imageID == "" {
-		log.Printf("⚠️ No image ID found in message")
+		logger.Warn("⚠️ No image ID found in message")
 		return
 	}
 
-	log.Printf("🖼️ Received image message: %s from %s", imageID, sender)
+	logger.Info("🖼️ Received image message", "image_id", imageID, "from", sender)
 
 	// Download the image file
 	filename := "image_" + imageID + ".jpg"
 	savedPath, err := handler.client.DownloadMedia(imageID, filename)
 	if err != nil {
-		log.Printf("❌ Error downloading image: %v", err)
+		logger.Error("❌ Error downloading image", "error", err)
 		handler.ReplyText("Sorry, I couldn't process your image.")
 		return
 	}
 
-	log.Printf("✅ Image saved to: %s", savedPath)
+	logger.Info("✅ Image saved", "path", savedPath)
 	handler.ReplyText("📸 Great image! I've received it.")
 
 	// Here you could:
@@ -762,69 +929,87 @@ func (b *WhatsAppBridge) handleImageMessage(handler *WebhookHandler, sender stri
 }
 
 // handleVideoMessage handles incoming video messages
-func (b *WhatsAppBridge) handleVideoMessage(handler *WebhookHandler, sender string) {
+func (b *WhatsAppBridge) handleVideoMessage(ctx context.Context, handler *WebhookHandler, sender string) {
+	logger := loggerFrom(ctx)
 	videoID := handler.VideoID()
 	if videoID == "" {
-		log.Printf("⚠️ No video ID found in message")
+		logger.Warn("⚠️ No video ID found in message")
 		return
 	}
 
-	log.Printf("🎥 Received video message: %s from %s", videoID, sender)
+	logger.Info("🎥 Received video message", "video_id", videoID, "from", sender)
 
 	// Download the video file
 	filename := "video_" + videoID + ".mp4"
 	savedPath, err := handler.client.DownloadMedia(videoID, filename)
 	if err != nil {
-		log.Printf("❌ Error downloading video: %v", err)
+		logger.Error("❌ Error downloading video", "error", err)
 		handler.ReplyText("Sorry, I couldn't process your video.")
 		return
 	}
 
-	log.Printf("✅ Video saved to: %s", savedPath)
+	logger.Info("✅ Video saved", "path", savedPath)
 	handler.ReplyText("🎬 Thanks for the video! I've received it.")
 }
 
-// acceptIncomingCall handles accepting an incoming WhatsApp call
-func (b *WhatsAppBridge) acceptIncomingCall(callID, sdpOffer, callerNumber string) {
-	log.Printf("🔔 Processing incoming call %s from %s", callID, callerNumber)
-	log.Printf("📋 Call flow: 1) Create PeerConnection → 2) Set SDP → 3) Pre-accept → 4) Accept → 5) Media flow")
-
-	// Grant call permission automatically - user calling us grants implicit permission for callbacks
-	if err := GrantCallPermission(callerNumber); err != nil {
-		log.Printf("⚠️ Failed to grant call permission for %s: %v", callerNumber, err)
-		// Continue anyway - permission tracking is not critical for call handling
-	}
-
-	// Check if call is already being processed
+// reserveIncomingCall registers an inbound call in activeCalls and starts
+// its watchdog. It does no I/O, so the webhook job that carries the connect
+// can call it before returning. It returns nil if the call is already known.
+func (b *WhatsAppBridge) reserveIncomingCall(ctx context.Context, tenant *Tenant, callID, callerNumber string) *Call {
 	b.mu.Lock()
 	if _, exists := b.activeCalls[callID]; exists {
 		b.mu.Unlock()
-		log.Printf("⚠️ Call %s already being processed, ignoring duplicate", callID)
-		return
-	}
-	// Reserve this call ID immediately to prevent race conditions
-	b.activeCalls[callID] = &Call{
-		ID:        callID,
-		StartTime: time.Now(),
+		loggerFrom(ctx).Warn("⚠️ Call already being processed, ignoring duplicate", "call_id", callID)
+		return nil
 	}
+	call := newCall(callID, tenant)
+	call.Caller = callerNumber
+	b.activeCalls[callID] = call
 	b.mu.Unlock()
+
+	// From here on every exit path goes through endCall
+	b.startCallWatchdog(call)
+	return call
+}
+
+// acceptIncomingCall answers a reserved inbound call. A terminate handled
+// while it is being set up ends the call, and it is then never accepted.
+func (b *WhatsAppBridge) acceptIncomingCall(ctx context.Context, call *Call, sdpOffer string) {
+	tenant, callID, callerNumber := call.Tenant, call.ID, call.Caller
+	logger := loggerFrom(ctx).With("call_id", callID)
+	logger.Info("🔔 Processing incoming call", "from", callerNumber, "tenant", tenant.Name)
+	logger.Debug("📋 Call flow: 1) Create PeerConnection → 2) Set SDP → 3) Pre-accept → 4) Accept → 5) Media flow")
+
+	// Grant call permission automatically - user calling us grants implicit permission for callbacks
+	if err := GrantCallPermission(tenant.SupabaseSchema, callerNumber); err != nil {
+		logger.Warn("⚠️ Failed to grant call permission", "phone", callerNumber, "error", err)
+		// Continue anyway - permission tracking is not critical for call handling
+	}
 	
 	// Create a new PeerConnection
 	pc, err := b.api.NewPeerConnection(b.config)
 	if err != nil {
-		log.Printf("❌ Failed to create peer connection: %v", err)
-		b.mu.Lock()
-		delete(b.activeCalls, callID)
-		b.mu.Unlock()
+		logger.Error("❌ Failed to create peer connection", "error", err)
+		b.RejectCall(tenant, callID, "failed to create peer connection")
+		return
+	}
+	b.mu.Lock()
+	call.PeerConnection = pc
+	b.mu.Unlock()
+	// A terminate may already have torn the call down before it had a connection
+	if call.ended() {
+		logger.Info("☎️ Call ended while being set up - not answering it")
+		pc.Close()
 		return
 	}
+	b.setCallState(call, CallStateNegotiating)
 	
 	// Set up ICE connection channel
 	iceConnected := make(chan bool, 1)
 	
 	// Set up handlers
 	pc.OnICEConnectionStateChange(func(state webrtc.ICEConnectionState) {
-		log.Printf("ICE Connection State for call %s: %s", callID, state.String())
+		logger.Info("🧊 ICE connection state", "state", state.String())
 		if state == webrtc.ICEConnectionStateConnected || state == webrtc.ICEConnectionStateCompleted {
 			select {
 			case iceConnected <- true:
@@ -833,38 +1018,34 @@ func (b *WhatsAppBridge) acceptIncomingCall(callID, sdpOffer, callerNumber strin
 		}
 		// v4: Handle explicit DTLS close (instant disconnect detection)
 		if state == webrtc.ICEConnectionStateClosed {
-			log.Printf("🔴 Call %s: ICE connection explicitly closed via DTLS", callID)
+			logger.Info("🔴 ICE connection explicitly closed via DTLS")
 			// Connection closed gracefully - cleanup will happen in terminate handler
 		}
+		b.onCallICEState(call, state.String())
+	})
+
+	pc.OnConnectionStateChange(func(state webrtc.PeerConnectionState) {
+		logger.Info("🔌 Peer connection state", "state", state.String())
+		if state == webrtc.PeerConnectionStateFailed {
+			b.endCall(call, "DTLS/peer connection failed")
+		}
 	})
 	
 	pc.OnICEGatheringStateChange(func(state webrtc.ICEGatheringState) {
-		log.Printf("ICE Gathering State for call %s: %s", callID, state.String())
+		logger.Debug("🧊 ICE gathering state", "state", state.String())
 	})
 	
 	pc.OnICECandidate(func(candidate *webrtc.ICECandidate) {
 		if candidate != nil {
-			log.Printf("ICE Candidate for call %s: %s", callID, candidate.String())
+			logger.Debug("🧊 ICE candidate", "candidate", candidate.String())
 		}
 	})
 	
 	// We'll create and add the audio track AFTER setting remote description
 	
-	// Create the call object early
-	call := &Call{
-		ID:             callID,
-		PeerConnection: pc,
-		StartTime:      time.Now(),
-	}
-	
-	// Store the call early so we can access it in OnTrack
-	b.mu.Lock()
-	b.activeCalls[callID] = call
-	b.mu.Unlock()
-	
 	pc.OnTrack(func(track *webrtc.TrackRemote, receiver *webrtc.RTPReceiver) {
-		log.Printf("🔊 Received audio track for call %s: %s (codec: %s)", callID, track.ID(), track.Codec().MimeType)
-		log.Printf("📊 Track details: PayloadType=%d, SSRC=%d, Kind=%s", track.PayloadType(), track.SSRC(), track.Kind().String())
+		logger.Info("🔊 Received audio track", "track", track.ID(), "codec", track.Codec().MimeType)
+		logger.Debug("📊 Track details", "payload_type", track.PayloadType(), "ssrc", track.SSRC(), "kind", track.Kind().String())
 		
 		// Verify call exists
 		b.mu.Lock()
@@ -872,76 +1053,15 @@ func (b *WhatsAppBridge) acceptIncomingCall(callID, sdpOffer, callerNumber strin
 		b.mu.Unlock()
 		
 		if !exists {
-			log.Printf("❌ Call %s not found in active calls", callID)
+			logger.Error("❌ Call not found in active calls")
 			return
 		}
 		
-		// Start reading audio packets and dynamically check for OpenAI client
-		go func() {
-			packetCount := 0
-			totalBytes := 0
-			openAIForwardingStarted := false
-
-			for {
-				// v4 FIX: Use ReadRTP() to access full packet with headers
-				rtpPacket, _, readErr := track.ReadRTP()
-				if readErr != nil {
-					log.Printf("❌ Error reading audio after %d packets: %v", packetCount, readErr)
-					return
-				}
-
-				// v4 FIX: Clear extension headers to avoid conflicts between WhatsApp and OpenAI
-				// Different endpoints use different extension header IDs, causing audio corruption
-				rtpPacket.Extension = false
-				rtpPacket.Extensions = nil
-
-				// Marshal back to bytes for forwarding
-				rtpBytes, marshalErr := rtpPacket.Marshal()
-				if marshalErr != nil {
-					log.Printf("❌ Error marshaling RTP packet: %v", marshalErr)
-					continue
-				}
-
-				packetCount++
-				totalBytes += len(rtpBytes)
-
-				// Check for OpenAI client on every packet (it might become available later)
-				b.mu.Lock()
-				activeCall, exists := b.activeCalls[callID]
-				var openAIClient *OpenAIRealtimeClient
-				if exists && activeCall != nil {
-					openAIClient = activeCall.OpenAIClient
-				}
-				b.mu.Unlock()
-
-				if openAIClient != nil {
-					// OpenAI client is available - forward the packet
-					if !openAIForwardingStarted {
-						log.Printf("🔄 OpenAI client now available - starting WhatsApp->OpenAI forwarding")
-						openAIForwardingStarted = true
-					}
+		// The receiver's RTCP carries WhatsApp's sender reports
+		go readRTCP(receiver, b.receiverRTCP(call, whatsappCaptureLeg))
 
-					// Forward cleaned RTP packet to OpenAI
-					if err := openAIClient.ForwardRTPToOpenAI(rtpBytes); err != nil {
-						if packetCount <= 3 { // Only log first few errors
-							log.Printf("❌ Error forwarding RTP to OpenAI: %v", err)
-						}
-					} else if packetCount == 1 || packetCount%100 == 0 {
-						if packetCount == 1 {
-							log.Printf("✅ First WhatsApp RTP packet forwarded to OpenAI! (cleaned headers)")
-						} else {
-							log.Printf("📦 Forwarded %d WhatsApp RTP packets (%d KB) to OpenAI",
-								packetCount, totalBytes/1024)
-						}
-					}
-				} else {
-					// OpenAI client not ready yet - just count packets
-					if packetCount%100 == 0 {
-						log.Printf("🎤 Received %d audio packets (total: %d bytes) - waiting for OpenAI", packetCount, totalBytes)
-					}
-				}
-			}
-		}()
+		// Forward the user's audio, picking up the voice agent whenever it connects
+		go b.forwardCallerAudio(call, trackRTPSource{track})
 	})
 	
 	// Clean and validate the SDP
@@ -952,46 +1072,45 @@ func (b *WhatsAppBridge) acceptIncomingCall(callID, sdpOffer, callerNumber strin
 	// Ensure SDP ends with a newline (required by some parsers)
 	if !strings.HasSuffix(sdpOffer, "\n") && !strings.HasSuffix(sdpOffer, "\r\n") {
 		sdpOffer += "\r\n"
-		log.Printf("📝 Added missing newline to SDP")
+		logger.Debug("📝 Added missing newline to SDP")
 	}
 	
-	log.Printf("🔍 SDP Offer (cleaned):\n%s", sdpOffer)
-	log.Printf("📏 SDP length: %d bytes", len(sdpOffer))
+	logger.Debug("🔍 SDP Offer (cleaned)", "sdp", sdpOffer)
+	logger.Debug("📏 SDP length", "bytes", len(sdpOffer))
 	
 	// Validate SDP starts correctly
 	if !strings.HasPrefix(sdpOffer, "v=0") {
-		log.Printf("❌ Invalid SDP: doesn't start with v=0")
+		logger.Warn("❌ Invalid SDP: doesn't start with v=0")
 	}
 	
 	// Count the number of lines for debugging
 	lines := strings.Split(sdpOffer, "\n")
-	log.Printf("📊 SDP has %d lines", len(lines))
+	logger.Debug("📊 SDP line count", "lines", len(lines))
 	
 	// Check for common SDP sections
 	hasAudio := strings.Contains(sdpOffer, "m=audio")
 	hasIceLite := strings.Contains(sdpOffer, "a=ice-lite")
 	hasOpus := strings.Contains(sdpOffer, "opus/48000")
 	hasTelephoneEvent := strings.Contains(sdpOffer, "telephone-event")
-	log.Printf("✓ SDP contains: audio=%v, ice-lite=%v, opus=%v, telephone-event=%v", hasAudio, hasIceLite, hasOpus, hasTelephoneEvent)
+	logger.Debug("✓ SDP contents", "audio", hasAudio, "ice_lite", hasIceLite, "opus", hasOpus, "telephone_event", hasTelephoneEvent)
 	
 	// Extract codecs offered by WhatsApp
 	if hasAudio {
 		lines := strings.Split(sdpOffer, "\n")
 		for _, line := range lines {
 			if strings.HasPrefix(line, "m=audio") {
-				log.Printf("📊 WhatsApp audio line: %s", strings.TrimSpace(line))
+				logger.Debug("📊 WhatsApp audio line", "line", strings.TrimSpace(line))
 			} else if strings.HasPrefix(line, "a=rtpmap:") {
-				log.Printf("📊 WhatsApp codec: %s", strings.TrimSpace(line))
+				logger.Debug("📊 WhatsApp codec", "line", strings.TrimSpace(line))
 			}
 		}
 	}
 	
 	// Try to parse specific problem areas
 	if strings.Contains(sdpOffer, "a=extmap:") {
-		log.Printf("📡 SDP contains extmap attributes - these might cause parsing issues")
 		// Count extmap lines
 		extmapCount := strings.Count(sdpOffer, "a=extmap:")
-		log.Printf("📡 Found %d extmap attributes", extmapCount)
+		logger.Debug("📡 SDP contains extmap attributes - these might cause parsing issues", "count", extmapCount)
 	}
 	
 	// Set the remote description (WhatsApp's offer)
@@ -1001,35 +1120,22 @@ func (b *WhatsAppBridge) acceptIncomingCall(callID, sdpOffer, callerNumber strin
 	}
 	
 	if err := pc.SetRemoteDescription(offer); err != nil {
-		log.Printf("❌ Failed to set remote description: %v", err)
-		log.Printf("📋 Error type: %T", err)
-		log.Printf("📋 Full error string: %q", err.Error())
-		log.Printf("📋 SDP that failed:\n%s", sdpOffer)
-		
-		// Try parsing the SDP manually to see where it fails
-		if sdpBytes := []byte(sdpOffer); len(sdpBytes) > 0 {
-			log.Printf("📋 First 50 chars: %q", string(sdpBytes[:min(50, len(sdpBytes))]))
-			log.Printf("📋 Last 50 chars: %q", string(sdpBytes[max(0, len(sdpBytes)-50):]))
-		}
+		logger.Error("❌ Failed to set remote description", "error", err, "error_type", fmt.Sprintf("%T", err))
+		logger.Debug("📋 SDP that failed", "sdp", sdpOffer)
 		
 		// Check for specific error patterns
 		errStr := err.Error()
 		if strings.Contains(errStr, "EOF") {
-			log.Printf("💡 EOF error - SDP might be truncated or have parsing issues")
+			logger.Info("💡 EOF error - SDP might be truncated or have parsing issues")
 		}
 		if strings.Contains(errStr, "extmap") {
-			log.Printf("💡 Error related to RTP extensions")
+			logger.Info("💡 Error related to RTP extensions")
 		}
 		if strings.Contains(errStr, "codec") {
-			log.Printf("💡 Error related to codec registration")
+			logger.Info("💡 Error related to codec registration")
 		}
 		
-		b.mu.Lock()
-		delete(b.activeCalls, callID)
-		b.mu.Unlock()
-		if pc != nil {
-			pc.Close()
-		}
+		b.RejectCall(tenant, callID, "invalid SDP offer")
 		return
 	}
 	
@@ -1046,89 +1152,53 @@ func (b *WhatsAppBridge) acceptIncomingCall(callID, sdpOffer, callerNumber strin
 		"bridge-audio",
 	)
 	if err != nil {
-		log.Printf("❌ Failed to create audio track: %v", err)
-		b.mu.Lock()
-		delete(b.activeCalls, callID)
-		b.mu.Unlock()
-		if pc != nil {
-			pc.Close()
-		}
+		logger.Error("❌ Failed to create audio track", "error", err)
+		b.RejectCall(tenant, callID, "failed to create audio track")
 		return
 	}
 
 	// Add the track directly to ensure Opus is in the SDP
 	rtpSender, err := pc.AddTrack(audioTrack)
 	if err != nil {
-		log.Printf("❌ Failed to add audio track: %v", err)
-		b.mu.Lock()
-		delete(b.activeCalls, callID)
-		b.mu.Unlock()
-		if pc != nil {
-			pc.Close()
-		}
+		logger.Error("❌ Failed to add audio track", "error", err)
+		b.RejectCall(tenant, callID, "failed to add audio track")
 		return
 	}
 	
 	// Read incoming RTCP packets
-	go func() {
-		rtcpBuf := make([]byte, 1500)
-		for {
-			if _, _, rtcpErr := rtpSender.Read(rtcpBuf); rtcpErr != nil {
-				return
-			}
-		}
-	}()
+	go readRTCP(rtpSender, b.senderRTCP(call, whatsappCaptureLeg))
 	
 	// Store the track in the call
 	call.AudioTrack = audioTrack
 	
 	// Log track details
-	log.Printf("✅ Added audio track with Opus codec for bidirectional audio")
+	logger.Debug("✅ Added audio track with Opus codec for bidirectional audio")
 	
 	// Create answer
 	answer, err := pc.CreateAnswer(nil)
 	if err != nil {
-		log.Printf("❌ Failed to create answer: %v", err)
-		if pc != nil {
-			pc.Close()
-		}
-		b.mu.Lock()
-		delete(b.activeCalls, callID)
-		b.mu.Unlock()
+		logger.Error("❌ Failed to create answer", "error", err)
+		b.RejectCall(tenant, callID, "failed to create SDP answer")
 		return
 	}
 	
 	// Set local description
 	if err := pc.SetLocalDescription(answer); err != nil {
-		log.Printf("❌ Failed to set local description: %v", err)
-		if pc != nil {
-			pc.Close()
-		}
-		b.mu.Lock()
-		delete(b.activeCalls, callID)
-		b.mu.Unlock()
+		logger.Error("❌ Failed to set local description", "error", err)
+		b.RejectCall(tenant, callID, "failed to set local description")
 		return
 	}
 	
-	// Log the final SDP that will be sent
-	log.Printf("📄 Final SDP Answer being sent to WhatsApp (first 500 chars):")
-	if len(answer.SDP) > 500 {
-		log.Printf("%s...", answer.SDP[:500])
-	} else {
-		log.Printf("%s", answer.SDP)
-	}
-	
 	// Check if it contains sendrecv
 	if strings.Contains(answer.SDP, "a=sendrecv") {
-		log.Printf("✅ SDP contains sendrecv - bidirectional audio enabled")
+		logger.Debug("✅ SDP contains sendrecv - bidirectional audio enabled")
 	} else if strings.Contains(answer.SDP, "a=recvonly") {
-		log.Printf("❌ WARNING: SDP contains recvonly - only receive audio!")
+		logger.Warn("❌ SDP answer contains recvonly - only receive audio!")
 	} else if strings.Contains(answer.SDP, "a=sendonly") {
-		log.Printf("⚠️ WARNING: SDP contains sendonly - only send audio!")
+		logger.Warn("⚠️ SDP answer contains sendonly - only send audio!")
 	} else {
-		log.Printf("⚠️ WARNING: No explicit direction attribute found in SDP")
-		// If no direction is specified, sendrecv is the default, so add it explicitly
-		log.Printf("📝 Note: When no direction is specified, sendrecv is the default behavior")
+		// If no direction is specified, sendrecv is the default
+		logger.Info("⚠️ No explicit direction attribute found in SDP answer; sendrecv is the default")
 	}
 	
 	// Also check for audio media line
@@ -1137,12 +1207,12 @@ func (b *WhatsAppBridge) acceptIncomingCall(callID, sdpOffer, callerNumber strin
 		lines := strings.Split(answer.SDP, "\n")
 		for _, line := range lines {
 			if strings.HasPrefix(line, "m=audio") {
-				log.Printf("📊 Audio media line: %s", strings.TrimSpace(line))
+				logger.Debug("📊 Audio media line", "line", strings.TrimSpace(line))
 				break
 			}
 		}
 	} else {
-		log.Printf("❌ WARNING: No audio media line in SDP!")
+		logger.Warn("❌ No audio media line in SDP answer!")
 	}
 	
 	// Wait for ICE gathering to complete
@@ -1151,74 +1221,68 @@ func (b *WhatsAppBridge) acceptIncomingCall(callID, sdpOffer, callerNumber strin
 	// Wait up to 3 seconds for gathering
 	select {
 	case <-gatherComplete:
-		log.Printf("✅ ICE gathering complete for call %s", callID)
+		logger.Debug("✅ ICE gathering complete")
 	case <-time.After(3 * time.Second):
-		log.Printf("⏱️ ICE gathering timeout for call %s", callID)
+		logger.Warn("⏱️ ICE gathering timeout")
 	}
 	
 	// Get the local description with candidates
 	localDesc := pc.LocalDescription()
 	if localDesc != nil {
-		log.Printf("📄 SDP Answer with candidates:\n%s", localDesc.SDP)
+		logger.Debug("📄 SDP Answer with candidates", "sdp", localDesc.SDP)
 		answer.SDP = localDesc.SDP
 	} else {
-		log.Printf("📄 SDP Answer (no additional candidates):\n%s", answer.SDP)
+		logger.Debug("📄 SDP Answer (no additional candidates)", "sdp", answer.SDP)
 	}
 	
 	// The call is already stored with peer connection
 	
 	// Send pre-accept to WhatsApp API first to establish WebRTC connection
-	log.Printf("📞 Sending pre-accept for call %s", callID)
-	log.Printf("📄 First 200 chars of answer SDP: %.200s...", answer.SDP)
-	if err := b.sendPreAcceptCall(callID, answer.SDP); err != nil {
-		log.Printf("❌ Failed to pre-accept call: %v", err)
-		b.mu.Lock()
-		if pc != nil {
-			pc.Close()
-		}
-		delete(b.activeCalls, callID)
-		b.mu.Unlock()
+	if call.ended() {
+		logger.Info("☎️ Call ended while being set up - not pre-accepting it")
+		return
+	}
+	logger.Info("📞 Sending pre-accept")
+	if err := b.sendPreAcceptCall(tenant, callID, answer.SDP); err != nil {
+		logger.Error("❌ Failed to pre-accept call", "error", err)
+		b.RejectCall(tenant, callID, "pre-accept failed")
 		return
 	}
+	b.setCallState(call, CallStatePreAccepted)
 	
 	// According to WhatsApp diagram, we should send accept immediately
 	// The connection becomes active on first packet OR accept
-	log.Printf("📞 Sending accept immediately after pre-accept for call %s", callID)
-	if err := b.sendAcceptCall(callID, answer.SDP); err != nil {
-		log.Printf("❌ Failed to accept call: %v", err)
-		b.mu.Lock()
-		if pc != nil {
-			pc.Close()
-		}
-		delete(b.activeCalls, callID)
-		b.mu.Unlock()
+	if call.ended() {
+		logger.Info("☎️ Call ended while being set up - not accepting it")
+		return
+	}
+	logger.Info("📞 Sending accept immediately after pre-accept")
+	if err := b.sendAcceptCall(tenant, callID, answer.SDP); err != nil {
+		logger.Error("❌ Failed to accept call", "error", err)
+		// WhatsApp already has our pre-accept, so tell it we're not taking the call
+		b.RejectCall(tenant, callID, "accept failed")
 		return
 	}
 	
-	log.Printf("✅ Call accepted: %s from %s", callID, callerNumber)
+	logger.Info("✅ Call accepted", "from", callerNumber)
+	b.setCallState(call, CallStateAccepted)
 	
 	// Log the current state
 	connectionState := pc.ConnectionState()
 	iceState := pc.ICEConnectionState()
-	log.Printf("📊 Connection states - PC: %s, ICE: %s", connectionState.String(), iceState.String())
+	logger.Debug("📊 Connection states", "pc", connectionState.String(), "ice", iceState.String())
 	
-	// OpenAI will send audio, so we don't need continuous silence anymore
-	log.Printf("🎧 Waiting for OpenAI to send audio...")
-	
-	// Now that the call is accepted, start media flow
-	// Connect to Azure OpenAI Realtime API if configured
-	azureKey := os.Getenv("AZURE_OPENAI_API_KEY")
-
-	if azureKey != "" {
-		log.Printf("🔵 Azure OpenAI API key found, starting Azure AI integration...")
-		// Start Azure integration only after accept succeeds
+	// Now that the call is accepted, start media flow with the tenant's voice agent
+	if backend := voiceAgentBackend(tenant, ""); backend != "" {
+		logger.Info("🤖 Starting voice agent...", "backend", backend)
+		// Start the agent only after accept succeeds
 		go func() {
 			// Small delay to ensure everything is ready
 			time.Sleep(500 * time.Millisecond)
-			b.connectToOpenAIRealtime(callID, pc, azureKey, callerNumber, "") // No reminder for inbound calls
+			b.connectVoiceAgent(call, backend)
 		}()
 	} else {
-		log.Printf("⚠️ AZURE_OPENAI_API_KEY not set - no AI agent will respond")
+		logger.Warn("⚠️ No voice agent configured - no AI agent will respond")
 		// Play a welcome message only after accept succeeds
 		go func() {
 			// Small delay to ensure media channel is ready
@@ -1229,31 +1293,45 @@ func (b *WhatsAppBridge) acceptIncomingCall(callID, sdpOffer, callerNumber strin
 }
 
 // sendPreAcceptCall sends pre-accept to WhatsApp API
-func (b *WhatsAppBridge) sendPreAcceptCall(callID, sdpAnswer string) error {
-	return b.callWhatsAppAPI("pre_accept", callID, sdpAnswer)
+func (b *WhatsAppBridge) sendPreAcceptCall(tenant *Tenant, callID, sdpAnswer string) error {
+	return b.callWhatsAppAPI(tenant, "pre_accept", callID, sdpAnswer)
 }
 
 // sendAcceptCall sends accept to WhatsApp API
-func (b *WhatsAppBridge) sendAcceptCall(callID, sdpAnswer string) error {
-	return b.callWhatsAppAPI("accept", callID, sdpAnswer)
+func (b *WhatsAppBridge) sendAcceptCall(tenant *Tenant, callID, sdpAnswer string) error {
+	return b.callWhatsAppAPI(tenant, "accept", callID, sdpAnswer)
+}
+
+// sendTerminateCall asks WhatsApp to hang up an active call
+func (b *WhatsAppBridge) sendTerminateCall(tenant *Tenant, callID string) error {
+	return b.callWhatsAppAPI(tenant, "terminate", callID, "")
+}
+
+// sendRejectCall declines an inbound call that hasn't been accepted
+func (b *WhatsAppBridge) sendRejectCall(tenant *Tenant, callID string) error {
+	return b.callWhatsAppAPI(tenant, "reject", callID, "")
 }
 
-// callWhatsAppAPI makes API calls to WhatsApp
-func (b *WhatsAppBridge) callWhatsAppAPI(action, callID, sdpAnswer string) error {
-	if b.accessToken == "" || b.phoneNumberID == "" {
-		return fmt.Errorf("WhatsApp credentials not configured")
+// callWhatsAppAPI makes API calls to WhatsApp on behalf of a tenant
+func (b *WhatsAppBridge) callWhatsAppAPI(tenant *Tenant, action, callID, sdpAnswer string) error {
+	if tenant.AccessToken == "" || tenant.PhoneNumberID == "" {
+		return fmt.Errorf("WhatsApp credentials not configured for tenant %s", tenant.Name)
 	}
 	
-	url := fmt.Sprintf("https://graph.facebook.com/v21.0/%s/calls", b.phoneNumberID)
+	url := graphURL(graphAPIVersion, tenant.PhoneNumberID, "calls")
 	
 	payload := map[string]interface{}{
 		"messaging_product": "whatsapp",
 		"call_id":          callID,
 		"action":           action,
-		"session": map[string]string{
+	}
+	
+	// terminate and reject carry no session
+	if sdpAnswer != "" {
+		payload["session"] = map[string]string{
 			"sdp_type": "answer",
 			"sdp":      sdpAnswer,
-		},
+		}
 	}
 	
 	if action == "accept" {
@@ -1265,8 +1343,9 @@ func (b *WhatsAppBridge) callWhatsAppAPI(action, callID, sdpAnswer string) error
 		return err
 	}
 	
-	log.Printf("📤 WhatsApp API %s request to %s:", action, url)
-	log.Printf("📤 Payload: %s", string(jsonData))
+	logger := callLogger(callID).With("action", action)
+	logger.Info("📤 WhatsApp calls API request", "url", url)
+	logger.Debug("📤 Payload", "body", string(jsonData))
 	
 	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
 	if err != nil {
@@ -1274,7 +1353,7 @@ func (b *WhatsAppBridge) callWhatsAppAPI(action, callID, sdpAnswer string) error
 	}
 	
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+b.accessToken)
+	req.Header.Set("Authorization", "Bearer "+tenant.AccessToken)
 	
 	client := &http.Client{Timeout: 10 * time.Second}
 	resp, err := client.Do(req)
@@ -1285,7 +1364,8 @@ func (b *WhatsAppBridge) callWhatsAppAPI(action, callID, sdpAnswer string) error
 	
 	body, _ := io.ReadAll(resp.Body)
 	
-	log.Printf("📡 WhatsApp API %s response: Status=%d, Body=%s", action, resp.StatusCode, string(body))
+	logger.Info("📡 WhatsApp calls API response", "status", resp.StatusCode)
+	logger.Debug("📡 WhatsApp calls API response body", "body", string(body))
 	
 	if resp.StatusCode != http.StatusOK {
 		return fmt.Errorf("WhatsApp API error: %s - %s", resp.Status, string(body))
@@ -1295,155 +1375,163 @@ func (b *WhatsAppBridge) callWhatsAppAPI(action, callID, sdpAnswer string) error
 	var apiResp map[string]interface{}
 	if err := json.Unmarshal(body, &apiResp); err == nil {
 		if success, ok := apiResp["success"].(bool); ok && success {
-			log.Printf("✅ WhatsApp API %s successful for call %s", action, callID)
+			logger.Info("✅ WhatsApp calls API request successful")
 		} else {
-			log.Printf("⚠️ WhatsApp API %s response doesn't confirm success: %v", action, apiResp)
+			logger.Warn("⚠️ WhatsApp calls API response doesn't confirm success", "response", apiResp)
 		}
 	}
 	
 	return nil
 }
 
-// connectToOpenAIRealtime connects the WhatsApp call to OpenAI's Realtime API
-func (b *WhatsAppBridge) connectToOpenAIRealtime(callID string, whatsappPC *webrtc.PeerConnection, apiKey string, phoneNumber string, reminderText string) {
-	log.Printf("🤖 Connecting call %s to OpenAI Realtime API (caller: %s)", callID, phoneNumber)
-
-	// Create OpenAI client with phone number for task context and optional reminder
-	openAIClient := NewOpenAIRealtimeClient(apiKey, phoneNumber, reminderText)
-	
-	// Get ephemeral token
-	if err := openAIClient.GetEphemeralToken(); err != nil {
-		log.Printf("❌ Failed to get OpenAI token: %v", err)
+// connectVoiceAgent starts a session on the named voice backend and bridges
+// it to the WhatsApp call in both directions
+func (b *WhatsAppBridge) connectVoiceAgent(call *Call, backend string) {
+	callID := call.ID
+	logger := callLogger(callID).With("backend", backend)
+	logger.Info("🤖 Connecting call to voice agent", "peer", call.Peer())
+
+	// Create the session with phone number for task context, tenant persona and optional reminder
+	agent, err := newVoiceAgent(backend, VoiceAgentConfig{
+		Tenant:       call.Tenant,
+		CallID:       callID,
+		PhoneNumber:  call.Peer(),
+		ReminderText: call.ReminderText,
+		Recorded:     call.shouldRecord(),
+		API:          b.api,
+		OnEndCall: func(reason string) {
+			if err := b.HangupCall(callID, reason); err != nil {
+				logger.Error("❌ Voice agent failed to hang up call", "error", err)
+			}
+		},
+		OnSenderRTCP:   b.senderRTCP(call, agentCaptureLeg),
+		OnReceiverRTCP: b.receiverRTCP(call, agentCaptureLeg),
+	})
+	if err != nil {
+		logger.Error("❌ Failed to create voice agent", "error", err)
 		return
 	}
-	
-	// Connect to OpenAI Realtime API
-	if err := openAIClient.ConnectToRealtimeAPI(b.api); err != nil {
-		log.Printf("❌ Failed to connect to OpenAI: %v", err)
+
+	if err := agent.Connect(); err != nil {
+		logger.Error("❌ Voice agent failed to connect", "error", err)
+		agent.Close()
 		return
 	}
 	
-	// Store OpenAI client in call
+	// Attach the agent to the call. If the call ended while we were connecting,
+	// nothing else will close this session, so close it here.
 	b.mu.Lock()
-	if call, exists := b.activeCalls[callID]; exists {
-		call.OpenAIClient = openAIClient
+	_, exists := b.activeCalls[callID]
+	if exists {
+		call.Agent = agent
 	}
+	whatsappTrack := call.AudioTrack
 	b.mu.Unlock()
-	
-	// Get the audio track we already created
-	b.mu.Lock()
-	call, exists := b.activeCalls[callID]
-	var whatsappAudioTrack *webrtc.TrackLocalStaticRTP
-	if exists && call != nil {
-		whatsappAudioTrack = call.AudioTrack
+	if !exists {
+		logger.Info("☎️ Call ended before the voice agent connected - closing it")
+		agent.Close()
+		return
 	}
-	b.mu.Unlock()
 	
-	if whatsappAudioTrack == nil {
-		log.Printf("❌ No audio track found on WhatsApp connection")
+	if whatsappTrack == nil {
+		logger.Error("❌ No audio track found on WhatsApp connection")
 		return
 	}
 	
-	log.Printf("✅ Using existing audio track for OpenAI->WhatsApp audio")
-	
-	// Wait a moment for connections to stabilize
-	time.Sleep(500 * time.Millisecond)
+	logger.Debug("✅ Using existing audio track for agent->WhatsApp audio")
 	
-	// Set up bidirectional audio forwarding between WhatsApp and OpenAI
-	log.Printf("🔄 Setting up bidirectional audio forwarding (v3 - no test packets)")
-	log.Printf("📊 Call %s: WhatsApp → OpenAI (RTP forwarding)", callID)
-	log.Printf("📊 Call %s: OpenAI → WhatsApp (waiting for track)", callID)
-	
-	// Forward audio from OpenAI to WhatsApp
+	// Forward audio from the agent to WhatsApp
 	go func() {
-		// Wait for OpenAI's remote audio track
-		log.Printf("⏳ Waiting for OpenAI audio track...")
-		var openAITrack *webrtc.TrackRemote
+		// Wait for the agent's audio output
+		logger.Debug("⏳ Waiting for voice agent audio...")
+		var agentAudio RTPSource
 		for i := 0; i < 100; i++ { // Wait up to 10 seconds
-			if track := openAIClient.GetRemoteAudioTrack(); track != nil {
-				openAITrack = track
+			if agentAudio = agent.AudioOutput(); agentAudio != nil {
 				break
 			}
 			time.Sleep(100 * time.Millisecond)
 		}
 		
-		if openAITrack == nil {
-			log.Printf("❌ OpenAI audio track not available after 10 seconds")
+		if agentAudio == nil {
+			logger.Error("❌ Voice agent audio not available after 10 seconds")
 			return
 		}
 		
-		log.Printf("🔊 Starting OpenAI → WhatsApp audio forwarding")
-		
-		// Get WhatsApp audio track
-		b.mu.Lock()
-		activeCall, exists := b.activeCalls[callID]
-		var whatsappTrack *webrtc.TrackLocalStaticRTP
-		if exists && activeCall != nil {
-			whatsappTrack = activeCall.AudioTrack
-		}
-		b.mu.Unlock()
-		
-		if whatsappTrack == nil {
-			log.Printf("❌ No WhatsApp track for audio forwarding")
+		logger.Info("🔊 Starting agent → WhatsApp audio forwarding")
+		b.forwardAgentAudio(call, agentAudio, whatsappTrack)
+	}()
+	
+	// The OnTrack handlers forward the user's audio once the agent is attached
+	logger.Info("✅ Voice agent connected")
+}
+
+// forwardAgentAudio forwards the voice agent's audio to WhatsApp until either
+// side closes
+func (b *WhatsAppBridge) forwardAgentAudio(call *Call, agentAudio RTPSource, whatsappTrack io.Writer) {
+	logger := callLogger(call.ID)
+	packetCount := 0
+	lastLogTime := time.Now()
+
+	for {
+		// Read the full RTP packet (not just payload)
+		rtpPacket, readErr := agentAudio.ReadRTP()
+		if readErr != nil {
+			logger.Info("❌ Error reading voice agent RTP", "error", readErr)
 			return
 		}
-		
-		// Forward RTP packets from OpenAI to WhatsApp
-		packetCount := 0
-		lastLogTime := time.Now()
-
-		for {
-			// Read the full RTP packet (not just payload)
-			rtpPacket, _, readErr := openAITrack.ReadRTP()
-			if readErr != nil {
-				log.Printf("❌ Error reading OpenAI RTP: %v", readErr)
-				return
+
+		// Capture the packet as the agent sent it, extension headers included
+		capture := b.callCapture(call)
+		if capture != nil {
+			if raw, err := rtpPacket.Marshal(); err == nil {
+				capture.WriteRTP(CaptureAgentIn, raw)
 			}
+		}
 
-			// v4 FIX: Clear extension headers before forwarding to WhatsApp
-			// Prevents conflicts with WhatsApp's extension header IDs
-			rtpPacket.Extension = false
-			rtpPacket.Extensions = nil
+		// v4 FIX: Clear extension headers before forwarding to WhatsApp
+		// Prevents conflicts with WhatsApp's extension header IDs
+		rtpPacket.Extension = false
+		rtpPacket.Extensions = nil
 
-			// Log first few packets for debugging
-			if packetCount < 3 {
-				log.Printf("🔍 OpenAI RTP packet %d: PayloadType=%d, SequenceNumber=%d, Timestamp=%d, PayloadSize=%d",
-					packetCount, rtpPacket.PayloadType, rtpPacket.SequenceNumber, rtpPacket.Timestamp, len(rtpPacket.Payload))
-			}
+		// Log first few packets for debugging
+		if packetCount < 3 {
+			logger.Debug("🔍 Agent RTP packet", "packet", packetCount, "payload_type", rtpPacket.PayloadType,
+				"sequence_number", rtpPacket.SequenceNumber, "timestamp", rtpPacket.Timestamp, "payload_size", len(rtpPacket.Payload))
+		}
 
-			// Marshal the RTP packet to bytes
-			rtpBytes, marshalErr := rtpPacket.Marshal()
-			if marshalErr != nil {
-				log.Printf("❌ Error marshaling RTP packet: %v", marshalErr)
-				continue
-			}
+		// Marshal the RTP packet to bytes
+		rtpBytes, marshalErr := rtpPacket.Marshal()
+		if marshalErr != nil {
+			logger.Error("❌ Error marshaling RTP packet", "error", marshalErr)
+			continue
+		}
 
-			// Write the complete RTP packet to WhatsApp
-			bytesWritten, writeErr := whatsappTrack.Write(rtpBytes)
-			if writeErr != nil {
-				log.Printf("❌ Error forwarding to WhatsApp (packet %d): %v", packetCount, writeErr)
-				return
-			}
+		// Write the complete RTP packet to WhatsApp
+		if capture != nil {
+			capture.WriteRTP(CaptureWhatsAppOut, rtpBytes)
+		}
+		bytesWritten, writeErr := whatsappTrack.Write(rtpBytes)
+		if writeErr != nil {
+			logger.Error("❌ Error forwarding to WhatsApp", "packet", packetCount, "error", writeErr)
+			return
+		}
 
-			if packetCount < 3 {
-				log.Printf("✅ Wrote %d bytes to WhatsApp track", bytesWritten)
-			}
+		if packetCount < 3 {
+			logger.Debug("✅ Wrote to WhatsApp track", "bytes", bytesWritten)
+		}
+		call.Lifecycle.countSent(bytesWritten)
+		if recorder := b.callRecorder(call); recorder != nil {
+			recorder.WriteAgent(rtpPacket)
+		}
 
-			packetCount++
-			if packetCount == 1 {
-				log.Printf("✅ First OpenAI audio packet forwarded to WhatsApp!")
-			} else if time.Since(lastLogTime) > 5*time.Second {
-				log.Printf("📦 Forwarded %d OpenAI audio packets to WhatsApp", packetCount)
-				lastLogTime = time.Now()
-			}
+		packetCount++
+		if packetCount == 1 {
+			logger.Info("✅ First voice agent audio packet forwarded to WhatsApp!")
+		} else if time.Since(lastLogTime) > 5*time.Second {
+			logger.Debug("📦 Forwarded voice agent audio packets to WhatsApp", "packets", packetCount)
+			lastLogTime = time.Now()
 		}
-	}()
-	
-	// The OnTrack handler is already set up in acceptIncomingCall
-	// It will start forwarding audio once OpenAI client is stored
-	log.Printf("🎧 Audio forwarding will start when WhatsApp track arrives")
-	
-	log.Printf("✅ OpenAI Realtime connection established for call %s", callID)
+	}
 }
 
 // playWelcomeMessage plays a welcome message or tone
@@ -1454,7 +1542,7 @@ func (b *WhatsAppBridge) playWelcomeMessage(pc *webrtc.PeerConnection) {
 	// 3. Send it through the peer connection
 	
 	// For now, just log that we would play audio
-	log.Println("🎵 Would play welcome message to caller")
+	slog.Debug("🎵 Would play welcome message to caller")
 	
 	// Example of how to add an audio track:
 	// track, err := webrtc.NewTrackLocalStaticRTP(webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypePCMA}, "audio", "pion")
@@ -1522,15 +1610,15 @@ func (b *WhatsAppBridge) processIncomingSDP(offerSDP string) (string, error) {
 	
 	// Set up handlers
 	pc.OnICEConnectionStateChange(func(state webrtc.ICEConnectionState) {
-		log.Printf("ICE Connection State: %s", state.String())
+		slog.Info("🧊 Test call ICE connection state", "state", state.String())
 		// v4: Handle explicit DTLS close
 		if state == webrtc.ICEConnectionStateClosed {
-			log.Printf("🔴 Test call: ICE connection explicitly closed")
+			slog.Info("🔴 Test call: ICE connection explicitly closed")
 		}
 	})
 	
 	pc.OnTrack(func(track *webrtc.TrackRemote, receiver *webrtc.RTPReceiver) {
-		log.Printf("📞 Received audio track: %s", track.ID())
+		slog.Info("📞 Test call received audio track", "track", track.ID())
 		
 		// Read RTP packets to detect audio
 		go func() {
@@ -1541,7 +1629,7 @@ func (b *WhatsAppBridge) processIncomingSDP(offerSDP string) (string, error) {
 					return
 				}
 				// Audio detected - in real implementation, we'd process it
-				log.Println("🔊 Audio packet received")
+				slog.Debug("🔊 Test call audio packet received")
 			}
 		}()
 	})
@@ -1569,26 +1657,16 @@ func (b *WhatsAppBridge) processIncomingSDP(offerSDP string) (string, error) {
 	
 	// Store the call
 	callID := fmt.Sprintf("call_%d", time.Now().Unix())
+	call := newCall(callID, nil)
+	call.PeerConnection = pc
 	b.mu.Lock()
-	b.activeCalls[callID] = &Call{
-		ID:             callID,
-		PeerConnection: pc,
-		StartTime:      time.Now(),
-	}
+	b.activeCalls[callID] = call
 	b.mu.Unlock()
 	
 	// Clean up after timeout
-	go func() {
-		time.Sleep(5 * time.Minute)
-		b.mu.Lock()
-		if call, exists := b.activeCalls[callID]; exists {
-			if call.PeerConnection != nil {
-				call.PeerConnection.Close()
-			}
-			delete(b.activeCalls, callID)
-		}
-		b.mu.Unlock()
-	}()
+	time.AfterFunc(5*time.Minute, func() {
+		b.endCall(call, "test call expired")
+	})
 	
 	return answer.SDP, nil
 }
@@ -1616,6 +1694,19 @@ func (b *WhatsAppBridge) handleStatus(w http.ResponseWriter, r *http.Request) {
 	b.mu.Lock()
 	activeCallCount := len(b.activeCalls)
 	b.mu.Unlock()
+	activeCallStates, recentCalls := b.callStatus()
+
+	var tenantStatus []map[string]interface{}
+	for _, tenant := range b.tenants.All() {
+		tenantStatus = append(tenantStatus, map[string]interface{}{
+			"name":                tenant.Name,
+			"phone_number_id":     tenant.PhoneNumberID,
+			"whatsapp_token_set":  tenant.AccessToken != "",
+			"verify_token_set":    tenant.VerifyToken != "",
+			"persona_set":         tenant.Persona != "",
+			"supabase_schema":     tenant.SupabaseSchema,
+		})
+	}
 	
 	status := map[string]interface{}{
 		"status":       "running",
@@ -1626,15 +1717,30 @@ func (b *WhatsAppBridge) handleStatus(w http.ResponseWriter, r *http.Request) {
 		"webhook_ready": true,
 		"echo_enabled": os.Getenv("ENABLE_ECHO") == "true",
 		"environment": map[string]bool{
-			"whatsapp_token_set": b.accessToken != "",
-			"phone_number_id_set": b.phoneNumberID != "",
-			"verify_token_set": b.verifyToken != "",
+			"app_secret_set": b.appSecret != "",
+		},
+		"tenants": tenantStatus,
+		"outbound_calls": b.outboundCallStatus(),
+		"calls":          activeCallStates,
+		"recent_calls":   recentCalls,
+		"call_limits": map[string]string{
+			"setup_timeout":        b.callLimits.SetupTimeout.String(),
+			"no_media_timeout":     b.callLimits.NoMediaTimeout.String(),
+			"ice_disconnect_grace": b.callLimits.ICEDisconnectGrace.String(),
+			"max_duration":         b.callLimits.MaxDuration.String(),
 		},
 		"codec_support": []string{
 			"opus/48000/2 (PT:111)",
 			"telephone-event/8000 (PT:126)",
 		},
 		"webhook_endpoint": "/whatsapp-call",
+		"webhook_security": map[string]interface{}{
+			"signature_verification": !b.skipSignature,
+			"rejected_webhooks":      b.rejectedWebhooks.Load(),
+			"duplicate_events":       b.duplicateEvents.Load(),
+		},
+		"webhook_queue": b.queue.Stats(),
+		"webhook_archive_enabled": b.archive != nil,
 		"railway_url": os.Getenv("RAILWAY_PUBLIC_DOMAIN"),
 	}
 	
@@ -1650,34 +1756,44 @@ func (b *WhatsAppBridge) handleHealth(w http.ResponseWriter, r *http.Request) {
 
 // handleRequestCallPermission sends a permission request message to a user
 func (b *WhatsAppBridge) handleRequestCallPermission(w http.ResponseWriter, r *http.Request) {
-	log.Printf("📞 Received request-call-permission request from %s", r.RemoteAddr)
+	logger := loggerFrom(r.Context())
+	logger.Info("📞 Received request-call-permission request", "remote_addr", r.RemoteAddr)
 
 	var req struct {
-		To string `json:"to"` // Phone number to request permission from (without +)
+		To            string `json:"to"`              // Phone number to request permission from (without +)
+		PhoneNumberID string `json:"phone_number_id"` // Optional: tenant to send from (defaults to the first tenant)
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		log.Printf("❌ Failed to decode request body: %v", err)
+		logger.Warn("❌ Failed to decode request body", "error", err)
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
 	if req.To == "" {
-		log.Printf("❌ Phone number not provided in request")
+		logger.Warn("❌ Phone number not provided in request")
 		http.Error(w, "Phone number required", http.StatusBadRequest)
 		return
 	}
 
-	log.Printf("📤 Requesting call permission from %s", req.To)
+	tenant := b.tenants.Resolve(req.PhoneNumberID)
+	if tenant == nil {
+		logger.Warn("❌ Unknown phone_number_id", "phone_number_id", req.PhoneNumberID)
+		http.Error(w, "Unknown phone_number_id", http.StatusBadRequest)
+		return
+	}
+
+	logger = logger.With("to", req.To, "tenant", tenant.Name)
+	logger.Info("📤 Requesting call permission")
 
 	// Send permission request message
-	if err := SendCallPermissionRequest(req.To); err != nil {
+	if err := SendCallPermissionRequest(withLogger(r.Context(), logger), tenant, req.To); err != nil {
 		if err.Error() == "rate limited" {
-			log.Printf("🚫 Rate limited: %s", req.To)
+			logger.Warn("🚫 Rate limited")
 			http.Error(w, "Rate limited. You can only send 1 request per 24 hours, 2 per 7 days.", http.StatusTooManyRequests)
 			return
 		}
-		log.Printf("❌ Failed to send permission request: %v", err)
+		logger.Error("❌ Failed to send permission request", "error", err)
 		http.Error(w, fmt.Sprintf("Failed to send permission request: %v", err), http.StatusInternalServerError)
 		return
 	}
@@ -1689,53 +1805,125 @@ func (b *WhatsAppBridge) handleRequestCallPermission(w http.ResponseWriter, r *h
 		"to":      req.To,
 	})
 
-	log.Printf("✅ Successfully sent call permission request to %s", req.To)
+	logger.Info("✅ Successfully sent call permission request")
 }
 
 // handleInitiateCall initiates an outbound call to a WhatsApp user
 func (b *WhatsAppBridge) handleInitiateCall(w http.ResponseWriter, r *http.Request) {
-	log.Printf("📞 Received initiate-call request from %s", r.RemoteAddr)
+	logger := loggerFrom(r.Context())
+	logger.Info("📞 Received initiate-call request", "remote_addr", r.RemoteAddr)
 
 	var req struct {
-		To           string `json:"to"`            // Phone number to call (without +)
-		ReminderID   string `json:"reminder_id"`   // Optional: ID of reminder if this is a reminder call
-		ReminderText string `json:"reminder_text"` // Optional: What to remind about
+		To            string `json:"to"`              // Phone number to call (without +)
+		PhoneNumberID string `json:"phone_number_id"` // Optional: tenant to call from (defaults to the first tenant)
+		ReminderID    string `json:"reminder_id"`     // Optional: ID of reminder if this is a reminder call
+		ReminderText  string `json:"reminder_text"`   // Optional: What to remind about
+		VoiceAgent    string `json:"voice_agent"`     // Optional: voice backend for this call (defaults to the tenant's)
+		Record        *bool  `json:"record"`          // Optional: whether the recipient agreed to recording (defaults to the tenant's implied_consent)
+		CaptureRTP    bool   `json:"capture_rtp"`     // Optional: capture this call's packets even if the tenant doesn't capture_rtp
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		log.Printf("❌ Failed to decode request body: %v", err)
+		logger.Warn("❌ Failed to decode request body", "error", err)
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
+	if err := validateVoiceAgent(req.VoiceAgent); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	if req.To == "" {
-		log.Printf("❌ Phone number not provided in request")
+		logger.Warn("❌ Phone number not provided in request")
 		http.Error(w, "Phone number required", http.StatusBadRequest)
 		return
 	}
 
+	tenant := b.tenants.Resolve(req.PhoneNumberID)
+	if tenant == nil {
+		logger.Warn("❌ Unknown phone_number_id", "phone_number_id", req.PhoneNumberID)
+		http.Error(w, "Unknown phone_number_id", http.StatusBadRequest)
+		return
+	}
+
+	logger.Info("📞 Initiating outbound call", "to", req.To, "tenant", tenant.Name)
+
+	// The tenant's record_calls gates recording; a request may record with the
+	// recipient's explicit agreement or opt out of implied consent
+	recordingConsent := tenant.impliesRecordingConsent()
+	if req.Record != nil {
+		recordingConsent = tenant.RecordCalls && *req.Record
+	}
+
+	callID, err := b.startOutboundCall(r.Context(), tenant, req.To, req.ReminderID, req.ReminderText, req.VoiceAgent, recordingConsent, req.CaptureRTP)
+	if errors.Is(err, errNoCallPermission) {
+		http.Error(w, "No call permission from recipient. They must call you first to grant permission.", http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		logger.Error("❌ Failed to initiate call", "error", err)
+		http.Error(w, fmt.Sprintf("Failed to initiate call: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	// Respond with call ID
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"call_id": callID,
+		"status":  string(OutboundStateRinging),
+		"to":      req.To,
+	})
+}
+
+// errNoCallPermission is returned by startOutboundCall when the user hasn't
+// granted the business permission to call them
+var errNoCallPermission = errors.New("no call permission from recipient")
+
+// ICE gathering for an outbound offer gives up after this long and sends the
+// candidates found so far
+const outboundICEGatherTimeout = 3 * time.Second
+
+// startOutboundCall places a business-initiated call and registers it with the
+// outbound state machine. All WebRTC handlers are wired before the offer is
+// created so nothing that happens after the user answers can be missed.
+// Every outbound call goes through here so none skips the permission check.
+// ctx only carries the logger; the call outlives it.
+func (b *WhatsAppBridge) startOutboundCall(ctx context.Context, tenant *Tenant, to, reminderID, reminderText, voiceAgent string, recordingConsent, captureRTP bool) (string, error) {
+	logger := loggerFrom(ctx).With("to", to)
+
 	// Check if we have permission to call this number
-	permission, err := CheckCallPermission(req.To)
+	permission, err := CheckCallPermission(tenant.SupabaseSchema, to)
 	if err != nil {
-		log.Printf("⚠️ Error checking call permission for %s: %v", req.To, err)
+		logger.Warn("⚠️ Error checking call permission", "error", err)
 		// Continue anyway - if Supabase is down, we don't want to block calls
 	} else if permission == nil {
-		log.Printf("🚫 No call permission for %s - user has not called us first", req.To)
-		http.Error(w, "No call permission from recipient. They must call you first to grant permission.", http.StatusForbidden)
-		return
+		logger.Info("🚫 No call permission - user has not called us first")
+		return "", errNoCallPermission
 	} else {
-		log.Printf("✅ Call permission verified for %s (granted on %s)", req.To, permission.FirstInboundCallAt)
+		logger.Info("✅ Call permission verified", "granted_at", permission.FirstInboundCallAt)
 	}
 
-	log.Printf("📞 Initiating outbound call to %s", req.To)
-
 	// Create WebRTC peer connection
 	pc, err := b.api.NewPeerConnection(b.config)
 	if err != nil {
-		log.Printf("❌ Failed to create peer connection: %v", err)
-		http.Error(w, "Failed to create connection", http.StatusInternalServerError)
-		return
-	}
+		return "", fmt.Errorf("failed to create peer connection: %w", err)
+	}
+
+	// The call ID is only known once the Graph API answers, so it starts empty
+	call := newCall("", tenant)
+	call.PeerConnection = pc
+	call.ReminderID = reminderID
+	call.ReminderText = reminderText
+	call.RecordingConsent = recordingConsent
+	call.CaptureRTP = call.CaptureRTP || captureRTP
+	call.Outbound = NewOutboundCall(to)
+
+	// Give up if the offer can't be placed in time
+	call.Outbound.armTimeout(OutboundStateOffering, func() {
+		logger.Warn("⏱️ Outbound call timed out while offering")
+		b.endOutboundCall(call, "timeout in offering")
+	})
 
 	// Create audio track for sending audio to WhatsApp user
 	audioTrack, err := webrtc.NewTrackLocalStaticRTP(
@@ -1748,201 +1936,233 @@ func (b *WhatsAppBridge) handleInitiateCall(w http.ResponseWriter, r *http.Reque
 		"pion-stream",
 	)
 	if err != nil {
-		log.Printf("❌ Failed to create audio track: %v", err)
-		http.Error(w, "Failed to create audio track", http.StatusInternalServerError)
-		return
+		b.endOutboundCall(call, "failed to create audio track")
+		return "", fmt.Errorf("failed to create audio track: %w", err)
 	}
+	call.AudioTrack = audioTrack
 
 	// Add track to peer connection
-	_, err = pc.AddTrack(audioTrack)
+	rtpSender, err := pc.AddTrack(audioTrack)
 	if err != nil {
-		log.Printf("❌ Failed to add track: %v", err)
-		http.Error(w, "Failed to add track", http.StatusInternalServerError)
-		return
+		b.endOutboundCall(call, "failed to add track")
+		return "", fmt.Errorf("failed to add track: %w", err)
 	}
 
+	// Read incoming RTCP packets
+	go readRTCP(rtpSender, b.senderRTCP(call, whatsappCaptureLeg))
+
 	// Handle ICE connection state changes
 	pc.OnICEConnectionStateChange(func(connectionState webrtc.ICEConnectionState) {
-		log.Printf("🧊 Outbound call ICE state: %s", connectionState.String())
-		// v4: Handle explicit DTLS close
+		logger.Info("🧊 Outbound call ICE state", "call_id", call.ID, "state", connectionState.String())
 		if connectionState == webrtc.ICEConnectionStateClosed {
-			log.Printf("🔴 Outbound call: ICE connection explicitly closed via DTLS")
+			// v4: Handle explicit DTLS close
+			logger.Info("🔴 Outbound call: ICE connection explicitly closed via DTLS", "call_id", call.ID)
 		}
+		b.onCallICEState(call, connectionState.String())
 	})
 
 	// Handle peer connection state changes
 	pc.OnConnectionStateChange(func(s webrtc.PeerConnectionState) {
-		log.Printf("🔌 Outbound call connection state: %s", s.String())
+		logger.Info("🔌 Outbound call connection state", "call_id", call.ID, "state", s.String())
+		if s == webrtc.PeerConnectionStateFailed {
+			b.endOutboundCall(call, "DTLS/peer connection failed")
+		}
+	})
+
+	// Handle incoming audio from user
+	pc.OnTrack(func(track *webrtc.TrackRemote, receiver *webrtc.RTPReceiver) {
+		logger.Info("🔊 Received audio track from outbound call", "call_id", call.ID, "track", track.ID(), "codec", track.Codec().MimeType)
+		go readRTCP(receiver, b.receiverRTCP(call, whatsappCaptureLeg))
+		go b.forwardCallerAudio(call, trackRTPSource{track})
 	})
 
 	// Create SDP offer
 	offer, err := pc.CreateOffer(nil)
 	if err != nil {
-		log.Printf("❌ Failed to create offer: %v", err)
-		http.Error(w, "Failed to create offer", http.StatusInternalServerError)
-		return
+		b.endOutboundCall(call, "failed to create offer")
+		return "", fmt.Errorf("failed to create offer: %w", err)
 	}
 
 	// Set local description
 	if err := pc.SetLocalDescription(offer); err != nil {
-		log.Printf("❌ Failed to set local description: %v", err)
-		http.Error(w, "Failed to set local description", http.StatusInternalServerError)
-		return
+		b.endOutboundCall(call, "failed to set local description")
+		return "", fmt.Errorf("failed to set local description: %w", err)
 	}
 
 	// Wait for ICE gathering to complete
 	gatherComplete := webrtc.GatheringCompletePromise(pc)
-	<-gatherComplete
+	select {
+	case <-gatherComplete:
+		logger.Debug("✅ ICE gathering complete for outbound call")
+	case <-time.After(outboundICEGatherTimeout):
+		logger.Warn("⏱️ ICE gathering timeout for outbound call, sending the candidates gathered so far")
+	}
 
 	// Get complete SDP with ICE candidates
 	sdpOffer := pc.LocalDescription().SDP
 
-	log.Printf("📤 Sending outbound call request to WhatsApp API")
-	log.Printf("📄 SDP Offer length: %d bytes", len(sdpOffer))
-	log.Printf("📄 Full SDP Offer:\n%s", sdpOffer)
-	log.Printf("=====================================")
+	logger.Info("📤 Sending outbound call request to WhatsApp API", "sdp_length", len(sdpOffer))
+	logger.Debug("📄 SDP offer", "sdp", sdpOffer)
 
 	// Call WhatsApp API to initiate call
-	callID, err := b.initiateWhatsAppCall(req.To, sdpOffer)
+	callID, err := b.initiateWhatsAppCall(ctx, tenant, to, sdpOffer)
 	if err != nil {
-		log.Printf("❌ Failed to initiate call: %v", err)
-		http.Error(w, fmt.Sprintf("Failed to initiate call: %v", err), http.StatusInternalServerError)
-		pc.Close()
-		return
+		b.endOutboundCall(call, "Graph API connect failed")
+		return "", err
 	}
 
-	log.Printf("✅ Outbound call initiated: call_id=%s, to=%s", callID, req.To)
-
-	// Store the call
-	call := &Call{
-		ID:             callID,
-		PeerConnection: pc,
-		AudioTrack:     audioTrack,
-		StartTime:      time.Now(),
-		ReminderID:     req.ReminderID,
-		ReminderText:   req.ReminderText,
-	}
+	callLog := logger.With("call_id", callID)
+	callLog.Info("✅ Outbound call initiated")
 
 	// Log if this is a reminder call
-	if req.ReminderID != "" {
-		log.Printf("⏰ This is a reminder call: %s", req.ReminderText)
+	if reminderID != "" {
+		callLog.Info("⏰ This is a reminder call", "reminder_id", reminderID)
+		callLog.Debug("⏰ Reminder", "reminder", reminderText)
 	}
 
+	// Store the call, unless the offering timeout already ended it while we
+	// were waiting on the Graph API (endCall would then never remove it)
 	b.mu.Lock()
+	if state, _ := call.State(); state == CallStateTerminating || state == CallStateEnded {
+		b.mu.Unlock()
+		return "", fmt.Errorf("call %s timed out while offering", callID)
+	}
+	call.ID = callID
 	b.activeCalls[callID] = call
-	log.Printf("✅ Stored call in activeCalls map with key: %s", callID)
-	log.Printf("📊 Total active calls: %d", len(b.activeCalls))
+	callLog.Debug("✅ Stored call in activeCalls", "active_calls", len(b.activeCalls))
 	b.mu.Unlock()
+	b.startCallWatchdog(call)
 
-	// Pre-connect to Azure OpenAI so it's ready when user answers
-	azureKey := os.Getenv("AZURE_OPENAI_API_KEY")
+	// The Graph API accepted the offer - the user's phone is ringing now
+	if !b.advanceOutbound(call, OutboundStateRinging, "Graph API returned call_id") {
+		// The offering timeout fired between the check above and now
+		b.endOutboundCall(call, "timeout in offering")
+		return "", fmt.Errorf("call %s timed out while offering", callID)
+	}
 
-	if azureKey != "" {
-		log.Printf("🔵 Pre-connecting to Azure OpenAI before user answers...")
-		go func() {
-			// Connect to Azure OpenAI in background while call is ringing
-			// This way Azure is ready immediately when user answers
-			b.connectToOpenAIRealtime(callID, pc, azureKey, req.To, req.ReminderText)
-			log.Printf("✅ Azure OpenAI pre-connected and ready for call %s", callID)
-		}()
+	// Pre-connect the voice agent so it's ready when user answers
+	if backend := voiceAgentBackend(tenant, voiceAgent); backend != "" {
+		callLog.Info("🤖 Pre-connecting voice agent before user answers...", "backend", backend)
+		// Connect in background while call is ringing
+		// This way the agent is ready immediately when user answers
+		go b.connectVoiceAgent(call, backend)
 	} else {
-		log.Printf("⚠️ AZURE_OPENAI_API_KEY not set - no AI agent will respond")
+		callLog.Warn("⚠️ No voice agent configured - no AI agent will respond")
 	}
 
-	// Handle incoming audio from user
-	pc.OnTrack(func(track *webrtc.TrackRemote, receiver *webrtc.RTPReceiver) {
-		log.Printf("🔊 Received audio track from outbound call: %s (codec: %s)", track.ID(), track.Codec().MimeType)
+	return callID, nil
+}
 
-		// Forward audio to OpenAI when connected
-		go func() {
-			packetCount := 0
-			totalBytes := 0
-			openAIForwardingStarted := false
+// forwardCallerAudio forwards the user's audio to the voice agent, picking the
+// agent up whenever it attaches to the call. On outbound calls the first
+// packet moves the call to the media state.
+func (b *WhatsAppBridge) forwardCallerAudio(call *Call, source RTPSource) {
+	logger := callLogger(call.ID)
+	packetCount := 0
+	totalBytes := 0
+	agentForwardingStarted := false
+
+	for {
+		// v4 FIX: Use ReadRTP() to access full packet with headers
+		rtpPacket, readErr := source.ReadRTP()
+		if readErr != nil {
+			logger.Info("❌ Error reading caller audio", "packets", packetCount, "error", readErr)
+			return
+		}
 
-			for {
-				// v4 FIX: Use ReadRTP() to access full packet with headers
-				rtpPacket, _, readErr := track.ReadRTP()
-				if readErr != nil {
-					log.Printf("❌ Error reading audio from outbound call after %d packets: %v", packetCount, readErr)
-					return
-				}
+		if packetCount == 0 && call.Outbound != nil {
+			b.advanceOutbound(call, OutboundStateMedia, "first RTP packet received")
+		}
 
-				// v4 FIX: Clear extension headers to avoid conflicts between WhatsApp and OpenAI
-				rtpPacket.Extension = false
-				rtpPacket.Extensions = nil
+		// Capture the packet as WhatsApp sent it, extension headers included
+		capture := b.callCapture(call)
+		if capture != nil {
+			if raw, err := rtpPacket.Marshal(); err == nil {
+				capture.WriteRTP(CaptureWhatsAppIn, raw)
+			}
+		}
 
-				// Marshal back to bytes for forwarding
-				rtpBytes, marshalErr := rtpPacket.Marshal()
-				if marshalErr != nil {
-					log.Printf("❌ Error marshaling outbound call RTP packet: %v", marshalErr)
-					continue
-				}
+		// v4 FIX: Clear extension headers to avoid conflicts between WhatsApp and OpenAI
+		// Different endpoints use different extension header IDs, causing audio corruption
+		rtpPacket.Extension = false
+		rtpPacket.Extensions = nil
 
-				packetCount++
-				totalBytes += len(rtpBytes)
+		// Marshal back to bytes for forwarding
+		rtpBytes, marshalErr := rtpPacket.Marshal()
+		if marshalErr != nil {
+			logger.Error("❌ Error marshaling RTP packet", "error", marshalErr)
+			continue
+		}
 
-				// Check for OpenAI client on every packet (it becomes available after answer is received)
-				b.mu.Lock()
-				activeCall, exists := b.activeCalls[callID]
-				var openAIClient *OpenAIRealtimeClient
-				if exists && activeCall != nil {
-					openAIClient = activeCall.OpenAIClient
-				}
-				b.mu.Unlock()
+		packetCount++
+		totalBytes += len(rtpBytes)
+		b.onCallMedia(call, len(rtpBytes))
+		if recorder := b.callRecorder(call); recorder != nil {
+			recorder.WriteCaller(rtpPacket)
+		}
 
-				if openAIClient != nil {
-					// OpenAI client is available - forward the packet
-					if !openAIForwardingStarted {
-						log.Printf("🔄 OpenAI client available - starting outbound call audio forwarding")
-						openAIForwardingStarted = true
-					}
+		// Check for the voice agent on every packet (it might become available later)
+		b.mu.Lock()
+		agent := call.Agent
+		b.mu.Unlock()
 
-					// Forward cleaned RTP packet to OpenAI
-					if err := openAIClient.ForwardRTPToOpenAI(rtpBytes); err != nil {
-						if packetCount <= 3 {
-							log.Printf("❌ Error forwarding outbound call RTP to OpenAI: %v", err)
-						}
-					} else if packetCount == 1 || packetCount%100 == 0 {
-						if packetCount == 1 {
-							log.Printf("✅ First outbound call RTP packet forwarded to OpenAI! (cleaned headers)")
-						} else {
-							log.Printf("📦 Forwarded %d outbound call RTP packets (%d KB) to OpenAI",
-								packetCount, totalBytes/1024)
-						}
-					}
+		if agent != nil {
+			// Voice agent is available - forward the packet
+			if !agentForwardingStarted {
+				logger.Info("🔄 Voice agent now available - starting WhatsApp->agent forwarding", "backend", agent.Name())
+				agentForwardingStarted = true
+			}
+
+			// Forward cleaned RTP packet to the agent
+			if capture != nil {
+				capture.WriteRTP(CaptureAgentOut, rtpBytes)
+			}
+			if err := agent.WriteRTP(rtpBytes); err != nil {
+				if packetCount <= 3 { // Only log first few errors
+					logger.Error("❌ Error forwarding RTP to voice agent", "error", err)
+				}
+			} else if packetCount == 1 || packetCount%100 == 0 {
+				if packetCount == 1 {
+					logger.Info("✅ First WhatsApp RTP packet forwarded to voice agent! (cleaned headers)")
 				} else {
-					// OpenAI client not ready yet - just count packets
-					if packetCount%100 == 0 {
-						log.Printf("🎤 Received %d outbound call audio packets (waiting for OpenAI)", packetCount)
-					}
+					logger.Debug("📦 Forwarded WhatsApp RTP packets to voice agent",
+						"packets", packetCount, "kb", totalBytes/1024)
 				}
 			}
-		}()
-	})
-
-	// Respond with call ID
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{
-		"call_id": callID,
-		"status":  "ringing",
-		"to":      req.To,
-	})
+		} else {
+			// Voice agent not ready yet - just count packets
+			if packetCount%100 == 0 {
+				logger.Debug("🎤 Received audio packets - waiting for voice agent", "packets", packetCount, "bytes", totalBytes)
+			}
+		}
+	}
 }
 
 // handleCheckReminders checks for due reminders and initiates calls
 func (b *WhatsAppBridge) handleCheckReminders(w http.ResponseWriter, r *http.Request) {
-	log.Printf("⏰ Checking for due reminders...")
+	logger := loggerFrom(r.Context())
+	logger.Info("⏰ Checking for due reminders...")
 
-	// Get all due reminders
-	reminders, err := GetDueReminders()
-	if err != nil {
-		log.Printf("❌ Failed to get due reminders: %v", err)
-		http.Error(w, "Failed to check reminders", http.StatusInternalServerError)
-		return
+	// Get all due reminders across every tenant's schema
+	type dueReminder struct {
+		ZiggyReminder
+		tenant *Tenant
+	}
+	var reminders []dueReminder
+	for _, tenant := range b.tenants.All() {
+		tenantReminders, err := GetDueReminders(tenant.SupabaseSchema)
+		if err != nil {
+			logger.Error("❌ Failed to get due reminders", "tenant", tenant.Name, "error", err)
+			http.Error(w, "Failed to check reminders", http.StatusInternalServerError)
+			return
+		}
+		for _, reminder := range tenantReminders {
+			reminders = append(reminders, dueReminder{ZiggyReminder: reminder, tenant: tenant})
+		}
 	}
 
 	if len(reminders) == 0 {
-		log.Printf("✅ No due reminders found")
+		logger.Info("✅ No due reminders found")
 		w.WriteHeader(http.StatusOK)
 		json.NewEncoder(w).Encode(map[string]interface{}{
 			"status":  "success",
@@ -1952,45 +2172,31 @@ func (b *WhatsAppBridge) handleCheckReminders(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	log.Printf("📞 Found %d due reminders, initiating calls...", len(reminders))
+	logger.Info("📞 Found due reminders, initiating calls...", "count", len(reminders))
 
 	calledCount := 0
 	failedCount := 0
 
 	for _, reminder := range reminders {
-		log.Printf("📞 Calling %s for reminder: %s", reminder.PhoneNumber, reminder.ReminderText)
-
-		// Make the request to initiate call
-		req := struct {
-			To           string `json:"to"`
-			ReminderText string `json:"reminder_text,omitempty"`
-			ReminderID   string `json:"reminder_id,omitempty"`
-		}{
-			To:           reminder.PhoneNumber,
-			ReminderText: reminder.ReminderText,
-			ReminderID:   reminder.ID,
-		}
+		reminderLog := logger.With("reminder_id", reminder.ID, "to", reminder.PhoneNumber)
+		reminderLog.Info("📞 Calling for reminder")
+		reminderLog.Debug("📞 Reminder", "reminder", reminder.ReminderText)
 
-		jsonData, _ := json.Marshal(req)
-		resp, err := http.Post("http://localhost:3011/initiate-call", "application/json", bytes.NewBuffer(jsonData))
+		// Place the call the same way /initiate-call does, with the tenant's defaults
+		tenant := reminder.tenant
+		_, err := b.startOutboundCall(withLogger(r.Context(), logger.With("reminder_id", reminder.ID)), tenant, reminder.PhoneNumber, reminder.ID, reminder.ReminderText, "", tenant.impliesRecordingConsent(), false)
 		if err != nil {
-			log.Printf("❌ Failed to initiate call for reminder %s: %v", reminder.ID, err)
+			reminderLog.Error("❌ Failed to initiate call for reminder", "error", err)
 			failedCount++
 			continue
 		}
-		defer resp.Body.Close()
 
-		if resp.StatusCode == http.StatusOK {
-			// Update reminder status to 'called'
-			if err := UpdateReminderStatus(reminder.ID, "called", ""); err != nil {
-				log.Printf("⚠️ Failed to update reminder status: %v", err)
-			} else {
-				log.Printf("✅ Reminder call initiated for %s", reminder.PhoneNumber)
-				calledCount++
-			}
+		// Update reminder status to 'called'
+		if err := UpdateReminderStatus(tenant.SupabaseSchema, reminder.ID, "called", ""); err != nil {
+			reminderLog.Warn("⚠️ Failed to update reminder status", "error", err)
 		} else {
-			log.Printf("❌ Failed to initiate call, status: %d", resp.StatusCode)
-			failedCount++
+			reminderLog.Info("✅ Reminder call initiated")
+			calledCount++
 		}
 	}
 
@@ -2004,27 +2210,28 @@ func (b *WhatsAppBridge) handleCheckReminders(w http.ResponseWriter, r *http.Req
 }
 
 // handleOutboundCallAnswer processes the SDP answer when user accepts outbound call
-func (b *WhatsAppBridge) handleOutboundCallAnswer(callID, sdpAnswer, from string) {
-	log.Printf("🔄 Processing outbound call answer for %s", callID)
+func (b *WhatsAppBridge) handleOutboundCallAnswer(ctx context.Context, callID, sdpAnswer, from string) {
+	logger := loggerFrom(ctx).With("call_id", callID)
+	logger.Info("🔄 Processing outbound call answer")
 
 	// Get the call from active calls
 	b.mu.Lock()
-	log.Printf("🔍 Looking for call_id: %s", callID)
-	log.Printf("🔍 Active calls count: %d", len(b.activeCalls))
-
-	// Log all active call IDs for comparison
-	activeIDs := []string{}
-	for id := range b.activeCalls {
-		activeIDs = append(activeIDs, id)
-	}
-	log.Printf("🔍 Active call IDs: %v", activeIDs)
-
 	call, exists := b.activeCalls[callID]
 	b.mu.Unlock()
 
-	if !exists {
-		log.Printf("❌ Call %s not found in active calls", callID)
-		log.Printf("❌ This means the call_id from webhook doesn't match what we stored")
+	if !exists || call.Outbound == nil {
+		logger.Warn("❌ Outbound call not found in active calls")
+		return
+	}
+
+	// Only apply the answer once, and never to a call that has already ended:
+	// the transition to connecting is atomic, so of several deliveries of the
+	// connect webhook (or one racing endCall) exactly one gets past here
+	if !b.advanceOutbound(call, OutboundStateConnecting, "SDP answer received") {
+		call.Outbound.mu.Lock()
+		state := call.Outbound.State
+		call.Outbound.mu.Unlock()
+		logger.Warn("⚠️ Ignoring SDP answer for outbound call", "state", state)
 		return
 	}
 
@@ -2035,81 +2242,18 @@ func (b *WhatsAppBridge) handleOutboundCallAnswer(callID, sdpAnswer, from string
 	}
 
 	if err := call.PeerConnection.SetRemoteDescription(answer); err != nil {
-		log.Printf("❌ Failed to set remote description: %v", err)
+		logger.Error("❌ Failed to set remote description", "error", err)
+		b.endOutboundCall(call, "invalid SDP answer")
 		return
 	}
 
-	log.Printf("✅ Set remote SDP answer for call %s", callID)
-	log.Printf("✅ Outbound call %s connected - media should now flow", callID)
-	log.Printf("🎙️ Azure OpenAI should already be connected and ready to respond")
-}
-
-// acceptOutboundCall sends the final accept to WhatsApp API for outbound call
-func (b *WhatsAppBridge) acceptOutboundCall(callID string) error {
-	url := fmt.Sprintf("https://graph.facebook.com/v21.0/%s/calls", b.phoneNumberID)
-
-	// Get the call
-	b.mu.Lock()
-	call, exists := b.activeCalls[callID]
-	b.mu.Unlock()
-
-	if !exists {
-		return fmt.Errorf("call %s not found", callID)
-	}
-
-	// Get our local SDP
-	localSDP := call.PeerConnection.LocalDescription().SDP
-
-	reqBody := map[string]interface{}{
-		"messaging_product": "whatsapp",
-		"call_id":           callID,
-		"action":            "accept",
-		"session": map[string]string{
-			"sdp_type": "answer",
-			"sdp":      localSDP,
-		},
-	}
-
-	jsonData, err := json.Marshal(reqBody)
-	if err != nil {
-		return err
-	}
-
-	log.Printf("📤 Sending accept for outbound call %s", callID)
-
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return err
-	}
-
-	req.Header.Set("Authorization", "Bearer "+b.accessToken)
-	req.Header.Set("Content-Type", "application/json")
-
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return err
-	}
-
-	log.Printf("📡 WhatsApp API accept response: Status=%s, Body=%s", resp.Status, string(body))
-
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("accept failed: %s - %s", resp.Status, string(body))
-	}
-
-	log.Printf("✅ WhatsApp API accept successful for outbound call %s", callID)
-	return nil
+	logger.Info("✅ Set remote SDP answer")
 }
 
 // initiateWhatsAppCall calls WhatsApp API to initiate an outbound call
-func (b *WhatsAppBridge) initiateWhatsAppCall(phoneNumber, sdpOffer string) (string, error) {
-	url := fmt.Sprintf("https://graph.facebook.com/v21.0/%s/calls", b.phoneNumberID)
+func (b *WhatsAppBridge) initiateWhatsAppCall(ctx context.Context, tenant *Tenant, phoneNumber, sdpOffer string) (string, error) {
+	logger := loggerFrom(ctx)
+	url := graphURL(graphAPIVersion, tenant.PhoneNumberID, "calls")
 
 	reqBody := map[string]interface{}{
 		"messaging_product": "whatsapp",
@@ -2131,7 +2275,7 @@ func (b *WhatsAppBridge) initiateWhatsAppCall(phoneNumber, sdpOffer string) (str
 		return "", err
 	}
 
-	req.Header.Set("Authorization", "Bearer "+b.accessToken)
+	req.Header.Set("Authorization", "Bearer "+tenant.AccessToken)
 	req.Header.Set("Content-Type", "application/json")
 
 	client := &http.Client{Timeout: 10 * time.Second}
@@ -2146,10 +2290,11 @@ func (b *WhatsAppBridge) initiateWhatsAppCall(phoneNumber, sdpOffer string) (str
 		return "", err
 	}
 
-	log.Printf("📡 WhatsApp API response: Status=%s, Body=%s", resp.Status, string(body))
+	logger.Info("📡 WhatsApp calls API response", "status", resp.Status)
+	logger.Debug("📡 WhatsApp calls API response body", "body", string(body))
 
 	if resp.StatusCode != http.StatusOK {
-		log.Printf("❌ WhatsApp API error: Status=%s, Body=%s", resp.Status, string(body))
+		logger.Error("❌ WhatsApp calls API error", "status", resp.Status, "response", string(body))
 		return "", fmt.Errorf("WhatsApp API error: %s - %s", resp.Status, string(body))
 	}
 
@@ -2167,22 +2312,22 @@ func (b *WhatsAppBridge) initiateWhatsAppCall(phoneNumber, sdpOffer string) (str
 	}
 
 	if err := json.Unmarshal(body, &result); err != nil {
-		log.Printf("❌ Failed to parse response: %v", err)
+		logger.Error("❌ Failed to parse response", "error", err)
 		return "", err
 	}
 
 	if result.Error != nil {
-		log.Printf("❌ WhatsApp API returned error: %+v", result.Error)
+		logger.Error("❌ WhatsApp API returned error", "message", result.Error.Message, "type", result.Error.Type, "code", result.Error.Code)
 		return "", fmt.Errorf("WhatsApp API error: %s (code: %d)", result.Error.Message, result.Error.Code)
 	}
 
 	if len(result.Calls) == 0 {
-		log.Printf("❌ WhatsApp API response has no calls array")
+		logger.Error("❌ WhatsApp API response has no calls array")
 		return "", fmt.Errorf("no call_id in response")
 	}
 
 	callID := result.Calls[0].ID
-	log.Printf("✅ WhatsApp API returned call_id: %s", callID)
+	logger.Info("✅ WhatsApp API returned call_id", "call_id", callID)
 	return callID, nil
 }
 
@@ -2194,6 +2339,11 @@ func max(a, b int) int {
 }
 
 func main() {
+	// rtp-replay runs a packet capture through the forwarding loops and exits
+	if len(os.Args) > 1 && os.Args[1] == "rtp-replay" {
+		os.Exit(runRTPReplay(os.Args[2:]))
+	}
+
 	// Load .env file
 	if err := godotenv.Load(); err != nil {
 		log.Println("⚠️  No .env file found or error loading it")
@@ -2201,9 +2351,11 @@ func main() {
 		log.Println("✅ Loaded .env file")
 	}
 
-	log.Println("🚀 Starting Pion WhatsApp Bridge v3 - Proper Audio Architecture")
-	log.Println("✨ Pure Go implementation with native ice-lite support")
-	log.Println("🎯 Direct RTP forwarding: WhatsApp ↔️ OpenAI")
+	setupLogging()
+
+	slog.Info("🚀 Starting Pion WhatsApp Bridge v3 - Proper Audio Architecture")
+	slog.Info("✨ Pure Go implementation with native ice-lite support")
+	slog.Info("🎯 Direct RTP forwarding: WhatsApp ↔️ OpenAI")
 
 	bridge := NewWhatsAppBridge()
 	bridge.Start()
@@ -0,0 +1,319 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pion/webrtc/v4"
+)
+
+const (
+	testAppSecret     = "test-app-secret"
+	testPhoneNumberID = "106382542433515"
+)
+
+// newTestBridge returns a bridge serving one tenant, with an in-memory dedupe
+// store and a started webhook queue in a temporary directory. The store is a
+// fresh SQLite file for the duration of the test.
+func newTestBridge(t *testing.T) *WhatsAppBridge {
+	t.Helper()
+
+	store, err := NewSQLiteStore(filepath.Join(t.TempDir(), "ziggy.db"))
+	if err != nil {
+		t.Fatalf("NewSQLiteStore: %v", err)
+	}
+	previousStore := dataStore
+	dataStore = store
+	t.Cleanup(func() { dataStore = previousStore })
+
+	tenants := &TenantRegistry{tenants: make(map[string]*Tenant)}
+	if err := tenants.Add(&Tenant{
+		Name:               "test",
+		PhoneNumberID:      testPhoneNumberID,
+		DisplayPhoneNumber: "15551234567",
+		AccessToken:        "test-access-token",
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	b := &WhatsAppBridge{
+		activeCalls: make(map[string]*Call),
+		tenants:     tenants,
+		appSecret:   testAppSecret,
+		dedupe:      NewMemoryDedupeStore(time.Hour, 1000),
+		callLimits:  callLimitsFromEnv(),
+	}
+	queue, err := NewWebhookQueue(filepath.Join(t.TempDir(), "queue"), 1, 1, b.processWebhookJob, webhookOrderKeys)
+	if err != nil {
+		t.Fatalf("NewWebhookQueue: %v", err)
+	}
+	b.queue = queue
+	queue.Start()
+	return b
+}
+
+// postWebhook posts body to the bridge's webhook endpoint, signed like Meta does
+func postWebhook(t *testing.T, b *WhatsAppBridge, body []byte) {
+	t.Helper()
+
+	mac := hmac.New(sha256.New, []byte(testAppSecret))
+	mac.Write(body)
+	req := httptest.NewRequest(http.MethodPost, "/whatsapp-call", bytes.NewReader(body))
+	req.Header.Set("X-Hub-Signature-256", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	rec := httptest.NewRecorder()
+	b.handleWebhookEvent(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("webhook returned %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+// waitForProcessed waits until the queue has processed n webhooks
+func waitForProcessed(t *testing.T, q *WebhookQueue, n int64) {
+	t.Helper()
+
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		stats := q.Stats()
+		if stats["processed"].(int64) >= n {
+			return
+		}
+		if stats["dead_lettered"].(int64) > 0 {
+			t.Fatalf("webhook was dead-lettered: %v", stats)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %d processed webhooks: %v", n, q.Stats())
+}
+
+// sentMessage is one message the bridge sent through the fake Graph API
+type sentMessage struct {
+	To   string
+	Text string
+}
+
+// fakeMessaging points the bridge's LLM and Graph API calls at local servers.
+// The LLM answers every message with "re: <message>" and the Graph API
+// records what the bridge sends.
+func fakeMessaging(t *testing.T) func() []sentMessage {
+	t.Helper()
+
+	llm := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Input []map[string]interface{} `json:"input"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Input) == 0 {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		content, _ := req.Input[len(req.Input)-1]["content"].(string)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"output": []map[string]interface{}{{
+				"type":    "message",
+				"content": []map[string]interface{}{{"type": "output_text", "text": "re: " + content}},
+			}},
+		})
+	}))
+	t.Cleanup(llm.Close)
+
+	var mu sync.Mutex
+	var sent []sentMessage
+	graph := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var msg struct {
+			To   string `json:"to"`
+			Text struct {
+				Body string `json:"body"`
+			} `json:"text"`
+		}
+		if strings.HasSuffix(r.URL.Path, "/"+testPhoneNumberID+"/messages") && json.Unmarshal(body, &msg) == nil {
+			mu.Lock()
+			sent = append(sent, sentMessage{To: msg.To, Text: msg.Text.Body})
+			mu.Unlock()
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"messaging_product":"whatsapp","messages":[{"id":"wamid.reply"}]}`))
+	}))
+	t.Cleanup(graph.Close)
+
+	t.Setenv("AZURE_OPENAI_API_KEY", "test-llm-key")
+	t.Setenv("AZURE_OPENAI_ENDPOINT", llm.URL)
+	t.Setenv("WHATSAPP_GRAPH_URL", graph.URL)
+
+	return func() []sentMessage {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]sentMessage(nil), sent...)
+	}
+}
+
+// TestWebhookMultiEntryFixture posts a delivery that batches two entries, the
+// first with a messages change and a calls change, and checks the event of
+// every change in every entry is handled, in the order it was sent.
+func TestWebhookMultiEntryFixture(t *testing.T) {
+	sent := fakeMessaging(t)
+	b := newTestBridge(t)
+
+	// The calls change carries RINGING for an outbound call we placed
+	call := newCall("wacid.TEST_CALL_ID_456", b.tenants.Default())
+	call.Outbound = NewOutboundCall("15559876543")
+	b.activeCalls[call.ID] = call
+	defer call.Outbound.transition(OutboundStateEnded, "test finished")
+
+	body, err := os.ReadFile("testdata/webhook_multi_entry.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	postWebhook(t, b, body)
+	waitForProcessed(t, b.queue, 1)
+
+	want := []sentMessage{
+		{To: "15559876543", Text: "re: First message"},
+		{To: "15550001111", Text: "re: Second message"},
+		{To: "15559876543", Text: "re: Third message from a second entry"},
+	}
+	got := sent()
+	if len(got) != len(want) {
+		t.Fatalf("bridge sent %d replies, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("reply %d = %+v, want %+v", i+1, got[i], want[i])
+		}
+	}
+
+	for _, id := range []string{"wamid.batch.1", "wamid.batch.2", "wamid.batch.3"} {
+		if !b.dedupe.CheckAndMark(messageDedupeKey(id)) {
+			t.Errorf("message %s was not marked as processed", id)
+		}
+	}
+
+	call.Outbound.mu.Lock()
+	state := call.Outbound.State
+	call.Outbound.mu.Unlock()
+	if state != OutboundStateRinging {
+		t.Errorf("outbound call state = %s, want %s from the calls change", state, OutboundStateRinging)
+	}
+}
+
+// TestWebhookOrderKeys checks a delivery is keyed by every call and phone
+// number it carries events for
+func TestWebhookOrderKeys(t *testing.T) {
+	body, err := os.ReadFile("testdata/webhook_multi_entry.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := webhookOrderKeys(body)
+	want := []string{"phone:15559876543", "phone:15550001111", "call:wacid.TEST_CALL_ID_456"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("webhookOrderKeys = %v, want %v", got, want)
+	}
+}
+
+// TestTerminateDuringCallSetup queues a connect and then a terminate for the
+// same inbound call while the pre-accept is still in flight, and checks the
+// terminate is not lost: the call is never accepted.
+func TestTerminateDuringCallSetup(t *testing.T) {
+	var mu sync.Mutex
+	var actions []string
+	release := make(chan struct{})
+	graph := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Action string `json:"action"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		mu.Lock()
+		actions = append(actions, req.Action)
+		mu.Unlock()
+		if req.Action == "pre_accept" {
+			<-release
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"success":true}`))
+	}))
+	t.Cleanup(graph.Close)
+	t.Setenv("WHATSAPP_GRAPH_URL", graph.URL)
+
+	b := newTestBridge(t)
+	m := &webrtc.MediaEngine{}
+	if err := m.RegisterDefaultCodecs(); err != nil {
+		t.Fatal(err)
+	}
+	b.api = webrtc.NewAPI(webrtc.WithMediaEngine(m))
+
+	caller, err := webrtc.NewPeerConnection(webrtc.Configuration{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer caller.Close()
+	if _, err := caller.AddTransceiverFromKind(webrtc.RTPCodecTypeAudio); err != nil {
+		t.Fatal(err)
+	}
+	offer, err := caller.CreateOffer(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const callID = "wacid.TERMINATE_DURING_SETUP"
+	callEvent := func(call map[string]interface{}) []byte {
+		body, err := json.Marshal(map[string]interface{}{
+			"object": "whatsapp_business_account",
+			"entry": []interface{}{map[string]interface{}{
+				"id": "123456789",
+				"changes": []interface{}{map[string]interface{}{
+					"field": "calls",
+					"value": map[string]interface{}{
+						"messaging_product": "whatsapp",
+						"metadata": map[string]string{
+							"display_phone_number": "15551234567",
+							"phone_number_id":      testPhoneNumberID,
+						},
+						"calls": []interface{}{call},
+					},
+				}},
+			}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return body
+	}
+	postWebhook(t, b, callEvent(map[string]interface{}{
+		"id": callID, "from": "15559876543", "to": "15551234567",
+		"event": CallEventConnect, "direction": CallDirectionUserInitiated,
+		"session": map[string]string{"sdp_type": "offer", "sdp": offer.SDP},
+	}))
+	postWebhook(t, b, callEvent(map[string]interface{}{
+		"id": callID, "from": "15559876543", "to": "15551234567",
+		"event": CallEventTerminate, "status": "COMPLETED",
+	}))
+	waitForProcessed(t, b.queue, 2)
+	close(release)
+
+	// Give the setup goroutine time to send an accept if it was going to
+	time.Sleep(500 * time.Millisecond)
+	b.mu.Lock()
+	_, active := b.activeCalls[callID]
+	b.mu.Unlock()
+	if active {
+		t.Error("call is still active after its terminate")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	for _, action := range actions {
+		if action == "accept" {
+			t.Fatalf("call was accepted after its terminate; calls API actions: %v", actions)
+		}
+	}
+}
@@ -2,13 +2,15 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/pion/webrtc/v4"
@@ -25,21 +27,71 @@ type OpenAIRealtimeClient struct {
 	audioTrack       *webrtc.TrackLocalStaticRTP
 	remoteAudioTrack *webrtc.TrackRemote
 	phoneNumber      string
-	reminderText     string // If this is a reminder call, what to remind about
+	reminderText     string  // If this is a reminder call, what to remind about
+	recorded         bool    // The call is recorded, so the disclosure comes first
+	tenant           *Tenant // Business number the call is on (persona, storage schema)
+	onEndCall        func(reason string) // Hangs up the WhatsApp call when the assistant uses end_call
+	onSenderRTCP     func(packet []byte) // Receives OpenAI's feedback on our audio when the call is being captured
+	onReceiverRTCP   func(packet []byte) // Receives OpenAI's sender reports when the call is being captured
+	callID           string              // WhatsApp call this session serves, for log correlation
+	api              *webrtc.API         // Used by Connect to build the OpenAI peer connection
+	transport        string              // realtimeTransportWebRTC or realtimeTransportWebSocket
+	ws               *realtimeWebSocket  // WebSocket transport connection
+	audio            *realtimeAudio      // Opus <-> PCM16 pipeline for the WebSocket transport
+	transcript       *callTranscript     // Speaker-labeled turns saved to ziggy_call_transcripts; nil when not recorded
+	memory           string              // Conversation memory block, fetched once per call
+	memoryOnce       sync.Once
+	mu               sync.Mutex          // Guards remoteAudioTrack, which arrives on a pion callback, and ws/audio
 }
 
+// Realtime endpoints used unless overridden by OPENAI_REALTIME_URL and
+// AZURE_OPENAI_WEBRTC_URL
+const (
+	defaultRealtimeURL            = "https://api.openai.com/v1/realtime"
+	defaultAzureRealtimeWebRTCURL = "https://eastus2.realtimeapi-preview.ai.azure.com/v1/realtimertc"
+)
+
+// realtimeBaseURL returns the OpenAI Realtime API base that client_secrets,
+// calls and the WebSocket endpoint hang off: the in-process fake when it is
+// running, then OPENAI_REALTIME_URL, then api.openai.com
+func realtimeBaseURL() string {
+	if fakeRealtime != nil {
+		return fakeRealtime.URL()
+	}
+	if url := os.Getenv("OPENAI_REALTIME_URL"); url != "" {
+		return strings.TrimSuffix(url, "/")
+	}
+	return defaultRealtimeURL
+}
+
+// azureRealtimeWebRTCURL returns the regional Azure endpoint that takes SDP offers
+func azureRealtimeWebRTCURL() string {
+	if url := os.Getenv("AZURE_OPENAI_WEBRTC_URL"); url != "" {
+		return url
+	}
+	return defaultAzureRealtimeWebRTCURL
+}
+
+// endCallGoodbyeDelay gives the assistant time to say goodbye before end_call hangs up
+const endCallGoodbyeDelay = 4 * time.Second
+
 // NewOpenAIRealtimeClient creates a new OpenAI Realtime client
-func NewOpenAIRealtimeClient(apiKey, phoneNumber, reminderText string) *OpenAIRealtimeClient {
+func NewOpenAIRealtimeClient(tenant *Tenant, apiKey, phoneNumber, reminderText string) *OpenAIRealtimeClient {
 	// Check if using Azure OpenAI
 	azureEndpoint := os.Getenv("AZURE_OPENAI_ENDPOINT")
 	azureDeployment := os.Getenv("AZURE_OPENAI_DEPLOYMENT")
 
-	if azureEndpoint != "" {
-		log.Printf("🔵 Using Azure OpenAI: %s", azureEndpoint)
+	// The fake endpoint speaks the OpenAI flavor of the API
+	if fakeRealtime != nil {
+		azureEndpoint = ""
+		slog.Info("🧪 Using the fake Realtime endpoint", "url", fakeRealtime.URL())
+	} else if azureEndpoint != "" {
+		slog.Info("🔵 Using Azure OpenAI", "endpoint", azureEndpoint)
 	}
 
 	if reminderText != "" {
-		log.Printf("⏰ Creating OpenAI client for reminder call: %s", reminderText)
+		slog.Info("⏰ Creating OpenAI client for reminder call")
+		slog.Debug("⏰ Reminder", "reminder", reminderText)
 	}
 
 	return &OpenAIRealtimeClient{
@@ -48,14 +100,37 @@ func NewOpenAIRealtimeClient(apiKey, phoneNumber, reminderText string) *OpenAIRe
 		azureDeployment: azureDeployment,
 		phoneNumber:     phoneNumber,
 		reminderText:    reminderText,
+		tenant:          tenant,
+		transport:       realtimeTransportWebRTC,
 	}
 }
 
-// getInstructions returns the appropriate instructions based on whether this is a reminder call
+// getInstructions returns the appropriate instructions based on whether this is a reminder call,
+// with the tenant's persona and the user's conversation memory appended when available
 func (c *OpenAIRealtimeClient) getInstructions() string {
+	instructions := c.baseInstructions()
+	if c.tenant.Persona != "" {
+		instructions += " " + c.tenant.Persona
+	}
+	if c.recorded {
+		instructions += fmt.Sprintf(" RECORDING DISCLOSURE: This call is being recorded. Before anything else, including any greeting or reminder, say exactly: '%s'", c.tenant.recordingDisclosure())
+	}
+
+	// Both the token request and session.update send instructions; fetch the memory once
+	c.memoryOnce.Do(func() {
+		c.memory = NewConversationMemory(c.tenant, c.phoneNumber, c.logger()).ContextBlock(true)
+	})
+	if c.memory != "" {
+		instructions += "\n\n" + c.memory
+	}
+	return instructions
+}
+
+// baseInstructions returns the shared Ziggy instructions for this call
+func (c *OpenAIRealtimeClient) baseInstructions() string {
 	if c.reminderText != "" {
 		// This is a reminder call - announce the reminder immediately
-		return fmt.Sprintf("You are Ziggy, a helpful voice assistant. This is a reminder call. IMMEDIATELY when the call starts, announce the reminder: 'Hello! This is Ziggy calling to remind you about: %s' Then ask if they have completed this task or would like to reschedule. Speak ONLY in English. Be friendly and concise.", c.reminderText)
+		return fmt.Sprintf("You are Ziggy, a helpful voice assistant. This is a reminder call. IMMEDIATELY when the call starts, announce the reminder: 'Hello! This is Ziggy calling to remind you about: %s' Then ask if they have completed this task or would like to reschedule. Once they have confirmed or rescheduled, say a short goodbye and use end_call. Speak ONLY in English. Be friendly and concise.", c.reminderText)
 	}
 
 	// Detect user's timezone from phone number to provide context
@@ -65,7 +140,7 @@ func (c *OpenAIRealtimeClient) getInstructions() string {
 	// Get current date and time in user's timezone
 	loc, err := time.LoadLocation(timezone)
 	if err != nil {
-		log.Printf("⚠️ Failed to load timezone %s, falling back to UTC: %v", timezone, err)
+		c.logger().Warn("⚠️ Failed to load timezone, falling back to UTC", "timezone", timezone, "error", err)
 		loc = time.UTC // Fallback to UTC (should rarely happen as GetTimezoneFromPhoneNumber returns valid timezones)
 	}
 	currentTime := time.Now().In(loc)
@@ -88,11 +163,9 @@ func (c *OpenAIRealtimeClient) GetEphemeralToken() error {
 	// Use Azure endpoint if configured, otherwise use OpenAI
 	if c.azureEndpoint != "" {
 		// Azure requires ephemeral token from sessions endpoint
-		log.Printf("🔵 Getting Azure OpenAI ephemeral token from sessions endpoint")
 		sessionsURL := fmt.Sprintf("%s/openai/realtimeapi/sessions?api-version=2025-04-01-preview",
 			c.azureEndpoint)
-		log.Printf("📤 Sessions URL: %s", sessionsURL)
-		log.Printf("🔑 API Key (first 20 chars): %.20s...", c.apiKey)
+		c.logger().Info("🔵 Getting Azure OpenAI ephemeral token from sessions endpoint", "url", sessionsURL)
 
 		reqBody := map[string]interface{}{
 			"model": c.azureDeployment,
@@ -108,178 +181,7 @@ func (c *OpenAIRealtimeClient) GetEphemeralToken() error {
 			"input_audio_transcription": map[string]interface{}{
 				"model": "whisper-1",
 			},
-			"tools": []map[string]interface{}{
-				// Tasks
-				{
-					"type":        "function",
-					"name":        "add_task",
-					"description": "Create a new task for the caller. Use this when they ask to add, create, or remember a task or todo item.",
-					"parameters": map[string]interface{}{
-						"type": "object",
-						"properties": map[string]interface{}{
-							"title": map[string]interface{}{
-								"type":        "string",
-								"description": "Brief title of the task",
-							},
-							"description": map[string]interface{}{
-								"type":        "string",
-								"description": "Detailed description of the task (optional)",
-							},
-							"priority": map[string]interface{}{
-								"type":        "string",
-								"description": "Priority level: low, medium, high, or urgent",
-								"enum":        []string{"low", "medium", "high", "urgent"},
-							},
-						},
-						"required": []string{"title"},
-					},
-				},
-				{
-					"type":        "function",
-					"name":        "list_tasks",
-					"description": "List all tasks for the caller. Can optionally filter by status.",
-					"parameters": map[string]interface{}{
-						"type": "object",
-						"properties": map[string]interface{}{
-							"status": map[string]interface{}{
-								"type":        "string",
-								"description": "Filter by status: pending, in_progress, completed, or cancelled (optional)",
-								"enum":        []string{"pending", "in_progress", "completed", "cancelled"},
-							},
-						},
-					},
-				},
-				{
-					"type":        "function",
-					"name":        "update_task_status",
-					"description": "Update the status of a task. Use when user wants to mark task as done, complete, in progress, etc.",
-					"parameters": map[string]interface{}{
-						"type": "object",
-						"properties": map[string]interface{}{
-							"task_id": map[string]interface{}{
-								"type":        "string",
-								"description": "The ID of the task to update",
-							},
-							"status": map[string]interface{}{
-								"type":        "string",
-								"description": "New status: pending, in_progress, completed, or cancelled",
-								"enum":        []string{"pending", "in_progress", "completed", "cancelled"},
-							},
-						},
-						"required": []string{"task_id", "status"},
-					},
-				},
-				// Reminders
-				{
-					"type":        "function",
-					"name":        "add_reminder",
-					"description": "Set a reminder for the caller. Supports one-time and recurring reminders. When the reminder time comes, Ziggy will call them back.",
-					"parameters": map[string]interface{}{
-						"type": "object",
-						"properties": map[string]interface{}{
-							"reminder_text": map[string]interface{}{
-								"type":        "string",
-								"description": "What to remind the user about",
-							},
-							"reminder_time": map[string]interface{}{
-								"type":        "string",
-								"description": "When to send the reminder in local timezone using format YYYY-MM-DD HH:MM (e.g., 2025-11-09 14:30 for 2:30 PM). Use 24-hour format. Ask the user for the exact date and time if not provided.",
-							},
-							"recurrence": map[string]interface{}{
-								"type":        "string",
-								"description": "Recurrence pattern: 'once' (default, one-time), 'daily', 'weekly', 'monthly', 'yearly'. Only specify if user wants recurring reminder.",
-								"enum":        []string{"once", "daily", "weekly", "monthly", "yearly"},
-							},
-						},
-						"required": []string{"reminder_text", "reminder_time"},
-					},
-				},
-				{
-					"type":        "function",
-					"name":        "list_reminders",
-					"description": "List all reminders for the caller. Can filter by status (pending, called, completed, cancelled).",
-					"parameters": map[string]interface{}{
-						"type": "object",
-						"properties": map[string]interface{}{
-							"status": map[string]interface{}{
-								"type":        "string",
-								"description": "Optional filter by status: 'pending', 'called', 'completed', 'cancelled'. If not provided, shows all reminders.",
-								"enum":        []string{"pending", "called", "completed", "cancelled"},
-							},
-						},
-					},
-				},
-				{
-					"type":        "function",
-					"name":        "cancel_reminder",
-					"description": "Cancel a reminder. Use this when user wants to stop or delete a reminder.",
-					"parameters": map[string]interface{}{
-						"type": "object",
-						"properties": map[string]interface{}{
-							"reminder_id": map[string]interface{}{
-								"type":        "string",
-								"description": "The ID of the reminder to cancel. Get this from list_reminders.",
-							},
-						},
-						"required": []string{"reminder_id"},
-					},
-				},
-				// Notes
-				{
-					"type":        "function",
-					"name":        "add_note",
-					"description": "Create a note for the caller. Use this when they ask to note something, write something down, remember something, or save information.",
-					"parameters": map[string]interface{}{
-						"type": "object",
-						"properties": map[string]interface{}{
-							"note_content": map[string]interface{}{
-								"type":        "string",
-								"description": "The content of the note to save",
-							},
-						},
-						"required": []string{"note_content"},
-					},
-				},
-				{
-					"type":        "function",
-					"name":        "list_notes",
-					"description": "List all notes for the caller. Shows all saved notes in chronological order.",
-					"parameters": map[string]interface{}{
-						"type":       "object",
-						"properties": map[string]interface{}{},
-					},
-				},
-				{
-					"type":        "function",
-					"name":        "search_notes",
-					"description": "Search through notes for specific keywords or content. Use this when user wants to find specific notes.",
-					"parameters": map[string]interface{}{
-						"type": "object",
-						"properties": map[string]interface{}{
-							"query": map[string]interface{}{
-								"type":        "string",
-								"description": "Search query to find in notes",
-							},
-						},
-						"required": []string{"query"},
-					},
-				},
-				{
-					"type":        "function",
-					"name":        "delete_note",
-					"description": "Delete a specific note. Use this when user wants to remove or delete a note.",
-					"parameters": map[string]interface{}{
-						"type": "object",
-						"properties": map[string]interface{}{
-							"note_id": map[string]interface{}{
-								"type":        "string",
-								"description": "The ID of the note to delete. Get this from list_notes or search_notes.",
-							},
-						},
-						"required": []string{"note_id"},
-					},
-				},
-			},
+			"tools": toolRegistry.RealtimeTools(c.toolCaller()),
 			"tool_choice": "auto",
 			"temperature": 1.0,
 		}
@@ -310,11 +212,11 @@ func (c *OpenAIRealtimeClient) GetEphemeralToken() error {
 		}
 
 		if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
-			log.Printf("❌ Azure sessions API error. Status: %s, Body: %s", resp.Status, string(body))
+			c.logger().Error("❌ Azure sessions API error", "status", resp.Status, "response", string(body))
 			return fmt.Errorf("Azure sessions API error: %s - %s", resp.Status, string(body))
 		}
 
-		log.Printf("📥 Azure session response: %s", string(body))
+		c.logger().Debug("📥 Azure session response", "body", string(body))
 
 		var sessionResp struct {
 			ID           string `json:"id"`
@@ -324,16 +226,16 @@ func (c *OpenAIRealtimeClient) GetEphemeralToken() error {
 		}
 
 		if err := json.Unmarshal(body, &sessionResp); err != nil {
-			log.Printf("❌ Failed to parse Azure session response: %v", err)
+			c.logger().Error("❌ Failed to parse Azure session response", "error", err)
 			return err
 		}
 
 		c.ephemeralToken = sessionResp.ClientSecret.Value
-		log.Printf("✅ Got Azure ephemeral token for session: %s", sessionResp.ID)
+		c.logger().Info("✅ Got Azure ephemeral token", "session_id", sessionResp.ID)
 		return nil
 	}
 
-	url = "https://api.openai.com/v1/realtime/client_secrets"
+	url = realtimeBaseURL() + "/client_secrets"
 
 	reqBody := map[string]interface{}{
 		"session": map[string]interface{}{
@@ -373,25 +275,26 @@ func (c *OpenAIRealtimeClient) GetEphemeralToken() error {
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		log.Printf("❌ Failed to get ephemeral token. Status: %s, Body: %s", resp.Status, string(body))
+		c.logger().Error("❌ Failed to get ephemeral token", "status", resp.Status, "response", string(body))
 		return fmt.Errorf("failed to get ephemeral token: %s - %s", resp.Status, string(body))
 	}
 
-	log.Printf("📥 Token response body: %s", string(body))
+	c.logger().Debug("📥 Token response", "body", string(body))
 
 	var tokenResp EphemeralTokenResponse
 	if err := json.Unmarshal(body, &tokenResp); err != nil {
-		log.Printf("❌ Failed to parse token response: %v, Body: %s", err, string(body))
+		c.logger().Error("❌ Failed to parse token response", "error", err)
+		c.logger().Debug("📥 Token response", "body", string(body))
 		return err
 	}
 
 	if tokenResp.Value == "" {
-		log.Printf("❌ Empty token value in response")
+		c.logger().Error("❌ Empty token value in response")
 		return fmt.Errorf("empty token value received")
 	}
 
 	c.ephemeralToken = tokenResp.Value
-	log.Printf("✅ Got ephemeral token (first 20 chars): %.20s..., expires at: %d", tokenResp.Value, tokenResp.ExpiresAt)
+	c.logger().Info("✅ Got ephemeral token", "expires_at", tokenResp.ExpiresAt)
 
 	return nil
 }
@@ -436,14 +339,14 @@ func (c *OpenAIRealtimeClient) ConnectToRealtimeAPI(api *webrtc.API) error {
 	
 	// Log connection state changes
 	pc.OnConnectionStateChange(func(state webrtc.PeerConnectionState) {
-		log.Printf("🔌 OpenAI connection state: %s", state.String())
+		c.logger().Info("🔌 OpenAI connection state", "state", state.String())
 	})
 	
 	pc.OnICEConnectionStateChange(func(state webrtc.ICEConnectionState) {
-		log.Printf("🧊 OpenAI ICE state: %s", state.String())
+		c.logger().Info("🧊 OpenAI ICE state", "state", state.String())
 		// v4: Handle explicit DTLS close
 		if state == webrtc.ICEConnectionStateClosed {
-			log.Printf("🔴 OpenAI: ICE connection explicitly closed via DTLS")
+			c.logger().Info("🔴 OpenAI: ICE connection explicitly closed via DTLS")
 		}
 	})
 	
@@ -481,22 +384,17 @@ func (c *OpenAIRealtimeClient) ConnectToRealtimeAPI(api *webrtc.API) error {
 		return fmt.Errorf("failed to add audio transceiver from track: %v", err)
 	}
 	
-	log.Printf("✅ Added audio transceiver from track with direction: %v", transceiver.Direction())
+	c.logger().Debug("✅ Added audio transceiver from track", "direction", transceiver.Direction().String())
 	
 	// Read incoming RTCP packets (required for audio to work properly)
 	go func() {
-		rtcpBuf := make([]byte, 1500)
 		// Get the sender from the transceiver
 		sender := transceiver.Sender()
 		if sender == nil {
-			log.Printf("⚠️ No sender available for RTCP reading")
+			c.logger().Warn("⚠️ No sender available for RTCP reading")
 			return
 		}
-		for {
-			if _, _, rtcpErr := sender.Read(rtcpBuf); rtcpErr != nil {
-				return
-			}
-		}
+		readRTCP(sender, c.onSenderRTCP)
 	}()
 	
 	// Store the audio track for later use
@@ -504,308 +402,23 @@ func (c *OpenAIRealtimeClient) ConnectToRealtimeAPI(api *webrtc.API) error {
 	
 	// Handle incoming audio from OpenAI
 	pc.OnTrack(func(track *webrtc.TrackRemote, receiver *webrtc.RTPReceiver) {
-		log.Printf("🔊 Received audio track from OpenAI: %s (codec: %s)", track.ID(), track.Codec().MimeType)
+		c.logger().Info("🔊 Received audio track from OpenAI", "track", track.ID(), "codec", track.Codec().MimeType)
+		c.mu.Lock()
 		c.remoteAudioTrack = track
+		c.mu.Unlock()
+		if c.onReceiverRTCP != nil {
+			go readRTCP(receiver, c.onReceiverRTCP)
+		}
 	})
 	
 	// Set up data channel handlers
 	dataChannel.OnOpen(func() {
-		log.Println("✅ OpenAI Realtime data channel opened")
-		
-		// Send initial configuration (Azure format - flat structure)
-		config := map[string]interface{}{
-			"type": "session.update",
-			"session": map[string]interface{}{
-				"modalities": []string{"audio", "text"},
-				"instructions": c.getInstructions(),
-				"voice": "shimmer",
-				"turn_detection": map[string]interface{}{
-					"type": "server_vad",
-					"threshold": 0.5,
-					"prefix_padding_ms": 100,
-					"silence_duration_ms": 100,
-				},
-				"input_audio_transcription": map[string]interface{}{
-					"model": "whisper-1",
-				},
-				"tools": []map[string]interface{}{
-					{
-						"type": "function",
-						"name": "add_task",
-						"description": "Create a new task for the caller. Use this when they ask to add, create, or remember a task or todo item.",
-						"parameters": map[string]interface{}{
-							"type": "object",
-							"properties": map[string]interface{}{
-								"title": map[string]interface{}{
-									"type": "string",
-									"description": "Brief title of the task",
-								},
-								"description": map[string]interface{}{
-									"type": "string",
-									"description": "Detailed description of the task (optional)",
-								},
-								"priority": map[string]interface{}{
-									"type": "string",
-									"description": "Priority level: low, medium, high, or urgent",
-									"enum": []string{"low", "medium", "high", "urgent"},
-								},
-							},
-							"required": []string{"title"},
-						},
-					},
-					{
-						"type": "function",
-						"name": "list_tasks",
-						"description": "List all tasks for the caller. Can optionally filter by status.",
-						"parameters": map[string]interface{}{
-							"type": "object",
-							"properties": map[string]interface{}{
-								"status": map[string]interface{}{
-									"type": "string",
-									"description": "Filter by status: pending, in_progress, completed, or cancelled (optional)",
-									"enum": []string{"pending", "in_progress", "completed", "cancelled"},
-								},
-							},
-						},
-					},
-					{
-						"type": "function",
-						"name": "update_task_status",
-						"description": "Update the status of a task. Use when user wants to mark task as done, complete, in progress, etc.",
-						"parameters": map[string]interface{}{
-							"type": "object",
-							"properties": map[string]interface{}{
-								"task_id": map[string]interface{}{
-									"type": "string",
-									"description": "The ID of the task to update",
-								},
-								"status": map[string]interface{}{
-									"type": "string",
-									"description": "New status: pending, in_progress, completed, or cancelled",
-									"enum": []string{"pending", "in_progress", "completed", "cancelled"},
-								},
-							},
-							"required": []string{"task_id", "status"},
-						},
-					},
-					{
-						"type": "function",
-						"name": "add_reminder",
-						"description": "Set a reminder for the caller. Supports one-time and recurring reminders. When the reminder time comes, Ziggy will call them back.",
-						"parameters": map[string]interface{}{
-							"type": "object",
-							"properties": map[string]interface{}{
-								"reminder_text": map[string]interface{}{
-									"type": "string",
-									"description": "What to remind the user about",
-								},
-								"reminder_time": map[string]interface{}{
-									"type": "string",
-									"description": "When to send the reminder in local timezone using format YYYY-MM-DD HH:MM (e.g., 2025-11-09 14:30 for 2:30 PM). Use 24-hour format. Ask the user for the exact date and time if not provided.",
-								},
-								"recurrence": map[string]interface{}{
-									"type": "string",
-									"description": "Recurrence pattern: 'once' (default, one-time), 'daily', 'weekly', 'monthly', 'yearly'. Only specify if user wants recurring reminder.",
-									"enum": []string{"once", "daily", "weekly", "monthly", "yearly"},
-								},
-							},
-							"required": []string{"reminder_text", "reminder_time"},
-						},
-					},
-					{
-						"type": "function",
-						"name": "list_reminders",
-						"description": "List all reminders for the caller. Can filter by status (pending, called, completed, cancelled).",
-						"parameters": map[string]interface{}{
-							"type": "object",
-							"properties": map[string]interface{}{
-								"status": map[string]interface{}{
-									"type": "string",
-									"description": "Optional filter by status: 'pending', 'called', 'completed', 'cancelled'. If not provided, shows all reminders.",
-									"enum": []string{"pending", "called", "completed", "cancelled"},
-								},
-							},
-						},
-					},
-					{
-						"type": "function",
-						"name": "cancel_reminder",
-						"description": "Cancel a reminder. Use this when user wants to stop or delete a reminder.",
-						"parameters": map[string]interface{}{
-							"type": "object",
-							"properties": map[string]interface{}{
-								"reminder_id": map[string]interface{}{
-									"type": "string",
-									"description": "The ID of the reminder to cancel. Get this from list_reminders.",
-								},
-							},
-							"required": []string{"reminder_id"},
-						},
-					},
-					// Notes
-					{
-						"type": "function",
-						"name": "add_note",
-						"description": "Create a note for the caller. Use this when they ask to note something, write something down, remember something, or save information.",
-						"parameters": map[string]interface{}{
-							"type": "object",
-							"properties": map[string]interface{}{
-								"note_content": map[string]interface{}{
-									"type": "string",
-									"description": "The content of the note to save",
-								},
-							},
-							"required": []string{"note_content"},
-						},
-					},
-					{
-						"type": "function",
-						"name": "list_notes",
-						"description": "List all notes for the caller. Shows all saved notes in chronological order.",
-						"parameters": map[string]interface{}{
-							"type": "object",
-							"properties": map[string]interface{}{},
-						},
-					},
-					{
-						"type": "function",
-						"name": "search_notes",
-						"description": "Search through notes for specific keywords or content. Use this when user wants to find specific notes.",
-						"parameters": map[string]interface{}{
-							"type": "object",
-							"properties": map[string]interface{}{
-								"query": map[string]interface{}{
-									"type": "string",
-									"description": "Search query to find in notes",
-								},
-							},
-							"required": []string{"query"},
-						},
-					},
-					{
-						"type": "function",
-						"name": "delete_note",
-						"description": "Delete a specific note. Use this when user wants to remove or delete a note.",
-						"parameters": map[string]interface{}{
-							"type": "object",
-							"properties": map[string]interface{}{
-								"note_id": map[string]interface{}{
-									"type": "string",
-									"description": "The ID of the note to delete. Get this from list_notes or search_notes.",
-								},
-							},
-							"required": []string{"note_id"},
-						},
-					},
-				},
-				"tool_choice": "auto",
-				"temperature": 1.0,
-			},
-		}
-		
-		configJSON, _ := json.Marshal(config)
-		log.Printf("📤 Sending session update config: %s", string(configJSON))
-		if err := dataChannel.SendText(string(configJSON)); err != nil {
-			log.Printf("❌ Failed to send config: %v", err)
-		} else {
-			log.Printf("✅ Session update config sent successfully")
-		}
+		c.logger().Info("✅ OpenAI Realtime data channel opened")
+		c.sendSessionUpdate()
 	})
 	
 	dataChannel.OnMessage(func(msg webrtc.DataChannelMessage) {
-		// Parse the message
-		var event map[string]interface{}
-		if err := json.Unmarshal(msg.Data, &event); err != nil {
-			log.Printf("❌ Failed to parse message: %v, Data: %s", err, string(msg.Data))
-			return
-		}
-
-		// Handle different event types (GA interface)
-		eventType, _ := event["type"].(string)
-		switch eventType {
-		case "session.created":
-			log.Println("✅ Session created with OpenAI")
-			if session, ok := event["session"].(map[string]interface{}); ok {
-				log.Printf("📋 Session details: %+v", session)
-			}
-			// Trigger immediate greeting with NO delay
-			greeting := map[string]interface{}{
-				"type": "response.create",
-			}
-			greetingJSON, _ := json.Marshal(greeting)
-			if err := c.dataChannel.SendText(string(greetingJSON)); err != nil {
-				log.Printf("❌ Failed to send initial greeting: %v", err)
-			} else {
-				log.Println("🎙️ Triggered immediate greeting")
-			}
-		case "session.updated":
-			log.Println("✅ Session updated")
-			if session, ok := event["session"].(map[string]interface{}); ok {
-				if instructions, ok := session["instructions"].(string); ok {
-					log.Printf("📋 Active instructions: %s", instructions)
-				}
-				log.Printf("📋 Full session config: %+v", session)
-			}
-		case "conversation.item.created":
-			log.Println("📝 Conversation item created")
-		case "conversation.item.added":
-			log.Println("📝 Conversation item added")
-		case "conversation.item.done":
-			log.Println("✅ Conversation item done")
-		case "response.output_audio.delta":
-			// Audio data from OpenAI (GA interface - new event name)
-			if delta, ok := event["delta"].(string); ok {
-				log.Printf("🔊 Received audio delta from OpenAI: %d bytes (base64)", len(delta))
-			}
-			c.handleAudioDelta(event)
-		case "response.output_audio_transcript.delta":
-			// Transcript update (GA interface - new event name)
-			c.handleTranscriptDelta(event)
-		case "response.output_text.delta":
-			// Text response (GA interface - new event name)
-			if delta, ok := event["delta"].(string); ok {
-				log.Printf("💬 Response: %s", delta)
-			}
-		case "response.done":
-			log.Println("✅ Response complete")
-			// Log response details to debug why no audio
-			if response, ok := event["response"].(map[string]interface{}); ok {
-				log.Printf("📋 Response details: %+v", response)
-			}
-		case "input_audio_buffer.speech_started":
-			log.Println("🎤 Speech detected by OpenAI")
-		case "input_audio_buffer.speech_stopped":
-			log.Println("🔇 Speech ended")
-		case "input_audio_buffer.committed":
-			log.Println("📤 Audio buffer committed to OpenAI")
-		case "conversation.item.input_audio_transcription.completed":
-			// Transcription succeeded (GA interface)
-			if transcript, ok := event["transcript"].(string); ok {
-				log.Printf("📝 Transcription: %s", transcript)
-			}
-		case "conversation.item.input_audio_transcription.failed":
-			// Transcription failed - log detailed error
-			log.Printf("❌ Transcription failed! Event details: %+v", event)
-			if errorData, ok := event["error"].(map[string]interface{}); ok {
-				log.Printf("❌ Error details: %+v", errorData)
-				if code, ok := errorData["code"].(string); ok {
-					log.Printf("❌ Error code: %s", code)
-				}
-				if message, ok := errorData["message"].(string); ok {
-					log.Printf("❌ Error message: %s", message)
-				}
-			}
-		case "response.function_call_arguments.done":
-			// Function call completed
-			c.handleFunctionCall(event)
-		case "error":
-			log.Printf("❌ OpenAI error event: %+v", event)
-			if errorData, ok := event["error"].(map[string]interface{}); ok {
-				log.Printf("❌ Error details: %+v", errorData)
-			}
-		default:
-			log.Printf("📥 OpenAI event: %s", eventType)
-		}
+		c.handleEvent(msg.Data)
 	})
 	
 	c.dataChannel = dataChannel
@@ -830,9 +443,9 @@ func (c *OpenAIRealtimeClient) ConnectToRealtimeAPI(api *webrtc.API) error {
 	
 	select {
 	case <-gatherComplete:
-		log.Println("✅ ICE gathering complete for OpenAI connection")
+		c.logger().Debug("✅ ICE gathering complete for OpenAI connection")
 	case <-time.After(3 * time.Second):
-		log.Println("⏱️ ICE gathering timeout for OpenAI connection")
+		c.logger().Warn("⏱️ ICE gathering timeout for OpenAI connection")
 	}
 	
 	// Get the offer with candidates
@@ -841,11 +454,9 @@ func (c *OpenAIRealtimeClient) ConnectToRealtimeAPI(api *webrtc.API) error {
 		return fmt.Errorf("no local description available")
 	}
 	
-	// Log the SDP to verify it has audio
-	log.Printf("📄 SDP Offer to OpenAI (first 500 chars):\n%.500s", localDesc.SDP)
+	// Check the SDP has audio
 	if !strings.Contains(localDesc.SDP, "m=audio") {
-		log.Printf("⚠️ WARNING: SDP does not contain audio media section!")
-		log.Printf("📄 Full SDP:\n%s", localDesc.SDP)
+		c.logger().Warn("⚠️ SDP offer to OpenAI does not contain an audio media section")
 	}
 	
 	// Send offer to OpenAI
@@ -865,6 +476,158 @@ func (c *OpenAIRealtimeClient) ConnectToRealtimeAPI(api *webrtc.API) error {
 	return nil
 }
 
+// sessionConfig is the session.update payload: instructions, voice, VAD and tools.
+// The WebSocket transport also asks for pcm16 audio in both directions.
+func (c *OpenAIRealtimeClient) sessionConfig() map[string]interface{} {
+	session := map[string]interface{}{
+		"modalities": []string{"audio", "text"},
+		"instructions": c.getInstructions(),
+		"voice": "shimmer",
+		"turn_detection": map[string]interface{}{
+			"type": "server_vad",
+			"threshold": 0.5,
+			"prefix_padding_ms": 100,
+			"silence_duration_ms": 100,
+		},
+		"input_audio_transcription": map[string]interface{}{
+			"model": "whisper-1",
+		},
+		"tools": toolRegistry.RealtimeTools(c.toolCaller()),
+		"tool_choice": "auto",
+		"temperature": 1.0,
+	}
+	if c.transport == realtimeTransportWebSocket {
+		session["input_audio_format"] = "pcm16"
+		session["output_audio_format"] = "pcm16"
+	}
+	return session
+}
+
+// sendSessionUpdate configures the session once the event channel is open
+func (c *OpenAIRealtimeClient) sendSessionUpdate() {
+	config := map[string]interface{}{
+		"type":    "session.update",
+		"session": c.sessionConfig(),
+	}
+	configJSON, _ := json.Marshal(config)
+	c.logger().Debug("📤 Sending session update config", "session", string(configJSON))
+	if err := c.sendEvent(config); err != nil {
+		c.logger().Error("❌ Failed to send config", "error", err)
+	} else {
+		c.logger().Info("✅ Session update config sent successfully")
+	}
+}
+
+// handleEvent dispatches one server event from either transport
+func (c *OpenAIRealtimeClient) handleEvent(data []byte) {
+	// Parse the message
+	var event map[string]interface{}
+	if err := json.Unmarshal(data, &event); err != nil {
+		c.logger().Error("❌ Failed to parse message", "error", err)
+		c.logger().Debug("📥 Unparsed message", "event", string(data))
+		return
+	}
+
+	// Handle different event types (GA interface)
+	eventType, _ := event["type"].(string)
+	switch eventType {
+	case "session.created":
+		c.logger().Info("✅ Session created with OpenAI")
+		if session, ok := event["session"].(map[string]interface{}); ok {
+			c.logger().Debug("📋 Session details", "session", session)
+		}
+		// Trigger immediate greeting with NO delay
+		greeting := map[string]interface{}{
+			"type": "response.create",
+		}
+		if err := c.sendEvent(greeting); err != nil {
+			c.logger().Error("❌ Failed to send initial greeting", "error", err)
+		} else {
+			c.logger().Info("🎙️ Triggered immediate greeting")
+		}
+	case "session.updated":
+		c.logger().Info("✅ Session updated")
+		if session, ok := event["session"].(map[string]interface{}); ok {
+			if instructions, ok := session["instructions"].(string); ok {
+				c.logger().Debug("📋 Active instructions", "instructions", instructions)
+			}
+			c.logger().Debug("📋 Full session config", "session", session)
+		}
+	case "conversation.item.created":
+		c.logger().Debug("📝 Conversation item created")
+	case "conversation.item.added":
+		c.logger().Debug("📝 Conversation item added")
+	case "conversation.item.done":
+		c.logger().Debug("✅ Conversation item done")
+	case "response.output_audio.delta", "response.audio.delta":
+		// Audio data from OpenAI (GA name; Azure preview WebSocket sessions use the beta name).
+		// Only the WebSocket transport carries audio in events.
+		c.handleAudioDelta(event)
+	case "response.output_audio_transcript.delta", "response.audio_transcript.delta":
+		// Transcript update (GA interface - new event name)
+		c.handleTranscriptDelta(event)
+	case "response.output_audio_transcript.done", "response.audio_transcript.done":
+		// The assistant finished speaking this item
+		if c.transcript != nil {
+			itemID, _ := event["item_id"].(string)
+			transcript, _ := event["transcript"].(string)
+			c.transcript.assistantDone(itemID, transcript)
+		}
+	case "response.output_text.delta":
+		// Text response (GA interface - new event name)
+		if delta, ok := event["delta"].(string); ok {
+			c.logger().Debug("💬 Response", "delta", delta)
+		}
+	case "response.done":
+		c.logger().Info("✅ Response complete")
+		// Log response details to debug why no audio
+		if response, ok := event["response"].(map[string]interface{}); ok {
+			c.logger().Debug("📋 Response details", "event", response)
+		}
+	case "input_audio_buffer.speech_started":
+		c.logger().Info("🎤 Speech detected by OpenAI")
+		if c.transcript != nil {
+			itemID, _ := event["item_id"].(string)
+			c.transcript.userSpeechStarted(itemID)
+		}
+		// Over WebRTC OpenAI cuts its own audio; over WebSocket the queued audio is ours to drop
+		if audio := c.realtimeAudio(); audio != nil {
+			audio.interrupt()
+		}
+	case "input_audio_buffer.speech_stopped":
+		c.logger().Info("🔇 Speech ended")
+	case "input_audio_buffer.committed":
+		c.logger().Debug("📤 Audio buffer committed to OpenAI")
+	case "conversation.item.input_audio_transcription.completed":
+		// Transcription succeeded (GA interface)
+		if transcript, ok := event["transcript"].(string); ok {
+			c.logger().Info("📝 Transcription", "transcript", transcript)
+			if c.transcript != nil {
+				itemID, _ := event["item_id"].(string)
+				c.transcript.userTurn(itemID, transcript)
+			}
+		}
+	case "conversation.item.input_audio_transcription.failed":
+		// Transcription failed - log detailed error
+		errorData, _ := event["error"].(map[string]interface{})
+		code, _ := errorData["code"].(string)
+		message, _ := errorData["message"].(string)
+		c.logger().Error("❌ Transcription failed", "code", code, "message", message)
+		c.logger().Debug("❌ Transcription failure event", "event", event)
+	case "response.function_call_arguments.done":
+		// Function call completed
+		c.handleFunctionCall(event)
+	case "error":
+		errorData, _ := event["error"].(map[string]interface{})
+		code, _ := errorData["code"].(string)
+		message, _ := errorData["message"].(string)
+		c.logger().Error("❌ OpenAI error event", "code", code, "message", message)
+		c.logger().Debug("❌ OpenAI error event details", "event", event)
+	default:
+		c.logger().Debug("📥 OpenAI event", "type", eventType)
+	}
+}
+
 // sendOfferToOpenAI sends the WebRTC offer to OpenAI and gets the answer (GA interface)
 func (c *OpenAIRealtimeClient) sendOfferToOpenAI(offerSDP string) (string, error) {
 	if c.ephemeralToken == "" {
@@ -876,15 +639,14 @@ func (c *OpenAIRealtimeClient) sendOfferToOpenAI(offerSDP string) (string, error
 	// Use Azure endpoint if configured
 	if c.azureEndpoint != "" && c.azureDeployment != "" {
 		// Azure WebRTC endpoint uses region-specific subdomain
-		url = fmt.Sprintf("https://eastus2.realtimeapi-preview.ai.azure.com/v1/realtimertc?model=%s",
-			c.azureDeployment)
-		log.Printf("🔵 Using Azure OpenAI WebRTC endpoint: %s", url)
+		url = fmt.Sprintf("%s?model=%s", azureRealtimeWebRTCURL(), c.azureDeployment)
+		c.logger().Info("🔵 Using Azure OpenAI WebRTC endpoint", "url", url)
 	} else {
-		url = "https://api.openai.com/v1/realtime/calls"
+		url = realtimeBaseURL() + "/calls"
 	}
 
-	log.Printf("📤 Sending SDP offer (length: %d bytes)", len(offerSDP))
-	log.Printf("📄 Full SDP Offer:\n%s", offerSDP)
+	c.logger().Info("📤 Sending SDP offer", "bytes", len(offerSDP))
+	c.logger().Debug("📄 SDP offer to OpenAI", "sdp", offerSDP)
 
 	req, err := http.NewRequest("POST", url, bytes.NewReader([]byte(offerSDP)))
 	if err != nil {
@@ -908,68 +670,85 @@ func (c *OpenAIRealtimeClient) sendOfferToOpenAI(offerSDP string) (string, error
 	}
 
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
-		log.Printf("❌ OpenAI SDP exchange failed. Status: %s, Response: %s", resp.Status, string(answerSDP))
+		c.logger().Error("❌ OpenAI SDP exchange failed", "status", resp.Status, "response", string(answerSDP))
 		return "", fmt.Errorf("OpenAI API error: %s - %s", resp.Status, string(answerSDP))
 	}
 
-	log.Printf("📥 Received SDP answer from OpenAI (status: %d, length: %d bytes)", resp.StatusCode, len(answerSDP))
+	c.logger().Info("📥 Received SDP answer from OpenAI", "status", resp.StatusCode, "bytes", len(answerSDP))
+	c.logger().Debug("📄 SDP answer from OpenAI", "sdp", string(answerSDP))
 	return string(answerSDP), nil
 }
 
-// SendAudioToOpenAI sends audio data to OpenAI
-func (c *OpenAIRealtimeClient) SendAudioToOpenAI(audioData []byte) error {
-	if c.dataChannel == nil || c.dataChannel.ReadyState() != webrtc.DataChannelStateOpen {
-		return fmt.Errorf("data channel not open")
-	}
-	
-	// Send audio append event
-	event := map[string]interface{}{
-		"type": "input_audio_buffer.append",
-		"audio": audioData, // Should be base64 encoded PCM16 audio
-	}
-	
+// sendEvent sends a client event over whichever transport the session uses
+func (c *OpenAIRealtimeClient) sendEvent(event map[string]interface{}) error {
 	eventJSON, err := json.Marshal(event)
 	if err != nil {
 		return err
 	}
-	
+
+	if c.transport == realtimeTransportWebSocket {
+		c.mu.Lock()
+		ws := c.ws
+		c.mu.Unlock()
+		if ws == nil {
+			return fmt.Errorf("realtime WebSocket not connected")
+		}
+		return ws.send(eventJSON)
+	}
+
+	if c.dataChannel == nil || c.dataChannel.ReadyState() != webrtc.DataChannelStateOpen {
+		return fmt.Errorf("data channel not open")
+	}
 	return c.dataChannel.SendText(string(eventJSON))
 }
 
+// SendAudioToOpenAI sends base64-encoded PCM16 audio to the input buffer
+func (c *OpenAIRealtimeClient) SendAudioToOpenAI(audioData []byte) error {
+	return c.sendEvent(map[string]interface{}{
+		"type":  "input_audio_buffer.append",
+		"audio": string(audioData),
+	})
+}
+
 // CommitAudioBuffer commits the audio buffer for processing
 func (c *OpenAIRealtimeClient) CommitAudioBuffer() error {
-	if c.dataChannel == nil || c.dataChannel.ReadyState() != webrtc.DataChannelStateOpen {
-		return fmt.Errorf("data channel not open")
-	}
-	
-	event := map[string]interface{}{
+	return c.sendEvent(map[string]interface{}{
 		"type": "input_audio_buffer.commit",
-	}
-	
-	eventJSON, err := json.Marshal(event)
-	if err != nil {
-		return err
-	}
-	
-	return c.dataChannel.SendText(string(eventJSON))
+	})
 }
 
-// handleAudioDelta processes audio responses from OpenAI
+// handleAudioDelta encodes base64 PCM16 audio from OpenAI into Opus RTP for the WhatsApp caller
 func (c *OpenAIRealtimeClient) handleAudioDelta(event map[string]interface{}) {
-	// Extract audio data from the event
-	// This would contain base64 encoded PCM16 audio data
-	// You would decode and play this audio to the WhatsApp caller
-	
-	if delta, ok := event["delta"].(string); ok {
-		log.Printf("🔊 Received audio delta: %d bytes", len(delta))
-		// TODO: Decode base64 and send to WhatsApp caller
+	delta, ok := event["delta"].(string)
+	if !ok {
+		return
+	}
+
+	audio := c.realtimeAudio()
+	if audio == nil {
+		c.logger().Warn("⚠️ Dropping audio delta: no WebSocket audio pipeline", "bytes", len(delta))
+		return
+	}
+	if err := audio.encode(delta); err != nil {
+		c.logger().Error("❌ Failed to encode audio delta", "error", err)
 	}
 }
 
+// realtimeAudio returns the WebSocket audio pipeline, or nil on WebRTC
+func (c *OpenAIRealtimeClient) realtimeAudio() *realtimeAudio {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.audio
+}
+
 // handleTranscriptDelta processes transcript updates
 func (c *OpenAIRealtimeClient) handleTranscriptDelta(event map[string]interface{}) {
 	if delta, ok := event["delta"].(string); ok {
-		log.Printf("💬 Transcript: %s", delta)
+		c.logger().Debug("💬 Transcript", "transcript", delta)
+		if c.transcript != nil {
+			itemID, _ := event["item_id"].(string)
+			c.transcript.assistantDelta(itemID, delta)
+		}
 	}
 }
 
@@ -990,362 +769,82 @@ func (c *OpenAIRealtimeClient) ForwardRTPToOpenAI(rtpPacket []byte) error {
 
 // GetRemoteAudioTrack returns the audio track from OpenAI
 func (c *OpenAIRealtimeClient) GetRemoteAudioTrack() *webrtc.TrackRemote {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	return c.remoteAudioTrack
 }
 
-// TriggerResponse sends a response.create event to make OpenAI speak
-func (c *OpenAIRealtimeClient) TriggerResponse(text string) error {
-	if c.dataChannel == nil || c.dataChannel.ReadyState() != webrtc.DataChannelStateOpen {
-		return fmt.Errorf("data channel not open")
-	}
+// Name implements VoiceAgent
+func (c *OpenAIRealtimeClient) Name() string { return "openai" }
 
-	event := map[string]interface{}{
-		"type": "response.create",
-		"response": map[string]interface{}{
-			"instructions": text,
-		},
+// Connect implements VoiceAgent: it opens the WebSocket session, or fetches an
+// ephemeral token and opens the WebRTC session with the Realtime API
+func (c *OpenAIRealtimeClient) Connect() error {
+	if c.transport == realtimeTransportWebSocket {
+		if err := c.ConnectToRealtimeWebSocket(); err != nil {
+			return fmt.Errorf("failed to connect to OpenAI: %w", err)
+		}
+		return nil
 	}
 
-	eventJSON, err := json.Marshal(event)
-	if err != nil {
-		return err
+	if err := c.GetEphemeralToken(); err != nil {
+		return fmt.Errorf("failed to get OpenAI token: %w", err)
 	}
-
-	log.Printf("📤 Triggering OpenAI response: %s", text)
-	return c.dataChannel.SendText(string(eventJSON))
+	if err := c.ConnectToRealtimeAPI(c.api); err != nil {
+		return fmt.Errorf("failed to connect to OpenAI: %w", err)
+	}
+	return nil
 }
 
-// handleFunctionCall processes function call requests from OpenAI
-func (c *OpenAIRealtimeClient) handleFunctionCall(event map[string]interface{}) {
-	log.Printf("🔧 [FUNCTION_CALL] Full event: %+v", event)
-
-	// Extract function name and arguments
-	functionName, _ := event["name"].(string)
-	arguments, _ := event["arguments"].(string)
-	callID, _ := event["call_id"].(string)
-
-	log.Printf("📞 [FUNCTION_CALL] Function=%s, CallID=%s", functionName, callID)
-	log.Printf("📞 [FUNCTION_CALL] Arguments string: %s", arguments)
-	
-	// Parse arguments
-	var args map[string]interface{}
-	if arguments != "" {
-		if err := json.Unmarshal([]byte(arguments), &args); err != nil {
-			log.Printf("❌ Failed to parse function arguments: %v", err)
-			return
-		}
+// WriteRTP implements VoiceAgent. Over WebSocket the Opus is decoded and
+// appended to the input buffer as PCM16; server VAD commits it.
+func (c *OpenAIRealtimeClient) WriteRTP(packet []byte) error {
+	if c.transport != realtimeTransportWebSocket {
+		return c.ForwardRTPToOpenAI(packet)
 	}
 
-	var resultJSON []byte
-
-	switch functionName {
-	case "add_task":
-		title, _ := args["title"].(string)
-		description, _ := args["description"].(string)
-		priority, _ := args["priority"].(string)
-
-		if priority == "" {
-			priority = "medium"
-		}
-
-		log.Printf("📝 Adding task: %s (priority: %s)", title, priority)
-
-		task, err := AddTask(title, description, priority, c.phoneNumber)
-		if err != nil {
-			log.Printf("❌ Failed to add task: %v", err)
-			errorResult := map[string]string{
-				"status": "error",
-				"message": fmt.Sprintf("Failed to create task: %v", err),
-			}
-			resultJSON, _ = json.Marshal(errorResult)
-		} else {
-			resultJSON, _ = json.Marshal(map[string]interface{}{
-				"status": "success",
-				"message": fmt.Sprintf("Task '%s' created successfully", title),
-				"task_id": task.ID,
-				"title": task.Title,
-			})
-			log.Printf("✅ Task created: %s (ID: %s)", title, task.ID)
-		}
-
-	case "list_tasks":
-		status, _ := args["status"].(string)
-		log.Printf("📋 Listing tasks (status filter: %s)", status)
-
-		tasks, err := ListTasks(c.phoneNumber, status)
-		if err != nil {
-			log.Printf("❌ Failed to list tasks: %v", err)
-			errorResult := map[string]string{
-				"status": "error",
-				"message": fmt.Sprintf("Failed to list tasks: %v", err),
-			}
-			resultJSON, _ = json.Marshal(errorResult)
-		} else {
-			// Format tasks for the AI
-			taskList := make([]map[string]interface{}, len(tasks))
-			for i, task := range tasks {
-				taskList[i] = map[string]interface{}{
-					"id":          task.ID,
-					"title":       task.Title,
-					"description": task.Description,
-					"status":      task.Status,
-					"priority":    task.Priority,
-				}
-			}
-			resultJSON, _ = json.Marshal(map[string]interface{}{
-				"status": "success",
-				"count":  len(tasks),
-				"tasks":  taskList,
-			})
-			log.Printf("✅ Retrieved %d tasks", len(tasks))
-		}
-
-	case "update_task_status":
-		taskID, _ := args["task_id"].(string)
-		newStatus, _ := args["status"].(string)
-
-		log.Printf("🔄 Updating task %s to status: %s", taskID, newStatus)
-
-		err := UpdateTaskStatus(taskID, newStatus)
-		if err != nil {
-			log.Printf("❌ Failed to update task: %v", err)
-			errorResult := map[string]string{
-				"status": "error",
-				"message": fmt.Sprintf("Failed to update task: %v", err),
-			}
-			resultJSON, _ = json.Marshal(errorResult)
-		} else {
-			resultJSON, _ = json.Marshal(map[string]interface{}{
-				"status": "success",
-				"message": fmt.Sprintf("Task updated to %s", newStatus),
-				"task_id": taskID,
-			})
-			log.Printf("✅ Task %s updated to %s", taskID, newStatus)
-		}
-
-	case "add_reminder":
-		reminderText, _ := args["reminder_text"].(string)
-		reminderTime, _ := args["reminder_time"].(string)
-		recurrence, _ := args["recurrence"].(string)
-		if recurrence == "" {
-			recurrence = "once"
-		}
-
-		log.Printf("⏰ Adding %s reminder: %s at %s", recurrence, reminderText, reminderTime)
-
-		reminder, err := AddReminder(reminderText, reminderTime, c.phoneNumber, recurrence)
-		if err != nil {
-			log.Printf("❌ Failed to add reminder: %v", err)
-			errorResult := map[string]string{
-				"status": "error",
-				"message": fmt.Sprintf("Failed to create reminder: %v", err),
-			}
-			resultJSON, _ = json.Marshal(errorResult)
-		} else {
-			var message string
-			if recurrence == "once" {
-				message = fmt.Sprintf("Reminder set for %s. I'll call you back at that time.", reminderTime)
-			} else {
-				message = fmt.Sprintf("%s reminder set for %s. I'll call you back %s.",
-					recurrence, reminderTime, recurrence)
-			}
-			resultJSON, _ = json.Marshal(map[string]interface{}{
-				"status": "success",
-				"message": message,
-				"reminder_id": reminder.ID,
-				"reminder_text": reminder.ReminderText,
-				"reminder_time": reminder.ReminderTime,
-				"recurrence": reminder.RecurrencePattern,
-			})
-			log.Printf("✅ %s reminder created: %s at %s (ID: %s)", recurrence, reminderText, reminderTime, reminder.ID)
-		}
-
-	case "list_reminders":
-		status, _ := args["status"].(string)
-
-		log.Printf("📋 Listing reminders (status: %s)", status)
-
-		reminders, err := ListReminders(c.phoneNumber, status)
-		if err != nil {
-			log.Printf("❌ Failed to list reminders: %v", err)
-			errorResult := map[string]string{
-				"status": "error",
-				"message": fmt.Sprintf("Failed to list reminders: %v", err),
-			}
-			resultJSON, _ = json.Marshal(errorResult)
-		} else {
-			var reminderList []map[string]interface{}
-			for _, r := range reminders {
-				reminderList = append(reminderList, map[string]interface{}{
-					"id":          r.ID,
-					"text":        r.ReminderText,
-					"time":        r.ReminderTime,
-					"recurrence":  r.RecurrencePattern,
-					"status":      r.Status,
-				})
-			}
-			resultJSON, _ = json.Marshal(map[string]interface{}{
-				"status": "success",
-				"count":  len(reminders),
-				"reminders": reminderList,
-			})
-			log.Printf("✅ Retrieved %d reminders", len(reminders))
-		}
-
-	case "cancel_reminder":
-		reminderID, _ := args["reminder_id"].(string)
-
-		log.Printf("🗑️ Cancelling reminder: %s", reminderID)
-
-		err := CancelReminder(reminderID)
-		if err != nil {
-			log.Printf("❌ Failed to cancel reminder: %v", err)
-			errorResult := map[string]string{
-				"status": "error",
-				"message": fmt.Sprintf("Failed to cancel reminder: %v", err),
-			}
-			resultJSON, _ = json.Marshal(errorResult)
-		} else {
-			resultJSON, _ = json.Marshal(map[string]interface{}{
-				"status": "success",
-				"message": "Reminder cancelled successfully",
-				"reminder_id": reminderID,
-			})
-			log.Printf("✅ Reminder %s cancelled", reminderID)
-		}
-
-	case "add_note":
-		noteContent, _ := args["note_content"].(string)
-
-		log.Printf("📝 [NOTES] ========== ADD_NOTE FUNCTION CALLED ==========")
-		log.Printf("📝 [NOTES] Phone number: %s", c.phoneNumber)
-		log.Printf("📝 [NOTES] Note content: %s", noteContent)
-		log.Printf("📝 [NOTES] Full args: %+v", args)
-		log.Printf("📝 [NOTES] Calling AddNote() now...")
-
-		note, err := AddNote(noteContent, c.phoneNumber)
-		if err != nil {
-			log.Printf("❌ [NOTES] AddNote() returned error: %v", err)
-			errorResult := map[string]string{
-				"status":  "error",
-				"message": fmt.Sprintf("Failed to save note: %v", err),
-			}
-			resultJSON, _ = json.Marshal(errorResult)
-		} else {
-			log.Printf("✅ [NOTES] AddNote() returned success!")
-			log.Printf("✅ [NOTES] Note ID: %s", note.ID)
-			log.Printf("✅ [NOTES] Note content: %s", note.NoteContent)
-			resultJSON, _ = json.Marshal(map[string]interface{}{
-				"status":  "success",
-				"message": "Note saved successfully",
-				"note_id": note.ID,
-				"content": note.NoteContent,
-			})
-		}
-		log.Printf("📝 [NOTES] Function result JSON: %s", string(resultJSON))
-
-	case "list_notes":
-		log.Printf("📋 Listing notes")
-
-		notes, err := ListNotes(c.phoneNumber, 0)
-		if err != nil {
-			log.Printf("❌ Failed to list notes: %v", err)
-			errorResult := map[string]string{
-				"status":  "error",
-				"message": fmt.Sprintf("Failed to list notes: %v", err),
-			}
-			resultJSON, _ = json.Marshal(errorResult)
-		} else {
-			var noteList []map[string]interface{}
-			for _, n := range notes {
-				noteList = append(noteList, map[string]interface{}{
-					"id":      n.ID,
-					"content": n.NoteContent,
-				})
-			}
-			resultJSON, _ = json.Marshal(map[string]interface{}{
-				"status": "success",
-				"count":  len(notes),
-				"notes":  noteList,
-			})
-			log.Printf("✅ Retrieved %d notes", len(notes))
-		}
-
-	case "search_notes":
-		query, _ := args["query"].(string)
-
-		log.Printf("🔍 Searching notes for: %s", query)
-
-		notes, err := SearchNotes(c.phoneNumber, query)
-		if err != nil {
-			log.Printf("❌ Failed to search notes: %v", err)
-			errorResult := map[string]string{
-				"status":  "error",
-				"message": fmt.Sprintf("Failed to search notes: %v", err),
-			}
-			resultJSON, _ = json.Marshal(errorResult)
-		} else {
-			var noteList []map[string]interface{}
-			for _, n := range notes {
-				noteList = append(noteList, map[string]interface{}{
-					"id":      n.ID,
-					"content": n.NoteContent,
-				})
-			}
-			resultJSON, _ = json.Marshal(map[string]interface{}{
-				"status": "success",
-				"count":  len(notes),
-				"notes":  noteList,
-				"query":  query,
-			})
-			log.Printf("✅ Found %d notes matching '%s'", len(notes), query)
-		}
-
-	case "delete_note":
-		noteID, _ := args["note_id"].(string)
-
-		log.Printf("🗑️ Deleting note: %s", noteID)
-
-		err := DeleteNote(noteID)
-		if err != nil {
-			log.Printf("❌ Failed to delete note: %v", err)
-			errorResult := map[string]string{
-				"status":  "error",
-				"message": fmt.Sprintf("Failed to delete note: %v", err),
-			}
-			resultJSON, _ = json.Marshal(errorResult)
-		} else {
-			resultJSON, _ = json.Marshal(map[string]interface{}{
-				"status":  "success",
-				"message": "Note deleted successfully",
-				"note_id": noteID,
-			})
-			log.Printf("✅ Note %s deleted", noteID)
-		}
+	audio := c.realtimeAudio()
+	if audio == nil {
+		return fmt.Errorf("realtime WebSocket not connected")
+	}
+	pcm, err := audio.decode(packet)
+	if err != nil || pcm == "" {
+		return err
+	}
+	return c.SendAudioToOpenAI([]byte(pcm))
+}
 
-	default:
-		log.Printf("⚠️ Unknown function: %s", functionName)
-		errorResult := map[string]string{
-			"status": "error",
-			"message": fmt.Sprintf("Unknown function: %s", functionName),
-		}
-		resultJSON, _ = json.Marshal(errorResult)
+// AudioOutput implements VoiceAgent
+func (c *OpenAIRealtimeClient) AudioOutput() RTPSource {
+	if audio := c.realtimeAudio(); audio != nil {
+		return audio.output
+	}
+	track := c.GetRemoteAudioTrack()
+	if track == nil {
+		return nil
 	}
+	return trackRTPSource{track: track}
+}
+
+// InjectText implements VoiceAgent
+func (c *OpenAIRealtimeClient) InjectText(text string) error {
+	return c.TriggerResponse(text)
+}
 
-	// Send function result back
+// SendToolResult implements VoiceAgent: it returns a function call's output
+// and asks the model to respond to it
+func (c *OpenAIRealtimeClient) SendToolResult(toolCallID, output string) error {
 	functionOutput := map[string]interface{}{
 		"type": "conversation.item.create",
 		"item": map[string]interface{}{
 			"type":    "function_call_output",
-			"call_id": callID,
-			"output":  string(resultJSON),
+			"call_id": toolCallID,
+			"output":  output,
 		},
 	}
 
-	outputJSON, _ := json.Marshal(functionOutput)
-	if err := c.dataChannel.SendText(string(outputJSON)); err != nil {
-		log.Printf("❌ Failed to send function output: %v", err)
-		return
+	if err := c.sendEvent(functionOutput); err != nil {
+		return fmt.Errorf("failed to send function output: %w", err)
 	}
 
 	// Trigger model to respond
@@ -1353,16 +852,72 @@ func (c *OpenAIRealtimeClient) handleFunctionCall(event map[string]interface{})
 		"type": "response.create",
 	}
 
-	responseJSON, _ := json.Marshal(responseCreate)
-	if err := c.dataChannel.SendText(string(responseJSON)); err != nil {
-		log.Printf("❌ Failed to trigger response: %v", err)
-	} else {
-		log.Printf("🎙️ Triggered model response")
+	if err := c.sendEvent(responseCreate); err != nil {
+		return fmt.Errorf("failed to trigger response: %w", err)
+	}
+	c.logger().Info("🎙️ Triggered model response")
+	return nil
+}
+
+// TriggerResponse sends a response.create event to make OpenAI speak
+func (c *OpenAIRealtimeClient) TriggerResponse(text string) error {
+	event := map[string]interface{}{
+		"type": "response.create",
+		"response": map[string]interface{}{
+			"instructions": text,
+		},
+	}
+
+	c.logger().Info("📤 Triggering OpenAI response")
+	c.logger().Debug("📤 Response instructions", "instructions", text)
+	return c.sendEvent(event)
+}
+
+// logger tags the client's records with the call it serves
+func (c *OpenAIRealtimeClient) logger() *slog.Logger {
+	return callLogger(c.callID)
+}
+
+// toolCaller identifies this call to the tool registry
+func (c *OpenAIRealtimeClient) toolCaller() *ToolCaller {
+	return &ToolCaller{
+		Tenant:      c.tenant,
+		PhoneNumber: c.phoneNumber,
+		Channel:     toolChannelVoice,
+		EndCall:     c.onEndCall,
+	}
+}
+
+// handleFunctionCall runs a function the assistant called and sends back the result
+func (c *OpenAIRealtimeClient) handleFunctionCall(event map[string]interface{}) {
+	functionName, _ := event["name"].(string)
+	arguments, _ := event["arguments"].(string)
+	callID, _ := event["call_id"].(string)
+
+	logger := c.logger().With("function", functionName, "tool_call_id", callID)
+	logger.Info("📞 [FUNCTION_CALL] Assistant called a function")
+	logger.Debug("📞 [FUNCTION_CALL] Arguments", "arguments", arguments)
+
+	result := toolRegistry.Execute(withLogger(context.Background(), logger), c.toolCaller(), functionName, arguments)
+	if err := c.SendToolResult(callID, result); err != nil {
+		logger.Error("❌ Failed to send function result", "error", err)
 	}
 }
 
 // Close closes the connection to OpenAI
 func (c *OpenAIRealtimeClient) Close() {
+	c.mu.Lock()
+	ws, audio := c.ws, c.audio
+	c.mu.Unlock()
+	if ws != nil {
+		ws.conn.Close()
+	}
+	if audio != nil {
+		audio.close()
+	}
+	if c.transcript != nil {
+		c.transcript.flush()
+	}
 	if c.dataChannel != nil {
 		c.dataChannel.Close()
 	}
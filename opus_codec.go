@@ -0,0 +1,269 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+	"os"
+	"time"
+
+	"github.com/pion/rtp"
+	"github.com/thesyncim/gopus"
+)
+
+// Opus on WhatsApp and the Realtime WebRTC endpoint always runs on a 48 kHz
+// RTP clock with 20 ms frames
+const (
+	opusSampleRate   = 48000
+	opusFrameSamples = 960  // 20 ms at 48 kHz
+	opusMaxFrameSize = 5760 // 120 ms at 48 kHz, the longest Opus packet
+	opusMaxPacket    = 1500
+)
+
+// OpusDecoder turns Opus RTP payloads into mono PCM16 at 48 kHz
+type OpusDecoder struct {
+	dec *gopus.Decoder
+	pcm []int16
+}
+
+// NewOpusDecoder creates a mono 48 kHz decoder
+func NewOpusDecoder() (*OpusDecoder, error) {
+	dec, err := gopus.NewDecoder(gopus.DefaultDecoderConfig(opusSampleRate, 1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Opus decoder: %w", err)
+	}
+	return &OpusDecoder{dec: dec, pcm: make([]int16, opusMaxFrameSize)}, nil
+}
+
+// Decode decodes one Opus payload. The returned slice is reused by the next call.
+func (d *OpusDecoder) Decode(payload []byte) ([]int16, error) {
+	n, err := d.dec.DecodeInt16(payload, d.pcm)
+	if err != nil {
+		return nil, err
+	}
+	return d.pcm[:n], nil
+}
+
+// Conceal synthesizes one 20 ms frame in place of a lost packet. The returned
+// slice is reused by the next call.
+func (d *OpusDecoder) Conceal() ([]int16, error) {
+	n, err := d.dec.DecodeInt16(nil, d.pcm[:opusFrameSamples])
+	if err != nil {
+		return nil, err
+	}
+	return d.pcm[:n], nil
+}
+
+// OpusRTPEncoder encodes PCM16 at 48 kHz into 20 ms Opus RTP packets
+type OpusRTPEncoder struct {
+	enc         *gopus.Encoder
+	channels    int
+	pending     []int16 // Samples waiting for a full frame (interleaved if stereo)
+	buf         []byte
+	payloadType uint8
+	sequence    uint16
+	timestamp   uint32
+}
+
+// NewOpusRTPEncoder creates a mono VoIP-tuned encoder producing packets with payloadType
+func NewOpusRTPEncoder(payloadType uint8) (*OpusRTPEncoder, error) {
+	return newOpusRTPEncoder(payloadType, 1)
+}
+
+// newOpusRTPEncoder creates an encoder for interleaved PCM16 with the given channel count
+func newOpusRTPEncoder(payloadType uint8, channels int) (*OpusRTPEncoder, error) {
+	enc, err := gopus.NewEncoder(gopus.EncoderConfig{
+		SampleRate:  opusSampleRate,
+		Channels:    channels,
+		Application: gopus.ApplicationVoIP,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Opus encoder: %w", err)
+	}
+	// Match what WhatsApp advertises: useinbandfec=1, maxaveragebitrate=20000
+	enc.SetFEC(true)
+	if err := enc.SetBitrate(20000); err != nil {
+		return nil, fmt.Errorf("failed to set Opus bitrate: %w", err)
+	}
+
+	return &OpusRTPEncoder{
+		enc:         enc,
+		channels:    channels,
+		buf:         make([]byte, opusMaxPacket),
+		payloadType: payloadType,
+		sequence:    uint16(rand.Uint32()),
+		timestamp:   rand.Uint32(),
+	}, nil
+}
+
+// Encode buffers pcm and returns a packet for every complete 20 ms frame
+func (e *OpusRTPEncoder) Encode(pcm []int16) ([]*rtp.Packet, error) {
+	e.pending = append(e.pending, pcm...)
+
+	frame := opusFrameSamples * e.channels
+	var packets []*rtp.Packet
+	offset := 0
+	for len(e.pending)-offset >= frame {
+		n, err := e.enc.EncodeInt16(e.pending[offset:offset+frame], e.buf)
+		if err != nil {
+			e.pending = append(e.pending[:0], e.pending[offset:]...)
+			return packets, err
+		}
+		offset += frame
+
+		payload := make([]byte, n)
+		copy(payload, e.buf[:n])
+		packets = append(packets, &rtp.Packet{
+			Header: rtp.Header{
+				Version:        2,
+				PayloadType:    e.payloadType,
+				SequenceNumber: e.sequence,
+				Timestamp:      e.timestamp,
+			},
+			Payload: payload,
+		})
+		e.sequence++
+		e.timestamp += opusFrameSamples
+	}
+
+	// Move the partial frame to the front so the buffer doesn't grow without bound
+	e.pending = append(e.pending[:0], e.pending[offset:]...)
+	return packets, nil
+}
+
+// Flush drops any partial frame, e.g. when the speaker is interrupted
+func (e *OpusRTPEncoder) Flush() {
+	e.pending = e.pending[:0]
+}
+
+// resamplePCM16 converts mono PCM16 between sample rates. Downsampling averages
+// the input samples each output sample covers, a cheap low-pass that keeps
+// 48 kHz speech from aliasing at 24 or 16 kHz; upsampling interpolates linearly.
+func resamplePCM16(in []int16, fromRate, toRate int) []int16 {
+	if fromRate == toRate || len(in) == 0 {
+		out := make([]int16, len(in))
+		copy(out, in)
+		return out
+	}
+
+	outLen := len(in) * toRate / fromRate
+	out := make([]int16, outLen)
+	step := float64(fromRate) / float64(toRate)
+
+	if fromRate > toRate {
+		for i := range out {
+			start := int(float64(i) * step)
+			end := int(float64(i+1) * step)
+			if end > len(in) {
+				end = len(in)
+			}
+			sum := 0
+			for _, s := range in[start:end] {
+				sum += int(s)
+			}
+			out[i] = int16(sum / (end - start))
+		}
+		return out
+	}
+
+	for i := range out {
+		pos := float64(i) * step
+		idx := int(pos)
+		if idx >= len(in)-1 {
+			out[i] = in[len(in)-1]
+			continue
+		}
+		frac := pos - float64(idx)
+		out[i] = int16(float64(in[idx])*(1-frac) + float64(in[idx+1])*frac)
+	}
+	return out
+}
+
+// pcm16ToBytes encodes samples as little-endian PCM16, the Realtime API's pcm16 format
+func pcm16ToBytes(samples []int16) []byte {
+	out := make([]byte, len(samples)*2)
+	for i, s := range samples {
+		binary.LittleEndian.PutUint16(out[i*2:], uint16(s))
+	}
+	return out
+}
+
+// bytesToPCM16 decodes little-endian PCM16
+func bytesToPCM16(data []byte) []int16 {
+	out := make([]int16, len(data)/2)
+	for i := range out {
+		out[i] = int16(binary.LittleEndian.Uint16(data[i*2:]))
+	}
+	return out
+}
+
+// readOggOpus returns the audio packets of an Ogg Opus file, skipping the
+// OpusHead and OpusTags headers. A page's segment table gives each segment's
+// length; a packet ends at the first segment shorter than 255 bytes and may
+// continue onto the next page.
+func readOggOpus(path string) ([][]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var packets [][]byte
+	var packet []byte
+	for len(data) > 0 {
+		if len(data) < 27 || string(data[:4]) != "OggS" {
+			return nil, fmt.Errorf("%s is not an Ogg file", path)
+		}
+		segments := int(data[26])
+		if len(data) < 27+segments {
+			return nil, fmt.Errorf("%s: truncated Ogg page", path)
+		}
+		table := data[27 : 27+segments]
+		body := data[27+segments:]
+		for _, size := range table {
+			if len(body) < int(size) {
+				return nil, fmt.Errorf("%s: truncated Ogg page", path)
+			}
+			packet = append(packet, body[:size]...)
+			body = body[size:]
+			if size < 255 {
+				packets = append(packets, packet)
+				packet = nil
+			}
+		}
+		data = body
+	}
+
+	if len(packets) <= 2 {
+		return nil, fmt.Errorf("%s has no audio", path)
+	}
+	return packets[2:], nil
+}
+
+// opusPacketDuration reads how much audio a packet holds from its TOC byte (RFC 6716 §3.1)
+func opusPacketDuration(packet []byte) time.Duration {
+	if len(packet) == 0 {
+		return 20 * time.Millisecond
+	}
+	config := packet[0] >> 3
+
+	var frame time.Duration
+	switch {
+	case config < 12: // SILK: 10, 20, 40, 60 ms
+		frame = []time.Duration{10, 20, 40, 60}[config%4] * time.Millisecond
+	case config < 16: // Hybrid: 10, 20 ms
+		frame = []time.Duration{10, 20}[config%2] * time.Millisecond
+	default: // CELT: 2.5, 5, 10, 20 ms
+		frame = []time.Duration{2500, 5000, 10000, 20000}[config%4] * time.Microsecond
+	}
+
+	frames := 1
+	switch packet[0] & 0x3 {
+	case 1, 2:
+		frames = 2
+	case 3:
+		if len(packet) > 1 {
+			frames = int(packet[1] & 0x3f)
+		}
+	}
+	return frame * time.Duration(frames)
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+// TestResamplePCM16 checks the output length for each conversion the bridge
+// makes and that a tone survives the 48 kHz -> 24 kHz -> 48 kHz round trip
+func TestResamplePCM16(t *testing.T) {
+	tests := []struct {
+		name     string
+		from, to int
+		in       int
+		want     int
+	}{
+		{"48 kHz to 24 kHz", 48000, 24000, 960, 480},
+		{"24 kHz to 48 kHz", 24000, 48000, 480, 960},
+		{"48 kHz to 16 kHz", 48000, 16000, 960, 320},
+		{"48 kHz to the WAV rate", 48000, recordingWAVSampleRate, 960, 960 * recordingWAVSampleRate / 48000},
+		{"same rate", 24000, 24000, 480, 480},
+		{"empty", 48000, 24000, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := len(resamplePCM16(make([]int16, tt.in), tt.from, tt.to)); got != tt.want {
+				t.Errorf("resampled %d samples to %d, want %d", tt.in, got, tt.want)
+			}
+		})
+	}
+
+	// A 440 Hz tone is far below either Nyquist rate, so it should come back intact
+	tone := make([]int16, opusFrameSamples*5)
+	for i := range tone {
+		tone[i] = int16(8000 * math.Sin(2*math.Pi*440*float64(i)/48000))
+	}
+	roundTrip := resamplePCM16(resamplePCM16(tone, 48000, 24000), 24000, 48000)
+	if len(roundTrip) != len(tone) {
+		t.Fatalf("round trip returned %d samples, want %d", len(roundTrip), len(tone))
+	}
+	var errSum, sigSum float64
+	// Skip the last samples, which repeat the final input sample
+	for i := 0; i < len(tone)-2; i++ {
+		d := float64(roundTrip[i]) - float64(tone[i])
+		errSum += d * d
+		sigSum += float64(tone[i]) * float64(tone[i])
+	}
+	if snr := 10 * math.Log10(sigSum/errSum); snr < 20 {
+		t.Errorf("round trip SNR = %.1f dB, want at least 20 dB", snr)
+	}
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// OutboundCallState is a step in the business-initiated call flow
+type OutboundCallState string
+
+// Outbound calls move strictly forward through these states:
+//
+//	offering → ringing → accepted → connecting → media → ended
+//
+// "accepted" may be skipped when Meta delivers the connect webhook before the
+// ACCEPTED status, and any state can jump straight to "ended".
+const (
+	OutboundStateOffering   OutboundCallState = "offering"   // Building our SDP offer and calling the Graph API
+	OutboundStateRinging    OutboundCallState = "ringing"    // Graph returned a call_id, the user's phone is ringing
+	OutboundStateAccepted   OutboundCallState = "accepted"   // ACCEPTED status received, waiting for the SDP answer
+	OutboundStateConnecting OutboundCallState = "connecting" // SDP answer applied, waiting for ICE/DTLS and first RTP
+	OutboundStateMedia      OutboundCallState = "media"      // Audio is flowing
+	OutboundStateEnded      OutboundCallState = "ended"
+)
+
+// outboundStateOrder ranks states so transitions can only move forward
+var outboundStateOrder = map[OutboundCallState]int{
+	OutboundStateOffering:   0,
+	OutboundStateRinging:    1,
+	OutboundStateAccepted:   2,
+	OutboundStateConnecting: 3,
+	OutboundStateMedia:      4,
+	OutboundStateEnded:      5,
+}
+
+// outboundStateTimeouts is how long a call may sit in each state before it is
+// torn down. States without an entry have no timeout.
+var outboundStateTimeouts = map[OutboundCallState]time.Duration{
+	OutboundStateOffering:   30 * time.Second,
+	OutboundStateRinging:    60 * time.Second,
+	OutboundStateAccepted:   20 * time.Second,
+	OutboundStateConnecting: 20 * time.Second,
+}
+
+// OutboundTransition records one state change for /status
+type OutboundTransition struct {
+	State  OutboundCallState `json:"state"`
+	At     time.Time         `json:"at"`
+	Reason string            `json:"reason,omitempty"`
+}
+
+// OutboundCall tracks a business-initiated call through its answer flow
+type OutboundCall struct {
+	To         string
+	State      OutboundCallState
+	StateSince time.Time
+	History    []OutboundTransition
+	EndReason  string
+	timer      *time.Timer
+	mu         sync.Mutex
+}
+
+// NewOutboundCall creates the state for a call we're about to place
+func NewOutboundCall(to string) *OutboundCall {
+	now := time.Now()
+	return &OutboundCall{
+		To:         to,
+		State:      OutboundStateOffering,
+		StateSince: now,
+		History:    []OutboundTransition{{State: OutboundStateOffering, At: now}},
+	}
+}
+
+// transition moves the call forward to state. It returns false (and changes
+// nothing) if the call is already at or past that state.
+func (o *OutboundCall) transition(state OutboundCallState, reason string) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if outboundStateOrder[state] <= outboundStateOrder[o.State] {
+		return false
+	}
+
+	now := time.Now()
+	o.State = state
+	o.StateSince = now
+	o.History = append(o.History, OutboundTransition{State: state, At: now, Reason: reason})
+	if state == OutboundStateEnded {
+		o.EndReason = reason
+	}
+
+	if o.timer != nil {
+		o.timer.Stop()
+		o.timer = nil
+	}
+	return true
+}
+
+// armTimeout schedules onTimeout if the call is still in state after that state's timeout
+func (o *OutboundCall) armTimeout(state OutboundCallState, onTimeout func()) {
+	timeout, ok := outboundStateTimeouts[state]
+	if !ok {
+		return
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.State != state {
+		return
+	}
+	o.timer = time.AfterFunc(timeout, func() {
+		o.mu.Lock()
+		stillThere := o.State == state
+		o.mu.Unlock()
+		if stillThere {
+			onTimeout()
+		}
+	})
+}
+
+// Snapshot returns the call's state for /status
+func (o *OutboundCall) Snapshot() map[string]interface{} {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	history := make([]OutboundTransition, len(o.History))
+	copy(history, o.History)
+
+	snapshot := map[string]interface{}{
+		"to":          o.To,
+		"state":       o.State,
+		"state_since": o.StateSince.Format(time.RFC3339),
+		"history":     history,
+	}
+	if timeout, ok := outboundStateTimeouts[o.State]; ok {
+		snapshot["state_timeout"] = timeout.String()
+	}
+	return snapshot
+}
+
+// advanceOutboundCall moves an outbound call to state and arms that state's timeout.
+// It is a no-op for unknown calls, inbound calls and backwards transitions.
+func (b *WhatsAppBridge) advanceOutboundCall(callID string, state OutboundCallState, reason string) bool {
+	b.mu.Lock()
+	call, exists := b.activeCalls[callID]
+	b.mu.Unlock()
+
+	if !exists || call.Outbound == nil {
+		return false
+	}
+	return b.advanceOutbound(call, state, reason)
+}
+
+// outboundLifecycleStates maps the answer flow onto the shared call lifecycle
+var outboundLifecycleStates = map[OutboundCallState]CallState{
+	OutboundStateRinging:    CallStateNegotiating,
+	OutboundStateAccepted:   CallStateAccepted,
+	OutboundStateConnecting: CallStateAccepted,
+	OutboundStateMedia:      CallStateMediaActive,
+}
+
+// advanceOutbound moves call to state and arms that state's timeout
+func (b *WhatsAppBridge) advanceOutbound(call *Call, state OutboundCallState, reason string) bool {
+	if !call.Outbound.transition(state, reason) {
+		return false
+	}
+	if lifecycleState, ok := outboundLifecycleStates[state]; ok {
+		b.setCallState(call, lifecycleState)
+	}
+
+	callLogger(call.ID).Info("📞 Outbound call state", "to", state, "reason", reason)
+
+	call.Outbound.armTimeout(state, func() {
+		timeout := outboundStateTimeouts[state]
+		callLogger(call.ID).Warn("⏱️ Outbound call timed out", "timeout", timeout, "state", state)
+		b.endOutboundCall(call, "timeout in "+string(state))
+	})
+	return true
+}
+
+// endOutboundCall moves an outbound call to ended and tears it down. Safe to call more than once.
+func (b *WhatsAppBridge) endOutboundCall(call *Call, reason string) {
+	if call.Outbound.transition(OutboundStateEnded, reason) {
+		callLogger(call.ID).Info("📞 Outbound call state", "to", OutboundStateEnded, "reason", reason)
+	}
+	b.endCall(call, reason)
+}
+
+// outboundCallStatus lists the outbound calls currently tracked, for /status
+func (b *WhatsAppBridge) outboundCallStatus() []map[string]interface{} {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	calls := []map[string]interface{}{}
+	for id, call := range b.activeCalls {
+		if call.Outbound == nil {
+			continue
+		}
+		snapshot := call.Outbound.Snapshot()
+		snapshot["call_id"] = id
+		calls = append(calls, snapshot)
+	}
+	return calls
+}
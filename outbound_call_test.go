@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/pion/webrtc/v4"
+)
+
+// TestOutboundCallAnswerAppliedOnce delivers the same SDP answer several
+// times at once, as Meta's retried connect webhooks can, and checks it is
+// applied exactly once: a second SetRemoteDescription would fail and end the call.
+func TestOutboundCallAnswerAppliedOnce(t *testing.T) {
+	offerer, err := webrtc.NewPeerConnection(webrtc.Configuration{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer offerer.Close()
+	answerer, err := webrtc.NewPeerConnection(webrtc.Configuration{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer answerer.Close()
+
+	if _, err := offerer.AddTransceiverFromKind(webrtc.RTPCodecTypeAudio); err != nil {
+		t.Fatal(err)
+	}
+	offer, err := offerer.CreateOffer(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := offerer.SetLocalDescription(offer); err != nil {
+		t.Fatal(err)
+	}
+	if err := answerer.SetRemoteDescription(offer); err != nil {
+		t.Fatal(err)
+	}
+	answer, err := answerer.CreateAnswer(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b := &WhatsAppBridge{activeCalls: make(map[string]*Call)}
+	call := newCall("wacid.ANSWER_TEST", nil)
+	call.PeerConnection = offerer
+	call.Outbound = NewOutboundCall("15559876543")
+	call.Outbound.transition(OutboundStateRinging, "test")
+	b.activeCalls[call.ID] = call
+	defer call.Outbound.transition(OutboundStateEnded, "test finished")
+
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			b.handleOutboundCallAnswer(context.Background(), call.ID, answer.SDP, "15559876543")
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	call.Outbound.mu.Lock()
+	state := call.Outbound.State
+	call.Outbound.mu.Unlock()
+	if state != OutboundStateConnecting {
+		t.Fatalf("outbound call state = %s, want %s", state, OutboundStateConnecting)
+	}
+	if desc := offerer.CurrentRemoteDescription(); desc == nil || desc.SDP != answer.SDP {
+		t.Error("the SDP answer was not applied")
+	}
+}
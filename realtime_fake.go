@@ -0,0 +1,519 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/pion/webrtc/v4"
+	"github.com/pion/webrtc/v4/pkg/media"
+)
+
+// fakeRealtime is the in-process Realtime endpoint started by
+// OPENAI_REALTIME_FAKE; nil when calls go to the real API
+var fakeRealtime *FakeRealtimeServer
+
+// fakeRealtimeTone is the step audio that plays a generated tone instead of a file
+const fakeRealtimeTone = "tone"
+
+// FakeRealtimeScript is what the fake endpoint does during a session. Steps
+// run in order; each waits for its trigger, sends its events over oai-events
+// and then plays its audio.
+type FakeRealtimeScript struct {
+	Steps []FakeRealtimeStep `json:"steps"`
+}
+
+// FakeRealtimeStep is one scripted turn
+type FakeRealtimeStep struct {
+	// On is what the step waits for: "open" (the oai-events channel opened),
+	// "audio" (the first RTP packet from the caller) or a client event type
+	// such as "session.update" or "response.create". Empty runs the step right
+	// after the previous one. Triggers that arrive early are remembered.
+	On     string                   `json:"on"`
+	Delay  string                   `json:"delay,omitempty"` // Wait before sending, e.g. "500ms"
+	Events []map[string]interface{} `json:"events,omitempty"`
+	Audio  string                   `json:"audio,omitempty"` // Ogg Opus file to play, or "tone"
+}
+
+// defaultFakeRealtimeScriptJSON greets the caller, waits for them to speak,
+// saves a note through add_note and confirms it once the tool result is back
+const defaultFakeRealtimeScriptJSON = `{
+  "steps": [
+    {"on": "open", "events": [{"type": "session.created", "session": {"id": "sess_fake", "model": "gpt-realtime"}}]},
+    {"on": "session.update", "events": [{"type": "session.updated", "session": {"id": "sess_fake"}}]},
+    {"on": "response.create", "audio": "tone", "events": [
+      {"type": "response.created", "response": {"id": "resp_fake_1"}},
+      {"type": "response.output_audio_transcript.delta", "item_id": "item_fake_1", "delta": "Hi! This is the test assistant."},
+      {"type": "response.output_audio_transcript.done", "item_id": "item_fake_1", "transcript": "Hi! This is the test assistant."}
+    ]},
+    {"events": [{"type": "response.done", "response": {"id": "resp_fake_1", "status": "completed"}}]},
+    {"on": "audio", "delay": "1s", "events": [
+      {"type": "input_audio_buffer.speech_started", "item_id": "item_fake_2"},
+      {"type": "input_audio_buffer.speech_stopped", "item_id": "item_fake_2"},
+      {"type": "input_audio_buffer.committed", "item_id": "item_fake_2"},
+      {"type": "conversation.item.input_audio_transcription.completed", "item_id": "item_fake_2", "transcript": "Please make a note that I need to buy milk."},
+      {"type": "response.function_call_arguments.done", "call_id": "call_fake_1", "name": "add_note", "arguments": "{\"note_content\":\"Buy milk\"}"}
+    ]},
+    {"on": "response.create", "audio": "tone", "events": [
+      {"type": "response.created", "response": {"id": "resp_fake_2"}},
+      {"type": "response.output_audio_transcript.delta", "item_id": "item_fake_3", "delta": "Done, I saved that note."},
+      {"type": "response.output_audio_transcript.done", "item_id": "item_fake_3", "transcript": "Done, I saved that note."}
+    ]},
+    {"events": [{"type": "response.done", "response": {"id": "resp_fake_2", "status": "completed"}}]}
+  ]
+}`
+
+// LoadFakeRealtimeScript reads a script from a JSON file
+func LoadFakeRealtimeScript(path string) (*FakeRealtimeScript, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseFakeRealtimeScript(data)
+}
+
+// parseFakeRealtimeScript decodes a script and checks its delays
+func parseFakeRealtimeScript(data []byte) (*FakeRealtimeScript, error) {
+	var script FakeRealtimeScript
+	if err := json.Unmarshal(data, &script); err != nil {
+		return nil, fmt.Errorf("invalid fake Realtime script: %w", err)
+	}
+	for i, step := range script.Steps {
+		if step.Delay != "" {
+			if _, err := time.ParseDuration(step.Delay); err != nil {
+				return nil, fmt.Errorf("step %d: invalid delay %q", i+1, step.Delay)
+			}
+		}
+	}
+	return &script, nil
+}
+
+// FakeRealtimeServer stands in for the OpenAI Realtime API's WebRTC interface.
+// It hands out ephemeral tokens, answers SDP offers, accepts the client's
+// oai-events channel, then follows a script of server events and canned Opus
+// so tool dispatch and audio forwarding can be tested end to end offline.
+type FakeRealtimeServer struct {
+	script   *FakeRealtimeScript
+	audio    map[string][]media.Sample // Canned audio for each step's Audio
+	api      *webrtc.API
+	listener net.Listener
+	server   *http.Server
+
+	mu           sync.Mutex
+	sessions     int
+	clientEvents []string          // Types of every client event received, in order
+	toolOutputs  map[string]string // function_call_output items received, by call_id
+	audioPackets atomic.Int64
+}
+
+// FakeRealtimeStats is what the fake endpoint has seen so far
+type FakeRealtimeStats struct {
+	Sessions     int               `json:"sessions"`
+	ClientEvents []string          `json:"client_events"`
+	ToolOutputs  map[string]string `json:"tool_outputs"`
+	AudioPackets int64             `json:"audio_packets"`
+}
+
+// NewFakeRealtimeServerFromEnv starts the fake endpoint when
+// OPENAI_REALTIME_FAKE is set: "true" runs the built-in script, anything else
+// is a script file. It listens on OPENAI_REALTIME_FAKE_ADDR (default a free
+// loopback port). Returns nil when the fake is disabled.
+func NewFakeRealtimeServerFromEnv() (*FakeRealtimeServer, error) {
+	value := os.Getenv("OPENAI_REALTIME_FAKE")
+	if value == "" {
+		return nil, nil
+	}
+	if transport, _ := realtimeTransportFromEnv(); transport != realtimeTransportWebRTC {
+		return nil, fmt.Errorf("the fake Realtime endpoint only speaks WebRTC; unset OPENAI_REALTIME_TRANSPORT")
+	}
+
+	var script *FakeRealtimeScript
+	var err error
+	if value == "true" {
+		script, err = parseFakeRealtimeScript([]byte(defaultFakeRealtimeScriptJSON))
+	} else {
+		script, err = LoadFakeRealtimeScript(value)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	addr := os.Getenv("OPENAI_REALTIME_FAKE_ADDR")
+	if addr == "" {
+		addr = "127.0.0.1:0"
+	}
+	return NewFakeRealtimeServer(script, addr)
+}
+
+// NewFakeRealtimeServer loads the script's audio and starts serving on addr
+func NewFakeRealtimeServer(script *FakeRealtimeScript, addr string) (*FakeRealtimeServer, error) {
+	f := &FakeRealtimeServer{
+		script:      script,
+		audio:       make(map[string][]media.Sample),
+		toolOutputs: make(map[string]string),
+	}
+	for _, step := range script.Steps {
+		if step.Audio == "" || f.audio[step.Audio] != nil {
+			continue
+		}
+		samples, err := loadFakeRealtimeAudio(step.Audio)
+		if err != nil {
+			return nil, err
+		}
+		f.audio[step.Audio] = samples
+	}
+
+	m := &webrtc.MediaEngine{}
+	if err := m.RegisterDefaultCodecs(); err != nil {
+		return nil, err
+	}
+	s := webrtc.SettingEngine{}
+	// The bridge is on the same machine
+	s.SetIncludeLoopbackCandidate(true)
+	f.api = webrtc.NewAPI(webrtc.WithMediaEngine(m), webrtc.WithSettingEngine(s))
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("fake Realtime endpoint can't listen on %s: %w", addr, err)
+	}
+	f.listener = listener
+
+	router := mux.NewRouter()
+	router.HandleFunc("/v1/realtime/client_secrets", f.handleClientSecrets).Methods("POST")
+	router.HandleFunc("/v1/realtime/calls", f.handleCalls).Methods("POST")
+	f.server = &http.Server{Handler: router}
+	go f.server.Serve(listener)
+
+	slog.Info("🧪 Fake Realtime endpoint listening", "url", f.URL(), "steps", len(script.Steps))
+	return f, nil
+}
+
+// URL is the base the Realtime client should use in place of OpenAI's
+func (f *FakeRealtimeServer) URL() string {
+	return "http://" + f.listener.Addr().String() + "/v1/realtime"
+}
+
+// Stats returns the sessions, client events, tool results and caller audio
+// seen so far
+func (f *FakeRealtimeServer) Stats() FakeRealtimeStats {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	toolOutputs := make(map[string]string, len(f.toolOutputs))
+	for callID, output := range f.toolOutputs {
+		toolOutputs[callID] = output
+	}
+	return FakeRealtimeStats{
+		Sessions:     f.sessions,
+		ClientEvents: append([]string{}, f.clientEvents...),
+		ToolOutputs:  toolOutputs,
+		AudioPackets: f.audioPackets.Load(),
+	}
+}
+
+// Close stops the HTTP server; sessions end with their peer connections
+func (f *FakeRealtimeServer) Close() error {
+	return f.server.Close()
+}
+
+// handleClientSecrets returns an ephemeral token the way the real API does
+func (f *FakeRealtimeServer) handleClientSecrets(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"value":      fmt.Sprintf("ek_fake_%d", time.Now().UnixNano()),
+		"expires_at": time.Now().Add(time.Minute).Unix(),
+	})
+}
+
+// handleCalls answers an SDP offer and starts a scripted session
+func (f *FakeRealtimeServer) handleCalls(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "Bearer ") {
+		http.Error(w, "Missing bearer token", http.StatusUnauthorized)
+		return
+	}
+	offer, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "Failed to read offer", http.StatusBadRequest)
+		return
+	}
+
+	answer, err := f.startSession(string(offer))
+	if err != nil {
+		loggerFrom(r.Context()).Error("❌ Fake Realtime endpoint couldn't answer", "error", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/sdp")
+	w.WriteHeader(http.StatusCreated)
+	io.WriteString(w, answer)
+}
+
+// fakeRealtimeSession is one client connection working through the script
+type fakeRealtimeSession struct {
+	server    *FakeRealtimeServer
+	id        int
+	pc        *webrtc.PeerConnection
+	track     *webrtc.TrackLocalStaticSample
+	triggers  chan string
+	done      chan struct{}
+	closeOnce sync.Once
+	heard     atomic.Bool // Caller audio has arrived
+	logger    *slog.Logger
+
+	mu      sync.Mutex
+	channel *webrtc.DataChannel
+	sent    int // Server events sent, for event ids
+}
+
+// startSession creates the answering peer and returns its SDP answer
+func (f *FakeRealtimeServer) startSession(offer string) (string, error) {
+	pc, err := f.api.NewPeerConnection(webrtc.Configuration{})
+	if err != nil {
+		return "", err
+	}
+
+	f.mu.Lock()
+	f.sessions++
+	session := &fakeRealtimeSession{
+		server:   f,
+		id:       f.sessions,
+		pc:       pc,
+		triggers: make(chan string, 64),
+		done:     make(chan struct{}),
+		logger:   slog.With("fake_session", f.sessions),
+	}
+	f.mu.Unlock()
+
+	if err := pc.SetRemoteDescription(webrtc.SessionDescription{Type: webrtc.SDPTypeOffer, SDP: offer}); err != nil {
+		pc.Close()
+		return "", fmt.Errorf("invalid offer: %w", err)
+	}
+
+	session.track, err = webrtc.NewTrackLocalStaticSample(
+		webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeOpus, ClockRate: opusSampleRate, Channels: 2},
+		"audio", "fake-realtime")
+	if err != nil {
+		pc.Close()
+		return "", err
+	}
+	if _, err := pc.AddTrack(session.track); err != nil {
+		pc.Close()
+		return "", err
+	}
+
+	pc.OnDataChannel(func(dc *webrtc.DataChannel) {
+		if dc.Label() != "oai-events" {
+			return
+		}
+		session.mu.Lock()
+		session.channel = dc
+		session.mu.Unlock()
+		dc.OnOpen(func() { session.trigger("open") })
+		dc.OnMessage(func(msg webrtc.DataChannelMessage) { session.handleClientEvent(msg.Data) })
+	})
+
+	pc.OnTrack(func(track *webrtc.TrackRemote, _ *webrtc.RTPReceiver) {
+		for {
+			if _, _, err := track.ReadRTP(); err != nil {
+				return
+			}
+			f.audioPackets.Add(1)
+			if !session.heard.Swap(true) {
+				session.logger.Info("🧪 Fake Realtime session: caller audio is arriving")
+				session.trigger("audio")
+			}
+		}
+	})
+
+	pc.OnConnectionStateChange(func(state webrtc.PeerConnectionState) {
+		switch state {
+		case webrtc.PeerConnectionStateClosed, webrtc.PeerConnectionStateFailed:
+			session.close()
+		}
+	})
+
+	answer, err := pc.CreateAnswer(nil)
+	if err != nil {
+		pc.Close()
+		return "", err
+	}
+	gatherComplete := webrtc.GatheringCompletePromise(pc)
+	if err := pc.SetLocalDescription(answer); err != nil {
+		pc.Close()
+		return "", err
+	}
+	<-gatherComplete
+
+	session.logger.Info("🧪 Fake Realtime session started")
+	go session.run()
+	return pc.LocalDescription().SDP, nil
+}
+
+// handleClientEvent records a client event, and any tool result it carries,
+// and offers it as a trigger
+func (s *fakeRealtimeSession) handleClientEvent(data []byte) {
+	var event struct {
+		Type string `json:"type"`
+		Item struct {
+			Type   string `json:"type"`
+			CallID string `json:"call_id"`
+			Output string `json:"output"`
+		} `json:"item"`
+	}
+	if err := json.Unmarshal(data, &event); err != nil {
+		s.logger.Warn("⚠️ Fake Realtime session: unparseable client event", "error", err)
+		s.logger.Debug("⚠️ Unparseable client event", "body", string(data))
+		return
+	}
+	s.server.mu.Lock()
+	s.server.clientEvents = append(s.server.clientEvents, event.Type)
+	if event.Type == "conversation.item.create" && event.Item.Type == "function_call_output" {
+		s.server.toolOutputs[event.Item.CallID] = event.Item.Output
+	}
+	s.server.mu.Unlock()
+	s.logger.Debug("🧪 Fake Realtime session received", "type", event.Type)
+	s.trigger(event.Type)
+}
+
+// trigger hands a trigger to the script runner without blocking pion's callbacks
+func (s *fakeRealtimeSession) trigger(name string) {
+	select {
+	case s.triggers <- name:
+	default:
+	}
+}
+
+// run works through the script. Each step consumes one occurrence of its trigger.
+func (s *fakeRealtimeSession) run() {
+	pending := map[string]int{}
+	for i, step := range s.server.script.Steps {
+		for step.On != "" && pending[step.On] == 0 {
+			select {
+			case trigger := <-s.triggers:
+				pending[trigger]++
+			case <-s.done:
+				return
+			}
+		}
+		if step.On != "" {
+			pending[step.On]--
+		}
+		if !s.runStep(i, step) {
+			return
+		}
+	}
+	s.logger.Info("🧪 Fake Realtime session finished its script")
+}
+
+// runStep sends one step's events and plays its audio. It returns false once
+// the session has ended.
+func (s *fakeRealtimeSession) runStep(index int, step FakeRealtimeStep) bool {
+	if step.Delay != "" {
+		delay, _ := time.ParseDuration(step.Delay)
+		select {
+		case <-time.After(delay):
+		case <-s.done:
+			return false
+		}
+	}
+
+	for _, event := range step.Events {
+		if err := s.send(event); err != nil {
+			s.logger.Error("❌ Fake Realtime session step failed", "step", index+1, "error", err)
+			return false
+		}
+	}
+
+	next := time.Now()
+	for _, sample := range s.server.audio[step.Audio] {
+		if err := s.track.WriteSample(sample); err != nil {
+			return false
+		}
+		next = next.Add(sample.Duration)
+		select {
+		case <-time.After(time.Until(next)):
+		case <-s.done:
+			return false
+		}
+	}
+	return true
+}
+
+// send writes one server event, adding an event_id when the script has none
+func (s *fakeRealtimeSession) send(event map[string]interface{}) error {
+	s.mu.Lock()
+	dc := s.channel
+	s.sent++
+	eventID := fmt.Sprintf("event_fake_%d_%d", s.id, s.sent)
+	s.mu.Unlock()
+	if dc == nil {
+		return fmt.Errorf("oai-events channel isn't open")
+	}
+
+	if _, ok := event["event_id"]; !ok {
+		withID := make(map[string]interface{}, len(event)+1)
+		for k, v := range event {
+			withID[k] = v
+		}
+		withID["event_id"] = eventID
+		event = withID
+	}
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	s.logger.Debug("🧪 Fake Realtime session sent", "type", event["type"])
+	return dc.SendText(string(data))
+}
+
+// close ends the session's script once the peer connection is gone
+func (s *fakeRealtimeSession) close() {
+	s.closeOnce.Do(func() {
+		close(s.done)
+		stats := s.server.Stats()
+		s.logger.Info("🧪 Fake Realtime session ended", "client_events", len(stats.ClientEvents), "audio_packets", stats.AudioPackets)
+	})
+}
+
+// loadFakeRealtimeAudio returns the canned Opus samples for a step: a
+// one-second 440 Hz tone, or the packets of an Ogg Opus file
+func loadFakeRealtimeAudio(name string) ([]media.Sample, error) {
+	if name != fakeRealtimeTone {
+		packets, err := readOggOpus(name)
+		if err != nil {
+			return nil, err
+		}
+		samples := make([]media.Sample, len(packets))
+		for i, packet := range packets {
+			samples[i] = media.Sample{Data: packet, Duration: opusPacketDuration(packet)}
+		}
+		return samples, nil
+	}
+
+	encoder, err := NewOpusRTPEncoder(111)
+	if err != nil {
+		return nil, err
+	}
+	pcm := make([]int16, opusSampleRate)
+	for i := range pcm {
+		pcm[i] = int16(8000 * math.Sin(2*math.Pi*440*float64(i)/opusSampleRate))
+	}
+	packets, err := encoder.Encode(pcm)
+	if err != nil {
+		return nil, err
+	}
+	samples := make([]media.Sample, len(packets))
+	for i, packet := range packets {
+		samples[i] = media.Sample{Data: packet.Payload, Duration: 20 * time.Millisecond}
+	}
+	return samples, nil
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestFakeRealtimeToolCall scripts a function call from the fake Realtime
+// endpoint and checks the bridge runs the registry tool and sends its output back
+func TestFakeRealtimeToolCall(t *testing.T) {
+	script, err := parseFakeRealtimeScript([]byte(`{
+  "steps": [
+    {"on": "session.update", "events": [
+      {"type": "session.updated", "session": {"id": "sess_fake"}},
+      {"type": "response.function_call_arguments.done", "call_id": "call_test_1", "name": "lookup_order", "arguments": "{\"order_id\":\"A17\"}"}
+    ]}
+  ]
+}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake, err := NewFakeRealtimeServer(script, "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fake.Close()
+
+	ran := make(chan ToolArgs, 1)
+	registry := NewToolRegistry()
+	registry.Register(&Tool{
+		Name:     "lookup_order",
+		Channels: []string{toolChannelVoice},
+		Handler: func(ctx context.Context, caller *ToolCaller, args ToolArgs) (map[string]interface{}, error) {
+			ran <- args
+			return map[string]interface{}{"order_status": "shipped"}, nil
+		},
+	})
+
+	savedFake, savedRegistry := fakeRealtime, toolRegistry
+	fakeRealtime, toolRegistry = fake, registry
+	defer func() { fakeRealtime, toolRegistry = savedFake, savedRegistry }()
+
+	client := NewOpenAIRealtimeClient(&Tenant{Name: "acme"}, "sk-test", "15559876543", "")
+	if err := client.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	select {
+	case args := <-ran:
+		if args["order_id"] != "A17" {
+			t.Errorf("lookup_order ran with %v", args)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("lookup_order never ran")
+	}
+
+	// The tool result goes back as a function_call_output item, then a response.create
+	want := []string{"session.update", "conversation.item.create", "response.create"}
+	var stats FakeRealtimeStats
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(20 * time.Millisecond) {
+		if stats = fake.Stats(); len(stats.ClientEvents) >= len(want) {
+			break
+		}
+	}
+	if strings.Join(stats.ClientEvents, ",") != strings.Join(want, ",") {
+		t.Errorf("client events = %v, want %v", stats.ClientEvents, want)
+	}
+	if output := stats.ToolOutputs["call_test_1"]; output != `{"order_status":"shipped","status":"success"}` {
+		t.Errorf("function_call_output for call_test_1 = %q", output)
+	}
+}
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/pion/rtp"
+)
+
+// Realtime API transports. WebRTC lets OpenAI handle the Opus media itself;
+// the WebSocket transport carries PCM16 in JSON events, so the bridge decodes
+// and encodes Opus and owns the whole audio pipeline.
+const (
+	realtimeTransportWebRTC    = "webrtc"
+	realtimeTransportWebSocket = "websocket"
+)
+
+const (
+	// realtimeSampleRate is the Realtime API's pcm16 format: 24 kHz mono little-endian
+	realtimeSampleRate = 24000
+
+	// realtimeAppendSamples is how much caller audio goes into one
+	// input_audio_buffer.append: 100 ms at 24 kHz
+	realtimeAppendSamples = realtimeSampleRate / 10
+
+	// realtimeOutputQueue bounds the assistant audio waiting to be played out. The
+	// model streams faster than real time, so this holds ~60s of 20 ms packets.
+	realtimeOutputQueue = 3000
+
+	// whatsappOpusPayloadType is the Opus payload type registered for WhatsApp calls
+	whatsappOpusPayloadType = 111
+)
+
+// realtimeTransportFromEnv returns the transport selected by OPENAI_REALTIME_TRANSPORT
+func realtimeTransportFromEnv() (string, error) {
+	switch transport := os.Getenv("OPENAI_REALTIME_TRANSPORT"); transport {
+	case "", realtimeTransportWebRTC:
+		return realtimeTransportWebRTC, nil
+	case realtimeTransportWebSocket:
+		return realtimeTransportWebSocket, nil
+	default:
+		return "", fmt.Errorf("unknown OPENAI_REALTIME_TRANSPORT %q (available: %s, %s)",
+			transport, realtimeTransportWebRTC, realtimeTransportWebSocket)
+	}
+}
+
+// realtimeWebSocket is the client's WebSocket connection to the Realtime API
+type realtimeWebSocket struct {
+	conn    *websocket.Conn
+	writeMu sync.Mutex // gorilla/websocket allows one concurrent writer
+}
+
+// send writes one JSON client event
+func (ws *realtimeWebSocket) send(event []byte) error {
+	ws.writeMu.Lock()
+	defer ws.writeMu.Unlock()
+	return ws.conn.WriteMessage(websocket.TextMessage, event)
+}
+
+// realtimeWebSocketURL returns the endpoint and auth headers for the WebSocket transport.
+// The API key is used directly; ephemeral tokens are only needed by browsers.
+func (c *OpenAIRealtimeClient) realtimeWebSocketURL() (string, http.Header, error) {
+	header := http.Header{}
+
+	if c.azureEndpoint != "" && c.azureDeployment != "" {
+		endpoint, err := url.Parse(c.azureEndpoint)
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid AZURE_OPENAI_ENDPOINT: %w", err)
+		}
+		endpoint.Scheme = "wss"
+		endpoint.Path = "/openai/realtime"
+		endpoint.RawQuery = url.Values{
+			"api-version": {"2025-04-01-preview"},
+			"deployment":  {c.azureDeployment},
+		}.Encode()
+		header.Set("api-key", c.apiKey)
+		return endpoint.String(), header, nil
+	}
+
+	endpoint, err := url.Parse(realtimeBaseURL())
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid OPENAI_REALTIME_URL: %w", err)
+	}
+	if endpoint.Scheme == "http" {
+		endpoint.Scheme = "ws"
+	} else {
+		endpoint.Scheme = "wss"
+	}
+	endpoint.RawQuery = url.Values{"model": {"gpt-realtime"}}.Encode()
+	header.Set("Authorization", "Bearer "+c.apiKey)
+	return endpoint.String(), header, nil
+}
+
+// ConnectToRealtimeWebSocket opens a WebSocket session with the Realtime API and
+// sets up the Opus <-> PCM16 pipeline used to talk to the WhatsApp call
+func (c *OpenAIRealtimeClient) ConnectToRealtimeWebSocket() error {
+	processor, err := NewAudioProcessor(realtimeSampleRate, whatsappOpusPayloadType, c.logger())
+	if err != nil {
+		return err
+	}
+
+	wsURL, header, err := c.realtimeWebSocketURL()
+	if err != nil {
+		return err
+	}
+	c.logger().Info("🔌 Connecting to Realtime API over WebSocket", "url", strings.SplitN(wsURL, "?", 2)[0])
+
+	dialer := websocket.Dialer{
+		Proxy:            http.ProxyFromEnvironment,
+		HandshakeTimeout: 10 * time.Second,
+	}
+	conn, resp, err := dialer.Dial(wsURL, header)
+	if err != nil {
+		if resp != nil {
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			return fmt.Errorf("WebSocket handshake failed: %s - %s", resp.Status, string(body))
+		}
+		return fmt.Errorf("failed to dial Realtime WebSocket: %w", err)
+	}
+
+	audio := newRealtimeAudio(processor, c.logger())
+	c.mu.Lock()
+	c.ws = &realtimeWebSocket{conn: conn}
+	c.audio = audio
+	c.mu.Unlock()
+
+	c.logger().Info("✅ OpenAI Realtime WebSocket connected")
+
+	go c.readWebSocket(conn, audio)
+
+	// The server sends session.created first; updating right away means the
+	// greeting that follows already uses our instructions and pcm16 formats
+	c.sendSessionUpdate()
+	return nil
+}
+
+// readWebSocket dispatches server events until the connection closes
+func (c *OpenAIRealtimeClient) readWebSocket(conn *websocket.Conn, audio *realtimeAudio) {
+	defer audio.close()
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			if !websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+				c.logger().Warn("🔌 OpenAI Realtime WebSocket closed", "error", err)
+			}
+			return
+		}
+		c.handleEvent(data)
+	}
+}
+
+// realtimeAudio converts between WhatsApp's Opus RTP and the Realtime API's
+// PCM16 for the WebSocket transport
+type realtimeAudio struct {
+	processor *AudioProcessor
+	logger    *slog.Logger
+	input     []int16         // Caller audio at 24 kHz waiting to be appended
+	output    *pacedRTPSource // Assistant audio as Opus RTP for the WhatsApp track
+	mu        sync.Mutex      // Guards input
+}
+
+// newRealtimeAudio creates the pipeline for one call
+func newRealtimeAudio(processor *AudioProcessor, logger *slog.Logger) *realtimeAudio {
+	return &realtimeAudio{
+		processor: processor,
+		logger:    logger,
+		output:    newPacedRTPSource(realtimeOutputQueue),
+	}
+}
+
+// decode turns one RTP packet of caller audio into PCM16 at 24 kHz. It returns
+// base64 audio ready for input_audio_buffer.append once enough has accumulated.
+func (a *realtimeAudio) decode(packet []byte) (string, error) {
+	pcm, err := a.processor.ProcessRTPPacket(packet)
+	if err != nil || len(pcm) == 0 {
+		return "", err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.input = append(a.input, pcm...)
+	if len(a.input) < realtimeAppendSamples {
+		return "", nil
+	}
+
+	audio := base64.StdEncoding.EncodeToString(pcm16ToBytes(a.input))
+	a.input = a.input[:0]
+	return audio, nil
+}
+
+// encode queues one base64 PCM16 delta from the assistant as Opus RTP
+func (a *realtimeAudio) encode(delta string) error {
+	data, err := base64.StdEncoding.DecodeString(delta)
+	if err != nil {
+		return fmt.Errorf("invalid audio delta: %w", err)
+	}
+
+	packets, err := a.processor.EncodePCM16(bytesToPCM16(data), realtimeSampleRate)
+	for _, p := range packets {
+		a.output.push(p)
+	}
+	return err
+}
+
+// interrupt drops queued assistant audio so the caller can barge in
+func (a *realtimeAudio) interrupt() {
+	a.processor.Flush()
+	if dropped := a.output.clear(); dropped > 0 {
+		a.logger.Info("✋ Caller interrupted - dropped queued audio", "packets", dropped)
+	}
+}
+
+// close ends the output stream
+func (a *realtimeAudio) close() {
+	a.output.close()
+}
+
+// pacedRTPSource plays queued 20 ms Opus packets out in real time. Timestamps
+// are shifted across pauses between responses so the receiver's jitter buffer
+// sees the silence rather than a burst of late packets.
+type pacedRTPSource struct {
+	packets   chan *rtp.Packet
+	closed    chan struct{}
+	closeOnce sync.Once
+
+	next      time.Time // When the next packet is due
+	tsOffset  uint32    // Ticks of silence inserted so far
+	talkspurt bool      // Whether the previous packet was sent on time
+}
+
+// newPacedRTPSource creates a source holding up to size packets
+func newPacedRTPSource(size int) *pacedRTPSource {
+	return &pacedRTPSource{
+		packets: make(chan *rtp.Packet, size),
+		closed:  make(chan struct{}),
+	}
+}
+
+// push queues a packet, dropping it if the queue is full
+func (s *pacedRTPSource) push(p *rtp.Packet) {
+	select {
+	case s.packets <- p:
+	default:
+	}
+}
+
+// clear drops every queued packet and returns how many there were
+func (s *pacedRTPSource) clear() int {
+	dropped := 0
+	for {
+		select {
+		case <-s.packets:
+			dropped++
+		default:
+			return dropped
+		}
+	}
+}
+
+// ReadRTP implements RTPSource
+func (s *pacedRTPSource) ReadRTP() (*rtp.Packet, error) {
+	var p *rtp.Packet
+	select {
+	case <-s.closed:
+		return nil, io.EOF
+	case p = <-s.packets:
+	}
+
+	const frame = 20 * time.Millisecond
+	now := time.Now()
+	if s.next.IsZero() || now.After(s.next.Add(frame)) {
+		// Start of a new talkspurt: account for the silence since the last one
+		if !s.next.IsZero() {
+			s.tsOffset += uint32(now.Sub(s.next) / frame * opusFrameSamples)
+		}
+		s.next = now
+		s.talkspurt = false
+	} else if wait := s.next.Sub(now); wait > 0 {
+		time.Sleep(wait)
+	}
+	s.next = s.next.Add(frame)
+
+	p.Timestamp += s.tsOffset
+	p.Marker = !s.talkspurt
+	s.talkspurt = true
+	return p, nil
+}
+
+// close unblocks ReadRTP
+func (s *pacedRTPSource) close() {
+	s.closeOnce.Do(func() { close(s.closed) })
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/pion/interceptor"
+	"github.com/pion/webrtc/v4/pkg/media/rtpdump"
+)
+
+// CaptureStream names one direction of one leg of a call. Each is written to
+// its own rtpdump file, named after the stream, in the call's capture directory.
+type CaptureStream string
+
+// The four streams of a call. The "in" streams are packets exactly as they
+// arrived, before the extension headers are stripped, plus the peer's sender
+// reports; the "out" streams are the bytes the forwarding loops sent on, plus
+// the peer's feedback on them (receiver reports, NACKs, PLIs).
+const (
+	CaptureWhatsAppIn  CaptureStream = "whatsapp-in"
+	CaptureWhatsAppOut CaptureStream = "whatsapp-out"
+	CaptureAgentIn     CaptureStream = "agent-in"
+	CaptureAgentOut    CaptureStream = "agent-out"
+
+	captureFileExt       = ".rtpdump"
+	defaultRTPCaptureDir = "data/rtp-captures"
+)
+
+var captureStreams = []CaptureStream{CaptureWhatsAppIn, CaptureWhatsAppOut, CaptureAgentIn, CaptureAgentOut}
+
+// captureLeg is the pair of streams of one peer connection of a call
+type captureLeg struct {
+	in, out CaptureStream
+}
+
+var (
+	whatsappCaptureLeg = captureLeg{in: CaptureWhatsAppIn, out: CaptureWhatsAppOut}
+	agentCaptureLeg    = captureLeg{in: CaptureAgentIn, out: CaptureAgentOut}
+)
+
+// rtpCaptureDir is where packet captures are written (RTP_CAPTURE_DIR)
+func rtpCaptureDir() string {
+	if dir := os.Getenv("RTP_CAPTURE_DIR"); dir != "" {
+		return dir
+	}
+	return defaultRTPCaptureDir
+}
+
+// captureFile is one open rtpdump file
+type captureFile struct {
+	file    *os.File
+	buf     *bufio.Writer
+	dump    *rtpdump.Writer
+	packets int64
+	rtcp    int64
+}
+
+// CallCapture writes every RTP and RTCP packet of a call to rtpdump files.
+// All files share the same start time so their offsets line up.
+type CallCapture struct {
+	dir    string
+	start  time.Time
+	files  map[CaptureStream]*captureFile
+	closed bool
+	logger *slog.Logger
+	mu     sync.Mutex
+}
+
+// NewCallCapture creates the call's capture directory and opens its files
+func NewCallCapture(callID string) (*CallCapture, error) {
+	dir := filepath.Join(rtpCaptureDir(), filepath.Base(callID))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create capture directory: %w", err)
+	}
+
+	c := &CallCapture{
+		logger: callLogger(callID),
+		dir:    dir,
+		start:  time.Now(),
+		files:  make(map[CaptureStream]*captureFile),
+	}
+	for _, stream := range captureStreams {
+		file, err := os.Create(filepath.Join(dir, string(stream)+captureFileExt))
+		if err != nil {
+			c.closeFiles()
+			return nil, err
+		}
+		buf := bufio.NewWriter(file)
+		// The peer's address isn't known to the forwarding loops; rtpdump only uses it as a label
+		dump, err := rtpdump.NewWriter(buf, rtpdump.Header{Start: c.start, Source: net.IPv4zero})
+		if err != nil {
+			file.Close()
+			c.closeFiles()
+			return nil, err
+		}
+		c.files[stream] = &captureFile{file: file, buf: buf, dump: dump}
+	}
+
+	c.logger.Info("🧲 Capturing RTP", "dir", dir)
+	return c, nil
+}
+
+// WriteRTP stores one RTP packet on stream
+func (c *CallCapture) WriteRTP(stream CaptureStream, packet []byte) {
+	c.write(stream, packet, false)
+}
+
+// WriteRTCP stores one compound RTCP packet on stream
+func (c *CallCapture) WriteRTCP(stream CaptureStream, packet []byte) {
+	c.write(stream, packet, true)
+}
+
+func (c *CallCapture) write(stream CaptureStream, packet []byte, isRTCP bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	f := c.files[stream]
+	if c.closed || f == nil {
+		return
+	}
+	err := f.dump.WritePacket(rtpdump.Packet{
+		Offset:  time.Since(c.start),
+		IsRTCP:  isRTCP,
+		Payload: packet,
+	})
+	if err != nil {
+		c.logger.Warn("⚠️ Failed to write capture packet", "stream", stream, "error", err)
+		return
+	}
+	if isRTCP {
+		f.rtcp++
+	} else {
+		f.packets++
+	}
+}
+
+// Close flushes and closes every file
+func (c *CallCapture) Close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return
+	}
+	c.closed = true
+
+	c.closeFiles()
+	var counts []any
+	for _, stream := range captureStreams {
+		if f := c.files[stream]; f != nil {
+			counts = append(counts, string(stream), fmt.Sprintf("%d/%d", f.packets, f.rtcp))
+		}
+	}
+	c.logger.Info("⏹️ RTP capture saved (RTP/RTCP packets per stream)", counts...)
+}
+
+// closeFiles closes whichever files were opened
+func (c *CallCapture) closeFiles() {
+	for stream, f := range c.files {
+		if err := f.buf.Flush(); err != nil {
+			c.logger.Warn("⚠️ Failed to flush capture", "stream", stream, "error", err)
+		}
+		f.file.Close()
+	}
+}
+
+// callCapture returns the call's packet capture, starting it on first use if
+// the call opted in. It returns nil once the call is ending.
+func (b *WhatsAppBridge) callCapture(call *Call) *CallCapture {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !call.CaptureRTP || call.Capture != nil || call.captureStarted || call.ID == "" {
+		return call.Capture
+	}
+	select {
+	case <-call.Lifecycle.done:
+		return nil // endCall has already collected the capture
+	default:
+	}
+
+	call.captureStarted = true
+	capture, err := NewCallCapture(call.ID)
+	if err != nil {
+		callLogger(call.ID).Error("❌ Failed to start RTP capture", "error", err)
+		return nil
+	}
+	call.Capture = capture
+	return capture
+}
+
+// captureRTCP returns a callback that stores RTCP on stream of the call's capture
+func (b *WhatsAppBridge) captureRTCP(call *Call, stream CaptureStream) func(packet []byte) {
+	return func(packet []byte) {
+		if capture := b.callCapture(call); capture != nil {
+			capture.WriteRTCP(stream, packet)
+		}
+	}
+}
+
+// senderRTCP returns the callback for RTCP read from a leg's RTPSender. That
+// is the peer's feedback on what the bridge sends, so it goes on the leg's
+// outgoing stream.
+func (b *WhatsAppBridge) senderRTCP(call *Call, leg captureLeg) func(packet []byte) {
+	return b.captureRTCP(call, leg.out)
+}
+
+// receiverRTCP returns the callback for RTCP read from a leg's RTPReceiver:
+// the peer's sender reports on its own media, for the leg's incoming stream
+func (b *WhatsAppBridge) receiverRTCP(call *Call, leg captureLeg) func(packet []byte) {
+	return b.captureRTCP(call, leg.in)
+}
+
+// rtcpReader is an RTPSender or RTPReceiver
+type rtcpReader interface {
+	Read(b []byte) (int, interceptor.Attributes, error)
+}
+
+// readRTCP drains RTCP from a sender or receiver until it closes, passing each
+// packet to onRTCP when it is set. Pion needs RTCP read for its interceptors
+// to run.
+func readRTCP(reader rtcpReader, onRTCP func(packet []byte)) {
+	rtcpBuf := make([]byte, 1500)
+	for {
+		n, _, err := reader.Read(rtcpBuf)
+		if err != nil {
+			return
+		}
+		if onRTCP != nil {
+			onRTCP(rtcpBuf[:n])
+		}
+	}
+}
+
+// handleStartCapture opts an active call into packet capture from now on
+func (b *WhatsAppBridge) handleStartCapture(w http.ResponseWriter, r *http.Request) {
+	callID := mux.Vars(r)["id"]
+	b.mu.Lock()
+	call, exists := b.activeCalls[callID]
+	if exists {
+		call.CaptureRTP = true
+	}
+	b.mu.Unlock()
+	if !exists {
+		http.Error(w, "Call not found", http.StatusNotFound)
+		return
+	}
+
+	if b.callCapture(call) == nil {
+		http.Error(w, "Failed to start capture", http.StatusInternalServerError)
+		return
+	}
+	writeCaptureFiles(w, callID)
+}
+
+// handleGetCapture lists a call's capture files
+func (b *WhatsAppBridge) handleGetCapture(w http.ResponseWriter, r *http.Request) {
+	callID := mux.Vars(r)["id"]
+	if _, err := os.Stat(filepath.Join(rtpCaptureDir(), filepath.Base(callID))); err != nil {
+		http.Error(w, "Capture not found", http.StatusNotFound)
+		return
+	}
+	writeCaptureFiles(w, callID)
+}
+
+// writeCaptureFiles responds with the names of a call's capture files
+func writeCaptureFiles(w http.ResponseWriter, callID string) {
+	files := make([]string, 0, len(captureStreams))
+	for _, stream := range captureStreams {
+		files = append(files, string(stream)+captureFileExt)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"call_id": callID,
+		"dir":     filepath.Join(rtpCaptureDir(), filepath.Base(callID)),
+		"files":   files,
+	})
+}
+
+// handleDownloadCapture serves one of a call's rtpdump files
+func (b *WhatsAppBridge) handleDownloadCapture(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	for _, stream := range captureStreams {
+		name := string(stream) + captureFileExt
+		if vars["file"] == name {
+			w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filepath.Base(vars["id"])+"-"+name))
+			http.ServeFile(w, r, filepath.Join(rtpCaptureDir(), filepath.Base(vars["id"]), name))
+			return
+		}
+	}
+	http.Error(w, "Capture file not found", http.StatusNotFound)
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v4"
+)
+
+// RTCP packet types of the first packet in a compound packet
+const (
+	rtcpTypeSenderReport   = 200
+	rtcpTypeReceiverReport = 201
+)
+
+// TestCaptureRTCPLegs connects a bridge-side peer connection to a remote one,
+// sends audio both ways and checks the remote's sender reports land on the
+// incoming stream and its receiver reports on the outgoing one
+func TestCaptureRTCPLegs(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("RTP_CAPTURE_DIR", dir)
+
+	b := &WhatsAppBridge{activeCalls: make(map[string]*Call)}
+	call := newCall("wacid.capture-test", nil)
+	call.CaptureRTP = true
+
+	local, localTrack := newAudioPeer(t)
+	remote, remoteTrack := newAudioPeer(t)
+
+	go readRTCP(local.GetSenders()[0], b.senderRTCP(call, whatsappCaptureLeg))
+	local.OnTrack(func(track *webrtc.TrackRemote, receiver *webrtc.RTPReceiver) {
+		go readRTCP(receiver, b.receiverRTCP(call, whatsappCaptureLeg))
+		drainTrack(track)
+	})
+	go readRTCP(remote.GetSenders()[0], nil)
+	remote.OnTrack(func(track *webrtc.TrackRemote, receiver *webrtc.RTPReceiver) {
+		go readRTCP(receiver, nil)
+		drainTrack(track)
+	})
+
+	connectPeers(t, local, remote)
+
+	// Reports go out every second once media is flowing
+	deadline := time.Now().Add(3 * time.Second)
+	for seq := uint16(0); time.Now().Before(deadline); seq++ {
+		for _, track := range []*webrtc.TrackLocalStaticRTP{localTrack, remoteTrack} {
+			packet := &rtp.Packet{
+				Header:  rtp.Header{Version: 2, PayloadType: 111, SequenceNumber: seq, Timestamp: uint32(seq) * 960},
+				Payload: []byte{0xf8, 0xff, 0xfe},
+			}
+			if err := track.WriteRTP(packet); err != nil {
+				t.Fatalf("WriteRTP: %v", err)
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	local.Close()
+	remote.Close()
+
+	capture := b.callCapture(call)
+	if capture == nil {
+		t.Fatal("capture was not started")
+	}
+	capture.Close()
+
+	types := func(stream CaptureStream) map[byte]int {
+		packets, err := readCaptureFile(filepath.Join(dir, call.ID, string(stream)+captureFileExt))
+		if err != nil {
+			t.Fatalf("reading %s: %v", stream, err)
+		}
+		counts := make(map[byte]int)
+		for _, packet := range packets {
+			if packet.IsRTCP && len(packet.Payload) > 1 {
+				counts[packet.Payload[1]]++
+			}
+		}
+		return counts
+	}
+
+	in := types(CaptureWhatsAppIn)
+	if in[rtcpTypeSenderReport] == 0 || in[rtcpTypeReceiverReport] != 0 {
+		t.Errorf("%s RTCP types = %v, want only the remote's sender reports", CaptureWhatsAppIn, in)
+	}
+	out := types(CaptureWhatsAppOut)
+	if out[rtcpTypeReceiverReport] == 0 || out[rtcpTypeSenderReport] != 0 {
+		t.Errorf("%s RTCP types = %v, want the remote's receiver reports and no sender reports", CaptureWhatsAppOut, out)
+	}
+}
+
+// newAudioPeer returns a peer connection sending one Opus track
+func newAudioPeer(t *testing.T) (*webrtc.PeerConnection, *webrtc.TrackLocalStaticRTP) {
+	t.Helper()
+
+	pc, err := webrtc.NewPeerConnection(webrtc.Configuration{})
+	if err != nil {
+		t.Fatalf("NewPeerConnection: %v", err)
+	}
+	t.Cleanup(func() { pc.Close() })
+	track, err := webrtc.NewTrackLocalStaticRTP(webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeOpus, ClockRate: 48000, Channels: 2}, "audio", "capture-test")
+	if err != nil {
+		t.Fatalf("NewTrackLocalStaticRTP: %v", err)
+	}
+	if _, err := pc.AddTrack(track); err != nil {
+		t.Fatalf("AddTrack: %v", err)
+	}
+	return pc, track
+}
+
+// connectPeers negotiates offer and answer with complete candidates and
+// waits for both sides to connect
+func connectPeers(t *testing.T, offerer, answerer *webrtc.PeerConnection) {
+	t.Helper()
+
+	connected := make(chan struct{}, 2)
+	for _, pc := range []*webrtc.PeerConnection{offerer, answerer} {
+		pc.OnConnectionStateChange(func(state webrtc.PeerConnectionState) {
+			if state == webrtc.PeerConnectionStateConnected {
+				connected <- struct{}{}
+			}
+		})
+	}
+
+	offer, err := offerer.CreateOffer(nil)
+	if err != nil {
+		t.Fatalf("CreateOffer: %v", err)
+	}
+	gathered := webrtc.GatheringCompletePromise(offerer)
+	if err := offerer.SetLocalDescription(offer); err != nil {
+		t.Fatalf("SetLocalDescription: %v", err)
+	}
+	<-gathered
+	if err := answerer.SetRemoteDescription(*offerer.LocalDescription()); err != nil {
+		t.Fatalf("SetRemoteDescription: %v", err)
+	}
+	answer, err := answerer.CreateAnswer(nil)
+	if err != nil {
+		t.Fatalf("CreateAnswer: %v", err)
+	}
+	gathered = webrtc.GatheringCompletePromise(answerer)
+	if err := answerer.SetLocalDescription(answer); err != nil {
+		t.Fatalf("SetLocalDescription: %v", err)
+	}
+	<-gathered
+	if err := offerer.SetRemoteDescription(*answerer.LocalDescription()); err != nil {
+		t.Fatalf("SetRemoteDescription: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		select {
+		case <-connected:
+		case <-time.After(10 * time.Second):
+			t.Fatal("peer connections did not connect")
+		}
+	}
+}
+
+// drainTrack reads a remote track until it ends, so its receiver keeps reporting
+func drainTrack(track *webrtc.TrackRemote) {
+	buf := make([]byte, 1500)
+	for {
+		if _, _, err := track.Read(buf); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v4/pkg/media/rtpdump"
+)
+
+// Files rtp-replay writes next to the capture, in the same format, so the
+// replayed output can be inspected with the same tools as the original
+const replayFilePrefix = "replay-"
+
+// runRTPReplay feeds a call's capture back through forwardCallerAudio and
+// forwardAgentAudio and compares what they send with what the live call
+// sent. It returns the exit code: 0 when the output matches, 1 when it
+// differs and 2 when the capture can't be read.
+//
+//	pion-whatsapp-bridge rtp-replay data/rtp-captures/<call_id>
+func runRTPReplay(args []string) int {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "usage: pion-whatsapp-bridge rtp-replay <capture dir>")
+		return 2
+	}
+	dir := args[0]
+
+	streams := make(map[CaptureStream][]rtpdump.Packet)
+	for _, stream := range captureStreams {
+		packets, err := readCaptureFile(filepath.Join(dir, string(stream)+captureFileExt))
+		if err != nil {
+			log.Printf("❌ Failed to read %s capture: %v", stream, err)
+			return 2
+		}
+		streams[stream] = packets
+	}
+
+	// A bridge with no tenant, peer connections or capture of its own; the
+	// replay agent stands in for the voice agent and records what it is sent
+	b := &WhatsAppBridge{activeCalls: make(map[string]*Call)}
+	call := newCall("rtp-replay", nil)
+	callerSource := &capturedRTPSource{packets: streams[CaptureWhatsAppIn]}
+	agent := &replayVoiceAgent{out: &replayOutput{source: callerSource}}
+	call.Agent = agent
+
+	log.Printf("🔁 Replaying %s through the WhatsApp → agent loop", dir)
+	b.forwardCallerAudio(call, callerSource)
+
+	log.Printf("🔁 Replaying %s through the agent → WhatsApp loop", dir)
+	agentSource := &capturedRTPSource{packets: streams[CaptureAgentIn]}
+	toWhatsApp := &replayOutput{source: agentSource}
+	b.forwardAgentAudio(call, agentSource, toWhatsApp)
+
+	matched := compareRTPStreams(CaptureAgentOut, streams[CaptureAgentOut], agent.out.packets)
+	matched = compareRTPStreams(CaptureWhatsAppOut, streams[CaptureWhatsAppOut], toWhatsApp.packets) && matched
+
+	for stream, packets := range map[CaptureStream][]rtpdump.Packet{
+		CaptureAgentOut:    agent.out.packets,
+		CaptureWhatsAppOut: toWhatsApp.packets,
+	} {
+		path := filepath.Join(dir, replayFilePrefix+string(stream)+captureFileExt)
+		if err := writeCaptureFile(path, packets); err != nil {
+			log.Printf("⚠️ Failed to write %s: %v", path, err)
+		}
+	}
+
+	if !matched {
+		log.Printf("❌ Replayed output differs from the capture")
+		return 1
+	}
+	log.Printf("✅ Replayed output matches the capture")
+	return 0
+}
+
+// readCaptureFile reads every packet of an rtpdump file
+func readCaptureFile(path string) ([]rtpdump.Packet, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader, _, err := rtpdump.NewReader(file)
+	if err != nil {
+		return nil, err
+	}
+	var packets []rtpdump.Packet
+	for {
+		packet, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return packets, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		packets = append(packets, packet)
+	}
+}
+
+// writeCaptureFile writes packets to a new rtpdump file
+func writeCaptureFile(path string, packets []rtpdump.Packet) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer, err := rtpdump.NewWriter(file, rtpdump.Header{Source: net.IPv4zero})
+	if err != nil {
+		return err
+	}
+	for _, packet := range packets {
+		if err := writer.WritePacket(packet); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// capturedRTPSource plays the RTP packets of a capture, skipping RTCP
+type capturedRTPSource struct {
+	packets []rtpdump.Packet
+	next    int
+	current rtpdump.Packet // The packet most recently returned
+}
+
+// ReadRTP implements RTPSource, returning io.EOF at the end of the capture
+func (s *capturedRTPSource) ReadRTP() (*rtp.Packet, error) {
+	for s.next < len(s.packets) {
+		packet := s.packets[s.next]
+		s.next++
+		if packet.IsRTCP {
+			continue
+		}
+		s.current = packet
+		p := &rtp.Packet{}
+		if err := p.Unmarshal(packet.Payload); err != nil {
+			log.Printf("⚠️ Skipping malformed captured RTP packet at %v: %v", packet.Offset, err)
+			continue
+		}
+		return p, nil
+	}
+	return nil, io.EOF
+}
+
+// replayOutput collects what a forwarding loop sends, stamped with the
+// capture offset of the packet that produced it
+type replayOutput struct {
+	source  *capturedRTPSource
+	packets []rtpdump.Packet
+}
+
+// Write implements io.Writer for forwardAgentAudio
+func (o *replayOutput) Write(p []byte) (int, error) {
+	o.packets = append(o.packets, rtpdump.Packet{
+		Offset:  o.source.current.Offset,
+		Payload: append([]byte(nil), p...),
+	})
+	return len(p), nil
+}
+
+// replayVoiceAgent is attached to the replayed call from the start and
+// records the caller audio forwarded to it
+type replayVoiceAgent struct {
+	out *replayOutput
+}
+
+func (a *replayVoiceAgent) Name() string                           { return "rtp-replay" }
+func (a *replayVoiceAgent) Connect() error                         { return nil }
+func (a *replayVoiceAgent) AudioOutput() RTPSource                 { return nil }
+func (a *replayVoiceAgent) InjectText(text string) error           { return nil }
+func (a *replayVoiceAgent) SendToolResult(id, output string) error { return nil }
+func (a *replayVoiceAgent) Close()                                 {}
+
+// WriteRTP implements VoiceAgent
+func (a *replayVoiceAgent) WriteRTP(packet []byte) error {
+	_, err := a.out.Write(packet)
+	return err
+}
+
+// rtpKey identifies a packet within a capture
+type rtpKey struct {
+	ssrc uint32
+	seq  uint16
+}
+
+// compareRTPStreams checks every packet the live call sent on stream against
+// the replayed packet with the same SSRC and sequence number. Replayed
+// packets the live call never sent (the agent wasn't connected yet) are only
+// counted.
+func compareRTPStreams(stream CaptureStream, captured, replayed []rtpdump.Packet) bool {
+	replayedByKey := make(map[rtpKey][]byte, len(replayed))
+	for _, packet := range replayed {
+		var header rtp.Header
+		if _, err := header.Unmarshal(packet.Payload); err == nil {
+			replayedByKey[rtpKey{header.SSRC, header.SequenceNumber}] = packet.Payload
+		}
+	}
+
+	matching, differing, missing := 0, 0, 0
+	for _, packet := range captured {
+		if packet.IsRTCP {
+			continue
+		}
+		var header rtp.Header
+		if _, err := header.Unmarshal(packet.Payload); err != nil {
+			continue
+		}
+		key := rtpKey{header.SSRC, header.SequenceNumber}
+		got, ok := replayedByKey[key]
+		delete(replayedByKey, key)
+		switch {
+		case !ok:
+			missing++
+			if missing <= 3 {
+				log.Printf("❌ %s: packet SSRC=%d seq=%d at %v was not reproduced", stream, key.ssrc, key.seq, packet.Offset)
+			}
+		case !bytes.Equal(got, packet.Payload):
+			differing++
+			if differing <= 3 {
+				log.Printf("❌ %s: packet SSRC=%d seq=%d at %v differs (%d bytes live, %d replayed)",
+					stream, key.ssrc, key.seq, packet.Offset, len(packet.Payload), len(got))
+			}
+		default:
+			matching++
+		}
+	}
+
+	log.Printf("📊 %s: %d packets match, %d differ, %d not reproduced, %d only in the replay",
+		stream, matching, differing, missing, len(replayedByKey))
+	return differing == 0 && missing == 0
+}
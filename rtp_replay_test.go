@@ -0,0 +1,104 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v4/pkg/media/rtpdump"
+)
+
+// writeTestCapture writes a small capture of both legs: incoming packets
+// carry an audio-level extension and the outgoing ones are the same packets
+// with it stripped, the way the forwarding loops send them
+func writeTestCapture(t *testing.T, dir string) map[CaptureStream][]rtpdump.Packet {
+	t.Helper()
+
+	streams := make(map[CaptureStream][]rtpdump.Packet)
+	for _, leg := range []struct {
+		in, out CaptureStream
+		ssrc    uint32
+	}{
+		{CaptureWhatsAppIn, CaptureAgentOut, 1111},
+		{CaptureAgentIn, CaptureWhatsAppOut, 2222},
+	} {
+		for seq := uint16(65533); seq != 3; seq++ {
+			packet := &rtp.Packet{
+				Header: rtp.Header{
+					Version:        2,
+					PayloadType:    111,
+					SequenceNumber: seq,
+					Timestamp:      uint32(seq) * 960,
+					SSRC:           leg.ssrc,
+				},
+				Payload: []byte{0xf8, 0xff, 0xfe, byte(seq)},
+			}
+			offset := time.Duration(seq-65533) * 20 * time.Millisecond
+
+			if err := packet.SetExtension(1, []byte{0x30}); err != nil {
+				t.Fatal(err)
+			}
+			raw, err := packet.Marshal()
+			if err != nil {
+				t.Fatal(err)
+			}
+			streams[leg.in] = append(streams[leg.in], rtpdump.Packet{Offset: offset, Payload: raw})
+
+			packet.Extension = false
+			packet.Extensions = nil
+			raw, err = packet.Marshal()
+			if err != nil {
+				t.Fatal(err)
+			}
+			streams[leg.out] = append(streams[leg.out], rtpdump.Packet{Offset: offset, Payload: raw})
+		}
+		// A sender report on the incoming stream is skipped by the replay
+		streams[leg.in] = append(streams[leg.in], rtpdump.Packet{
+			Offset:  time.Second,
+			IsRTCP:  true,
+			Payload: []byte{0x80, 200, 0x00, 0x06, 0, 0, 0x04, 0x57, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0},
+		})
+	}
+
+	for stream, packets := range streams {
+		if err := writeCaptureFile(filepath.Join(dir, string(stream)+captureFileExt), packets); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return streams
+}
+
+// TestRTPReplayExitCode replays a capture that matches what the forwarding
+// loops send, then the same capture with one outgoing packet altered
+func TestRTPReplayExitCode(t *testing.T) {
+	dir := t.TempDir()
+	streams := writeTestCapture(t, dir)
+
+	if code := runRTPReplay([]string{dir}); code != 0 {
+		t.Fatalf("matching capture: exit code %d, want 0", code)
+	}
+	replayed, err := readCaptureFile(filepath.Join(dir, replayFilePrefix+string(CaptureWhatsAppOut)+captureFileExt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(replayed) != len(streams[CaptureWhatsAppOut]) {
+		t.Errorf("replay wrote %d WhatsApp packets, want %d", len(replayed), len(streams[CaptureWhatsAppOut]))
+	}
+
+	// The live call "sent" a different payload byte than the loop produces
+	mutated := append([]rtpdump.Packet(nil), streams[CaptureWhatsAppOut]...)
+	payload := append([]byte(nil), mutated[2].Payload...)
+	payload[len(payload)-1] ^= 0xff
+	mutated[2].Payload = payload
+	if err := writeCaptureFile(filepath.Join(dir, string(CaptureWhatsAppOut)+captureFileExt), mutated); err != nil {
+		t.Fatal(err)
+	}
+	if code := runRTPReplay([]string{dir}); code != 1 {
+		t.Errorf("mutated capture: exit code %d, want 1", code)
+	}
+
+	if code := runRTPReplay([]string{t.TempDir()}); code != 2 {
+		t.Errorf("empty directory: exit code %d, want 2", code)
+	}
+}
@@ -0,0 +1,428 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+)
+
+// Store backends selectable with STORE_BACKEND
+const (
+	storeBackendSupabase = "supabase"
+	storeBackendPostgres = "postgres"
+	storeBackendSQLite   = "sqlite"
+)
+
+// Store persists Ziggy's data: tasks, reminders, notes, conversation history
+// and call permissions. Every method takes the tenant's schema; an empty
+// schema means the backend's default.
+type Store interface {
+	// Name identifies the backend in logs
+	Name() string
+
+	// Configured reports whether the backend can be used, e.g. whether the
+	// Supabase credentials are set
+	Configured() bool
+
+	// Tasks
+	InsertTask(schema string, task ZiggyTask) (*ZiggyTask, error)
+	ListTasks(schema, phoneNumber, status string) ([]ZiggyTask, error)
+	UpdateTaskStatus(schema, taskID, status string) error
+
+	// Reminders; ReminderTime is stored in UTC
+	InsertReminder(schema string, reminder ZiggyReminder) (*ZiggyReminder, error)
+	ListReminders(schema, phoneNumber, status string) ([]ZiggyReminder, error)
+	DueReminders(schema string, now time.Time) ([]ZiggyReminder, error)
+	UpdateReminderStatus(schema, reminderID, status, callID string) error
+
+	// Notes
+	InsertNote(schema string, note ZiggyNote) (*ZiggyNote, error)
+	ListNotes(schema, phoneNumber string, limit int) ([]ZiggyNote, error)
+	SearchNotes(schema, phoneNumber, query string) ([]ZiggyNote, error)
+	UpdateNote(schema, noteID, content string) error
+	DeleteNote(schema, noteID string) error
+
+	// WhatsApp text messages; saving a message ID twice is not an error
+	SaveMessage(schema string, msg TextMessage) error
+	CountMessages(schema, phoneNumber string) (int, error)
+	ListMessages(schema, phoneNumber string, limit int, ascending bool) ([]TextMessage, error)
+
+	// Voice call transcripts; ListCallTranscriptTurns returns newest first
+	SaveCallTranscriptTurn(schema string, turn CallTranscriptTurn) error
+	ListCallTranscriptTurns(schema, phoneNumber string, limit int) ([]CallTranscriptTurn, error)
+
+	// Call permissions. GrantedCallPermission returns nil when the number
+	// has no granted permission. fields are whatsapp_call_permissions columns.
+	GrantedCallPermission(schema, phoneNumber string) (*WhatsAppCallPermission, error)
+	InsertCallPermission(schema string, fields map[string]interface{}) error
+	UpdateCallPermission(schema, phoneNumber string, fields map[string]interface{}) error
+}
+
+// dataStore is where all of Ziggy's data lives. NewWhatsAppBridge replaces it
+// with the backend STORE_BACKEND selects once the environment is loaded.
+var dataStore Store = &SupabaseStore{}
+
+// NewStoreFromEnv builds the store selected by STORE_BACKEND: "supabase"
+// (default, SUPABASE_URL / SUPABASE_ANON_KEY), "postgres" (DATABASE_URL) or
+// "sqlite" (SQLITE_PATH, default data/ziggy.db)
+func NewStoreFromEnv() (Store, error) {
+	switch backend := os.Getenv("STORE_BACKEND"); backend {
+	case "", storeBackendSupabase:
+		store := NewSupabaseStore()
+		if !store.Configured() {
+			slog.Warn("⚠️ Supabase not configured - tasks, reminders, notes and history are disabled")
+		} else {
+			slog.Info("🗄️ Store: supabase")
+		}
+		return store, nil
+	case storeBackendPostgres:
+		dsn := os.Getenv("DATABASE_URL")
+		if dsn == "" {
+			return nil, fmt.Errorf("STORE_BACKEND=postgres requires DATABASE_URL")
+		}
+		return NewPostgresStore(dsn)
+	case storeBackendSQLite:
+		path := os.Getenv("SQLITE_PATH")
+		if path == "" {
+			path = "data/ziggy.db"
+		}
+		return NewSQLiteStore(path)
+	default:
+		return nil, fmt.Errorf("unknown STORE_BACKEND %q (available: %s, %s, %s)",
+			backend, storeBackendSupabase, storeBackendPostgres, storeBackendSQLite)
+	}
+}
+
+// AddTask creates a new task
+func AddTask(schema string, title, description, priority, phoneNumber string) (*ZiggyTask, error) {
+	task, err := dataStore.InsertTask(schema, ZiggyTask{
+		Title:       title,
+		Description: description,
+		Priority:    priority,
+		PhoneNumber: phoneNumber,
+		Status:      "pending",
+		CreatedBy:   "whatsapp_voice_agent",
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	slog.Info("✅ Task created", "task_id", task.ID)
+	slog.Debug("✅ Task title", "task_id", task.ID, "content", task.Title)
+	return task, nil
+}
+
+// ListTasks retrieves tasks for a phone number, newest first
+func ListTasks(schema string, phoneNumber string, status string) ([]ZiggyTask, error) {
+	tasks, err := dataStore.ListTasks(schema, phoneNumber, status)
+	if err != nil {
+		return nil, err
+	}
+
+	slog.Debug("📋 Retrieved tasks", "count", len(tasks), "phone", phoneNumber)
+	return tasks, nil
+}
+
+// UpdateTaskStatus updates the status of a task
+func UpdateTaskStatus(schema string, taskID, status string) error {
+	if err := dataStore.UpdateTaskStatus(schema, taskID, status); err != nil {
+		return err
+	}
+
+	slog.Info("✅ Task status updated", "task_id", taskID, "status", status)
+	return nil
+}
+
+// AddReminder creates a new reminder
+// reminderTime should be in local format like "2025-11-09 14:30" and will be converted to UTC based on phone number timezone
+// recurrencePattern can be: empty/"once" (one-time), "daily", "weekly", "monthly", "yearly"
+func AddReminder(schema string, reminderText, reminderTime, phoneNumber, recurrencePattern string) (*ZiggyReminder, error) {
+	// Convert local time to UTC for storage (timezone auto-detected from phone number)
+	utcTime, err := ConvertLocalToUTC(reminderTime, phoneNumber)
+	if err != nil {
+		return nil, fmt.Errorf("invalid reminder time: %v", err)
+	}
+
+	// Normalize recurrence pattern
+	if recurrencePattern == "" {
+		recurrencePattern = "once"
+	}
+
+	reminder, err := dataStore.InsertReminder(schema, ZiggyReminder{
+		PhoneNumber:       phoneNumber,
+		ReminderText:      reminderText,
+		ReminderTime:      utcTime,
+		RecurrencePattern: recurrencePattern,
+		Status:            "pending",
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	slog.Info("✅ Reminder created", "reminder_id", reminder.ID, "at", reminder.ReminderTime)
+	slog.Debug("✅ Reminder text", "reminder_id", reminder.ID, "reminder", reminder.ReminderText)
+	return reminder, nil
+}
+
+// GetDueReminders retrieves all pending reminders that are due
+func GetDueReminders(schema string) ([]ZiggyReminder, error) {
+	reminders, err := dataStore.DueReminders(schema, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+
+	slog.Debug("📋 Retrieved due reminders", "count", len(reminders))
+	return reminders, nil
+}
+
+// UpdateReminderStatus updates the status of a reminder
+func UpdateReminderStatus(schema string, reminderID, status string, callID string) error {
+	if err := dataStore.UpdateReminderStatus(schema, reminderID, status, callID); err != nil {
+		return err
+	}
+
+	slog.Info("✅ Reminder status updated", "reminder_id", reminderID, "status", status)
+	return nil
+}
+
+// ListReminders retrieves reminders for a phone number, soonest first
+func ListReminders(schema string, phoneNumber string, status string) ([]ZiggyReminder, error) {
+	reminders, err := dataStore.ListReminders(schema, phoneNumber, status)
+	if err != nil {
+		return nil, err
+	}
+
+	slog.Debug("📋 Retrieved reminders", "count", len(reminders), "phone", phoneNumber)
+	return reminders, nil
+}
+
+// CancelReminder cancels a reminder by updating its status to 'cancelled'
+// On Supabase a database trigger then unschedules the cron job
+func CancelReminder(schema string, reminderID string) error {
+	return UpdateReminderStatus(schema, reminderID, "cancelled", "")
+}
+
+// GrantCallPermission records that a user has granted call permission by calling us first
+// This is called automatically when we receive an inbound call
+// If the user already exists, it updates the last_inbound_call_at and increments the counter
+func GrantCallPermission(schema string, phoneNumber string) error {
+	now := time.Now().UTC().Format(time.RFC3339)
+
+	// First, check if permission already exists
+	existing, err := CheckCallPermission(schema, phoneNumber)
+	if err == nil && existing != nil {
+		// Update existing record: increment counter and update last call time
+		err := dataStore.UpdateCallPermission(schema, phoneNumber, map[string]interface{}{
+			"last_inbound_call_at": now,
+			"total_inbound_calls":  existing.TotalInboundCalls + 1,
+			"permission_granted":   true, // Re-grant if it was revoked
+			"updated_at":           now,
+		})
+		if err != nil {
+			return fmt.Errorf("error updating permission: %w", err)
+		}
+
+		slog.Info("✅ Updated call permission", "phone", phoneNumber, "total_calls", existing.TotalInboundCalls+1)
+		return nil
+	}
+
+	// Create new permission record
+	err = dataStore.InsertCallPermission(schema, map[string]interface{}{
+		"phone_number":        phoneNumber,
+		"permission_granted":  true,
+		"total_inbound_calls": 1,
+	})
+	if err != nil {
+		return fmt.Errorf("error creating permission: %w", err)
+	}
+
+	slog.Info("✅ Granted call permission on first inbound call", "phone", phoneNumber)
+	return nil
+}
+
+// CheckCallPermission checks if a phone number has permission to receive calls
+// Returns the permission record if it exists and is granted, nil otherwise
+// Also validates 72-hour expiry window for express permissions
+func CheckCallPermission(schema string, phoneNumber string) (*WhatsAppCallPermission, error) {
+	permission, err := dataStore.GrantedCallPermission(schema, phoneNumber)
+	if err != nil || permission == nil {
+		return nil, err
+	}
+
+	// Check if permission has expired (72-hour window for express permissions)
+	if permission.PermissionExpiresAt != "" {
+		expiresAt, err := time.Parse(time.RFC3339, permission.PermissionExpiresAt)
+		if err == nil && time.Now().UTC().After(expiresAt) {
+			slog.Info("⚠️ Call permission has expired", "phone", phoneNumber, "expired_at", permission.PermissionExpiresAt)
+			// Auto-revoke expired permission
+			RevokeCallPermission(schema, phoneNumber)
+			return nil, nil // Permission expired
+		}
+	}
+
+	// Permission is valid and not expired
+	return permission, nil
+}
+
+// RevokeCallPermission revokes call permission for a phone number
+// This can be called if a user opts out or requests to stop receiving calls
+func RevokeCallPermission(schema string, phoneNumber string) error {
+	err := dataStore.UpdateCallPermission(schema, phoneNumber, map[string]interface{}{
+		"permission_granted": false,
+		"updated_at":         time.Now().UTC().Format(time.RFC3339),
+	})
+	if err != nil {
+		return err
+	}
+
+	slog.Info("🚫 Revoked call permission", "phone", phoneNumber)
+	return nil
+}
+
+// RequestCallPermission records a request for call permission, enforcing Meta's limits
+// Returns true if request was recorded, false if rate limited
+// Rate limits: 1 request per 24 hours, 2 requests per 7 days
+func RequestCallPermission(schema string, phoneNumber string) (bool, error) {
+	// Check existing permission record
+	existing, _ := CheckCallPermission(schema, phoneNumber)
+
+	now := time.Now().UTC()
+
+	// Check rate limits
+	if existing != nil && existing.LastPermissionRequestAt != "" {
+		lastRequest, err := time.Parse(time.RFC3339, existing.LastPermissionRequestAt)
+		if err == nil {
+			// Check 24-hour limit
+			if now.Sub(lastRequest) < 24*time.Hour {
+				slog.Warn("🚫 Rate limited: cannot request permission within 24 hours", "phone", phoneNumber, "since_last", now.Sub(lastRequest))
+				return false, fmt.Errorf("rate limited: must wait 24 hours between requests")
+			}
+
+			// Check 7-day limit (2 requests max)
+			if existing.PermissionRequestCount >= 2 && now.Sub(lastRequest) < 7*24*time.Hour {
+				slog.Warn("🚫 Rate limited: 2 permission requests in past 7 days", "phone", phoneNumber)
+				return false, fmt.Errorf("rate limited: maximum 2 requests per 7 days")
+			}
+		}
+	}
+
+	// Create or update permission record with pending status
+	if existing == nil {
+		err := dataStore.InsertCallPermission(schema, map[string]interface{}{
+			"phone_number":               phoneNumber,
+			"permission_granted":         false, // Pending approval
+			"permission_requested_at":    now.Format(time.RFC3339),
+			"last_permission_request_at": now.Format(time.RFC3339),
+			"permission_request_count":   1,
+			"permission_source":          "express_request",
+		})
+		if err != nil {
+			return false, err
+		}
+	} else {
+		requestCount := existing.PermissionRequestCount + 1
+
+		// Reset count if 7 days have passed
+		if existing.LastPermissionRequestAt != "" {
+			lastRequest, err := time.Parse(time.RFC3339, existing.LastPermissionRequestAt)
+			if err == nil && now.Sub(lastRequest) >= 7*24*time.Hour {
+				requestCount = 1
+			}
+		}
+
+		err := dataStore.UpdateCallPermission(schema, phoneNumber, map[string]interface{}{
+			"permission_requested_at":    now.Format(time.RFC3339),
+			"last_permission_request_at": now.Format(time.RFC3339),
+			"permission_request_count":   requestCount,
+			"updated_at":                 now.Format(time.RFC3339),
+		})
+		if err != nil {
+			return false, err
+		}
+	}
+
+	slog.Info("✅ Recorded permission request", "phone", phoneNumber)
+	return true, nil
+}
+
+// ApproveCallPermission approves a call permission request
+// Sets 72-hour expiry window from approval time
+func ApproveCallPermission(schema string, phoneNumber, source string) error {
+	now := time.Now().UTC()
+	expiresAt := now.Add(72 * time.Hour) // 72-hour call window
+
+	err := dataStore.UpdateCallPermission(schema, phoneNumber, map[string]interface{}{
+		"permission_granted":     true,
+		"permission_approved_at": now.Format(time.RFC3339),
+		"permission_expires_at":  expiresAt.Format(time.RFC3339),
+		"permission_source":      source,
+		"updated_at":             now.Format(time.RFC3339),
+	})
+	if err != nil {
+		return err
+	}
+
+	slog.Info("✅ Approved call permission for 72 hours", "phone", phoneNumber)
+	return nil
+}
+
+// AddNote creates a new note
+func AddNote(schema string, noteContent, phoneNumber string) (*ZiggyNote, error) {
+	note, err := dataStore.InsertNote(schema, ZiggyNote{
+		PhoneNumber: phoneNumber,
+		NoteContent: noteContent,
+	})
+	if err != nil {
+		slog.Error("❌ [NOTES_DB] Failed to save note", "phone", phoneNumber, "error", err)
+		return nil, err
+	}
+
+	slog.Info("✅ [NOTES_DB] Note saved", "note_id", note.ID, "phone", note.PhoneNumber)
+	slog.Debug("✅ [NOTES_DB] Note content", "note_id", note.ID, "content", note.NoteContent)
+	return note, nil
+}
+
+// ListNotes retrieves all notes for a phone number, ordered by most recent first
+// If limit is provided and > 0, only returns that many notes
+func ListNotes(schema string, phoneNumber string, limit int) ([]ZiggyNote, error) {
+	notes, err := dataStore.ListNotes(schema, phoneNumber, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	slog.Debug("📋 Retrieved notes", "count", len(notes), "phone", phoneNumber)
+	return notes, nil
+}
+
+// SearchNotes searches notes for a phone number using full-text search
+func SearchNotes(schema string, phoneNumber, searchQuery string) ([]ZiggyNote, error) {
+	notes, err := dataStore.SearchNotes(schema, phoneNumber, searchQuery)
+	if err != nil {
+		return nil, err
+	}
+
+	slog.Info("🔍 Searched notes", "matches", len(notes), "phone", phoneNumber)
+	slog.Debug("🔍 Note search query", "text", searchQuery)
+	return notes, nil
+}
+
+// UpdateNote updates the content of an existing note
+func UpdateNote(schema string, noteID, newContent string) error {
+	if err := dataStore.UpdateNote(schema, noteID, newContent); err != nil {
+		return err
+	}
+
+	slog.Info("✅ Note updated", "note_id", noteID)
+	return nil
+}
+
+// DeleteNote deletes a note by ID
+func DeleteNote(schema string, noteID string) error {
+	if err := dataStore.DeleteNote(schema, noteID); err != nil {
+		return err
+	}
+
+	slog.Info("🗑️ Note deleted", "note_id", noteID)
+	return nil
+}
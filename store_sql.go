@@ -0,0 +1,758 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// sqlStore is the Store for a SQL database: Postgres directly (the same
+// tables the Supabase migrations create) or an embedded SQLite file, which
+// needs no server and lets the bridge run fully offline
+type sqlStore struct {
+	dialect string // storeBackendPostgres or storeBackendSQLite
+
+	// Postgres: one database, tenant schemas qualify the table names
+	db *sql.DB
+
+	// SQLite: one file per tenant schema, created on first use
+	path    string
+	mu      sync.Mutex
+	schemas map[string]*sql.DB
+}
+
+// NewPostgresStore connects to the Postgres database at dsn. The tables are
+// expected to exist already (see supabase/migrations).
+func NewPostgresStore(dsn string) (Store, error) {
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to connect to Postgres: %w", err)
+	}
+
+	slog.Info("🗄️ Store: postgres")
+	return &sqlStore{dialect: storeBackendPostgres, db: db}, nil
+}
+
+// NewSQLiteStore opens (or creates) the SQLite database at path. Tenants with
+// their own schema get a sibling file, e.g. data/ziggy.acme.db.
+func NewSQLiteStore(path string) (Store, error) {
+	s := &sqlStore{
+		dialect: storeBackendSQLite,
+		path:    path,
+		schemas: make(map[string]*sql.DB),
+	}
+	if _, err := s.sqliteDB(""); err != nil {
+		return nil, err
+	}
+
+	slog.Info("🗄️ Store: sqlite", "path", path)
+	return s, nil
+}
+
+// Name implements Store
+func (s *sqlStore) Name() string {
+	return s.dialect
+}
+
+// Configured implements Store
+func (s *sqlStore) Configured() bool {
+	return true
+}
+
+// sqliteDB returns the database file for a tenant schema, creating its tables
+// the first time it is opened
+func (s *sqlStore) sqliteDB(schema string) (*sql.DB, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if db, ok := s.schemas[schema]; ok {
+		return db, nil
+	}
+
+	path := s.path
+	if schema != "" {
+		ext := filepath.Ext(path)
+		path = strings.TrimSuffix(path, ext) + "." + schema + ext
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+
+	db, err := sql.Open("sqlite3", "file:"+path+"?_busy_timeout=5000&_journal_mode=WAL")
+	if err != nil {
+		return nil, err
+	}
+	// SQLite allows one writer at a time; a single connection avoids SQLITE_BUSY
+	db.SetMaxOpenConns(1)
+
+	for _, stmt := range sqliteSchema {
+		if _, err := db.Exec(stmt); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to create SQLite tables in %s: %w", path, err)
+		}
+	}
+
+	s.schemas[schema] = db
+	return db, nil
+}
+
+// prepare picks the database for a tenant's table and adapts query to the
+// dialect: %s in query becomes the table name and ? placeholders become $n
+// for Postgres
+func (s *sqlStore) prepare(schema, table, query string) (*sql.DB, string, error) {
+	if s.dialect == storeBackendSQLite {
+		db, err := s.sqliteDB(schema)
+		if err != nil {
+			return nil, "", err
+		}
+		return db, fmt.Sprintf(query, table), nil
+	}
+
+	if schema != "" {
+		table = pq.QuoteIdentifier(schema) + "." + table
+	}
+	query = fmt.Sprintf(query, table)
+
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return s.db, b.String(), nil
+}
+
+// exec runs a statement against a tenant's table
+func (s *sqlStore) exec(schema, table, query string, args ...interface{}) (sql.Result, error) {
+	db, query, err := s.prepare(schema, table, query)
+	if err != nil {
+		return nil, err
+	}
+	return db.Exec(query, args...)
+}
+
+// query runs a query against a tenant's table
+func (s *sqlStore) query(schema, table, query string, args ...interface{}) (*sql.Rows, error) {
+	db, query, err := s.prepare(schema, table, query)
+	if err != nil {
+		return nil, err
+	}
+	return db.Query(query, args...)
+}
+
+// insert runs an INSERT ... RETURNING id and returns the new row's ID
+func (s *sqlStore) insert(schema, table, query string, args ...interface{}) (string, error) {
+	db, query, err := s.prepare(schema, table, query+" RETURNING id")
+	if err != nil {
+		return "", err
+	}
+	var id string
+	if err := db.QueryRow(query, args...).Scan(&id); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// sqlTime formats a timestamp column the way PostgREST returns it
+func sqlTime(t sql.NullTime) string {
+	if !t.Valid {
+		return ""
+	}
+	return t.Time.UTC().Format(time.RFC3339)
+}
+
+// sqlTimeArg parses an RFC3339 value for a timestamp column; "" is NULL
+func sqlTimeArg(value string) (interface{}, error) {
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timestamp %q: %w", value, err)
+	}
+	return t.UTC(), nil
+}
+
+// sqlNow is the current time for timestamp columns
+func sqlNow() time.Time {
+	return time.Now().UTC()
+}
+
+const taskColumns = `id, title, COALESCE(description, ''), COALESCE(status, ''), COALESCE(priority, ''),
+	due_date, created_at, updated_at, COALESCE(created_by, ''), COALESCE(phone_number, '')`
+
+// scanTasks reads rows selected with taskColumns
+func scanTasks(rows *sql.Rows) ([]ZiggyTask, error) {
+	defer rows.Close()
+
+	tasks := []ZiggyTask{}
+	for rows.Next() {
+		var task ZiggyTask
+		var dueDate, createdAt, updatedAt sql.NullTime
+		if err := rows.Scan(&task.ID, &task.Title, &task.Description, &task.Status, &task.Priority,
+			&dueDate, &createdAt, &updatedAt, &task.CreatedBy, &task.PhoneNumber); err != nil {
+			return nil, err
+		}
+		task.DueDate = sqlTime(dueDate)
+		task.CreatedAt = sqlTime(createdAt)
+		task.UpdatedAt = sqlTime(updatedAt)
+		tasks = append(tasks, task)
+	}
+	return tasks, rows.Err()
+}
+
+// InsertTask implements Store
+func (s *sqlStore) InsertTask(schema string, task ZiggyTask) (*ZiggyTask, error) {
+	dueDate, err := sqlTimeArg(task.DueDate)
+	if err != nil {
+		return nil, err
+	}
+	now := sqlNow()
+
+	id, err := s.insert(schema, "ziggy_tasks",
+		`INSERT INTO %s (title, description, status, priority, due_date, created_by, phone_number, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		task.Title, task.Description, task.Status, task.Priority, dueDate, task.CreatedBy, task.PhoneNumber, now, now)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.query(schema, "ziggy_tasks", "SELECT "+taskColumns+" FROM %s WHERE id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	tasks, err := scanTasks(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(tasks) == 0 {
+		return nil, fmt.Errorf("task %s not found after insert", id)
+	}
+	return &tasks[0], nil
+}
+
+// ListTasks implements Store
+func (s *sqlStore) ListTasks(schema, phoneNumber, status string) ([]ZiggyTask, error) {
+	query := "SELECT " + taskColumns + " FROM %s WHERE phone_number = ?"
+	args := []interface{}{phoneNumber}
+	if status != "" {
+		query += " AND status = ?"
+		args = append(args, status)
+	}
+	query += " ORDER BY created_at DESC"
+
+	rows, err := s.query(schema, "ziggy_tasks", query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return scanTasks(rows)
+}
+
+// UpdateTaskStatus implements Store
+func (s *sqlStore) UpdateTaskStatus(schema, taskID, status string) error {
+	_, err := s.exec(schema, "ziggy_tasks", "UPDATE %s SET status = ?, updated_at = ? WHERE id = ?",
+		status, sqlNow(), taskID)
+	return err
+}
+
+const reminderColumns = `id, phone_number, reminder_text, reminder_time, COALESCE(recurrence_pattern, ''),
+	COALESCE(status, ''), created_at, updated_at, COALESCE(call_id, ''), COALESCE(attempts, 0)`
+
+// scanReminders reads rows selected with reminderColumns
+func scanReminders(rows *sql.Rows) ([]ZiggyReminder, error) {
+	defer rows.Close()
+
+	reminders := []ZiggyReminder{}
+	for rows.Next() {
+		var reminder ZiggyReminder
+		var reminderTime, createdAt, updatedAt sql.NullTime
+		if err := rows.Scan(&reminder.ID, &reminder.PhoneNumber, &reminder.ReminderText, &reminderTime,
+			&reminder.RecurrencePattern, &reminder.Status, &createdAt, &updatedAt, &reminder.CallID, &reminder.Attempts); err != nil {
+			return nil, err
+		}
+		reminder.ReminderTime = sqlTime(reminderTime)
+		reminder.CreatedAt = sqlTime(createdAt)
+		reminder.UpdatedAt = sqlTime(updatedAt)
+		reminders = append(reminders, reminder)
+	}
+	return reminders, rows.Err()
+}
+
+// InsertReminder implements Store
+func (s *sqlStore) InsertReminder(schema string, reminder ZiggyReminder) (*ZiggyReminder, error) {
+	reminderTime, err := sqlTimeArg(reminder.ReminderTime)
+	if err != nil {
+		return nil, err
+	}
+	now := sqlNow()
+
+	id, err := s.insert(schema, "ziggy_reminders",
+		`INSERT INTO %s (phone_number, reminder_text, reminder_time, recurrence_pattern, status, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		reminder.PhoneNumber, reminder.ReminderText, reminderTime, reminder.RecurrencePattern, reminder.Status, now, now)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.query(schema, "ziggy_reminders", "SELECT "+reminderColumns+" FROM %s WHERE id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	reminders, err := scanReminders(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(reminders) == 0 {
+		return nil, fmt.Errorf("reminder %s not found after insert", id)
+	}
+	return &reminders[0], nil
+}
+
+// ListReminders implements Store
+func (s *sqlStore) ListReminders(schema, phoneNumber, status string) ([]ZiggyReminder, error) {
+	query := "SELECT " + reminderColumns + " FROM %s WHERE phone_number = ?"
+	args := []interface{}{phoneNumber}
+	if status != "" {
+		query += " AND status = ?"
+		args = append(args, status)
+	}
+	query += " ORDER BY reminder_time ASC"
+
+	rows, err := s.query(schema, "ziggy_reminders", query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return scanReminders(rows)
+}
+
+// DueReminders implements Store
+func (s *sqlStore) DueReminders(schema string, now time.Time) ([]ZiggyReminder, error) {
+	rows, err := s.query(schema, "ziggy_reminders",
+		"SELECT "+reminderColumns+" FROM %s WHERE status = 'pending' AND reminder_time <= ? ORDER BY reminder_time ASC",
+		now.UTC())
+	if err != nil {
+		return nil, err
+	}
+	return scanReminders(rows)
+}
+
+// UpdateReminderStatus implements Store
+func (s *sqlStore) UpdateReminderStatus(schema, reminderID, status, callID string) error {
+	if callID != "" {
+		_, err := s.exec(schema, "ziggy_reminders", "UPDATE %s SET status = ?, call_id = ?, updated_at = ? WHERE id = ?",
+			status, callID, sqlNow(), reminderID)
+		return err
+	}
+	_, err := s.exec(schema, "ziggy_reminders", "UPDATE %s SET status = ?, updated_at = ? WHERE id = ?",
+		status, sqlNow(), reminderID)
+	return err
+}
+
+// callPermissionColumns are the whatsapp_call_permissions columns callers may
+// set, and whether each holds a timestamp
+var callPermissionColumns = map[string]bool{
+	"phone_number":               false,
+	"permission_granted":         false,
+	"total_inbound_calls":        false,
+	"permission_request_count":   false,
+	"permission_source":          false,
+	"first_inbound_call_at":      true,
+	"last_inbound_call_at":       true,
+	"permission_requested_at":    true,
+	"permission_approved_at":     true,
+	"permission_expires_at":      true,
+	"last_permission_request_at": true,
+	"updated_at":                 true,
+}
+
+// callPermissionArgs turns fields into sorted column names and values
+func callPermissionArgs(fields map[string]interface{}) ([]string, []interface{}, error) {
+	columns := make([]string, 0, len(fields))
+	for column := range fields {
+		if _, ok := callPermissionColumns[column]; !ok {
+			return nil, nil, fmt.Errorf("unknown whatsapp_call_permissions column %q", column)
+		}
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+
+	args := make([]interface{}, 0, len(columns))
+	for _, column := range columns {
+		value := fields[column]
+		if callPermissionColumns[column] {
+			s, _ := value.(string)
+			t, err := sqlTimeArg(s)
+			if err != nil {
+				return nil, nil, err
+			}
+			value = t
+		}
+		args = append(args, value)
+	}
+	return columns, args, nil
+}
+
+// GrantedCallPermission implements Store
+func (s *sqlStore) GrantedCallPermission(schema, phoneNumber string) (*WhatsAppCallPermission, error) {
+	rows, err := s.query(schema, "whatsapp_call_permissions",
+		`SELECT id, phone_number, first_inbound_call_at, last_inbound_call_at, permission_granted,
+			COALESCE(total_inbound_calls, 0), permission_requested_at, permission_approved_at, permission_expires_at,
+			COALESCE(permission_request_count, 0), last_permission_request_at, COALESCE(permission_source, ''),
+			created_at, updated_at
+		FROM %s WHERE phone_number = ? AND permission_granted = ? LIMIT 1`,
+		phoneNumber, true)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return nil, rows.Err() // No permission found
+	}
+
+	var p WhatsAppCallPermission
+	var firstCall, lastCall, requestedAt, approvedAt, expiresAt, lastRequest, createdAt, updatedAt sql.NullTime
+	if err := rows.Scan(&p.ID, &p.PhoneNumber, &firstCall, &lastCall, &p.PermissionGranted,
+		&p.TotalInboundCalls, &requestedAt, &approvedAt, &expiresAt,
+		&p.PermissionRequestCount, &lastRequest, &p.PermissionSource,
+		&createdAt, &updatedAt); err != nil {
+		return nil, err
+	}
+	p.FirstInboundCallAt = sqlTime(firstCall)
+	p.LastInboundCallAt = sqlTime(lastCall)
+	p.PermissionRequestedAt = sqlTime(requestedAt)
+	p.PermissionApprovedAt = sqlTime(approvedAt)
+	p.PermissionExpiresAt = sqlTime(expiresAt)
+	p.LastPermissionRequestAt = sqlTime(lastRequest)
+	p.CreatedAt = sqlTime(createdAt)
+	p.UpdatedAt = sqlTime(updatedAt)
+	return &p, nil
+}
+
+// InsertCallPermission implements Store
+func (s *sqlStore) InsertCallPermission(schema string, fields map[string]interface{}) error {
+	columns, args, err := callPermissionArgs(fields)
+	if err != nil {
+		return err
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", ")
+	_, err = s.exec(schema, "whatsapp_call_permissions",
+		"INSERT INTO %s ("+strings.Join(columns, ", ")+") VALUES ("+placeholders+")", args...)
+	return err
+}
+
+// UpdateCallPermission implements Store
+func (s *sqlStore) UpdateCallPermission(schema, phoneNumber string, fields map[string]interface{}) error {
+	columns, args, err := callPermissionArgs(fields)
+	if err != nil {
+		return err
+	}
+
+	assignments := make([]string, len(columns))
+	for i, column := range columns {
+		assignments[i] = column + " = ?"
+	}
+	_, err = s.exec(schema, "whatsapp_call_permissions",
+		"UPDATE %s SET "+strings.Join(assignments, ", ")+" WHERE phone_number = ?", append(args, phoneNumber)...)
+	return err
+}
+
+// scanNotes reads id, phone_number, note_content rows
+func scanNotes(rows *sql.Rows) ([]ZiggyNote, error) {
+	defer rows.Close()
+
+	notes := []ZiggyNote{}
+	for rows.Next() {
+		var note ZiggyNote
+		if err := rows.Scan(&note.ID, &note.PhoneNumber, &note.NoteContent); err != nil {
+			return nil, err
+		}
+		notes = append(notes, note)
+	}
+	return notes, rows.Err()
+}
+
+// InsertNote implements Store
+func (s *sqlStore) InsertNote(schema string, note ZiggyNote) (*ZiggyNote, error) {
+	id, err := s.insert(schema, "ziggy_notes", "INSERT INTO %s (phone_number, note_content, created_at) VALUES (?, ?, ?)",
+		note.PhoneNumber, note.NoteContent, sqlNow())
+	if err != nil {
+		return nil, err
+	}
+	note.ID = id
+	return &note, nil
+}
+
+// ListNotes implements Store
+func (s *sqlStore) ListNotes(schema, phoneNumber string, limit int) ([]ZiggyNote, error) {
+	query := "SELECT id, phone_number, note_content FROM %s WHERE phone_number = ? ORDER BY created_at DESC"
+	if limit > 0 {
+		query += " LIMIT " + strconv.Itoa(limit)
+	}
+
+	rows, err := s.query(schema, "ziggy_notes", query, phoneNumber)
+	if err != nil {
+		return nil, err
+	}
+	return scanNotes(rows)
+}
+
+// SearchNotes implements Store. Postgres uses the same full-text search as
+// Supabase; SQLite matches notes containing every word of the query.
+func (s *sqlStore) SearchNotes(schema, phoneNumber, query string) ([]ZiggyNote, error) {
+	sqlQuery := "SELECT id, phone_number, note_content FROM %s WHERE phone_number = ?"
+	args := []interface{}{phoneNumber}
+
+	if s.dialect == storeBackendPostgres {
+		sqlQuery += " AND to_tsvector('english', note_content) @@ plainto_tsquery('english', ?)"
+		args = append(args, query)
+	} else {
+		for _, word := range strings.Fields(strings.ToLower(query)) {
+			sqlQuery += " AND lower(note_content) LIKE ?"
+			args = append(args, "%"+word+"%")
+		}
+	}
+	sqlQuery += " ORDER BY created_at DESC"
+
+	rows, err := s.query(schema, "ziggy_notes", sqlQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	return scanNotes(rows)
+}
+
+// UpdateNote implements Store
+func (s *sqlStore) UpdateNote(schema, noteID, content string) error {
+	_, err := s.exec(schema, "ziggy_notes", "UPDATE %s SET note_content = ? WHERE id = ?", content, noteID)
+	return err
+}
+
+// DeleteNote implements Store
+func (s *sqlStore) DeleteNote(schema, noteID string) error {
+	_, err := s.exec(schema, "ziggy_notes", "DELETE FROM %s WHERE id = ?", noteID)
+	return err
+}
+
+// SaveMessage implements Store
+func (s *sqlStore) SaveMessage(schema string, msg TextMessage) error {
+	timestamp := sqlNow()
+	if at, err := time.Parse(time.RFC3339, msg.Timestamp); err == nil {
+		timestamp = at.UTC()
+	}
+
+	var messageID, contactName interface{}
+	if msg.MessageID != "" {
+		messageID = msg.MessageID
+	}
+	if msg.ContactName != "" {
+		contactName = msg.ContactName
+	}
+
+	// A duplicate message ID means the message is already saved
+	_, err := s.exec(schema, "ziggy_messages",
+		`INSERT INTO %s (phone_number, message_content, direction, message_type, "timestamp", created_at, message_id, contact_name)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?) ON CONFLICT DO NOTHING`,
+		msg.PhoneNumber, msg.MessageContent, msg.Direction, msg.MessageType, timestamp, sqlNow(), messageID, contactName)
+	return err
+}
+
+// CountMessages implements Store
+func (s *sqlStore) CountMessages(schema, phoneNumber string) (int, error) {
+	db, query, err := s.prepare(schema, "ziggy_messages", "SELECT COUNT(*) FROM %s WHERE phone_number = ?")
+	if err != nil {
+		return 0, err
+	}
+	var count int
+	err = db.QueryRow(query, phoneNumber).Scan(&count)
+	return count, err
+}
+
+// ListMessages implements Store
+func (s *sqlStore) ListMessages(schema, phoneNumber string, limit int, ascending bool) ([]TextMessage, error) {
+	order := "ASC"
+	if !ascending {
+		order = "DESC"
+	}
+
+	rows, err := s.query(schema, "ziggy_messages",
+		`SELECT message_content, direction, "timestamp" FROM %s WHERE phone_number = ? ORDER BY "timestamp" `+order+
+			" LIMIT "+strconv.Itoa(limit),
+		phoneNumber)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	messages := []TextMessage{}
+	for rows.Next() {
+		var msg TextMessage
+		var timestamp sql.NullTime
+		if err := rows.Scan(&msg.MessageContent, &msg.Direction, &timestamp); err != nil {
+			return nil, err
+		}
+		msg.Timestamp = sqlTime(timestamp)
+		messages = append(messages, msg)
+	}
+	return messages, rows.Err()
+}
+
+// SaveCallTranscriptTurn implements Store
+func (s *sqlStore) SaveCallTranscriptTurn(schema string, turn CallTranscriptTurn) error {
+	startedAt, err := sqlTimeArg(turn.StartedAt)
+	if err != nil {
+		return err
+	}
+	if startedAt == nil {
+		startedAt = sqlNow()
+	}
+	endedAt, err := sqlTimeArg(turn.EndedAt)
+	if err != nil {
+		return err
+	}
+
+	var itemID interface{}
+	if turn.ItemID != "" {
+		itemID = turn.ItemID
+	}
+
+	// Same item already saved for this call
+	_, err = s.exec(schema, "ziggy_call_transcripts",
+		`INSERT INTO %s (call_id, phone_number, speaker, content, item_id, started_at, ended_at, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?) ON CONFLICT DO NOTHING`,
+		turn.CallID, turn.PhoneNumber, turn.Speaker, turn.Content, itemID, startedAt, endedAt, sqlNow())
+	return err
+}
+
+// ListCallTranscriptTurns implements Store
+func (s *sqlStore) ListCallTranscriptTurns(schema, phoneNumber string, limit int) ([]CallTranscriptTurn, error) {
+	query := `SELECT id, call_id, phone_number, speaker, content, COALESCE(item_id, ''), started_at, ended_at
+		FROM %s WHERE phone_number = ? ORDER BY started_at DESC`
+	if limit > 0 {
+		query += " LIMIT " + strconv.Itoa(limit)
+	}
+
+	rows, err := s.query(schema, "ziggy_call_transcripts", query, phoneNumber)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	turns := []CallTranscriptTurn{}
+	for rows.Next() {
+		var turn CallTranscriptTurn
+		var startedAt, endedAt sql.NullTime
+		if err := rows.Scan(&turn.ID, &turn.CallID, &turn.PhoneNumber, &turn.Speaker, &turn.Content,
+			&turn.ItemID, &startedAt, &endedAt); err != nil {
+			return nil, err
+		}
+		turn.StartedAt = sqlTime(startedAt)
+		turn.EndedAt = sqlTime(endedAt)
+		turns = append(turns, turn)
+	}
+	return turns, rows.Err()
+}
+
+// sqliteSchema creates the tables for an embedded SQLite store. They mirror
+// the Supabase tables; IDs are random hex strings instead of UUIDs.
+var sqliteSchema = []string{
+	`CREATE TABLE IF NOT EXISTS ziggy_tasks (
+		id TEXT PRIMARY KEY DEFAULT (lower(hex(randomblob(16)))),
+		title TEXT NOT NULL,
+		description TEXT,
+		status TEXT DEFAULT 'pending',
+		priority TEXT DEFAULT 'medium',
+		due_date TIMESTAMP,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		created_by TEXT,
+		phone_number TEXT
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_ziggy_tasks_phone_number ON ziggy_tasks(phone_number, created_at)`,
+
+	`CREATE TABLE IF NOT EXISTS ziggy_reminders (
+		id TEXT PRIMARY KEY DEFAULT (lower(hex(randomblob(16)))),
+		phone_number TEXT NOT NULL,
+		reminder_text TEXT NOT NULL,
+		reminder_time TIMESTAMP NOT NULL,
+		recurrence_pattern TEXT DEFAULT 'once',
+		status TEXT DEFAULT 'pending',
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		call_id TEXT,
+		attempts INTEGER DEFAULT 0
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_ziggy_reminders_due ON ziggy_reminders(status, reminder_time)`,
+
+	`CREATE TABLE IF NOT EXISTS whatsapp_call_permissions (
+		id TEXT PRIMARY KEY DEFAULT (lower(hex(randomblob(16)))),
+		phone_number TEXT NOT NULL UNIQUE,
+		first_inbound_call_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		last_inbound_call_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		permission_granted BOOLEAN DEFAULT 1,
+		total_inbound_calls INTEGER DEFAULT 0,
+		permission_requested_at TIMESTAMP,
+		permission_approved_at TIMESTAMP,
+		permission_expires_at TIMESTAMP,
+		permission_request_count INTEGER DEFAULT 0,
+		last_permission_request_at TIMESTAMP,
+		permission_source TEXT,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`,
+
+	`CREATE TABLE IF NOT EXISTS ziggy_notes (
+		id TEXT PRIMARY KEY DEFAULT (lower(hex(randomblob(16)))),
+		phone_number TEXT NOT NULL,
+		note_content TEXT NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_ziggy_notes_phone_number ON ziggy_notes(phone_number)`,
+
+	`CREATE TABLE IF NOT EXISTS ziggy_messages (
+		id TEXT PRIMARY KEY DEFAULT (lower(hex(randomblob(16)))),
+		phone_number TEXT NOT NULL,
+		message_content TEXT NOT NULL,
+		direction TEXT NOT NULL CHECK (direction IN ('inbound', 'outbound')),
+		message_type TEXT DEFAULT 'text',
+		"timestamp" TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		message_id TEXT,
+		contact_name TEXT,
+		raw_webhook_data TEXT
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_ziggy_messages_phone_timestamp ON ziggy_messages(phone_number, "timestamp" DESC)`,
+	`CREATE UNIQUE INDEX IF NOT EXISTS idx_ziggy_messages_message_id ON ziggy_messages(message_id) WHERE message_id IS NOT NULL`,
+
+	`CREATE TABLE IF NOT EXISTS ziggy_call_transcripts (
+		id TEXT PRIMARY KEY DEFAULT (lower(hex(randomblob(16)))),
+		call_id TEXT NOT NULL,
+		phone_number TEXT NOT NULL,
+		speaker TEXT NOT NULL CHECK (speaker IN ('user', 'assistant')),
+		content TEXT NOT NULL,
+		item_id TEXT,
+		started_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		ended_at TIMESTAMP,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_ziggy_call_transcripts_phone_started ON ziggy_call_transcripts(phone_number, started_at DESC)`,
+	`CREATE UNIQUE INDEX IF NOT EXISTS idx_ziggy_call_transcripts_item_id ON ziggy_call_transcripts(call_id, item_id) WHERE item_id IS NOT NULL`,
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestSQLiteStoreReminderRoundTrip opens an embedded store and checks a
+// reminder survives insert, listing, the due query and a status update. It
+// fails if go-sqlite3 was built without cgo, as it is with CGO_ENABLED=0.
+func TestSQLiteStoreReminderRoundTrip(t *testing.T) {
+	store, err := NewSQLiteStore(filepath.Join(t.TempDir(), "ziggy.db"))
+	if err != nil {
+		t.Fatalf("NewSQLiteStore: %v", err)
+	}
+
+	due := time.Now().UTC().Add(-time.Minute).Truncate(time.Second)
+	inserted, err := store.InsertReminder("", ZiggyReminder{
+		PhoneNumber:       "15559876543",
+		ReminderText:      "Call the dentist",
+		ReminderTime:      due.Format(time.RFC3339),
+		RecurrencePattern: "weekly",
+		Status:            "pending",
+	})
+	if err != nil {
+		t.Fatalf("InsertReminder: %v", err)
+	}
+	if inserted.ID == "" {
+		t.Fatal("InsertReminder returned a reminder without an ID")
+	}
+
+	reminders, err := store.ListReminders("", "15559876543", "pending")
+	if err != nil {
+		t.Fatalf("ListReminders: %v", err)
+	}
+	if len(reminders) != 1 {
+		t.Fatalf("ListReminders returned %d reminders, want 1", len(reminders))
+	}
+	got := reminders[0]
+	if got.ID != inserted.ID || got.ReminderText != "Call the dentist" || got.RecurrencePattern != "weekly" || got.Status != "pending" {
+		t.Errorf("ListReminders returned %+v, want the inserted reminder %+v", got, *inserted)
+	}
+	gotTime, err := time.Parse(time.RFC3339, got.ReminderTime)
+	if err != nil || !gotTime.Equal(due) {
+		t.Errorf("ReminderTime = %q, want %s", got.ReminderTime, due.Format(time.RFC3339))
+	}
+
+	dueReminders, err := store.DueReminders("", time.Now())
+	if err != nil {
+		t.Fatalf("DueReminders: %v", err)
+	}
+	if len(dueReminders) != 1 || dueReminders[0].ID != inserted.ID {
+		t.Fatalf("DueReminders returned %+v, want the inserted reminder", dueReminders)
+	}
+
+	if err := store.UpdateReminderStatus("", inserted.ID, "called", "wacid.TEST"); err != nil {
+		t.Fatalf("UpdateReminderStatus: %v", err)
+	}
+	reminders, err = store.ListReminders("", "15559876543", "called")
+	if err != nil {
+		t.Fatalf("ListReminders: %v", err)
+	}
+	if len(reminders) != 1 || reminders[0].CallID != "wacid.TEST" {
+		t.Fatalf("after UpdateReminderStatus got %+v, want one called reminder with call ID wacid.TEST", reminders)
+	}
+	if dueReminders, _ := store.DueReminders("", time.Now()); len(dueReminders) != 0 {
+		t.Errorf("DueReminders still returned %d reminders after the status update", len(dueReminders))
+	}
+}
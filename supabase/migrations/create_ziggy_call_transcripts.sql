@@ -0,0 +1,55 @@
+-- Create ziggy_call_transcripts table for storing voice call transcripts, one row per turn
+CREATE TABLE IF NOT EXISTS public.ziggy_call_transcripts (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    call_id TEXT NOT NULL,
+    phone_number TEXT NOT NULL,
+    speaker TEXT NOT NULL CHECK (speaker IN ('user', 'assistant')),
+    content TEXT NOT NULL,
+    item_id TEXT,
+    started_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    ended_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+-- Create indexes for efficient queries
+CREATE INDEX IF NOT EXISTS idx_ziggy_call_transcripts_call_id ON public.ziggy_call_transcripts(call_id, started_at);
+CREATE INDEX IF NOT EXISTS idx_ziggy_call_transcripts_phone_started ON public.ziggy_call_transcripts(phone_number, started_at DESC);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_ziggy_call_transcripts_item_id ON public.ziggy_call_transcripts(call_id, item_id) WHERE item_id IS NOT NULL;
+
+-- Enable RLS
+ALTER TABLE public.ziggy_call_transcripts ENABLE ROW LEVEL SECURITY;
+
+-- Allow anon users full access
+CREATE POLICY "Allow anon users to select ziggy_call_transcripts"
+    ON public.ziggy_call_transcripts
+    FOR SELECT
+    TO anon
+    USING (true);
+
+CREATE POLICY "Allow anon users to insert ziggy_call_transcripts"
+    ON public.ziggy_call_transcripts
+    FOR INSERT
+    TO anon
+    WITH CHECK (true);
+
+CREATE POLICY "Allow anon users to update ziggy_call_transcripts"
+    ON public.ziggy_call_transcripts
+    FOR UPDATE
+    TO anon
+    USING (true)
+    WITH CHECK (true);
+
+CREATE POLICY "Allow anon users to delete ziggy_call_transcripts"
+    ON public.ziggy_call_transcripts
+    FOR DELETE
+    TO anon
+    USING (true);
+
+COMMENT ON TABLE public.ziggy_call_transcripts IS 'Stores speaker-labeled turns of Ziggy voice calls';
+COMMENT ON COLUMN public.ziggy_call_transcripts.call_id IS 'WhatsApp call ID the turn belongs to';
+COMMENT ON COLUMN public.ziggy_call_transcripts.phone_number IS 'User phone number in international format';
+COMMENT ON COLUMN public.ziggy_call_transcripts.speaker IS 'Who spoke: user (caller) or assistant (Ziggy)';
+COMMENT ON COLUMN public.ziggy_call_transcripts.content IS 'Transcribed text of the turn';
+COMMENT ON COLUMN public.ziggy_call_transcripts.item_id IS 'Realtime API conversation item ID for deduplication';
+COMMENT ON COLUMN public.ziggy_call_transcripts.started_at IS 'When the speaker started talking';
+COMMENT ON COLUMN public.ziggy_call_transcripts.ended_at IS 'When the turn was complete';
@@ -0,0 +1,48 @@
+-- Create ziggy_processed_events table for webhook de-duplication
+-- Meta retries webhooks it considers undelivered; the bridge records every
+-- message ID and call event here so a retry never triggers a second AI reply
+-- or a second call accept, even across restarts or multiple instances.
+-- Used when the bridge runs with DEDUPE_STORE=supabase.
+
+CREATE TABLE IF NOT EXISTS public.ziggy_processed_events (
+    event_key TEXT PRIMARY KEY, -- "msg:<wamid>" or "call:<call_id>:<event>"
+    processed_at TIMESTAMPTZ DEFAULT NOW() NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+-- Index for purging expired keys
+CREATE INDEX IF NOT EXISTS idx_ziggy_processed_events_expires_at ON public.ziggy_processed_events(expires_at);
+
+-- Enable RLS
+ALTER TABLE public.ziggy_processed_events ENABLE ROW LEVEL SECURITY;
+
+-- Allow anon users full access
+CREATE POLICY "Allow anon users to select ziggy_processed_events"
+    ON public.ziggy_processed_events
+    FOR SELECT
+    TO anon
+    USING (true);
+
+CREATE POLICY "Allow anon users to insert ziggy_processed_events"
+    ON public.ziggy_processed_events
+    FOR INSERT
+    TO anon
+    WITH CHECK (true);
+
+CREATE POLICY "Allow anon users to delete ziggy_processed_events"
+    ON public.ziggy_processed_events
+    FOR DELETE
+    TO anon
+    USING (true);
+
+-- Purge expired keys every hour (requires pg_cron, see supabase_cron_setup.sql)
+SELECT cron.schedule(
+    'purge_ziggy_processed_events',
+    '0 * * * *',
+    $$DELETE FROM public.ziggy_processed_events WHERE expires_at < NOW()$$
+);
+
+-- Add table and column comments for documentation
+COMMENT ON TABLE public.ziggy_processed_events IS 'Webhook message IDs and call events already handled by the bridge';
+COMMENT ON COLUMN public.ziggy_processed_events.event_key IS 'Dedupe key: msg:<message id> or call:<call id>:<event>';
+COMMENT ON COLUMN public.ziggy_processed_events.expires_at IS 'After this time the key may be processed again';
@@ -1,20 +1,36 @@
 package main
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
-	"io"
-	"log"
-	"net/http"
-	"os"
+	"log/slog"
 	"strings"
 	"time"
 
 	"github.com/nyaruka/phonenumbers"
 )
 
-// Supabase client helper functions for task management
+// SupabaseStore is the Store backed by Supabase's REST API (PostgREST)
+type SupabaseStore struct {
+	client *SupabaseClient
+}
+
+// NewSupabaseStore creates a store for the project in SUPABASE_URL, using
+// SUPABASE_ANON_KEY. The store is unconfigured when either is missing.
+func NewSupabaseStore() *SupabaseStore {
+	return &SupabaseStore{client: NewSupabaseClient(10 * time.Second)}
+}
+
+// Name implements Store
+func (s *SupabaseStore) Name() string {
+	return storeBackendSupabase
+}
+
+// Configured implements Store
+func (s *SupabaseStore) Configured() bool {
+	return s.client.Configured()
+}
 
 type ZiggyTask struct {
 	ID          string    `json:"id,omitempty"`
@@ -29,155 +45,44 @@ type ZiggyTask struct {
 	PhoneNumber string    `json:"phone_number,omitempty"`
 }
 
-// AddTask creates a new task in Supabase
-func AddTask(title, description, priority, phoneNumber string) (*ZiggyTask, error) {
-	supabaseURL := os.Getenv("SUPABASE_URL")
-	supabaseKey := os.Getenv("SUPABASE_ANON_KEY")
-
-	if supabaseURL == "" || supabaseKey == "" {
-		return nil, fmt.Errorf("Supabase credentials not configured")
-	}
-
-	task := ZiggyTask{
-		Title:       title,
-		Description: description,
-		Priority:    priority,
-		PhoneNumber: phoneNumber,
-		Status:      "pending",
-		CreatedBy:   "whatsapp_voice_agent",
-	}
-
-	jsonData, err := json.Marshal(task)
+// InsertTask implements Store
+func (s *SupabaseStore) InsertTask(schema string, task ZiggyTask) (*ZiggyTask, error) {
+	resp, err := s.client.Do(schema, "POST", From("ziggy_tasks"), task, "return=representation")
 	if err != nil {
 		return nil, err
 	}
 
-	url := fmt.Sprintf("%s/rest/v1/ziggy_tasks", supabaseURL)
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return nil, err
-	}
-
-	req.Header.Set("apikey", supabaseKey)
-	req.Header.Set("Authorization", "Bearer "+supabaseKey)
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Prefer", "return=representation")
-
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	body, _ := io.ReadAll(resp.Body)
-
-	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("Supabase error: %s - %s", resp.Status, string(body))
-	}
-
 	var tasks []ZiggyTask
-	if err := json.Unmarshal(body, &tasks); err != nil {
+	if err := json.Unmarshal(resp.Body, &tasks); err != nil {
 		return nil, err
 	}
-
 	if len(tasks) == 0 {
 		return nil, fmt.Errorf("no task returned from Supabase")
 	}
-
-	log.Printf("✅ Task created in Supabase: %s (ID: %s)", tasks[0].Title, tasks[0].ID)
 	return &tasks[0], nil
 }
 
-// ListTasks retrieves tasks for a phone number
-func ListTasks(phoneNumber string, status string) ([]ZiggyTask, error) {
-	supabaseURL := os.Getenv("SUPABASE_URL")
-	supabaseKey := os.Getenv("SUPABASE_ANON_KEY")
-
-	if supabaseURL == "" || supabaseKey == "" {
-		return nil, fmt.Errorf("Supabase credentials not configured")
-	}
-
-	url := fmt.Sprintf("%s/rest/v1/ziggy_tasks?phone_number=eq.%s&order=created_at.desc",
-		supabaseURL, phoneNumber)
-
+// ListTasks implements Store
+func (s *SupabaseStore) ListTasks(schema, phoneNumber, status string) ([]ZiggyTask, error) {
+	q := From("ziggy_tasks").Eq("phone_number", phoneNumber).Order("created_at", false)
 	if status != "" {
-		url += fmt.Sprintf("&status=eq.%s", status)
-	}
-
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, err
-	}
-
-	req.Header.Set("apikey", supabaseKey)
-	req.Header.Set("Authorization", "Bearer "+supabaseKey)
-
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	body, _ := io.ReadAll(resp.Body)
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("Supabase error: %s - %s", resp.Status, string(body))
+		q.Eq("status", status)
 	}
 
 	var tasks []ZiggyTask
-	if err := json.Unmarshal(body, &tasks); err != nil {
+	if err := s.client.Get(schema, q, &tasks); err != nil {
 		return nil, err
 	}
-
-	log.Printf("📋 Retrieved %d tasks from Supabase for %s", len(tasks), phoneNumber)
 	return tasks, nil
 }
 
-// UpdateTaskStatus updates the status of a task
-func UpdateTaskStatus(taskID, status string) error {
-	supabaseURL := os.Getenv("SUPABASE_URL")
-	supabaseKey := os.Getenv("SUPABASE_ANON_KEY")
-
-	if supabaseURL == "" || supabaseKey == "" {
-		return fmt.Errorf("Supabase credentials not configured")
-	}
-
+// UpdateTaskStatus implements Store
+func (s *SupabaseStore) UpdateTaskStatus(schema, taskID, status string) error {
 	update := map[string]string{
 		"status": status,
 	}
-
-	jsonData, err := json.Marshal(update)
-	if err != nil {
-		return err
-	}
-
-	url := fmt.Sprintf("%s/rest/v1/ziggy_tasks?id=eq.%s", supabaseURL, taskID)
-	req, err := http.NewRequest("PATCH", url, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return err
-	}
-
-	req.Header.Set("apikey", supabaseKey)
-	req.Header.Set("Authorization", "Bearer "+supabaseKey)
-	req.Header.Set("Content-Type", "application/json")
-
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-
-	body, _ := io.ReadAll(resp.Body)
-
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
-		return fmt.Errorf("Supabase error: %s - %s", resp.Status, string(body))
-	}
-
-	log.Printf("✅ Task %s updated to status: %s", taskID, status)
-	return nil
+	_, err := s.client.Do(schema, "PATCH", From("ziggy_tasks").Eq("id", taskID), update, "")
+	return err
 }
 
 // ZiggyReminder represents a reminder
@@ -205,7 +110,7 @@ func GetTimezoneFromPhoneNumber(phoneNumber string) (string, error) {
 	// Parse the phone number
 	num, err := phonenumbers.Parse(phoneNumber, "")
 	if err != nil {
-		log.Printf("⚠️ Failed to parse phone number %s, defaulting to Asia/Kolkata: %v", phoneNumber, err)
+		slog.Warn("⚠️ Failed to parse phone number, defaulting to Asia/Kolkata", "phone", phoneNumber, "error", err)
 		return "Asia/Kolkata", nil // Default to IST for backwards compatibility
 	}
 
@@ -291,11 +196,11 @@ func GetTimezoneFromPhoneNumber(phoneNumber string) (string, error) {
 
 	timezone, ok := countryToTimezone[regionCode]
 	if !ok {
-		log.Printf("⚠️ Unknown country code %s for phone number %s, defaulting to Asia/Kolkata", regionCode, phoneNumber)
+		slog.Warn("⚠️ Unknown country code, defaulting to Asia/Kolkata", "region", regionCode, "phone", phoneNumber)
 		return "Asia/Kolkata", nil // Default to IST
 	}
 
-	log.Printf("🌍 Detected timezone %s from phone number %s (country: %s)", timezone, phoneNumber, regionCode)
+	slog.Debug("🌍 Detected timezone from phone number", "timezone", timezone, "phone", phoneNumber, "region", regionCode)
 	return timezone, nil
 }
 
@@ -336,7 +241,7 @@ func ConvertLocalToUTC(localDateTime string, phoneNumber string) (string, error)
 
 	// Convert to UTC and format as RFC3339
 	utcTime := parsedTime.UTC()
-	log.Printf("⏰ Converted %s (%s) to UTC %s", localDateTime, timezoneName, utcTime.Format(time.RFC3339))
+	slog.Debug("⏰ Converted local time to UTC", "local", localDateTime, "timezone", timezoneName, "utc", utcTime.Format(time.RFC3339))
 	return utcTime.Format(time.RFC3339), nil
 }
 
@@ -346,220 +251,58 @@ func ConvertISTToUTC(istDateTime string) (string, error) {
 	return ConvertLocalToUTC(istDateTime, "+91") // Assume India number for backwards compatibility
 }
 
-// AddReminder creates a new reminder in Supabase
-// reminderTime should be in local format like "2025-11-09 14:30" and will be converted to UTC based on phone number timezone
-// recurrencePattern can be: empty/"once" (one-time), "daily", "weekly", "monthly", "yearly"
-func AddReminder(reminderText, reminderTime, phoneNumber, recurrencePattern string) (*ZiggyReminder, error) {
-	supabaseURL := os.Getenv("SUPABASE_URL")
-	supabaseKey := os.Getenv("SUPABASE_ANON_KEY")
-
-	if supabaseURL == "" || supabaseKey == "" {
-		return nil, fmt.Errorf("Supabase credentials not configured")
-	}
-
-	// Convert local time to UTC for database storage (timezone auto-detected from phone number)
-	utcTime, err := ConvertLocalToUTC(reminderTime, phoneNumber)
-	if err != nil {
-		return nil, fmt.Errorf("invalid reminder time: %v", err)
-	}
-
-	// Normalize recurrence pattern
-	if recurrencePattern == "" {
-		recurrencePattern = "once"
-	}
-
-	reminder := ZiggyReminder{
-		PhoneNumber:       phoneNumber,
-		ReminderText:      reminderText,
-		ReminderTime:      utcTime, // Store as UTC
-		RecurrencePattern: recurrencePattern,
-		Status:            "pending",
-	}
-
-	jsonData, err := json.Marshal(reminder)
+// InsertReminder implements Store
+func (s *SupabaseStore) InsertReminder(schema string, reminder ZiggyReminder) (*ZiggyReminder, error) {
+	resp, err := s.client.Do(schema, "POST", From("ziggy_reminders"), reminder, "return=representation")
 	if err != nil {
 		return nil, err
 	}
 
-	url := fmt.Sprintf("%s/rest/v1/ziggy_reminders", supabaseURL)
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return nil, err
-	}
-
-	req.Header.Set("apikey", supabaseKey)
-	req.Header.Set("Authorization", "Bearer "+supabaseKey)
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Prefer", "return=representation")
-
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	body, _ := io.ReadAll(resp.Body)
-
-	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("Supabase error: %s - %s", resp.Status, string(body))
-	}
-
 	var reminders []ZiggyReminder
-	if err := json.Unmarshal(body, &reminders); err != nil {
+	if err := json.Unmarshal(resp.Body, &reminders); err != nil {
 		return nil, err
 	}
-
 	if len(reminders) == 0 {
 		return nil, fmt.Errorf("no reminder returned from Supabase")
 	}
-
-	log.Printf("✅ Reminder created in Supabase: %s at %s (ID: %s)", reminders[0].ReminderText, reminders[0].ReminderTime, reminders[0].ID)
 	return &reminders[0], nil
 }
 
-// GetDueReminders retrieves all pending reminders that are due
-func GetDueReminders() ([]ZiggyReminder, error) {
-	supabaseURL := os.Getenv("SUPABASE_URL")
-	supabaseKey := os.Getenv("SUPABASE_ANON_KEY")
-
-	if supabaseURL == "" || supabaseKey == "" {
-		return nil, fmt.Errorf("Supabase credentials not configured")
-	}
-
-	// Query for pending reminders where reminder_time <= now
-	url := fmt.Sprintf("%s/rest/v1/ziggy_reminders?status=eq.pending&reminder_time=lte.%s&order=reminder_time.asc",
-		supabaseURL, time.Now().UTC().Format(time.RFC3339))
-
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, err
+// ListReminders implements Store
+func (s *SupabaseStore) ListReminders(schema, phoneNumber, status string) ([]ZiggyReminder, error) {
+	q := From("ziggy_reminders").Eq("phone_number", phoneNumber).Order("reminder_time", true)
+	if status != "" {
+		q.Eq("status", status)
 	}
 
-	req.Header.Set("apikey", supabaseKey)
-	req.Header.Set("Authorization", "Bearer "+supabaseKey)
-
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
+	var reminders []ZiggyReminder
+	if err := s.client.Get(schema, q, &reminders); err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
-
-	body, _ := io.ReadAll(resp.Body)
+	return reminders, nil
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("Supabase error: %s - %s", resp.Status, string(body))
-	}
+// DueReminders implements Store
+func (s *SupabaseStore) DueReminders(schema string, now time.Time) ([]ZiggyReminder, error) {
+	q := From("ziggy_reminders").Eq("status", "pending").Lte("reminder_time", now).Order("reminder_time", true)
 
 	var reminders []ZiggyReminder
-	if err := json.Unmarshal(body, &reminders); err != nil {
+	if err := s.client.Get(schema, q, &reminders); err != nil {
 		return nil, err
 	}
-
-	log.Printf("📋 Retrieved %d due reminders from Supabase", len(reminders))
 	return reminders, nil
 }
 
-// UpdateReminderStatus updates the status of a reminder
-func UpdateReminderStatus(reminderID, status string, callID string) error {
-	supabaseURL := os.Getenv("SUPABASE_URL")
-	supabaseKey := os.Getenv("SUPABASE_ANON_KEY")
-
-	if supabaseURL == "" || supabaseKey == "" {
-		return fmt.Errorf("Supabase credentials not configured")
-	}
-
+// UpdateReminderStatus implements Store
+func (s *SupabaseStore) UpdateReminderStatus(schema, reminderID, status, callID string) error {
 	update := map[string]interface{}{
 		"status": status,
 	}
-
 	if callID != "" {
 		update["call_id"] = callID
 	}
-
-	jsonData, err := json.Marshal(update)
-	if err != nil {
-		return err
-	}
-
-	url := fmt.Sprintf("%s/rest/v1/ziggy_reminders?id=eq.%s", supabaseURL, reminderID)
-	req, err := http.NewRequest("PATCH", url, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return err
-	}
-
-	req.Header.Set("apikey", supabaseKey)
-	req.Header.Set("Authorization", "Bearer "+supabaseKey)
-	req.Header.Set("Content-Type", "application/json")
-
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-
-	body, _ := io.ReadAll(resp.Body)
-
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
-		return fmt.Errorf("Supabase error: %s - %s", resp.Status, string(body))
-	}
-
-	log.Printf("✅ Reminder %s updated to status: %s", reminderID, status)
-	return nil
-}
-
-// ListReminders retrieves reminders for a phone number
-func ListReminders(phoneNumber string, status string) ([]ZiggyReminder, error) {
-	supabaseURL := os.Getenv("SUPABASE_URL")
-	supabaseKey := os.Getenv("SUPABASE_ANON_KEY")
-
-	if supabaseURL == "" || supabaseKey == "" {
-		return nil, fmt.Errorf("Supabase credentials not configured")
-	}
-
-	url := fmt.Sprintf("%s/rest/v1/ziggy_reminders?phone_number=eq.%s&order=reminder_time.asc",
-		supabaseURL, phoneNumber)
-
-	if status != "" {
-		url += fmt.Sprintf("&status=eq.%s", status)
-	}
-
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, err
-	}
-
-	req.Header.Set("apikey", supabaseKey)
-	req.Header.Set("Authorization", "Bearer "+supabaseKey)
-
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	body, _ := io.ReadAll(resp.Body)
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("Supabase error: %s - %s", resp.Status, string(body))
-	}
-
-	var reminders []ZiggyReminder
-	if err := json.Unmarshal(body, &reminders); err != nil {
-		return nil, err
-	}
-
-	log.Printf("📋 Retrieved %d reminders from Supabase for %s", len(reminders), phoneNumber)
-	return reminders, nil
-}
-
-// CancelReminder cancels a reminder by updating its status to 'cancelled'
-// The database trigger will automatically unschedule the cron job
-func CancelReminder(reminderID string) error {
-	return UpdateReminderStatus(reminderID, "cancelled", "")
+	_, err := s.client.Do(schema, "PATCH", From("ziggy_reminders").Eq("id", reminderID), update, "")
+	return err
 }
 
 // WhatsAppCallPermission represents a call permission record
@@ -580,388 +323,37 @@ type WhatsAppCallPermission struct {
 	UpdatedAt                string `json:"updated_at,omitempty"`
 }
 
-// GrantCallPermission records that a user has granted call permission by calling us first
-// This is called automatically when we receive an inbound call
-// If the user already exists, it updates the last_inbound_call_at and increments the counter
-func GrantCallPermission(phoneNumber string) error {
-	supabaseURL := os.Getenv("SUPABASE_URL")
-	supabaseKey := os.Getenv("SUPABASE_ANON_KEY")
-
-	if supabaseURL == "" || supabaseKey == "" {
-		return fmt.Errorf("Supabase credentials not configured")
-	}
-
-	// First, check if permission already exists
-	existing, err := CheckCallPermission(phoneNumber)
-	if err == nil && existing != nil {
-		// Update existing record: increment counter and update last call time
-		update := map[string]interface{}{
-			"last_inbound_call_at": time.Now().UTC().Format(time.RFC3339),
-			"total_inbound_calls":  existing.TotalInboundCalls + 1,
-			"permission_granted":   true, // Re-grant if it was revoked
-			"updated_at":           time.Now().UTC().Format(time.RFC3339),
-		}
-
-		jsonData, err := json.Marshal(update)
-		if err != nil {
-			return err
-		}
-
-		url := fmt.Sprintf("%s/rest/v1/whatsapp_call_permissions?phone_number=eq.%s", supabaseURL, phoneNumber)
-		req, err := http.NewRequest("PATCH", url, bytes.NewBuffer(jsonData))
-		if err != nil {
-			return err
-		}
-
-		req.Header.Set("apikey", supabaseKey)
-		req.Header.Set("Authorization", "Bearer "+supabaseKey)
-		req.Header.Set("Content-Type", "application/json")
-
-		client := &http.Client{Timeout: 10 * time.Second}
-		resp, err := client.Do(req)
-		if err != nil {
-			return err
-		}
-		defer resp.Body.Close()
-
-		body, _ := io.ReadAll(resp.Body)
-		if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
-			return fmt.Errorf("Supabase error updating permission: %s - %s", resp.Status, string(body))
-		}
-
-		log.Printf("✅ Updated call permission for %s (total calls: %d)", phoneNumber, existing.TotalInboundCalls+1)
-		return nil
-	}
-
-	// Create new permission record
-	permission := WhatsAppCallPermission{
-		PhoneNumber:       phoneNumber,
-		PermissionGranted: true,
-		TotalInboundCalls: 1,
-	}
-
-	jsonData, err := json.Marshal(permission)
-	if err != nil {
-		return err
-	}
-
-	url := fmt.Sprintf("%s/rest/v1/whatsapp_call_permissions", supabaseURL)
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return err
-	}
-
-	req.Header.Set("apikey", supabaseKey)
-	req.Header.Set("Authorization", "Bearer "+supabaseKey)
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Prefer", "return=representation")
-
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-
-	body, _ := io.ReadAll(resp.Body)
-
-	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("Supabase error creating permission: %s - %s", resp.Status, string(body))
-	}
-
-	log.Printf("✅ Granted call permission for %s (first inbound call)", phoneNumber)
-	return nil
-}
-
-// CheckCallPermission checks if a phone number has permission to receive calls
-// Returns the permission record if it exists and is granted, nil otherwise
-// Also validates 72-hour expiry window for express permissions
-func CheckCallPermission(phoneNumber string) (*WhatsAppCallPermission, error) {
-	supabaseURL := os.Getenv("SUPABASE_URL")
-	supabaseKey := os.Getenv("SUPABASE_ANON_KEY")
-
-	if supabaseURL == "" || supabaseKey == "" {
-		return nil, fmt.Errorf("Supabase credentials not configured")
-	}
-
-	url := fmt.Sprintf("%s/rest/v1/whatsapp_call_permissions?phone_number=eq.%s&permission_granted=eq.true",
-		supabaseURL, phoneNumber)
-
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, err
-	}
-
-	req.Header.Set("apikey", supabaseKey)
-	req.Header.Set("Authorization", "Bearer "+supabaseKey)
-
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	body, _ := io.ReadAll(resp.Body)
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("Supabase error: %s - %s", resp.Status, string(body))
-	}
+// GrantedCallPermission implements Store
+func (s *SupabaseStore) GrantedCallPermission(schema, phoneNumber string) (*WhatsAppCallPermission, error) {
+	q := From("whatsapp_call_permissions").Eq("phone_number", phoneNumber).EqBool("permission_granted", true)
 
 	var permissions []WhatsAppCallPermission
-	if err := json.Unmarshal(body, &permissions); err != nil {
+	if err := s.client.Get(schema, q, &permissions); err != nil {
 		return nil, err
 	}
-
 	if len(permissions) == 0 {
 		return nil, nil // No permission found
 	}
-
-	permission := &permissions[0]
-
-	// Check if permission has expired (72-hour window for express permissions)
-	if permission.PermissionExpiresAt != "" {
-		expiresAt, err := time.Parse(time.RFC3339, permission.PermissionExpiresAt)
-		if err == nil && time.Now().UTC().After(expiresAt) {
-			log.Printf("⚠️ Call permission for %s has expired (expired at %s)", phoneNumber, permission.PermissionExpiresAt)
-			// Auto-revoke expired permission
-			RevokeCallPermission(phoneNumber)
-			return nil, nil // Permission expired
-		}
-	}
-
-	// Permission is valid and not expired
-	return permission, nil
+	return &permissions[0], nil
 }
 
-// RevokeCallPermission revokes call permission for a phone number
-// This can be called if a user opts out or requests to stop receiving calls
-func RevokeCallPermission(phoneNumber string) error {
-	supabaseURL := os.Getenv("SUPABASE_URL")
-	supabaseKey := os.Getenv("SUPABASE_ANON_KEY")
-
-	if supabaseURL == "" || supabaseKey == "" {
-		return fmt.Errorf("Supabase credentials not configured")
-	}
-
-	update := map[string]interface{}{
-		"permission_granted": false,
-		"updated_at":         time.Now().UTC().Format(time.RFC3339),
-	}
-
-	jsonData, err := json.Marshal(update)
-	if err != nil {
-		return err
-	}
-
-	url := fmt.Sprintf("%s/rest/v1/whatsapp_call_permissions?phone_number=eq.%s", supabaseURL, phoneNumber)
-	req, err := http.NewRequest("PATCH", url, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return err
-	}
-
-	req.Header.Set("apikey", supabaseKey)
-	req.Header.Set("Authorization", "Bearer "+supabaseKey)
-	req.Header.Set("Content-Type", "application/json")
-
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-
-	body, _ := io.ReadAll(resp.Body)
-
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
-		return fmt.Errorf("Supabase error: %s - %s", resp.Status, string(body))
-	}
-
-	log.Printf("🚫 Revoked call permission for %s", phoneNumber)
-	return nil
-}
-
-// RequestCallPermission sends an interactive message asking user for call permission
-// Returns true if request was sent, false if rate limited
-// Rate limits: 1 request per 24 hours, 2 requests per 7 days
-func RequestCallPermission(phoneNumber string) (bool, error) {
-	supabaseURL := os.Getenv("SUPABASE_URL")
-	supabaseKey := os.Getenv("SUPABASE_ANON_KEY")
-
-	if supabaseURL == "" || supabaseKey == "" {
-		return false, fmt.Errorf("Supabase credentials not configured")
-	}
-
-	// Check existing permission record
-	existing, _ := CheckCallPermission(phoneNumber)
-
-	now := time.Now().UTC()
-
-	// Check rate limits
-	if existing != nil && existing.LastPermissionRequestAt != "" {
-		lastRequest, err := time.Parse(time.RFC3339, existing.LastPermissionRequestAt)
-		if err == nil {
-			// Check 24-hour limit
-			if now.Sub(lastRequest) < 24*time.Hour {
-				log.Printf("🚫 Rate limited: Cannot request permission for %s (last request was %v ago)",
-					phoneNumber, now.Sub(lastRequest))
-				return false, fmt.Errorf("rate limited: must wait 24 hours between requests")
-			}
-
-			// Check 7-day limit (2 requests max)
-			if existing.PermissionRequestCount >= 2 && now.Sub(lastRequest) < 7*24*time.Hour {
-				log.Printf("🚫 Rate limited: Cannot request permission for %s (2 requests in past 7 days)", phoneNumber)
-				return false, fmt.Errorf("rate limited: maximum 2 requests per 7 days")
-			}
-		}
-	}
-
-	// Create or update permission record with pending status
-	if existing == nil {
-		// Create new record
-		permission := map[string]interface{}{
-			"phone_number":               phoneNumber,
-			"permission_granted":         false, // Pending approval
-			"permission_requested_at":    now.Format(time.RFC3339),
-			"last_permission_request_at": now.Format(time.RFC3339),
-			"permission_request_count":   1,
-			"permission_source":          "express_request",
-		}
-
-		jsonData, err := json.Marshal(permission)
-		if err != nil {
-			return false, err
-		}
-
-		url := fmt.Sprintf("%s/rest/v1/whatsapp_call_permissions", supabaseURL)
-		req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
-		if err != nil {
-			return false, err
-		}
-
-		req.Header.Set("apikey", supabaseKey)
-		req.Header.Set("Authorization", "Bearer "+supabaseKey)
-		req.Header.Set("Content-Type", "application/json")
-
-		client := &http.Client{Timeout: 10 * time.Second}
-		resp, err := client.Do(req)
-		if err != nil {
-			return false, err
-		}
-		defer resp.Body.Close()
-
-		body, _ := io.ReadAll(resp.Body)
-		if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
-			return false, fmt.Errorf("Supabase error: %s - %s", resp.Status, string(body))
-		}
-	} else {
-		// Update existing record
-		requestCount := existing.PermissionRequestCount + 1
-
-		// Reset count if 7 days have passed
-		if existing.LastPermissionRequestAt != "" {
-			lastRequest, err := time.Parse(time.RFC3339, existing.LastPermissionRequestAt)
-			if err == nil && now.Sub(lastRequest) >= 7*24*time.Hour {
-				requestCount = 1
-			}
-		}
-
-		update := map[string]interface{}{
-			"permission_requested_at":    now.Format(time.RFC3339),
-			"last_permission_request_at": now.Format(time.RFC3339),
-			"permission_request_count":   requestCount,
-			"updated_at":                 now.Format(time.RFC3339),
-		}
-
-		jsonData, err := json.Marshal(update)
-		if err != nil {
-			return false, err
-		}
-
-		url := fmt.Sprintf("%s/rest/v1/whatsapp_call_permissions?phone_number=eq.%s", supabaseURL, phoneNumber)
-		req, err := http.NewRequest("PATCH", url, bytes.NewBuffer(jsonData))
-		if err != nil {
-			return false, err
-		}
-
-		req.Header.Set("apikey", supabaseKey)
-		req.Header.Set("Authorization", "Bearer "+supabaseKey)
-		req.Header.Set("Content-Type", "application/json")
-
-		client := &http.Client{Timeout: 10 * time.Second}
-		resp, err := client.Do(req)
-		if err != nil {
-			return false, err
-		}
-		defer resp.Body.Close()
-
-		body, _ := io.ReadAll(resp.Body)
-		if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
-			return false, fmt.Errorf("Supabase error: %s - %s", resp.Status, string(body))
-		}
-	}
-
-	log.Printf("✅ Recorded permission request for %s", phoneNumber)
-	return true, nil
+// InsertCallPermission implements Store
+func (s *SupabaseStore) InsertCallPermission(schema string, fields map[string]interface{}) error {
+	_, err := s.client.Do(schema, "POST", From("whatsapp_call_permissions"), fields, "")
+	return err
 }
 
-// ApproveCallPermission approves a call permission request
-// Sets 72-hour expiry window from approval time
-func ApproveCallPermission(phoneNumber, source string) error {
-	supabaseURL := os.Getenv("SUPABASE_URL")
-	supabaseKey := os.Getenv("SUPABASE_ANON_KEY")
-
-	if supabaseURL == "" || supabaseKey == "" {
-		return fmt.Errorf("Supabase credentials not configured")
-	}
-
-	now := time.Now().UTC()
-	expiresAt := now.Add(72 * time.Hour) // 72-hour call window
-
-	update := map[string]interface{}{
-		"permission_granted":    true,
-		"permission_approved_at": now.Format(time.RFC3339),
-		"permission_expires_at":  expiresAt.Format(time.RFC3339),
-		"permission_source":      source,
-		"updated_at":             now.Format(time.RFC3339),
-	}
-
-	jsonData, err := json.Marshal(update)
-	if err != nil {
-		return err
-	}
-
-	url := fmt.Sprintf("%s/rest/v1/whatsapp_call_permissions?phone_number=eq.%s", supabaseURL, phoneNumber)
-	req, err := http.NewRequest("PATCH", url, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return err
-	}
-
-	req.Header.Set("apikey", supabaseKey)
-	req.Header.Set("Authorization", "Bearer "+supabaseKey)
-	req.Header.Set("Content-Type", "application/json")
-
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-
-	body, _ := io.ReadAll(resp.Body)
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
-		return fmt.Errorf("Supabase error: %s - %s", resp.Status, string(body))
-	}
-
-	log.Printf("✅ Approved call permission for %s (expires in 72 hours)", phoneNumber)
-	return nil
+// UpdateCallPermission implements Store
+func (s *SupabaseStore) UpdateCallPermission(schema, phoneNumber string, fields map[string]interface{}) error {
+	_, err := s.client.Do(schema, "PATCH", From("whatsapp_call_permissions").Eq("phone_number", phoneNumber), fields, "")
+	return err
 }
 
 // SendCallPermissionRequest sends an interactive message to request call permission
 // Combines database tracking with actual WhatsApp message sending
-func SendCallPermissionRequest(phoneNumber string) error {
+func SendCallPermissionRequest(ctx context.Context, tenant *Tenant, phoneNumber string) error {
 	// First, check rate limits and record the request
-	allowed, err := RequestCallPermission(phoneNumber)
+	allowed, err := RequestCallPermission(tenant.SupabaseSchema, phoneNumber)
 	if err != nil {
 		return err
 	}
@@ -969,20 +361,12 @@ func SendCallPermissionRequest(phoneNumber string) error {
 		return fmt.Errorf("rate limited")
 	}
 
-	// Get WhatsApp credentials
-	token := os.Getenv("WHATSAPP_TOKEN")
-	phoneID := os.Getenv("PHONE_NUMBER_ID")
-
-	if token == "" || phoneID == "" {
-		return fmt.Errorf("WhatsApp credentials not configured")
+	if tenant.AccessToken == "" || tenant.PhoneNumberID == "" {
+		return fmt.Errorf("WhatsApp credentials not configured for tenant %s", tenant.Name)
 	}
 
-	// Create WhatsApp SDK client
-	config := &Config{
-		Token:   token,
-		PhoneID: phoneID,
-	}
-	client := NewWhatsAppClient(config)
+	// Send as the tenant's business number
+	client := tenant.WhatsAppClient()
 
 	// Create permission request buttons
 	buttons := []Button{
@@ -995,11 +379,10 @@ func SendCallPermissionRequest(phoneNumber string) error {
 
 	_, err = client.SendButtons(phoneNumber, body, buttons, nil)
 	if err != nil {
-		log.Printf("❌ Failed to send permission request to %s: %v", phoneNumber, err)
 		return fmt.Errorf("failed to send WhatsApp message: %v", err)
 	}
 
-	log.Printf("📤 Sent call permission request to %s", phoneNumber)
+	loggerFrom(ctx).Info("📤 Sent call permission request", "to", phoneNumber)
 	return nil
 }
 
@@ -1010,256 +393,110 @@ type ZiggyNote struct {
 	NoteContent string `json:"note_content"`
 }
 
-// AddNote creates a new note in Supabase
-func AddNote(noteContent, phoneNumber string) (*ZiggyNote, error) {
-	log.Printf("🔷 [NOTES_DB] ========== AddNote() ENTERED ==========")
-	log.Printf("🔷 [NOTES_DB] Input - noteContent: '%s'", noteContent)
-	log.Printf("🔷 [NOTES_DB] Input - phoneNumber: '%s'", phoneNumber)
-
-	supabaseURL := os.Getenv("SUPABASE_URL")
-	supabaseKey := os.Getenv("SUPABASE_ANON_KEY")
-
-	log.Printf("🔷 [NOTES_DB] Supabase URL: %s", supabaseURL)
-	log.Printf("🔷 [NOTES_DB] Supabase Key (first 20 chars): %.20s...", supabaseKey)
-
-	if supabaseURL == "" || supabaseKey == "" {
-		log.Printf("❌ [NOTES_DB] ERROR: Supabase credentials not configured!")
-		return nil, fmt.Errorf("Supabase credentials not configured")
-	}
-
-	note := ZiggyNote{
-		PhoneNumber: phoneNumber,
-		NoteContent: noteContent,
-	}
-	log.Printf("🔷 [NOTES_DB] Created ZiggyNote struct: %+v", note)
-
-	jsonData, err := json.Marshal(note)
-	if err != nil {
-		log.Printf("❌ [NOTES_DB] ERROR: JSON Marshal failed: %v", err)
-		return nil, err
-	}
-	log.Printf("🔷 [NOTES_DB] JSON payload: %s", string(jsonData))
-
-	url := fmt.Sprintf("%s/rest/v1/ziggy_notes", supabaseURL)
-	log.Printf("🔷 [NOTES_DB] POST URL: %s", url)
-
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
+// InsertNote implements Store
+func (s *SupabaseStore) InsertNote(schema string, note ZiggyNote) (*ZiggyNote, error) {
+	resp, err := s.client.Do(schema, "POST", From("ziggy_notes"), note, "return=representation")
 	if err != nil {
-		log.Printf("❌ [NOTES_DB] ERROR: Failed to create HTTP request: %v", err)
 		return nil, err
 	}
 
-	req.Header.Set("apikey", supabaseKey)
-	req.Header.Set("Authorization", "Bearer "+supabaseKey)
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Prefer", "return=representation")
-	log.Printf("🔷 [NOTES_DB] HTTP headers set")
-
-	client := &http.Client{Timeout: 10 * time.Second}
-	log.Printf("🔷 [NOTES_DB] Sending HTTP POST request...")
-	resp, err := client.Do(req)
-	if err != nil {
-		log.Printf("❌ [NOTES_DB] ERROR: HTTP request failed: %v", err)
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	body, _ := io.ReadAll(resp.Body)
-	log.Printf("🔷 [NOTES_DB] Response Status Code: %d", resp.StatusCode)
-	log.Printf("🔷 [NOTES_DB] Response Status: %s", resp.Status)
-	log.Printf("🔷 [NOTES_DB] Response Body: %s", string(body))
-
-	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
-		log.Printf("❌ [NOTES_DB] ERROR: Supabase returned error status")
-		return nil, fmt.Errorf("Supabase error: %s - %s", resp.Status, string(body))
-	}
-
 	var notes []ZiggyNote
-	if err := json.Unmarshal(body, &notes); err != nil {
-		log.Printf("❌ [NOTES_DB] ERROR: Failed to unmarshal response: %v", err)
+	if err := json.Unmarshal(resp.Body, &notes); err != nil {
 		return nil, err
 	}
-	log.Printf("🔷 [NOTES_DB] Unmarshaled %d notes from response", len(notes))
-
 	if len(notes) == 0 {
-		log.Printf("❌ [NOTES_DB] ERROR: No note returned from Supabase")
 		return nil, fmt.Errorf("no note returned from Supabase")
 	}
-
-	log.Printf("✅ [NOTES_DB] SUCCESS! Note created in Supabase")
-	log.Printf("✅ [NOTES_DB] Note ID: %s", notes[0].ID)
-	log.Printf("✅ [NOTES_DB] Note Content: %s", notes[0].NoteContent)
-	log.Printf("✅ [NOTES_DB] Note Phone: %s", notes[0].PhoneNumber)
 	return &notes[0], nil
 }
 
-// ListNotes retrieves all notes for a phone number, ordered by most recent first
-// If limit is provided and > 0, only returns that many notes
-func ListNotes(phoneNumber string, limit int) ([]ZiggyNote, error) {
-	supabaseURL := os.Getenv("SUPABASE_URL")
-	supabaseKey := os.Getenv("SUPABASE_ANON_KEY")
-
-	if supabaseURL == "" || supabaseKey == "" {
-		return nil, fmt.Errorf("Supabase credentials not configured")
-	}
-
-	url := fmt.Sprintf("%s/rest/v1/ziggy_notes?phone_number=eq.%s&order=created_at.desc",
-		supabaseURL, phoneNumber)
-
-	if limit > 0 {
-		url += fmt.Sprintf("&limit=%d", limit)
-	}
-
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, err
-	}
-
-	req.Header.Set("apikey", supabaseKey)
-	req.Header.Set("Authorization", "Bearer "+supabaseKey)
-
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	body, _ := io.ReadAll(resp.Body)
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("Supabase error: %s - %s", resp.Status, string(body))
-	}
+// ListNotes implements Store
+func (s *SupabaseStore) ListNotes(schema, phoneNumber string, limit int) ([]ZiggyNote, error) {
+	q := From("ziggy_notes").Eq("phone_number", phoneNumber).Order("created_at", false).Limit(limit)
 
 	var notes []ZiggyNote
-	if err := json.Unmarshal(body, &notes); err != nil {
+	if err := s.client.Get(schema, q, &notes); err != nil {
 		return nil, err
 	}
-
-	log.Printf("📋 Retrieved %d notes from Supabase for %s", len(notes), phoneNumber)
 	return notes, nil
 }
 
-// SearchNotes searches notes for a phone number using full-text search
-func SearchNotes(phoneNumber, searchQuery string) ([]ZiggyNote, error) {
-	supabaseURL := os.Getenv("SUPABASE_URL")
-	supabaseKey := os.Getenv("SUPABASE_ANON_KEY")
-
-	if supabaseURL == "" || supabaseKey == "" {
-		return nil, fmt.Errorf("Supabase credentials not configured")
-	}
-
-	// Use PostgreSQL full-text search with to_tsvector and plainto_tsquery
-	// Format: note_content.fts(english).searchQuery
-	url := fmt.Sprintf("%s/rest/v1/ziggy_notes?phone_number=eq.%s&note_content=fts.%s",
-		supabaseURL, phoneNumber, searchQuery)
-
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, err
-	}
-
-	req.Header.Set("apikey", supabaseKey)
-	req.Header.Set("Authorization", "Bearer "+supabaseKey)
-
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	body, _ := io.ReadAll(resp.Body)
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("Supabase error: %s - %s", resp.Status, string(body))
-	}
+// SearchNotes implements Store
+func (s *SupabaseStore) SearchNotes(schema, phoneNumber, query string) ([]ZiggyNote, error) {
+	q := From("ziggy_notes").Eq("phone_number", phoneNumber).Search("note_content", query).Order("created_at", false)
 
 	var notes []ZiggyNote
-	if err := json.Unmarshal(body, &notes); err != nil {
+	if err := s.client.Get(schema, q, &notes); err != nil {
 		return nil, err
 	}
-
-	log.Printf("🔍 Found %d notes matching '%s' for %s", len(notes), searchQuery, phoneNumber)
 	return notes, nil
 }
 
-// UpdateNote updates the content of an existing note
-func UpdateNote(noteID, newContent string) error {
-	supabaseURL := os.Getenv("SUPABASE_URL")
-	supabaseKey := os.Getenv("SUPABASE_ANON_KEY")
-
-	if supabaseURL == "" || supabaseKey == "" {
-		return fmt.Errorf("Supabase credentials not configured")
-	}
-
+// UpdateNote implements Store
+func (s *SupabaseStore) UpdateNote(schema, noteID, content string) error {
 	update := map[string]string{
-		"note_content": newContent,
+		"note_content": content,
 	}
+	_, err := s.client.Do(schema, "PATCH", From("ziggy_notes").Eq("id", noteID), update, "")
+	return err
+}
 
-	jsonData, err := json.Marshal(update)
-	if err != nil {
-		return err
-	}
+// DeleteNote implements Store
+func (s *SupabaseStore) DeleteNote(schema, noteID string) error {
+	_, err := s.client.Do(schema, "DELETE", From("ziggy_notes").Eq("id", noteID), nil, "")
+	return err
+}
 
-	url := fmt.Sprintf("%s/rest/v1/ziggy_notes?id=eq.%s", supabaseURL, noteID)
-	req, err := http.NewRequest("PATCH", url, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return err
+// SaveMessage implements Store
+func (s *SupabaseStore) SaveMessage(schema string, msg TextMessage) error {
+	_, err := s.client.Do(schema, "POST", From("ziggy_messages"), msg, "return=minimal")
+	if isSupabaseConflict(err) {
+		return nil // Duplicate message ID - already saved
 	}
+	return err
+}
 
-	req.Header.Set("apikey", supabaseKey)
-	req.Header.Set("Authorization", "Bearer "+supabaseKey)
-	req.Header.Set("Content-Type", "application/json")
-
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(req)
+// CountMessages implements Store
+func (s *SupabaseStore) CountMessages(schema, phoneNumber string) (int, error) {
+	q := From("ziggy_messages").Eq("phone_number", phoneNumber).Select("id").Limit(1)
+	resp, err := s.client.Do(schema, "GET", q, nil, "count=exact")
 	if err != nil {
-		return err
+		return 0, err
 	}
-	defer resp.Body.Close()
-
-	body, _ := io.ReadAll(resp.Body)
-
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
-		return fmt.Errorf("Supabase error: %s - %s", resp.Status, string(body))
-	}
-
-	log.Printf("✅ Note %s updated", noteID)
-	return nil
+	return resp.Count()
 }
 
-// DeleteNote deletes a note by ID
-func DeleteNote(noteID string) error {
-	supabaseURL := os.Getenv("SUPABASE_URL")
-	supabaseKey := os.Getenv("SUPABASE_ANON_KEY")
-
-	if supabaseURL == "" || supabaseKey == "" {
-		return fmt.Errorf("Supabase credentials not configured")
-	}
+// ListMessages implements Store
+func (s *SupabaseStore) ListMessages(schema, phoneNumber string, limit int, ascending bool) ([]TextMessage, error) {
+	q := From("ziggy_messages").
+		Eq("phone_number", phoneNumber).
+		Select("message_content", "direction", "timestamp").
+		Order("timestamp", ascending).
+		Limit(limit)
 
-	url := fmt.Sprintf("%s/rest/v1/ziggy_notes?id=eq.%s", supabaseURL, noteID)
-	req, err := http.NewRequest("DELETE", url, nil)
-	if err != nil {
-		return err
+	var messages []TextMessage
+	if err := s.client.Get(schema, q, &messages); err != nil {
+		return nil, err
 	}
+	return messages, nil
+}
 
-	req.Header.Set("apikey", supabaseKey)
-	req.Header.Set("Authorization", "Bearer "+supabaseKey)
-
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		return err
+// SaveCallTranscriptTurn implements Store
+func (s *SupabaseStore) SaveCallTranscriptTurn(schema string, turn CallTranscriptTurn) error {
+	_, err := s.client.Do(schema, "POST", From("ziggy_call_transcripts"), turn, "return=minimal")
+	if isSupabaseConflict(err) {
+		return nil // Same item already saved for this call
 	}
-	defer resp.Body.Close()
+	return err
+}
 
-	body, _ := io.ReadAll(resp.Body)
+// ListCallTranscriptTurns implements Store
+func (s *SupabaseStore) ListCallTranscriptTurns(schema, phoneNumber string, limit int) ([]CallTranscriptTurn, error) {
+	q := From("ziggy_call_transcripts").Eq("phone_number", phoneNumber).Order("started_at", false).Limit(limit)
 
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
-		return fmt.Errorf("Supabase error: %s - %s", resp.Status, string(body))
+	var turns []CallTranscriptTurn
+	if err := s.client.Get(schema, q, &turns); err != nil {
+		return nil, err
 	}
-
-	log.Printf("🗑️ Note %s deleted", noteID)
-	return nil
+	return turns, nil
 }
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// supabaseMaxAttempts bounds retries of a failed Supabase request
+	supabaseMaxAttempts = 3
+
+	// supabaseRetryDelay is the wait before the first retry; it doubles after each attempt
+	supabaseRetryDelay = 250 * time.Millisecond
+)
+
+// PostgrestQuery is a request path for one table: filters, ordering and
+// paging. Column names come from code; every value is URL-encoded, so user
+// input can't break the query or add filters of its own.
+type PostgrestQuery struct {
+	table  string
+	params url.Values
+}
+
+// From starts a query on table
+func From(table string) *PostgrestQuery {
+	return &PostgrestQuery{table: table, params: url.Values{}}
+}
+
+// filter adds column=op.value
+func (q *PostgrestQuery) filter(column, op, value string) *PostgrestQuery {
+	q.params.Add(column, op+"."+value)
+	return q
+}
+
+// Eq keeps rows where column equals value
+func (q *PostgrestQuery) Eq(column, value string) *PostgrestQuery {
+	return q.filter(column, "eq", value)
+}
+
+// EqBool keeps rows where a boolean column equals value
+func (q *PostgrestQuery) EqBool(column string, value bool) *PostgrestQuery {
+	return q.filter(column, "eq", strconv.FormatBool(value))
+}
+
+// Lt keeps rows where column is before t
+func (q *PostgrestQuery) Lt(column string, t time.Time) *PostgrestQuery {
+	return q.filter(column, "lt", t.UTC().Format(time.RFC3339))
+}
+
+// Lte keeps rows where column is at or before t
+func (q *PostgrestQuery) Lte(column string, t time.Time) *PostgrestQuery {
+	return q.filter(column, "lte", t.UTC().Format(time.RFC3339))
+}
+
+// Search keeps rows whose text column matches the words in query, using
+// Postgres full-text search (plainto_tsquery) with the english config
+func (q *PostgrestQuery) Search(column, query string) *PostgrestQuery {
+	return q.filter(column, "plfts(english)", query)
+}
+
+// Select limits the columns returned
+func (q *PostgrestQuery) Select(columns ...string) *PostgrestQuery {
+	q.params.Set("select", strings.Join(columns, ","))
+	return q
+}
+
+// Order sorts by column
+func (q *PostgrestQuery) Order(column string, ascending bool) *PostgrestQuery {
+	direction := "asc"
+	if !ascending {
+		direction = "desc"
+	}
+	q.params.Set("order", column+"."+direction)
+	return q
+}
+
+// Limit caps the number of rows; zero or less means no limit
+func (q *PostgrestQuery) Limit(n int) *PostgrestQuery {
+	if n > 0 {
+		q.params.Set("limit", strconv.Itoa(n))
+	}
+	return q
+}
+
+// String renders the query as a path under /rest/v1/
+func (q *PostgrestQuery) String() string {
+	if len(q.params) == 0 {
+		return q.table
+	}
+	return q.table + "?" + q.params.Encode()
+}
+
+// SupabaseError is an error response from PostgREST
+type SupabaseError struct {
+	StatusCode int
+	Status     string
+	Code       string `json:"code"` // Postgres or PostgREST error code, e.g. 23505
+	Message    string `json:"message"`
+	Details    string `json:"details"`
+	Hint       string `json:"hint"`
+	Body       string // Raw body when it isn't a PostgREST error object
+}
+
+func (e *SupabaseError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("Supabase error: %s - %s", e.Status, e.Body)
+	}
+	msg := fmt.Sprintf("Supabase error: %s - %s", e.Status, e.Message)
+	if e.Code != "" {
+		msg += " (" + e.Code + ")"
+	}
+	if e.Details != "" {
+		msg += ": " + e.Details
+	}
+	return msg
+}
+
+// isSupabaseConflict reports whether err is a unique constraint violation
+func isSupabaseConflict(err error) bool {
+	var supabaseErr *SupabaseError
+	return errors.As(err, &supabaseErr) && supabaseErr.StatusCode == http.StatusConflict
+}
+
+// SupabaseResponse is a successful PostgREST response
+type SupabaseResponse struct {
+	Header http.Header
+	Body   []byte
+}
+
+// Count returns the total from a Prefer: count=exact response, whose
+// Content-Range header looks like "0-9/10" (or "*/0" when nothing matched)
+func (r *SupabaseResponse) Count() (int, error) {
+	contentRange := r.Header.Get("Content-Range")
+	total, err := strconv.Atoi(contentRange[strings.LastIndex(contentRange, "/")+1:])
+	if err != nil {
+		return 0, fmt.Errorf("unexpected Content-Range %q", contentRange)
+	}
+	return total, nil
+}
+
+// SupabaseClient sends PostgREST requests to the project in SUPABASE_URL.
+// Failed requests are retried when the server is overloaded or briefly
+// unavailable, and error bodies are decoded into SupabaseError.
+type SupabaseClient struct {
+	baseURL    string
+	apiKey     string
+	httpClient *http.Client
+}
+
+// NewSupabaseClient creates a client from SUPABASE_URL and SUPABASE_ANON_KEY
+// with a per-attempt timeout. It is unconfigured when either is missing.
+func NewSupabaseClient(timeout time.Duration) *SupabaseClient {
+	return &SupabaseClient{
+		baseURL:    strings.TrimSuffix(os.Getenv("SUPABASE_URL"), "/"),
+		apiKey:     os.Getenv("SUPABASE_ANON_KEY"),
+		httpClient: &http.Client{Timeout: timeout},
+	}
+}
+
+// Configured reports whether the Supabase credentials are set
+func (c *SupabaseClient) Configured() bool {
+	return c != nil && c.baseURL != "" && c.apiKey != ""
+}
+
+// Get runs a read query and decodes the rows into out
+func (c *SupabaseClient) Get(schema string, q *PostgrestQuery, out interface{}) error {
+	resp, err := c.Do(schema, "GET", q, nil, "")
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(resp.Body, out)
+}
+
+// Do sends one PostgREST request in the tenant's schema (empty for the
+// project's default). payload is sent as JSON; prefer sets the Prefer header.
+func (c *SupabaseClient) Do(schema, method string, q *PostgrestQuery, payload interface{}, prefer string) (*SupabaseResponse, error) {
+	if !c.Configured() {
+		return nil, fmt.Errorf("Supabase credentials not configured")
+	}
+
+	var body []byte
+	if payload != nil {
+		var err error
+		if body, err = json.Marshal(payload); err != nil {
+			return nil, err
+		}
+	}
+
+	delay := supabaseRetryDelay
+	for attempt := 1; ; attempt++ {
+		resp, retry, err := c.send(schema, method, q, body, prefer)
+		if err == nil || !retry || attempt == supabaseMaxAttempts {
+			return resp, err
+		}
+		slog.Warn("🔁 Supabase request failed, retrying", "method", method, "table", q.table,
+			"attempt", attempt, "max_attempts", supabaseMaxAttempts, "delay", delay, "error", err)
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// send makes one attempt and reports whether a failure is worth retrying
+func (c *SupabaseClient) send(schema, method string, q *PostgrestQuery, body []byte, prefer string) (*SupabaseResponse, bool, error) {
+	req, err := http.NewRequest(method, c.baseURL+"/rest/v1/"+q.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, false, err
+	}
+
+	req.Header.Set("apikey", c.apiKey)
+	req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	setSupabaseSchema(req, schema)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if prefer != "" {
+		req.Header.Set("Prefer", prefer)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		// The request may have been applied; only repeat it if that's harmless
+		return nil, method != "POST", err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, method != "POST", err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		supabaseErr := &SupabaseError{StatusCode: resp.StatusCode, Status: resp.Status}
+		if json.Unmarshal(respBody, supabaseErr) != nil || supabaseErr.Message == "" {
+			supabaseErr.Body = string(respBody)
+		}
+		return nil, retryableSupabaseStatus(method, resp.StatusCode), supabaseErr
+	}
+
+	return &SupabaseResponse{Header: resp.Header, Body: respBody}, false, nil
+}
+
+// retryableSupabaseStatus reports whether a status means the request can
+// safely be sent again. A gateway error on an insert may hide a row that was
+// written, so only rate limiting and maintenance are retried for POST.
+func retryableSupabaseStatus(method string, status int) bool {
+	switch status {
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		return true
+	case http.StatusBadGateway, http.StatusGatewayTimeout:
+		return method != "POST"
+	}
+	return false
+}
+
+// setSupabaseSchema points a PostgREST request at a tenant's schema.
+// Reads use Accept-Profile and writes use Content-Profile; an empty schema
+// leaves the project's default (public) schema in place.
+func setSupabaseSchema(req *http.Request, schema string) {
+	if schema == "" {
+		return
+	}
+	if req.Method == "GET" || req.Method == "HEAD" {
+		req.Header.Set("Accept-Profile", schema)
+	} else {
+		req.Header.Set("Content-Profile", schema)
+	}
+}
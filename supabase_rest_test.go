@@ -0,0 +1,139 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// TestPostgrestQueryEncoding checks values with PostgREST and URL syntax in
+// them are encoded, so they can't end the value or add filters of their own
+func TestPostgrestQueryEncoding(t *testing.T) {
+	tests := []struct {
+		name   string
+		query  *PostgrestQuery
+		want   string
+		column string
+		value  string
+	}{
+		{
+			name:   "ampersand and equals",
+			query:  From("ziggy_notes").Eq("phone_number", "1&phone_number=eq.2"),
+			want:   "ziggy_notes?phone_number=eq.1%26phone_number%3Deq.2",
+			column: "phone_number",
+			value:  "eq.1&phone_number=eq.2",
+		},
+		{
+			name:   "comma and dot",
+			query:  From("ziggy_tasks").Eq("title", "milk,eggs.bread"),
+			want:   "ziggy_tasks?title=eq.milk%2Ceggs.bread",
+			column: "title",
+			value:  "eq.milk,eggs.bread",
+		},
+		{
+			name:   "search with spaces",
+			query:  From("ziggy_notes").Search("note_content", "wifi password & door=code"),
+			want:   "ziggy_notes?note_content=plfts%28english%29.wifi+password+%26+door%3Dcode",
+			column: "note_content",
+			value:  "plfts(english).wifi password & door=code",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.query.String()
+			if got != tt.want {
+				t.Errorf("String() = %q, want %q", got, tt.want)
+			}
+			_, rawQuery, _ := strings.Cut(got, "?")
+			params, err := url.ParseQuery(rawQuery)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(params) != 1 || len(params[tt.column]) != 1 || params[tt.column][0] != tt.value {
+				t.Errorf("query decodes to %v, want only %s=%q", params, tt.column, tt.value)
+			}
+		})
+	}
+}
+
+// TestRetryableSupabaseStatus checks inserts are only retried when the
+// server can't have applied them
+func TestRetryableSupabaseStatus(t *testing.T) {
+	tests := []struct {
+		method string
+		status int
+		want   bool
+	}{
+		{"GET", http.StatusTooManyRequests, true},
+		{"GET", http.StatusBadGateway, true},
+		{"GET", http.StatusServiceUnavailable, true},
+		{"GET", http.StatusGatewayTimeout, true},
+		{"PATCH", http.StatusBadGateway, true},
+		{"POST", http.StatusTooManyRequests, true},
+		{"POST", http.StatusServiceUnavailable, true},
+		{"POST", http.StatusBadGateway, false},
+		{"POST", http.StatusGatewayTimeout, false},
+		{"GET", http.StatusInternalServerError, false},
+		{"GET", http.StatusNotFound, false},
+		{"POST", http.StatusConflict, false},
+	}
+	for _, tt := range tests {
+		if got := retryableSupabaseStatus(tt.method, tt.status); got != tt.want {
+			t.Errorf("retryableSupabaseStatus(%s, %d) = %v, want %v", tt.method, tt.status, got, tt.want)
+		}
+	}
+}
+
+// TestSupabaseInsertNotRetriedOnGatewayError checks a POST that got a 502
+// is sent once while a GET is retried
+func TestSupabaseInsertNotRetriedOnGatewayError(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		http.Error(w, "bad gateway", http.StatusBadGateway)
+	}))
+	defer server.Close()
+	t.Setenv("SUPABASE_URL", server.URL)
+	t.Setenv("SUPABASE_ANON_KEY", "test-anon-key")
+	client := NewSupabaseClient(0)
+
+	if _, err := client.Do("", "POST", From("ziggy_notes"), map[string]string{"note_content": "x"}, ""); err == nil {
+		t.Fatal("POST succeeded against a 502")
+	}
+	if n := requests.Swap(0); n != 1 {
+		t.Errorf("POST sent %d times, want 1", n)
+	}
+
+	if _, err := client.Do("", "GET", From("ziggy_notes"), nil, ""); err == nil {
+		t.Fatal("GET succeeded against a 502")
+	}
+	if n := requests.Load(); n != supabaseMaxAttempts {
+		t.Errorf("GET sent %d times, want %d", n, supabaseMaxAttempts)
+	}
+}
+
+// TestSupabaseResponseCount checks the total is read from Content-Range,
+// including an empty result
+func TestSupabaseResponseCount(t *testing.T) {
+	tests := []struct {
+		contentRange string
+		want         int
+		wantErr      bool
+	}{
+		{"0-9/10", 10, false},
+		{"0-0/1", 1, false},
+		{"*/0", 0, false},
+		{"0-9/*", 0, true},
+		{"", 0, true},
+	}
+	for _, tt := range tests {
+		r := &SupabaseResponse{Header: http.Header{"Content-Range": []string{tt.contentRange}}}
+		got, err := r.Count()
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("Count() with Content-Range %q = %d, %v; want %d, error %v", tt.contentRange, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+)
+
+// Tenant is one WhatsApp Business phone number served by this bridge.
+// Every webhook change is routed to its tenant by metadata.phone_number_id,
+// and all Graph API calls for that change use the tenant's own credentials.
+type Tenant struct {
+	Name                string   `json:"name"`
+	PhoneNumberID       string   `json:"phone_number_id"`
+	DisplayPhoneNumber  string   `json:"display_phone_number,omitempty"` // Optional extra check against metadata.display_phone_number
+	AccessToken         string   `json:"access_token"`
+	VerifyToken         string   `json:"verify_token,omitempty"`
+	Persona             string   `json:"persona,omitempty"`              // Extra assistant instructions for this number
+	SupabaseSchema      string   `json:"supabase_schema,omitempty"`      // PostgREST schema holding this tenant's tables
+	VoiceAgent          string   `json:"voice_agent,omitempty"`          // Voice backend for this number's calls ("openai", "echo", "none"); defaults to VOICE_AGENT
+	RecordCalls         bool     `json:"record_calls,omitempty"`         // Record calls to RECORDINGS_DIR; callers must be told the call is recorded
+	ImpliedConsent      bool     `json:"implied_consent,omitempty"`      // Callers who stay on after the recording disclosure consent to recording; without it only calls placed with "record": true are recorded
+	RecordingDisclosure string   `json:"recording_disclosure,omitempty"` // What the voice agent tells the caller first on a recorded call; defaults to defaultRecordingDisclosure
+	CaptureRTP          bool     `json:"capture_rtp,omitempty"`          // Capture every call's RTP/RTCP to RTP_CAPTURE_DIR for debugging media
+	Tools               []string `json:"tools,omitempty"`                // Plugin tools this number may use, by tool or plugin name ("*" for all)
+}
+
+// defaultRecordingDisclosure is the notice given at the start of recorded calls
+const defaultRecordingDisclosure = "This call is recorded. If you'd rather not be recorded, you can hang up now."
+
+// impliesRecordingConsent reports whether the tenant's calls are recorded
+// without the user having explicitly agreed, after the disclosure is given
+func (t *Tenant) impliesRecordingConsent() bool {
+	return t.RecordCalls && t.ImpliedConsent
+}
+
+// recordingDisclosure is what the voice agent says first on a recorded call
+func (t *Tenant) recordingDisclosure() string {
+	if t.RecordingDisclosure != "" {
+		return t.RecordingDisclosure
+	}
+	return defaultRecordingDisclosure
+}
+
+// AllowsTool reports whether the tenant's allow-list enables a plugin tool.
+// Built-in tools are always available.
+func (t *Tenant) AllowsTool(plugin, tool string) bool {
+	for _, allowed := range t.Tools {
+		if allowed == "*" || allowed == plugin || allowed == tool {
+			return true
+		}
+	}
+	return false
+}
+
+// WhatsAppClient returns a messaging client that sends as this tenant
+func (t *Tenant) WhatsAppClient() *WhatsAppClient {
+	return NewWhatsAppSDK(t.AccessToken, t.PhoneNumberID).Client
+}
+
+// TenantRegistry holds all configured tenants keyed by phone_number_id
+type TenantRegistry struct {
+	tenants   map[string]*Tenant
+	defaultID string // First tenant in the config; used when a request doesn't name one
+	mu        sync.RWMutex
+}
+
+// tenantConfigFile is the on-disk format of the tenant config
+type tenantConfigFile struct {
+	Tenants []*Tenant `json:"tenants"`
+}
+
+// LoadTenantRegistry loads tenants from the JSON file at path.
+// If path is empty or the file doesn't exist, a single tenant is built from the
+// legacy WHATSAPP_TOKEN / PHONE_NUMBER_ID / VERIFY_TOKEN environment variables.
+// Token fields may reference environment variables (e.g. "$ACME_WHATSAPP_TOKEN")
+// so secrets don't have to live in the config file.
+func LoadTenantRegistry(path string) (*TenantRegistry, error) {
+	registry := &TenantRegistry{
+		tenants: make(map[string]*Tenant),
+	}
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err == nil {
+			var cfg tenantConfigFile
+			if err := json.Unmarshal(data, &cfg); err != nil {
+				return nil, fmt.Errorf("failed to parse tenant config %s: %w", path, err)
+			}
+			for _, t := range cfg.Tenants {
+				t.AccessToken = os.ExpandEnv(t.AccessToken)
+				t.VerifyToken = os.ExpandEnv(t.VerifyToken)
+				if err := validateVoiceAgent(t.VoiceAgent); err != nil {
+					return nil, fmt.Errorf("tenant %s: %w", t.Name, err)
+				}
+				if err := registry.Add(t); err != nil {
+					return nil, err
+				}
+			}
+			if len(registry.tenants) == 0 {
+				return nil, fmt.Errorf("tenant config %s contains no tenants", path)
+			}
+			slog.Info("🏢 Loaded tenants", "count", len(registry.tenants), "path", path)
+			return registry, nil
+		} else if !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to read tenant config %s: %w", path, err)
+		}
+		slog.Info("ℹ️ Tenant config not found, using environment variables", "path", path)
+	}
+
+	registry.Add(tenantFromEnv())
+	return registry, nil
+}
+
+// tenantFromEnv builds the single legacy tenant from environment variables
+func tenantFromEnv() *Tenant {
+	verifyToken := os.Getenv("VERIFY_TOKEN")
+	if verifyToken == "" {
+		verifyToken = DEFAULT_VERIFY_TOKEN
+	}
+
+	accessToken := os.Getenv("WHATSAPP_TOKEN")
+	if accessToken == "" {
+		slog.Warn("⚠️ WHATSAPP_TOKEN not set - API calls will fail")
+	}
+
+	phoneNumberID := os.Getenv("PHONE_NUMBER_ID")
+	if phoneNumberID == "" {
+		slog.Warn("⚠️ PHONE_NUMBER_ID not set - API calls will fail")
+	}
+
+	displayPhoneNumber := os.Getenv("ALLOWED_DISPLAY_PHONE_NUMBER")
+	if displayPhoneNumber != "" {
+		slog.Info("🔒 Only processing webhooks from one display phone number", "display_phone_number", displayPhoneNumber)
+	}
+
+	return &Tenant{
+		Name:               "default",
+		PhoneNumberID:      phoneNumberID,
+		DisplayPhoneNumber: displayPhoneNumber,
+		AccessToken:        accessToken,
+		VerifyToken:        verifyToken,
+		SupabaseSchema:     os.Getenv("SUPABASE_SCHEMA"),
+	}
+}
+
+// Add registers a tenant. The first tenant added becomes the default.
+func (r *TenantRegistry) Add(t *Tenant) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.tenants[t.PhoneNumberID]; exists {
+		return fmt.Errorf("duplicate tenant for phone_number_id %q", t.PhoneNumberID)
+	}
+	if t.Name == "" {
+		t.Name = t.PhoneNumberID
+	}
+
+	r.tenants[t.PhoneNumberID] = t
+	if len(r.tenants) == 1 {
+		r.defaultID = t.PhoneNumberID
+	}
+	return nil
+}
+
+// Get returns the tenant for a phone_number_id, or nil if it isn't ours
+func (r *TenantRegistry) Get(phoneNumberID string) *Tenant {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.tenants[phoneNumberID]
+}
+
+// Default returns the tenant used when a request doesn't name one
+func (r *TenantRegistry) Default() *Tenant {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.tenants[r.defaultID]
+}
+
+// Resolve returns the tenant for phoneNumberID, falling back to the default
+// tenant when phoneNumberID is empty. Unknown IDs return nil.
+func (r *TenantRegistry) Resolve(phoneNumberID string) *Tenant {
+	if phoneNumberID == "" {
+		return r.Default()
+	}
+	return r.Get(phoneNumberID)
+}
+
+// All returns every registered tenant
+func (r *TenantRegistry) All() []*Tenant {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	tenants := make([]*Tenant, 0, len(r.tenants))
+	for _, t := range r.tenants {
+		tenants = append(tenants, t)
+	}
+	return tenants
+}
+
+// MatchesVerifyToken reports whether token belongs to any tenant
+func (r *TenantRegistry) MatchesVerifyToken(token string) bool {
+	if token == "" {
+		return false
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, t := range r.tenants {
+		if t.VerifyToken == token {
+			return true
+		}
+	}
+	return false
+}
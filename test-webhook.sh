@@ -3,15 +3,33 @@
 # Test webhook script to simulate WhatsApp call events
 
 WEBHOOK_URL="${1:-http://localhost:3000}/whatsapp-call"
+SCRIPT_DIR="$(cd "$(dirname "$0")" && pwd)"
 
 echo "🧪 Testing WhatsApp webhook at: $WEBHOOK_URL"
 echo ""
 
+# post_webhook sends a JSON payload, signing it like Meta does when
+# WHATSAPP_APP_SECRET is set so the bridge's signature check passes (unsigned
+# webhooks are only accepted by a bridge run with WEBHOOK_SKIP_SIGNATURE=true)
+post_webhook() {
+  local body="$1"
+  if [ -n "$WHATSAPP_APP_SECRET" ]; then
+    local sig
+    sig=$(printf '%s' "$body" | openssl dgst -sha256 -hmac "$WHATSAPP_APP_SECRET" | sed 's/^.* //')
+    curl -X POST "$WEBHOOK_URL" \
+      -H "Content-Type: application/json" \
+      -H "X-Hub-Signature-256: sha256=$sig" \
+      -d "$body"
+  else
+    curl -X POST "$WEBHOOK_URL" \
+      -H "Content-Type: application/json" \
+      -d "$body"
+  fi
+}
+
 # Test 1: Simple message webhook
 echo "Test 1: Simple message webhook"
-curl -X POST "$WEBHOOK_URL" \
-  -H "Content-Type: application/json" \
-  -d '{
+post_webhook '{
     "object": "whatsapp_business_account",
     "entry": [{
       "id": "123456789",
@@ -41,9 +59,7 @@ echo -e "\n\n"
 
 # Test 2: Call webhook (USER_INITIATED)
 echo "Test 2: Incoming call webhook"
-curl -X POST "$WEBHOOK_URL" \
-  -H "Content-Type: application/json" \
-  -d '{
+post_webhook '{
     "object": "whatsapp_business_account",
     "entry": [{
       "id": "123456789",
@@ -76,9 +92,7 @@ echo -e "\n\n"
 
 # Test 3: Call status webhook
 echo "Test 3: Call status webhook (ringing)"
-curl -X POST "$WEBHOOK_URL" \
-  -H "Content-Type: application/json" \
-  -d '{
+post_webhook '{
     "object": "whatsapp_business_account",
     "entry": [{
       "id": "123456789",
@@ -102,5 +116,19 @@ curl -X POST "$WEBHOOK_URL" \
     }]
   }'
 
+echo -e "\n\n"
+
+# Test 4: Batched delivery - two entries, several changes and messages.
+# Expect wamid.batch.1, .2 and .3 handled in order and the RINGING status logged.
+echo "Test 4: Batched multi-entry webhook"
+post_webhook "$(cat "$SCRIPT_DIR/testdata/webhook_multi_entry.json")"
+
+echo -e "\n\n"
+
+# Test 5: Meta retry - redeliver the batch from Test 4.
+# Expect every message to be logged as a duplicate (no second AI reply).
+echo "Test 5: Redelivered webhook (dedupe)"
+post_webhook "$(cat "$SCRIPT_DIR/testdata/webhook_multi_entry.json")"
+
 echo -e "\n\n"
 echo "✅ Tests complete. Check your logs!"
\ No newline at end of file
@@ -0,0 +1,495 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Plugin types. An HTTP plugin is any service that lists its tools at
+// GET {url}/tools and runs one at POST {url}/tools/{name}; an MCP plugin is a
+// Model Context Protocol server reached over the streamable HTTP transport.
+const (
+	toolPluginHTTP = "http"
+	toolPluginMCP  = "mcp"
+)
+
+// toolPluginDiscoveryTimeout bounds fetching a plugin's schemas at startup
+const toolPluginDiscoveryTimeout = 10 * time.Second
+
+// mcpProtocolVersion is the MCP revision this client speaks
+const mcpProtocolVersion = "2025-03-26"
+
+// toolPluginConfig is one external tool source in the plugin config file
+type toolPluginConfig struct {
+	Name         string            `json:"name"`
+	Type         string            `json:"type"` // "http" or "mcp"
+	URL          string            `json:"url"`
+	Headers      map[string]string `json:"headers,omitempty"`       // Sent with every request; values may reference environment variables
+	Timeout      string            `json:"timeout,omitempty"`       // Default per-call timeout, e.g. "5s"
+	ToolTimeouts map[string]string `json:"tool_timeouts,omitempty"` // Per-tool overrides of Timeout
+}
+
+// toolPluginConfigFile is the on-disk format of the plugin config
+type toolPluginConfigFile struct {
+	Plugins []toolPluginConfig `json:"plugins"`
+}
+
+// pluginToolSchema is a tool as described by a plugin
+type pluginToolSchema struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	Parameters  map[string]interface{} `json:"parameters"`  // HTTP plugins
+	InputSchema map[string]interface{} `json:"inputSchema"` // MCP servers
+}
+
+// toolPlugin fetches tool schemas from an external source and calls its tools
+type toolPlugin interface {
+	listTools(ctx context.Context) ([]pluginToolSchema, error)
+	callTool(ctx context.Context, caller *ToolCaller, name string, args ToolArgs) (map[string]interface{}, error)
+}
+
+// LoadToolPlugins registers the tools of every plugin in the JSON file at path.
+// A missing file means no plugins. A plugin that can't be reached is logged and
+// skipped so one broken integration doesn't keep the bridge from starting.
+func LoadToolPlugins(registry *ToolRegistry, path string) error {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		slog.Info("ℹ️ Tool plugin config not found, using built-in tools only", "path", path)
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to read tool plugin config %s: %w", path, err)
+	}
+
+	var cfg toolPluginConfigFile
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return fmt.Errorf("failed to parse tool plugin config %s: %w", path, err)
+	}
+
+	for _, pc := range cfg.Plugins {
+		plugin, err := newToolPlugin(pc)
+		if err != nil {
+			return fmt.Errorf("tool plugin %s: %w", pc.Name, err)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), toolPluginDiscoveryTimeout)
+		schemas, err := plugin.listTools(ctx)
+		cancel()
+		if err != nil {
+			slog.Error("❌ Tool plugin unavailable, skipping its tools", "plugin", pc.Name, "error", err)
+			continue
+		}
+
+		registered := 0
+		for _, schema := range schemas {
+			tool, err := pluginTool(pc, plugin, schema)
+			if err != nil {
+				return fmt.Errorf("tool plugin %s: %w", pc.Name, err)
+			}
+			if err := registry.Register(tool); err != nil {
+				slog.Warn("⚠️ Skipping tool plugin tool", "plugin", pc.Name, "error", err)
+				continue
+			}
+			registered++
+		}
+		slog.Info("🧩 Tool plugin registered", "plugin", pc.Name, "type", pc.Type, "tools", registered)
+	}
+	return nil
+}
+
+// newToolPlugin creates the client for one configured plugin
+func newToolPlugin(pc toolPluginConfig) (toolPlugin, error) {
+	if pc.Name == "" {
+		return nil, fmt.Errorf("name is required")
+	}
+	if _, err := url.ParseRequestURI(pc.URL); err != nil {
+		return nil, fmt.Errorf("invalid url %q: %w", pc.URL, err)
+	}
+
+	headers := http.Header{}
+	for name, value := range pc.Headers {
+		headers.Set(name, os.ExpandEnv(value))
+	}
+
+	switch pc.Type {
+	case toolPluginHTTP:
+		return &httpToolPlugin{baseURL: strings.TrimSuffix(pc.URL, "/"), headers: headers}, nil
+	case toolPluginMCP:
+		return &mcpToolPlugin{url: pc.URL, headers: headers}, nil
+	default:
+		return nil, fmt.Errorf("unknown type %q (available: %s, %s)", pc.Type, toolPluginHTTP, toolPluginMCP)
+	}
+}
+
+// pluginTool turns a plugin's schema into a registry tool
+func pluginTool(pc toolPluginConfig, plugin toolPlugin, schema pluginToolSchema) (*Tool, error) {
+	parameters := schema.Parameters
+	if parameters == nil {
+		parameters = schema.InputSchema
+	}
+	if parameters == nil {
+		parameters = map[string]interface{}{}
+	}
+	if _, ok := parameters["type"]; !ok {
+		parameters["type"] = "object"
+	}
+	if _, ok := parameters["properties"]; !ok {
+		parameters["properties"] = map[string]interface{}{}
+	}
+
+	timeout, err := parseToolTimeout(pc.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	if override, ok := pc.ToolTimeouts[schema.Name]; ok {
+		if timeout, err = parseToolTimeout(override); err != nil {
+			return nil, err
+		}
+	}
+
+	name := schema.Name
+	return &Tool{
+		Name:        name,
+		Description: schema.Description,
+		Schema:      parameters,
+		Plugin:      pc.Name,
+		Timeout:     timeout,
+		Handler: func(ctx context.Context, caller *ToolCaller, args ToolArgs) (map[string]interface{}, error) {
+			return plugin.callTool(ctx, caller, name, args)
+		},
+	}, nil
+}
+
+// parseToolTimeout parses a timeout from the config; "" means the registry default
+func parseToolTimeout(value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	timeout, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid timeout %q: %w", value, err)
+	}
+	return timeout, nil
+}
+
+// httpToolPlugin calls tools on a plain HTTP service
+type httpToolPlugin struct {
+	baseURL string
+	headers http.Header
+}
+
+// listTools implements toolPlugin: GET {url}/tools returns {"tools": [...]}
+func (p *httpToolPlugin) listTools(ctx context.Context) ([]pluginToolSchema, error) {
+	var listing struct {
+		Tools []pluginToolSchema `json:"tools"`
+	}
+	if err := p.do(ctx, "GET", p.baseURL+"/tools", nil, &listing); err != nil {
+		return nil, err
+	}
+	return listing.Tools, nil
+}
+
+// callTool implements toolPlugin: POST {url}/tools/{name} with the arguments and
+// who the tool runs for. A JSON object response is the result; anything else is
+// returned under "result".
+func (p *httpToolPlugin) callTool(ctx context.Context, caller *ToolCaller, name string, args ToolArgs) (map[string]interface{}, error) {
+	body := map[string]interface{}{
+		"arguments": args,
+		"caller": map[string]string{
+			"phone_number": caller.PhoneNumber,
+			"tenant":       caller.Tenant.Name,
+			"channel":      caller.Channel,
+		},
+	}
+
+	var result interface{}
+	if err := p.do(ctx, "POST", p.baseURL+"/tools/"+url.PathEscape(name), body, &result); err != nil {
+		return nil, err
+	}
+	if fields, ok := result.(map[string]interface{}); ok {
+		return fields, nil
+	}
+	return map[string]interface{}{"result": result}, nil
+}
+
+// do sends one JSON request and decodes the JSON response into out
+func (p *httpToolPlugin) do(ctx context.Context, method, url string, body interface{}, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		jsonData, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(jsonData)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, reader)
+	if err != nil {
+		return err
+	}
+	for name, values := range p.headers {
+		req.Header[name] = values
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("plugin error: %s - %s", resp.Status, string(respBody))
+	}
+	if err := json.Unmarshal(respBody, out); err != nil {
+		return fmt.Errorf("invalid plugin response: %w", err)
+	}
+	return nil
+}
+
+// mcpToolPlugin calls tools on an MCP server over the streamable HTTP transport
+type mcpToolPlugin struct {
+	url     string
+	headers http.Header
+
+	sessionID   string // Mcp-Session-Id assigned by the server, if any
+	initialized bool
+	nextID      int
+	mu          sync.Mutex // Guards the session fields
+}
+
+// mcpResponse is a JSON-RPC response from an MCP server
+type mcpResponse struct {
+	ID     json.RawMessage `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// listTools implements toolPlugin with tools/list, following pagination
+func (p *mcpToolPlugin) listTools(ctx context.Context) ([]pluginToolSchema, error) {
+	var tools []pluginToolSchema
+	cursor := ""
+	for {
+		params := map[string]interface{}{}
+		if cursor != "" {
+			params["cursor"] = cursor
+		}
+
+		var page struct {
+			Tools      []pluginToolSchema `json:"tools"`
+			NextCursor string             `json:"nextCursor"`
+		}
+		if err := p.request(ctx, "tools/list", params, &page); err != nil {
+			return nil, err
+		}
+		tools = append(tools, page.Tools...)
+		if page.NextCursor == "" {
+			return tools, nil
+		}
+		cursor = page.NextCursor
+	}
+}
+
+// callTool implements toolPlugin with tools/call. Structured content is
+// returned as is; text content is joined under "result".
+func (p *mcpToolPlugin) callTool(ctx context.Context, caller *ToolCaller, name string, args ToolArgs) (map[string]interface{}, error) {
+	var result struct {
+		Content []struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		} `json:"content"`
+		StructuredContent map[string]interface{} `json:"structuredContent"`
+		IsError           bool                   `json:"isError"`
+	}
+	params := map[string]interface{}{
+		"name":      name,
+		"arguments": args,
+	}
+	if err := p.request(ctx, "tools/call", params, &result); err != nil {
+		return nil, err
+	}
+
+	var text []string
+	for _, c := range result.Content {
+		if c.Type == "text" {
+			text = append(text, c.Text)
+		}
+	}
+	if result.IsError {
+		return nil, fmt.Errorf("%s", strings.Join(text, "\n"))
+	}
+	if result.StructuredContent != nil {
+		return result.StructuredContent, nil
+	}
+	return map[string]interface{}{"result": strings.Join(text, "\n")}, nil
+}
+
+// request performs the initialize handshake on first use, then sends one
+// JSON-RPC request and decodes its result into out
+func (p *mcpToolPlugin) request(ctx context.Context, method string, params interface{}, out interface{}) error {
+	p.mu.Lock()
+	initialized := p.initialized
+	p.mu.Unlock()
+
+	if !initialized && method != "initialize" {
+		if err := p.initialize(ctx); err != nil {
+			return fmt.Errorf("MCP initialize failed: %w", err)
+		}
+	}
+
+	p.mu.Lock()
+	p.nextID++
+	id := p.nextID
+	p.mu.Unlock()
+
+	resp, err := p.post(ctx, map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      id,
+		"method":  method,
+		"params":  params,
+	})
+	if err != nil {
+		return err
+	}
+	if resp.Error != nil {
+		return fmt.Errorf("MCP %s error %d: %s", method, resp.Error.Code, resp.Error.Message)
+	}
+	if out != nil {
+		return json.Unmarshal(resp.Result, out)
+	}
+	return nil
+}
+
+// initialize negotiates the protocol version and opens the session
+func (p *mcpToolPlugin) initialize(ctx context.Context) error {
+	params := map[string]interface{}{
+		"protocolVersion": mcpProtocolVersion,
+		"capabilities":    map[string]interface{}{},
+		"clientInfo": map[string]string{
+			"name":    "pion-whatsapp-bridge",
+			"version": "1.0.0",
+		},
+	}
+	if err := p.request(ctx, "initialize", params, nil); err != nil {
+		return err
+	}
+
+	// Notifications have no id and get no response body
+	if _, err := p.post(ctx, map[string]interface{}{
+		"jsonrpc": "2.0",
+		"method":  "notifications/initialized",
+	}); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	p.initialized = true
+	p.mu.Unlock()
+	return nil
+}
+
+// post sends one JSON-RPC message. The server may answer with plain JSON or
+// with an SSE stream carrying the response.
+func (p *mcpToolPlugin) post(ctx context.Context, message map[string]interface{}) (*mcpResponse, error) {
+	jsonData, err := json.Marshal(message)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", p.url, bytes.NewReader(jsonData))
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range p.headers {
+		req.Header[name] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	p.mu.Lock()
+	sessionID := p.sessionID
+	p.mu.Unlock()
+	if sessionID != "" {
+		req.Header.Set("Mcp-Session-Id", sessionID)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	p.mu.Lock()
+	if assigned := resp.Header.Get("Mcp-Session-Id"); assigned != "" {
+		p.sessionID = assigned
+	} else if resp.StatusCode == http.StatusNotFound && sessionID != "" {
+		// The server forgot our session; start a new one on the next request
+		p.sessionID = ""
+		p.initialized = false
+	}
+	p.mu.Unlock()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("MCP server error: %s - %s", resp.Status, string(body))
+	}
+	if _, isRequest := message["id"]; !isRequest {
+		return nil, nil
+	}
+
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		return readMCPEventStream(resp.Body, message["id"])
+	}
+
+	var response mcpResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("invalid MCP response: %w", err)
+	}
+	return &response, nil
+}
+
+// readMCPEventStream returns the response to request id from an SSE stream,
+// skipping any notifications the server sends first
+func readMCPEventStream(body io.Reader, id interface{}) (*mcpResponse, error) {
+	want, _ := json.Marshal(id)
+
+	var data strings.Builder
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "data:") {
+			data.WriteString(strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+			continue
+		}
+		if line != "" || data.Len() == 0 {
+			continue
+		}
+
+		// A blank line ends the event
+		var response mcpResponse
+		if err := json.Unmarshal([]byte(data.String()), &response); err == nil && bytes.Equal(response.ID, want) {
+			return &response, nil
+		}
+		data.Reset()
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, fmt.Errorf("MCP stream ended without a response")
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// writePluginConfig writes a tool plugin config file and returns its path
+func writePluginConfig(t *testing.T, plugins ...toolPluginConfig) string {
+	t.Helper()
+
+	data, err := json.Marshal(toolPluginConfigFile{Plugins: plugins})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "tool_plugins.json")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// TestHTTPToolPlugin loads a plain HTTP plugin, calls one of its tools and
+// checks a per-tool timeout override cuts a slow tool short
+func TestHTTPToolPlugin(t *testing.T) {
+	t.Setenv("TEST_PLUGIN_TOKEN", "plugin-secret")
+
+	var mu sync.Mutex
+	var called map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer plugin-secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.Method == "GET" && r.URL.Path == "/tools":
+			w.Write([]byte(`{"tools": [
+				{"name": "crm_lookup", "description": "Look up a customer", "parameters": {"type": "object", "properties": {"email": {"type": "string"}}}},
+				{"name": "crm_slow_report", "description": "Build a report"}
+			]}`))
+		case r.Method == "POST" && r.URL.Path == "/tools/crm_lookup":
+			mu.Lock()
+			json.NewDecoder(r.Body).Decode(&called)
+			mu.Unlock()
+			w.Write([]byte(`{"customer": "Alice"}`))
+		case r.Method == "POST" && r.URL.Path == "/tools/crm_slow_report":
+			// Drain the body so the server notices the client hanging up
+			io.Copy(io.Discard, r.Body)
+			select {
+			case <-r.Context().Done():
+			case <-time.After(5 * time.Second):
+			}
+			w.Write([]byte(`{"report": "late"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	registry := NewToolRegistry()
+	path := writePluginConfig(t, toolPluginConfig{
+		Name:         "crm",
+		Type:         toolPluginHTTP,
+		URL:          server.URL,
+		Headers:      map[string]string{"Authorization": "Bearer $TEST_PLUGIN_TOKEN"},
+		Timeout:      "5s",
+		ToolTimeouts: map[string]string{"crm_slow_report": "100ms"},
+	})
+	if err := LoadToolPlugins(registry, path); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := registry.byName["crm_lookup"].Timeout; got != 5*time.Second {
+		t.Errorf("crm_lookup timeout = %v, want the plugin's 5s", got)
+	}
+	if got := registry.byName["crm_slow_report"].Timeout; got != 100*time.Millisecond {
+		t.Errorf("crm_slow_report timeout = %v, want the 100ms override", got)
+	}
+
+	caller := &ToolCaller{Tenant: &Tenant{Name: "acme", Tools: []string{"crm"}}, PhoneNumber: "15559876543", Channel: toolChannelVoice}
+	var result map[string]interface{}
+	if err := json.Unmarshal([]byte(registry.Execute(context.Background(), caller, "crm_lookup", `{"email": "alice@example.com"}`)), &result); err != nil {
+		t.Fatal(err)
+	}
+	if result["status"] != "success" || result["customer"] != "Alice" {
+		t.Errorf("crm_lookup result = %v", result)
+	}
+	mu.Lock()
+	args, _ := called["arguments"].(map[string]interface{})
+	who, _ := called["caller"].(map[string]interface{})
+	mu.Unlock()
+	if args["email"] != "alice@example.com" || who["tenant"] != "acme" || who["phone_number"] != "15559876543" || who["channel"] != toolChannelVoice {
+		t.Errorf("plugin received %v", called)
+	}
+
+	start := time.Now()
+	out := registry.Execute(context.Background(), caller, "crm_slow_report", "")
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("slow tool ran for %v despite its 100ms timeout", elapsed)
+	}
+	if !strings.Contains(out, `"status":"error"`) || !strings.Contains(out, "took too long") {
+		t.Errorf("slow tool result = %s, want a timeout error", out)
+	}
+}
+
+// TestMCPToolPlugin loads an MCP server's tools across two pages and calls
+// one, checking the initialize handshake and session header along the way
+func TestMCPToolPlugin(t *testing.T) {
+	const sessionID = "mcp-session-1"
+
+	var mu sync.Mutex
+	var methods []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msg struct {
+			ID     json.RawMessage        `json:"id"`
+			Method string                 `json:"method"`
+			Params map[string]interface{} `json:"params"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		methods = append(methods, msg.Method)
+		mu.Unlock()
+
+		if msg.Method != "initialize" && r.Header.Get("Mcp-Session-Id") != sessionID {
+			http.Error(w, "unknown session", http.StatusNotFound)
+			return
+		}
+		respond := func(result string) {
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprintf(w, `{"jsonrpc": "2.0", "id": %s, "result": %s}`, msg.ID, result)
+		}
+		switch msg.Method {
+		case "initialize":
+			if msg.Params["protocolVersion"] != mcpProtocolVersion {
+				http.Error(w, "unsupported protocol version", http.StatusBadRequest)
+				return
+			}
+			w.Header().Set("Mcp-Session-Id", sessionID)
+			respond(`{"protocolVersion": "` + mcpProtocolVersion + `", "capabilities": {"tools": {}}, "serverInfo": {"name": "calendar"}}`)
+		case "notifications/initialized":
+			w.WriteHeader(http.StatusAccepted)
+		case "tools/list":
+			if msg.Params["cursor"] == nil {
+				respond(`{"tools": [{"name": "calendar_create_event", "description": "Create an event", "inputSchema": {"type": "object", "properties": {"title": {"type": "string"}}}}], "nextCursor": "page-2"}`)
+			} else {
+				respond(`{"tools": [{"name": "calendar_list_events", "description": "List events"}]}`)
+			}
+		case "tools/call":
+			// Answer over SSE, with a progress notification ahead of the response
+			w.Header().Set("Content-Type", "text/event-stream")
+			args, _ := msg.Params["arguments"].(map[string]interface{})
+			fmt.Fprintf(w, "event: message\ndata: {\"jsonrpc\": \"2.0\", \"method\": \"notifications/progress\", \"params\": {}}\n\n")
+			fmt.Fprintf(w, "event: message\ndata: {\"jsonrpc\": \"2.0\", \"id\": %s, \"result\": {\"content\": [{\"type\": \"text\", \"text\": \"Created %s\"}]}}\n\n", msg.ID, args["title"])
+		default:
+			http.Error(w, "unknown method", http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	registry := NewToolRegistry()
+	path := writePluginConfig(t, toolPluginConfig{Name: "calendar", Type: toolPluginMCP, URL: server.URL})
+	if err := LoadToolPlugins(registry, path); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"calendar_create_event", "calendar_list_events"} {
+		if tool := registry.byName[name]; tool == nil || tool.Plugin != "calendar" {
+			t.Fatalf("tool %s not registered from the calendar plugin", name)
+		}
+	}
+
+	caller := &ToolCaller{Tenant: &Tenant{Name: "acme", Tools: []string{"calendar_create_event"}}, Channel: toolChannelText}
+	out := registry.Execute(context.Background(), caller, "calendar_create_event", `{"title": "Dentist"}`)
+	var result map[string]interface{}
+	if err := json.Unmarshal([]byte(out), &result); err != nil {
+		t.Fatal(err)
+	}
+	if result["status"] != "success" || result["result"] != "Created Dentist" {
+		t.Errorf("tools/call result = %v", result)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := []string{"initialize", "notifications/initialized", "tools/list", "tools/list", "tools/call"}
+	if strings.Join(methods, ",") != strings.Join(want, ",") {
+		t.Errorf("MCP methods = %v, want %v", methods, want)
+	}
+}
+
+// TestPluginToolAllowList checks plugin tools are only offered to, and only
+// run for, tenants whose allow-list names the tool or its plugin
+func TestPluginToolAllowList(t *testing.T) {
+	registry := NewToolRegistry()
+	noop := func(ctx context.Context, caller *ToolCaller, args ToolArgs) (map[string]interface{}, error) {
+		return nil, nil
+	}
+	for _, tool := range []*Tool{
+		{Name: "get_tasks", Handler: noop},
+		{Name: "crm_lookup", Plugin: "crm", Handler: noop},
+		{Name: "crm_update", Plugin: "crm", Handler: noop},
+		{Name: "calendar_create_event", Plugin: "calendar", Handler: noop},
+	} {
+		if err := registry.Register(tool); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		tenant *Tenant
+		want   []string
+	}{
+		{"no allow-list", &Tenant{Name: "plain"}, []string{"get_tasks"}},
+		{"wildcard", &Tenant{Name: "all", Tools: []string{"*"}}, []string{"get_tasks", "crm_lookup", "crm_update", "calendar_create_event"}},
+		{"by plugin", &Tenant{Name: "sales", Tools: []string{"crm"}}, []string{"get_tasks", "crm_lookup", "crm_update"}},
+		{"by tool", &Tenant{Name: "support", Tools: []string{"crm_lookup", "calendar_create_event"}}, []string{"get_tasks", "crm_lookup", "calendar_create_event"}},
+		{"no matching entry", &Tenant{Name: "other", Tools: []string{"billing", "crm_delete"}}, []string{"get_tasks"}},
+		{"no tenant", nil, []string{"get_tasks"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			caller := &ToolCaller{Tenant: tt.tenant, Channel: toolChannelVoice}
+
+			var offered []string
+			for _, tool := range registry.toolsFor(caller) {
+				offered = append(offered, tool.Name)
+			}
+			if strings.Join(offered, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("offered %v, want %v", offered, tt.want)
+			}
+
+			for name := range registry.byName {
+				allowed := false
+				for _, w := range tt.want {
+					allowed = allowed || w == name
+				}
+				out := registry.Execute(context.Background(), caller, name, "")
+				if refused := strings.Contains(out, "Unknown function"); refused == allowed {
+					t.Errorf("Execute(%s) = %s, allowed %v", name, out, allowed)
+				}
+			}
+		})
+	}
+}
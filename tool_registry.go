@@ -0,0 +1,304 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Channels a tool can be offered on
+const (
+	toolChannelVoice = "voice"
+	toolChannelText  = "text"
+)
+
+// defaultToolTimeout bounds a tool that doesn't set its own Timeout
+const defaultToolTimeout = 15 * time.Second
+
+// ToolCaller is who a tool runs for: the user, the business number they
+// reached, and what the conversation is happening over
+type ToolCaller struct {
+	Tenant      *Tenant
+	PhoneNumber string
+	Channel     string              // toolChannelVoice or toolChannelText
+	EndCall     func(reason string) // Hangs up the call; nil outside calls
+}
+
+// ToolParam is one argument in a tool's JSON schema. Only string arguments
+// are needed so far.
+type ToolParam struct {
+	Name        string
+	Description string
+	Enum        []string
+	Required    bool
+}
+
+// ToolArgs are the decoded arguments of one tool call
+type ToolArgs map[string]interface{}
+
+// String returns a string argument, or "" when it is missing or null
+func (a ToolArgs) String(name string) string {
+	s, _ := a[name].(string)
+	return s
+}
+
+// ToolHandler runs a tool and returns the fields of its result. The registry
+// adds "status": "success", or turns an error into an error result.
+type ToolHandler func(ctx context.Context, caller *ToolCaller, args ToolArgs) (map[string]interface{}, error)
+
+// Tool is one function the assistant can call, defined once for voice and text
+type Tool struct {
+	Name        string
+	Description string
+	Params      []ToolParam
+	Schema      map[string]interface{} // Raw JSON schema used instead of Params (plugin tools); rendered without strict mode
+	Channels    []string               // Where the tool is offered; empty means everywhere
+	Plugin      string                 // Plugin that provides the tool; tenants must allow it by tool or plugin name
+	Timeout     time.Duration          // Zero means defaultToolTimeout
+	Handler     ToolHandler
+}
+
+// offeredTo reports whether the tool is available to caller
+func (t *Tool) offeredTo(caller *ToolCaller) bool {
+	if t.Plugin != "" && (caller.Tenant == nil || !caller.Tenant.AllowsTool(t.Plugin, t.Name)) {
+		return false
+	}
+	if len(t.Channels) == 0 {
+		return true
+	}
+	for _, channel := range t.Channels {
+		if channel == caller.Channel {
+			return true
+		}
+	}
+	return false
+}
+
+// ToolRegistry holds every tool and dispatches calls from both the Realtime
+// API and the Responses API
+type ToolRegistry struct {
+	tools  []*Tool
+	byName map[string]*Tool
+	mu     sync.RWMutex
+}
+
+// NewToolRegistry creates an empty registry
+func NewToolRegistry() *ToolRegistry {
+	return &ToolRegistry{byName: make(map[string]*Tool)}
+}
+
+// Register adds a tool. Names must be unique.
+func (r *ToolRegistry) Register(tool *Tool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.byName[tool.Name]; exists {
+		return fmt.Errorf("tool %q is already registered", tool.Name)
+	}
+	r.tools = append(r.tools, tool)
+	r.byName[tool.Name] = tool
+	return nil
+}
+
+// toolsFor returns the tools offered to caller in registration order
+func (r *ToolRegistry) toolsFor(caller *ToolCaller) []*Tool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var tools []*Tool
+	for _, tool := range r.tools {
+		if tool.offeredTo(caller) {
+			tools = append(tools, tool)
+		}
+	}
+	return tools
+}
+
+// RealtimeTools renders the caller's tools for a Realtime session: optional
+// arguments are simply left out of "required"
+func (r *ToolRegistry) RealtimeTools(caller *ToolCaller) []map[string]interface{} {
+	var rendered []map[string]interface{}
+	for _, tool := range r.toolsFor(caller) {
+		if tool.Schema != nil {
+			rendered = append(rendered, map[string]interface{}{
+				"type":        "function",
+				"name":        tool.Name,
+				"description": tool.Description,
+				"parameters":  tool.Schema,
+			})
+			continue
+		}
+
+		properties := map[string]interface{}{}
+		required := []string{}
+		for _, p := range tool.Params {
+			property := map[string]interface{}{
+				"type":        "string",
+				"description": p.Description,
+			}
+			if len(p.Enum) > 0 {
+				property["enum"] = p.Enum
+			}
+			properties[p.Name] = property
+			if p.Required {
+				required = append(required, p.Name)
+			}
+		}
+
+		parameters := map[string]interface{}{
+			"type":       "object",
+			"properties": properties,
+		}
+		if len(required) > 0 {
+			parameters["required"] = required
+		}
+		rendered = append(rendered, map[string]interface{}{
+			"type":        "function",
+			"name":        tool.Name,
+			"description": tool.Description,
+			"parameters":  parameters,
+		})
+	}
+	return rendered
+}
+
+// ResponsesTools renders the caller's tools for the Responses API with
+// strict: true, which requires every argument to be listed as required and
+// optional ones to accept null. Tools with a raw schema can't promise that and
+// are sent with strict: false.
+func (r *ToolRegistry) ResponsesTools(caller *ToolCaller) []map[string]interface{} {
+	var rendered []map[string]interface{}
+	for _, tool := range r.toolsFor(caller) {
+		if tool.Schema != nil {
+			rendered = append(rendered, map[string]interface{}{
+				"type":        "function",
+				"name":        tool.Name,
+				"description": tool.Description,
+				"parameters":  tool.Schema,
+				"strict":      false,
+			})
+			continue
+		}
+
+		properties := map[string]interface{}{}
+		required := []string{}
+		for _, p := range tool.Params {
+			property := map[string]interface{}{
+				"type":        "string",
+				"description": p.Description,
+			}
+			if p.Required {
+				if len(p.Enum) > 0 {
+					property["enum"] = p.Enum
+				}
+			} else {
+				property["type"] = []string{"string", "null"}
+				if len(p.Enum) > 0 {
+					enum := make([]interface{}, 0, len(p.Enum)+1)
+					for _, value := range p.Enum {
+						enum = append(enum, value)
+					}
+					property["enum"] = append(enum, nil)
+				}
+			}
+			properties[p.Name] = property
+			required = append(required, p.Name)
+		}
+
+		rendered = append(rendered, map[string]interface{}{
+			"type":        "function",
+			"name":        tool.Name,
+			"description": tool.Description,
+			"parameters": map[string]interface{}{
+				"type":                 "object",
+				"properties":           properties,
+				"required":             required,
+				"additionalProperties": false,
+			},
+			"strict": true,
+		})
+	}
+	return rendered
+}
+
+// Execute runs one tool call and returns its result as the JSON string both
+// APIs expect. Failures come back as {"status": "error", "message": ...} so the
+// model can tell the user what went wrong.
+func (r *ToolRegistry) Execute(ctx context.Context, caller *ToolCaller, name, arguments string) string {
+	r.mu.RLock()
+	tool, ok := r.byName[name]
+	r.mu.RUnlock()
+
+	logger := loggerFrom(ctx).With("tool", name, "channel", caller.Channel)
+	if !ok || !tool.offeredTo(caller) {
+		logger.Warn("⚠️ Unknown function")
+		return toolError(fmt.Errorf("Unknown function: %s", name))
+	}
+
+	args := ToolArgs{}
+	if arguments != "" {
+		if err := json.Unmarshal([]byte(arguments), &args); err != nil {
+			logger.Error("❌ Failed to parse tool arguments", "error", err)
+			logger.Debug("❌ Unparseable tool arguments", "arguments", arguments)
+			return toolError(fmt.Errorf("Invalid arguments"))
+		}
+	}
+
+	timeout := tool.Timeout
+	if timeout == 0 {
+		timeout = defaultToolTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	logger.Info("🔧 Running tool", "phone", caller.PhoneNumber)
+	logger.Debug("🔧 Tool arguments", "arguments", arguments)
+
+	type outcome struct {
+		result map[string]interface{}
+		err    error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		result, err := tool.Handler(ctx, caller, args)
+		done <- outcome{result, err}
+	}()
+
+	var result map[string]interface{}
+	select {
+	case o := <-done:
+		if o.err != nil {
+			logger.Error("❌ Tool failed", "error", o.err)
+			return toolError(o.err)
+		}
+		result = o.result
+	case <-ctx.Done():
+		logger.Warn("⏱️ Tool timed out", "timeout", timeout)
+		return toolError(fmt.Errorf("%s took too long to respond", name))
+	}
+
+	if result == nil {
+		result = map[string]interface{}{}
+	}
+	if _, ok := result["status"]; !ok {
+		result["status"] = "success"
+	}
+	resultJSON, err := json.Marshal(result)
+	if err != nil {
+		return toolError(err)
+	}
+	logger.Info("✅ Tool done")
+	logger.Debug("✅ Tool result", "result", string(resultJSON))
+	return string(resultJSON)
+}
+
+// toolError renders an error result
+func toolError(err error) string {
+	resultJSON, _ := json.Marshal(map[string]string{
+		"status":  "error",
+		"message": err.Error(),
+	})
+	return string(resultJSON)
+}
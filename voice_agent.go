@@ -0,0 +1,235 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v4"
+)
+
+// VoiceAgent is a realtime speech backend that talks to the WhatsApp user.
+// The bridge feeds it the user's Opus RTP and forwards whatever it plays back,
+// so a backend never touches the WhatsApp peer connection itself.
+type VoiceAgent interface {
+	// Name identifies the backend in logs and the /calls API
+	Name() string
+
+	// Connect opens the session; audio can flow once it returns
+	Connect() error
+
+	// WriteRTP sends one RTP packet of the user's Opus audio to the agent
+	WriteRTP(packet []byte) error
+
+	// AudioOutput returns the agent's Opus RTP audio for the user, or nil
+	// until the backend has it ready
+	AudioOutput() RTPSource
+
+	// InjectText asks the agent to say or respond to text (e.g. "greet the user")
+	InjectText(text string) error
+
+	// SendToolResult returns the output of a tool call the agent requested
+	SendToolResult(toolCallID, output string) error
+
+	// Close ends the session and releases its connections
+	Close()
+}
+
+// RTPSource is a stream of Opus RTP packets. *webrtc.TrackRemote satisfies it
+// through trackRTPSource.
+type RTPSource interface {
+	ReadRTP() (*rtp.Packet, error)
+}
+
+// trackRTPSource adapts a remote WebRTC track to RTPSource
+type trackRTPSource struct {
+	track *webrtc.TrackRemote
+}
+
+// ReadRTP implements RTPSource
+func (s trackRTPSource) ReadRTP() (*rtp.Packet, error) {
+	packet, _, err := s.track.ReadRTP()
+	return packet, err
+}
+
+// VoiceAgentConfig is what a backend needs to start a session for one call
+type VoiceAgentConfig struct {
+	Tenant         *Tenant
+	CallID         string
+	PhoneNumber    string // The WhatsApp user on the call
+	ReminderText   string // Set for reminder calls
+	Recorded       bool   // The call is recorded; the agent must open with the tenant's recording disclosure
+	API            *webrtc.API
+	OnEndCall      func(reason string) // Hangs up the call when the agent decides it is over
+	OnSenderRTCP   func(packet []byte) // Optional: receives the agent's feedback on the audio we send it, for packet capture
+	OnReceiverRTCP func(packet []byte) // Optional: receives the agent's sender reports, for packet capture
+}
+
+// VoiceAgentFactory creates a backend session for one call
+type VoiceAgentFactory func(cfg VoiceAgentConfig) (VoiceAgent, error)
+
+// voiceAgentBackends are the backends selectable with VOICE_AGENT, a tenant's
+// voice_agent setting or a per-call override
+var voiceAgentBackends = map[string]VoiceAgentFactory{
+	"openai": newOpenAIVoiceAgent,
+	"echo":   newEchoVoiceAgent,
+}
+
+// voiceAgentNone disables the voice agent for a tenant or call
+const voiceAgentNone = "none"
+
+// voiceAgentBackend picks the backend for a call: the per-call override, then
+// the tenant's setting, then VOICE_AGENT. Without any of those it falls back
+// to echo when ENABLE_ECHO=true and OpenAI when AZURE_OPENAI_API_KEY is set
+// or the fake Realtime endpoint is running.
+// It returns "" when the call should have no agent.
+func voiceAgentBackend(tenant *Tenant, override string) string {
+	name := override
+	if name == "" && tenant != nil {
+		name = tenant.VoiceAgent
+	}
+	if name == "" {
+		name = os.Getenv("VOICE_AGENT")
+	}
+	if name == "" {
+		if os.Getenv("ENABLE_ECHO") == "true" {
+			name = "echo"
+		} else if os.Getenv("AZURE_OPENAI_API_KEY") != "" || fakeRealtime != nil {
+			name = "openai"
+		}
+	}
+	if name == voiceAgentNone {
+		return ""
+	}
+	return name
+}
+
+// validateVoiceAgent reports an error for a backend name that isn't registered
+func validateVoiceAgent(name string) error {
+	if name == "" || name == voiceAgentNone {
+		return nil
+	}
+	if _, ok := voiceAgentBackends[name]; !ok {
+		names := make([]string, 0, len(voiceAgentBackends))
+		for n := range voiceAgentBackends {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("unknown voice agent %q (available: %s, %s)", name, strings.Join(names, ", "), voiceAgentNone)
+	}
+	return nil
+}
+
+// newVoiceAgent creates a session on the named backend
+func newVoiceAgent(name string, cfg VoiceAgentConfig) (VoiceAgent, error) {
+	if err := validateVoiceAgent(name); err != nil {
+		return nil, err
+	}
+	return voiceAgentBackends[name](cfg)
+}
+
+// newOpenAIVoiceAgent creates an Azure/OpenAI Realtime session over the
+// transport selected by OPENAI_REALTIME_TRANSPORT (WebRTC by default)
+func newOpenAIVoiceAgent(cfg VoiceAgentConfig) (VoiceAgent, error) {
+	apiKey := os.Getenv("AZURE_OPENAI_API_KEY")
+	if apiKey == "" && fakeRealtime != nil {
+		apiKey = "fake"
+	}
+	if apiKey == "" {
+		return nil, fmt.Errorf("AZURE_OPENAI_API_KEY not set")
+	}
+	transport, err := realtimeTransportFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	client := NewOpenAIRealtimeClient(cfg.Tenant, apiKey, cfg.PhoneNumber, cfg.ReminderText)
+	client.transport = transport
+	client.api = cfg.API
+	client.onEndCall = cfg.OnEndCall
+	client.onSenderRTCP = cfg.OnSenderRTCP
+	client.onReceiverRTCP = cfg.OnReceiverRTCP
+	client.callID = cfg.CallID
+	client.recorded = cfg.Recorded
+	if cfg.CallID != "" {
+		client.transcript = newCallTranscript(cfg.Tenant.SupabaseSchema, cfg.CallID, cfg.PhoneNumber)
+	}
+	return client, nil
+}
+
+// EchoVoiceAgent plays the user's own audio back to them. It needs no
+// credentials, which makes it a handy stand-in for testing call plumbing.
+type EchoVoiceAgent struct {
+	callID    string
+	packets   chan *rtp.Packet
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+// newEchoVoiceAgent creates an echo session
+func newEchoVoiceAgent(cfg VoiceAgentConfig) (VoiceAgent, error) {
+	return &EchoVoiceAgent{
+		callID:  cfg.CallID,
+		packets: make(chan *rtp.Packet, 50), // ~1s of 20ms frames
+		closed:  make(chan struct{}),
+	}, nil
+}
+
+// Name implements VoiceAgent
+func (e *EchoVoiceAgent) Name() string { return "echo" }
+
+// Connect implements VoiceAgent
+func (e *EchoVoiceAgent) Connect() error {
+	callLogger(e.callID).Info("🔁 Echo agent ready")
+	return nil
+}
+
+// WriteRTP implements VoiceAgent. Packets are dropped rather than queued
+// without bound if the playback side falls behind.
+func (e *EchoVoiceAgent) WriteRTP(packet []byte) error {
+	p := &rtp.Packet{}
+	if err := p.Unmarshal(packet); err != nil {
+		return err
+	}
+
+	select {
+	case <-e.closed:
+		return io.ErrClosedPipe
+	case e.packets <- p:
+	default:
+	}
+	return nil
+}
+
+// AudioOutput implements VoiceAgent
+func (e *EchoVoiceAgent) AudioOutput() RTPSource { return e }
+
+// ReadRTP implements RTPSource
+func (e *EchoVoiceAgent) ReadRTP() (*rtp.Packet, error) {
+	select {
+	case <-e.closed:
+		return nil, io.EOF
+	case p := <-e.packets:
+		return p, nil
+	}
+}
+
+// InjectText implements VoiceAgent; echo has nothing to say
+func (e *EchoVoiceAgent) InjectText(text string) error {
+	callLogger(e.callID).Debug("🔁 Echo agent ignoring text", "text", text)
+	return nil
+}
+
+// SendToolResult implements VoiceAgent; echo never calls tools
+func (e *EchoVoiceAgent) SendToolResult(toolCallID, output string) error {
+	return nil
+}
+
+// Close implements VoiceAgent
+func (e *EchoVoiceAgent) Close() {
+	e.closeOnce.Do(func() { close(e.closed) })
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Defaults for the webhook archive; override with WEBHOOK_ARCHIVE_MAX_BYTES
+// and WEBHOOK_ARCHIVE_MAX_FILES
+const (
+	defaultWebhookArchiveMaxBytes = 64 << 20
+	defaultWebhookArchiveMaxFiles = 20
+	webhookArchivePrefix          = "webhooks-"
+	webhookArchiveSuffix          = ".jsonl"
+)
+
+// CapturedWebhook is one raw webhook request as it reached the bridge. Body
+// is kept as a string so the bytes - and so the signature - survive exactly.
+type CapturedWebhook struct {
+	ReceivedAt time.Time   `json:"received_at"`
+	Method     string      `json:"method"`
+	Path       string      `json:"path"`
+	RemoteAddr string      `json:"remote_addr"`
+	Headers    http.Header `json:"headers"`
+	Body       string      `json:"body"`
+}
+
+// WebhookArchive appends every webhook request to JSONL files in dir, one
+// capture per line. A new file is started once the current one passes
+// maxBytes, and the oldest files are deleted beyond maxFiles. cmd/webhook-replay
+// posts the captures back at a bridge.
+type WebhookArchive struct {
+	dir      string
+	maxBytes int64
+	maxFiles int
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+// NewWebhookArchiveFromEnv opens the archive in WEBHOOK_ARCHIVE_DIR, or
+// returns nil when capture is disabled
+func NewWebhookArchiveFromEnv() (*WebhookArchive, error) {
+	dir := os.Getenv("WEBHOOK_ARCHIVE_DIR")
+	if dir == "" {
+		return nil, nil
+	}
+	maxBytes := envInt("WEBHOOK_ARCHIVE_MAX_BYTES", defaultWebhookArchiveMaxBytes)
+	maxFiles := envInt("WEBHOOK_ARCHIVE_MAX_FILES", defaultWebhookArchiveMaxFiles)
+	return NewWebhookArchive(dir, int64(maxBytes), maxFiles)
+}
+
+// NewWebhookArchive creates an archive rooted at dir. Captures hold raw
+// headers and bodies, so the directory and files are private to the bridge.
+func NewWebhookArchive(dir string, maxBytes int64, maxFiles int) (*WebhookArchive, error) {
+	if maxBytes <= 0 {
+		return nil, fmt.Errorf("webhook archive max bytes must be positive, got %d", maxBytes)
+	}
+	if maxFiles < 1 {
+		return nil, fmt.Errorf("webhook archive must keep at least 1 file, got %d", maxFiles)
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create webhook archive dir %s: %w", dir, err)
+	}
+	slog.Info("🗄️ Capturing raw webhooks", "dir", dir, "max_files", maxFiles, "max_bytes", maxBytes)
+	return &WebhookArchive{dir: dir, maxBytes: maxBytes, maxFiles: maxFiles}, nil
+}
+
+// Capture appends one request. Failures are logged rather than returned so
+// the archive can never cost us a webhook.
+func (a *WebhookArchive) Capture(r *http.Request, body []byte, receivedAt time.Time) {
+	line, err := json.Marshal(CapturedWebhook{
+		ReceivedAt: receivedAt.UTC(),
+		Method:     r.Method,
+		Path:       r.URL.RequestURI(),
+		RemoteAddr: r.RemoteAddr,
+		Headers:    r.Header,
+		Body:       string(body),
+	})
+	if err != nil {
+		loggerFrom(r.Context()).Error("❌ Failed to encode webhook capture", "error", err)
+		return
+	}
+	line = append(line, '\n')
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.file == nil || a.size+int64(len(line)) > a.maxBytes {
+		if err := a.rotate(receivedAt); err != nil {
+			loggerFrom(r.Context()).Error("❌ Failed to rotate webhook archive", "error", err)
+			return
+		}
+	}
+	n, err := a.file.Write(line)
+	a.size += int64(n)
+	if err != nil {
+		loggerFrom(r.Context()).Error("❌ Failed to write webhook capture", "error", err)
+	}
+}
+
+// rotate starts a new file and prunes the oldest ones. Names sort by time.
+func (a *WebhookArchive) rotate(now time.Time) error {
+	if a.file != nil {
+		a.file.Close()
+		a.file = nil
+	}
+
+	name := webhookArchivePrefix + now.UTC().Format("20060102T150405.000000000Z") + webhookArchiveSuffix
+	file, err := os.OpenFile(filepath.Join(a.dir, name), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	a.file = file
+	a.size = 0
+
+	files, err := a.files()
+	if err != nil {
+		return err
+	}
+	for len(files) > a.maxFiles {
+		if err := os.Remove(files[0]); err != nil {
+			return err
+		}
+		files = files[1:]
+	}
+	return nil
+}
+
+// files lists the archive's files, oldest first
+func (a *WebhookArchive) files() ([]string, error) {
+	entries, err := os.ReadDir(a.dir)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasPrefix(name, webhookArchivePrefix) && strings.HasSuffix(name, webhookArchiveSuffix) {
+			files = append(files, filepath.Join(a.dir, name))
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// Close closes the current file
+func (a *WebhookArchive) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.file == nil {
+		return nil
+	}
+	err := a.file.Close()
+	a.file = nil
+	return err
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewWebhookArchiveLimits(t *testing.T) {
+	tests := []struct {
+		name     string
+		maxBytes int64
+		maxFiles int
+		wantErr  bool
+	}{
+		{"defaults", defaultWebhookArchiveMaxBytes, defaultWebhookArchiveMaxFiles, false},
+		{"one file", 1, 1, false},
+		{"no files", 1024, 0, true},
+		{"negative files", 1024, -1, true},
+		{"no bytes", 0, 5, true},
+		{"negative bytes", -1, 5, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			archive, err := NewWebhookArchive(t.TempDir(), tt.maxBytes, tt.maxFiles)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewWebhookArchive(%d, %d) error = %v, want error %v", tt.maxBytes, tt.maxFiles, err, tt.wantErr)
+			}
+			if archive != nil {
+				archive.Close()
+			}
+		})
+	}
+}
+
+// TestWebhookArchiveRotation keeps a single private file that rotates on
+// every capture, so the newest capture is always the one left
+func TestWebhookArchiveRotation(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "archive")
+	archive, err := NewWebhookArchive(dir, 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer archive.Close()
+
+	start := time.Now()
+	for i, body := range []string{`{"n":1}`, `{"n":2}`, `{"n":3}`} {
+		r := httptest.NewRequest("POST", "/webhook", strings.NewReader(body))
+		archive.Capture(r, []byte(body), start.Add(time.Duration(i)*time.Millisecond))
+	}
+
+	files, err := archive.files()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Fatalf("archive holds %d files, want 1: %v", len(files), files)
+	}
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `{\"n\":3}`) {
+		t.Errorf("kept file is not the newest capture: %s", data)
+	}
+
+	for path, want := range map[string]os.FileMode{dir: 0o700, files[0]: 0o600} {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := info.Mode().Perm(); got != want {
+			t.Errorf("%s mode = %v, want %v", path, got, want)
+		}
+	}
+}
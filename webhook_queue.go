@@ -0,0 +1,477 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Defaults for the webhook queue; override with WEBHOOK_QUEUE_DIR,
+// WEBHOOK_WORKERS and WEBHOOK_MAX_ATTEMPTS
+const (
+	defaultWebhookQueueDir    = "data/webhook-queue"
+	defaultWebhookWorkers     = 4
+	defaultWebhookMaxAttempts = 8
+	webhookRetryBaseDelay     = 2 * time.Second
+	webhookRetryMaxDelay      = 5 * time.Minute
+)
+
+// WebhookJob is one raw webhook delivery persisted on disk until it has been processed
+type WebhookJob struct {
+	ID            string          `json:"id"`
+	Body          json.RawMessage `json:"body"`
+	ReceivedAt    time.Time       `json:"received_at"`
+	Attempts      int             `json:"attempts"`
+	NextAttemptAt time.Time       `json:"next_attempt_at"`
+	LastError     string          `json:"last_error,omitempty"`
+	DeadAt        *time.Time      `json:"dead_at,omitempty"`
+
+	orderKeys []string // Calls and phone numbers the body touches, from the queue's WebhookOrderKeys
+}
+
+// WebhookJobHandler processes one webhook body. ctx carries a logger tagged
+// with the job ID and attempt. Returning an error schedules a retry.
+type WebhookJobHandler func(ctx context.Context, body []byte) error
+
+// WebhookOrderKeys names what a webhook body is about, e.g. the calls and
+// phone numbers it carries events for. Jobs sharing a key run one at a time
+// in arrival order.
+type WebhookOrderKeys func(body []byte) []string
+
+// WebhookQueue is an embedded, file-backed job queue for inbound webhooks.
+// Every delivery is written to <dir>/pending before Meta gets its 200, so a
+// crash or deploy never loses an event: pending jobs are picked up again on
+// the next start. Failed jobs are retried with exponential backoff and moved
+// to <dir>/dead after maxAttempts, where they can be inspected and replayed.
+//
+// Workers run in parallel, but a job only starts once every older job that
+// shares one of its order keys has finished (including its retries), so a
+// call's connect is always handled before its terminate. Handlers that hand
+// work to a goroutine must first do whatever a later event for the same key
+// relies on; the call handlers reserve the call before returning.
+type WebhookQueue struct {
+	pendingDir  string
+	deadDir     string
+	workers     int
+	maxAttempts int
+	handler     WebhookJobHandler
+	orderKeys   WebhookOrderKeys
+
+	jobs     map[string]*WebhookJob // Pending jobs by ID
+	inFlight map[string]bool
+	work     chan *WebhookJob
+	wake     chan struct{}
+	mu       sync.Mutex
+
+	processed int64
+	retried   int64
+	dead      int64
+}
+
+// NewWebhookQueueFromEnv creates a queue configured from environment variables
+func NewWebhookQueueFromEnv(handler WebhookJobHandler, orderKeys WebhookOrderKeys) (*WebhookQueue, error) {
+	dir := os.Getenv("WEBHOOK_QUEUE_DIR")
+	if dir == "" {
+		dir = defaultWebhookQueueDir
+	}
+	workers := envInt("WEBHOOK_WORKERS", defaultWebhookWorkers)
+	maxAttempts := envInt("WEBHOOK_MAX_ATTEMPTS", defaultWebhookMaxAttempts)
+
+	return NewWebhookQueue(dir, workers, maxAttempts, handler, orderKeys)
+}
+
+// envInt reads a positive integer environment variable, falling back to def
+func envInt(name string, def int) int {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n <= 0 {
+		slog.Warn("⚠️ Invalid setting, using default", "name", name, "value", v, "default", def)
+		return def
+	}
+	return n
+}
+
+// NewWebhookQueue creates a queue rooted at dir and loads any jobs left over from a previous run
+func NewWebhookQueue(dir string, workers, maxAttempts int, handler WebhookJobHandler, orderKeys WebhookOrderKeys) (*WebhookQueue, error) {
+	q := &WebhookQueue{
+		pendingDir:  filepath.Join(dir, "pending"),
+		deadDir:     filepath.Join(dir, "dead"),
+		workers:     workers,
+		maxAttempts: maxAttempts,
+		handler:     handler,
+		orderKeys:   orderKeys,
+		jobs:        make(map[string]*WebhookJob),
+		inFlight:    make(map[string]bool),
+		work:        make(chan *WebhookJob),
+		wake:        make(chan struct{}, 1),
+	}
+
+	for _, d := range []string{q.pendingDir, q.deadDir} {
+		if err := os.MkdirAll(d, 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create webhook queue dir %s: %w", d, err)
+		}
+	}
+
+	recovered, err := readJobs(q.pendingDir)
+	if err != nil {
+		return nil, err
+	}
+	for _, job := range recovered {
+		q.keyJob(job)
+		q.jobs[job.ID] = job
+	}
+	if len(recovered) > 0 {
+		slog.Info("📥 Recovered pending webhooks", "count", len(recovered), "dir", q.pendingDir)
+	}
+
+	return q, nil
+}
+
+// Start launches the dispatcher and worker goroutines
+func (q *WebhookQueue) Start() {
+	for i := 0; i < q.workers; i++ {
+		go q.worker()
+	}
+	go q.dispatch()
+	q.notify()
+}
+
+// Enqueue persists a webhook body and schedules it for processing.
+// When it returns nil the event is durable and it is safe to acknowledge Meta.
+func (q *WebhookQueue) Enqueue(body []byte) (*WebhookJob, error) {
+	now := time.Now()
+	job := &WebhookJob{
+		ID:            newJobID(now),
+		Body:          json.RawMessage(body),
+		ReceivedAt:    now,
+		NextAttemptAt: now,
+	}
+	q.keyJob(job)
+
+	if err := writeJob(q.pendingDir, job); err != nil {
+		return nil, err
+	}
+
+	q.mu.Lock()
+	q.jobs[job.ID] = job
+	q.mu.Unlock()
+
+	q.notify()
+	return job, nil
+}
+
+// keyJob works out the job's order keys
+func (q *WebhookQueue) keyJob(job *WebhookJob) {
+	if q.orderKeys != nil {
+		job.orderKeys = q.orderKeys(job.Body)
+	}
+}
+
+// notify wakes the dispatcher without blocking
+func (q *WebhookQueue) notify() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// dispatch hands due jobs to the workers, oldest first, and sleeps until the next retry is due
+func (q *WebhookQueue) dispatch() {
+	timer := time.NewTimer(time.Hour)
+	for {
+		job, wait := q.nextDue()
+		if job != nil {
+			q.work <- job
+			continue
+		}
+
+		timer.Reset(wait)
+		select {
+		case <-q.wake:
+		case <-timer.C:
+		}
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+	}
+}
+
+// nextDue returns the oldest due job whose order keys aren't held by an older
+// job (marking it in flight), or how long to wait for one
+func (q *WebhookQueue) nextDue() (*WebhookJob, time.Duration) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	jobs := make([]*WebhookJob, 0, len(q.jobs))
+	for _, job := range q.jobs {
+		jobs = append(jobs, job)
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].ID < jobs[j].ID })
+
+	now := time.Now()
+	wait := time.Hour
+	held := make(map[string]bool) // Keys of older jobs that are still pending
+	for _, job := range jobs {
+		blocked := false
+		for _, key := range job.orderKeys {
+			blocked = blocked || held[key]
+			held[key] = true
+		}
+		if blocked || q.inFlight[job.ID] {
+			continue
+		}
+		if job.NextAttemptAt.After(now) {
+			if d := job.NextAttemptAt.Sub(now); d < wait {
+				wait = d
+			}
+			continue
+		}
+		q.inFlight[job.ID] = true
+		return job, wait
+	}
+	return nil, wait
+}
+
+// worker processes jobs until the process exits
+func (q *WebhookQueue) worker() {
+	for job := range q.work {
+		err := q.run(job)
+		q.finish(job, err)
+	}
+}
+
+// run calls the handler, turning a panic into an error so the job is retried
+func (q *WebhookQueue) run(job *WebhookJob) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	logger := slog.With("webhook_job", job.ID, "attempt", job.Attempts+1)
+	return q.handler(withLogger(context.Background(), logger), job.Body)
+}
+
+// finish records the outcome of one attempt
+func (q *WebhookQueue) finish(job *WebhookJob, err error) {
+	q.mu.Lock()
+	defer func() {
+		delete(q.inFlight, job.ID)
+		q.mu.Unlock()
+		q.notify()
+	}()
+
+	job.Attempts++
+	logger := slog.With("webhook_job", job.ID, "attempt", job.Attempts)
+
+	if err == nil {
+		delete(q.jobs, job.ID)
+		q.processed++
+		if rmErr := os.Remove(jobPath(q.pendingDir, job.ID)); rmErr != nil && !os.IsNotExist(rmErr) {
+			logger.Warn("⚠️ Failed to remove processed webhook", "error", rmErr)
+		}
+		return
+	}
+
+	job.LastError = err.Error()
+
+	if job.Attempts >= q.maxAttempts {
+		now := time.Now()
+		job.DeadAt = &now
+		delete(q.jobs, job.ID)
+		q.dead++
+		if wErr := writeJob(q.deadDir, job); wErr != nil {
+			logger.Error("❌ Failed to dead-letter webhook", "error", wErr)
+			return
+		}
+		os.Remove(jobPath(q.pendingDir, job.ID))
+		logger.Error("☠️ Webhook dead-lettered", "attempts", job.Attempts, "error", err)
+		return
+	}
+
+	delay := webhookRetryDelay(job.Attempts)
+	job.NextAttemptAt = time.Now().Add(delay)
+	q.retried++
+	if wErr := writeJob(q.pendingDir, job); wErr != nil {
+		logger.Warn("⚠️ Failed to persist webhook retry", "error", wErr)
+	}
+	logger.Warn("🔁 Webhook failed, retrying", "max_attempts", q.maxAttempts, "delay", delay, "error", err)
+}
+
+// webhookRetryDelay is the exponential backoff after the given number of failed attempts
+func webhookRetryDelay(attempts int) time.Duration {
+	delay := webhookRetryBaseDelay
+	for i := 1; i < attempts && delay < webhookRetryMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > webhookRetryMaxDelay {
+		delay = webhookRetryMaxDelay
+	}
+	return delay
+}
+
+// DeadLetters returns every dead-lettered job, oldest first
+func (q *WebhookQueue) DeadLetters() ([]*WebhookJob, error) {
+	return readJobs(q.deadDir)
+}
+
+// Replay moves a dead-lettered job back to the pending queue with a fresh retry budget
+func (q *WebhookQueue) Replay(id string) error {
+	if !validJobID(id) {
+		return fmt.Errorf("invalid job id %q", id)
+	}
+
+	data, err := os.ReadFile(jobPath(q.deadDir, id))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("dead-lettered webhook %s not found", id)
+		}
+		return err
+	}
+
+	var job WebhookJob
+	if err := json.Unmarshal(data, &job); err != nil {
+		return fmt.Errorf("failed to parse dead-lettered webhook %s: %w", id, err)
+	}
+	job.Attempts = 0
+	job.DeadAt = nil
+	job.NextAttemptAt = time.Now()
+	q.keyJob(&job)
+
+	if err := writeJob(q.pendingDir, &job); err != nil {
+		return err
+	}
+	if err := os.Remove(jobPath(q.deadDir, id)); err != nil {
+		return err
+	}
+
+	q.mu.Lock()
+	q.jobs[job.ID] = &job
+	q.mu.Unlock()
+
+	q.notify()
+	slog.Info("♻️ Replaying dead-lettered webhook", "webhook_job", id)
+	return nil
+}
+
+// Stats returns queue counters for /status
+func (q *WebhookQueue) Stats() map[string]interface{} {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	deadCount := 0
+	if entries, err := os.ReadDir(q.deadDir); err == nil {
+		for _, e := range entries {
+			if strings.HasSuffix(e.Name(), ".json") {
+				deadCount++
+			}
+		}
+	}
+
+	return map[string]interface{}{
+		"pending":       len(q.jobs),
+		"in_flight":     len(q.inFlight),
+		"dead_letters":  deadCount,
+		"processed":     q.processed,
+		"retried":       q.retried,
+		"dead_lettered": q.dead,
+		"workers":       q.workers,
+		"max_attempts":  q.maxAttempts,
+	}
+}
+
+// newJobID returns a sortable unique job ID (arrival time + random suffix)
+func newJobID(t time.Time) string {
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	return fmt.Sprintf("%019d-%s", t.UnixNano(), hex.EncodeToString(suffix))
+}
+
+// validJobID guards file paths built from admin-supplied IDs
+func validJobID(id string) bool {
+	if id == "" {
+		return false
+	}
+	for _, r := range id {
+		if !(r >= '0' && r <= '9' || r >= 'a' && r <= 'f' || r == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+// jobPath is the file a job is stored in
+func jobPath(dir, id string) string {
+	return filepath.Join(dir, id+".json")
+}
+
+// writeJob atomically writes a job file (temp file + fsync + rename)
+func writeJob(dir string, job *WebhookJob) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return fmt.Errorf("failed to marshal webhook job: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(dir, ".tmp-"+job.ID+"-*")
+	if err != nil {
+		return fmt.Errorf("failed to create webhook job file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write webhook job: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to sync webhook job: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), jobPath(dir, job.ID))
+}
+
+// readJobs loads every job file in dir, oldest first
+func readJobs(dir string) ([]*WebhookJob, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read webhook queue dir %s: %w", dir, err)
+	}
+
+	var jobs []*WebhookJob
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			slog.Warn("⚠️ Failed to read webhook job", "file", e.Name(), "error", err)
+			continue
+		}
+		var job WebhookJob
+		if err := json.Unmarshal(data, &job); err != nil {
+			slog.Warn("⚠️ Skipping corrupt webhook job", "file", e.Name(), "error", err)
+			continue
+		}
+		jobs = append(jobs, &job)
+	}
+
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].ID < jobs[j].ID })
+	return jobs, nil
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Test job bodies are JSON strings such as "call:1:connect"; the key is
+// everything before the event name
+func queueTestKeys(body []byte) []string {
+	name := queueTestName(body)
+	return []string{name[:strings.LastIndex(name, ":")]}
+}
+
+func queueTestName(body []byte) string {
+	return strings.Trim(string(body), `"`)
+}
+
+// queueRecorder records when each job's handler starts and finishes
+type queueRecorder struct {
+	mu     sync.Mutex
+	events []string
+	done   chan string
+}
+
+func (r *queueRecorder) record(event string) {
+	r.mu.Lock()
+	r.events = append(r.events, event)
+	r.mu.Unlock()
+}
+
+func (r *queueRecorder) index(event string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, e := range r.events {
+		if e == event {
+			return i
+		}
+	}
+	return -1
+}
+
+// waitDone waits for n jobs to finish successfully
+func (r *queueRecorder) waitDone(t *testing.T, n int, timeout time.Duration) {
+	t.Helper()
+	deadline := time.After(timeout)
+	for i := 0; i < n; i++ {
+		select {
+		case <-r.done:
+		case <-deadline:
+			t.Fatalf("timed out after %d of %d jobs: %v", i, n, r.events)
+		}
+	}
+}
+
+// TestWebhookQueueOrdersJobsByKey runs a slow job and checks a later job for
+// the same call waits for it while a job for another call runs alongside it
+func TestWebhookQueueOrdersJobsByKey(t *testing.T) {
+	rec := &queueRecorder{done: make(chan string, 10)}
+	release := make(chan struct{})
+	handler := func(ctx context.Context, body []byte) error {
+		name := queueTestName(body)
+		rec.record("start " + name)
+		if name == "call:1:connect" {
+			<-release
+		}
+		rec.record("end " + name)
+		rec.done <- name
+		return nil
+	}
+
+	q, err := NewWebhookQueue(filepath.Join(t.TempDir(), "queue"), 4, 3, handler, queueTestKeys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q.Start()
+	for _, body := range []string{"call:1:connect", "call:1:terminate", "call:2:connect"} {
+		if _, err := q.Enqueue([]byte(`"` + body + `"`)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The other call isn't held up by the slow connect
+	rec.waitDone(t, 1, 5*time.Second)
+	if rec.index("end call:2:connect") < 0 {
+		t.Fatalf("call 2 didn't run while call 1's connect was in flight: %v", rec.events)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if rec.index("start call:1:terminate") >= 0 {
+		t.Fatalf("call 1's terminate started before its connect finished: %v", rec.events)
+	}
+
+	close(release)
+	rec.waitDone(t, 2, 5*time.Second)
+	if rec.index("start call:1:terminate") < rec.index("end call:1:connect") {
+		t.Errorf("call 1's terminate ran before its connect finished: %v", rec.events)
+	}
+}
+
+// TestWebhookQueueRetryKeepsOrder fails a job once and checks the next job
+// for the same call waits for the retry instead of overtaking it
+func TestWebhookQueueRetryKeepsOrder(t *testing.T) {
+	rec := &queueRecorder{done: make(chan string, 10)}
+	failed := false
+	handler := func(ctx context.Context, body []byte) error {
+		name := queueTestName(body)
+		rec.record("start " + name)
+		if name == "call:1:connect" && !failed {
+			failed = true
+			return errors.New("temporary failure")
+		}
+		rec.record("end " + name)
+		rec.done <- name
+		return nil
+	}
+
+	q, err := NewWebhookQueue(filepath.Join(t.TempDir(), "queue"), 4, 3, handler, queueTestKeys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q.Start()
+	for _, body := range []string{"call:1:connect", "call:1:terminate"} {
+		if _, err := q.Enqueue([]byte(`"` + body + `"`)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rec.waitDone(t, 2, webhookRetryBaseDelay+5*time.Second)
+	if rec.index("start call:1:terminate") < rec.index("end call:1:connect") {
+		t.Errorf("call 1's terminate overtook the retry of its connect: %v", rec.events)
+	}
+}
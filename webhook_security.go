@@ -0,0 +1,31 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// signaturePrefix is the scheme prefix Meta puts in front of the hex digest
+const signaturePrefix = "sha256="
+
+// verifyWebhookSignature checks the X-Hub-Signature-256 header against an
+// HMAC-SHA256 of the raw request body keyed with the Meta app secret.
+// The comparison is constant time so the digest can't be probed byte by byte.
+func verifyWebhookSignature(appSecret string, body []byte, signatureHeader string) bool {
+	if appSecret == "" || !strings.HasPrefix(signatureHeader, signaturePrefix) {
+		return false
+	}
+
+	received, err := hex.DecodeString(strings.TrimPrefix(signatureHeader, signaturePrefix))
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, []byte(appSecret))
+	mac.Write(body)
+	expected := mac.Sum(nil)
+
+	return hmac.Equal(received, expected)
+}
@@ -5,13 +5,34 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
-	"sync"
+	"strings"
 	"time"
 )
 
+// graphAPIVersion is the Graph API version every request is made against
+const graphAPIVersion = "v21.0"
+
+// defaultGraphURL is where the WhatsApp Cloud API lives
+const defaultGraphURL = "https://graph.facebook.com"
+
+// graphBaseURL returns the Graph API origin. WHATSAPP_GRAPH_URL points the
+// bridge somewhere else, such as the local simulator in cmd/whatsapp-simulator.
+func graphBaseURL() string {
+	if url := os.Getenv("WHATSAPP_GRAPH_URL"); url != "" {
+		return strings.TrimSuffix(url, "/")
+	}
+	return defaultGraphURL
+}
+
+// graphURL builds a versioned Graph API URL from path segments,
+// e.g. graphURL(graphAPIVersion, phoneNumberID, "calls")
+func graphURL(apiVersion string, path ...string) string {
+	return graphBaseURL() + "/" + apiVersion + "/" + strings.Join(path, "/")
+}
+
 // MessageType represents the type of WhatsApp message
 type MessageType string
 
@@ -48,8 +69,8 @@ func NewConfig() *Config {
 		phoneID = os.Getenv("PHONE_ID")
 	}
 
-	apiVersion := "v21.0"
-	baseURL := fmt.Sprintf("https://graph.facebook.com/%s/%s/messages", apiVersion, phoneID)
+	apiVersion := graphAPIVersion
+	baseURL := graphURL(apiVersion, phoneID, "messages")
 
 	return &Config{
 		Token:      token,
@@ -84,10 +105,9 @@ type MediaHeader struct {
 
 // WhatsAppClient handles sending messages to WhatsApp
 type WhatsAppClient struct {
-	config       *Config
-	httpClient   *http.Client
-	processedIDs map[string]bool
-	mu           sync.RWMutex
+	config     *Config
+	httpClient *http.Client
+	dedupe     DedupeStore // Shared across clients so retries are caught between webhooks
 }
 
 // NewWhatsAppClient creates a new WhatsApp client
@@ -101,10 +121,15 @@ func NewWhatsAppClient(config *Config) *WhatsAppClient {
 		httpClient: &http.Client{
 			Timeout: 10 * time.Second,
 		},
-		processedIDs: make(map[string]bool),
+		dedupe: NewMemoryDedupeStore(defaultDedupeTTL, defaultDedupeMaxEntries),
 	}
 }
 
+// SetDedupeStore replaces the client's private dedupe store with a shared one
+func (c *WhatsAppClient) SetDedupeStore(store DedupeStore) {
+	c.dedupe = store
+}
+
 // request makes an HTTP request to the WhatsApp API
 func (c *WhatsAppClient) request(method, url string, body interface{}) (map[string]interface{}, error) {
 	if url == "" {
@@ -118,7 +143,7 @@ func (c *WhatsAppClient) request(method, url string, body interface{}) (map[stri
 			return nil, fmt.Errorf("failed to marshal request body: %w", err)
 		}
 		reqBody = bytes.NewBuffer(jsonData)
-		log.Printf("📤 WhatsApp Messaging API request: %s", string(jsonData))
+		slog.Debug("📤 WhatsApp Messaging API request", "method", method, "url", url, "body", string(jsonData))
 	}
 
 	req, err := http.NewRequest(method, url, reqBody)
@@ -140,7 +165,12 @@ func (c *WhatsAppClient) request(method, url string, body interface{}) (map[stri
 		return nil, fmt.Errorf("failed to read response: %w", err)
 	}
 
-	log.Printf("📡 WhatsApp Messaging API response: Status=%d, Body=%s", resp.StatusCode, string(respBody))
+	if resp.StatusCode != 200 {
+		// Graph errors carry no message content, so the response is logged outside debug too
+		slog.Warn("📡 WhatsApp Messaging API error", "method", method, "url", url, "status", resp.StatusCode, "response", string(respBody))
+	} else {
+		slog.Debug("📡 WhatsApp Messaging API response", "method", method, "url", url, "status", resp.StatusCode, "body", string(respBody))
+	}
 
 	var result map[string]interface{}
 	if err := json.Unmarshal(respBody, &result); err != nil {
@@ -290,7 +320,7 @@ func (c *WhatsAppClient) SendTemplate(to, templateName, language string, params
 
 // DownloadMedia downloads media from WhatsApp
 func (c *WhatsAppClient) DownloadMedia(mediaID, filename string) (string, error) {
-	url := fmt.Sprintf("https://graph.facebook.com/%s/%s", c.config.APIVersion, mediaID)
+	url := graphURL(c.config.APIVersion, mediaID)
 
 	mediaInfo, err := c.request("GET", url, nil)
 	if err != nil {
@@ -330,18 +360,28 @@ func (c *WhatsAppClient) DownloadMedia(mediaID, filename string) (string, error)
 	return filename, nil
 }
 
+// CheckAndMarkProcessed marks a message ID as processed and reports whether it already was
+func (c *WhatsAppClient) CheckAndMarkProcessed(messageID string) bool {
+	return c.dedupe.CheckAndMark(messageDedupeKey(messageID))
+}
+
 // IsProcessed checks if a message ID has been processed
+//
+// Deprecated: checking and marking separately lets two deliveries of the same
+// message both pass; use CheckAndMarkProcessed.
 func (c *WhatsAppClient) IsProcessed(messageID string) bool {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
-	return c.processedIDs[messageID]
+	if c.CheckAndMarkProcessed(messageID) {
+		return true
+	}
+	c.dedupe.Forget(messageDedupeKey(messageID))
+	return false
 }
 
 // MarkProcessed marks a message ID as processed
+//
+// Deprecated: use CheckAndMarkProcessed.
 func (c *WhatsAppClient) MarkProcessed(messageID string) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	c.processedIDs[messageID] = true
+	c.CheckAndMarkProcessed(messageID)
 }
 
 // Webhook data structures
@@ -417,12 +457,167 @@ type WebhookMetadata struct {
 	PhoneNumberID      string `json:"phone_number_id"`
 }
 
+// Call event names used in calls[].event
+const (
+	CallEventConnect   = "connect"
+	CallEventTerminate = "terminate"
+)
+
+// Call directions used in calls[].direction
+const (
+	CallDirectionUserInitiated     = "USER_INITIATED"
+	CallDirectionBusinessInitiated = "BUSINESS_INITIATED"
+)
+
+// EventTypeCallConnect is the value-level event_type Meta sends with the SDP answer for outbound calls
+const EventTypeCallConnect = "call.connect"
+
+// StatusTypeCall marks a statuses[] entry that belongs to a call rather than a message
+const StatusTypeCall = "call"
+
+// WebhookSession represents the SDP carried by call connect events
+type WebhookSession struct {
+	SDPType string `json:"sdp_type"` // "offer" for inbound calls, "answer" for outbound
+	SDP     string `json:"sdp"`
+}
+
+// WebhookErrorData holds the extra detail Meta attaches to webhook errors
+type WebhookErrorData struct {
+	Details string `json:"details"`
+}
+
+// WebhookError represents an error attached to a status, call or change
+type WebhookError struct {
+	Code      int               `json:"code"`
+	Title     string            `json:"title"`
+	Message   string            `json:"message,omitempty"`
+	ErrorData *WebhookErrorData `json:"error_data,omitempty"`
+	Href      string            `json:"href,omitempty"`
+}
+
+// Error implements the error interface
+func (e WebhookError) Error() string {
+	msg := fmt.Sprintf("%d: %s", e.Code, e.Title)
+	if e.Message != "" && e.Message != e.Title {
+		msg += " - " + e.Message
+	}
+	if e.ErrorData != nil && e.ErrorData.Details != "" {
+		msg += " (" + e.ErrorData.Details + ")"
+	}
+	return msg
+}
+
+// WebhookCall represents a call event (calls[] in a change value)
+type WebhookCall struct {
+	ID                    string          `json:"id"`
+	To                    string          `json:"to"`
+	From                  string          `json:"from"`
+	Event                 string          `json:"event"`
+	Timestamp             string          `json:"timestamp"`
+	Direction             string          `json:"direction,omitempty"`
+	Session               *WebhookSession `json:"session,omitempty"`
+	Status                string          `json:"status,omitempty"`     // Set on terminate, e.g. "COMPLETED" or "FAILED"
+	StartTime             string          `json:"start_time,omitempty"` // Set on terminate for answered calls
+	EndTime               string          `json:"end_time,omitempty"`
+	Duration              int             `json:"duration,omitempty"` // Seconds
+	BizOpaqueCallbackData string          `json:"biz_opaque_callback_data,omitempty"`
+	Errors                []WebhookError  `json:"errors,omitempty"`
+}
+
+// WebhookStatus represents a message or call status update (statuses[] in a change value)
+type WebhookStatus struct {
+	ID                    string         `json:"id"`
+	Type                  string         `json:"type,omitempty"` // "call" for call statuses, empty for message statuses
+	Status                string         `json:"status"`         // e.g. sent/delivered/read/failed or RINGING/ACCEPTED/REJECTED
+	Timestamp             string         `json:"timestamp"`
+	RecipientID           string         `json:"recipient_id"`
+	BizOpaqueCallbackData string         `json:"biz_opaque_callback_data,omitempty"`
+	Errors                []WebhookError `json:"errors,omitempty"`
+}
+
+// IsCall reports whether the status belongs to a call
+func (s *WebhookStatus) IsCall() bool {
+	return s.Type == StatusTypeCall
+}
+
 // WebhookValue represents the value in a change
 type WebhookValue struct {
-	MessagingProduct string            `json:"messaging_product"`
-	Metadata         WebhookMetadata   `json:"metadata"`
-	Contacts         []WebhookContact  `json:"contacts,omitempty"`
-	Messages         []WebhookMessage  `json:"messages,omitempty"`
+	MessagingProduct string           `json:"messaging_product"`
+	Metadata         WebhookMetadata  `json:"metadata"`
+	Contacts         []WebhookContact `json:"contacts,omitempty"`
+	Messages         []WebhookMessage `json:"messages,omitempty"`
+	Calls            []WebhookCall    `json:"calls,omitempty"`
+	Statuses         []WebhookStatus  `json:"statuses,omitempty"`
+	Errors           []WebhookError   `json:"errors,omitempty"`
+
+	// Value-level connect event for outbound calls (event_type "call.connect")
+	EventType string          `json:"event_type,omitempty"`
+	CallID    string          `json:"call_id,omitempty"`
+	From      string          `json:"from,omitempty"`
+	To        string          `json:"to,omitempty"`
+	Session   *WebhookSession `json:"session,omitempty"`
+}
+
+// WebhookEvent is one typed event from a change value. Switch on the concrete
+// type: *MessageEvent, *CallEvent, *StatusEvent or *CallConnectEvent.
+type WebhookEvent interface {
+	webhookEvent()
+}
+
+// MessageEvent is an inbound message
+type MessageEvent struct {
+	Value   *WebhookValue
+	Message *WebhookMessage
+}
+
+// CallEvent is an entry in calls[] (inbound connect, terminate, ...)
+type CallEvent struct {
+	Value *WebhookValue
+	Call  *WebhookCall
+}
+
+// StatusEvent is a message or call status update
+type StatusEvent struct {
+	Value  *WebhookValue
+	Status *WebhookStatus
+}
+
+// CallConnectEvent is the value-level event_type webhook carrying an outbound call's SDP answer
+type CallConnectEvent struct {
+	Value     *WebhookValue
+	EventType string
+	CallID    string
+	From      string
+	Session   *WebhookSession
+}
+
+func (*MessageEvent) webhookEvent()     {}
+func (*CallEvent) webhookEvent()        {}
+func (*StatusEvent) webhookEvent()      {}
+func (*CallConnectEvent) webhookEvent() {}
+
+// Events returns every typed event in the change value
+func (v *WebhookValue) Events() []WebhookEvent {
+	var events []WebhookEvent
+	if v.EventType != "" {
+		events = append(events, &CallConnectEvent{
+			Value:     v,
+			EventType: v.EventType,
+			CallID:    v.CallID,
+			From:      v.From,
+			Session:   v.Session,
+		})
+	}
+	for i := range v.Calls {
+		events = append(events, &CallEvent{Value: v, Call: &v.Calls[i]})
+	}
+	for i := range v.Messages {
+		events = append(events, &MessageEvent{Value: v, Message: &v.Messages[i]})
+	}
+	for i := range v.Statuses {
+		events = append(events, &StatusEvent{Value: v, Status: &v.Statuses[i]})
+	}
+	return events
 }
 
 // WebhookChange represents a change in the webhook
@@ -447,6 +642,8 @@ type WebhookData struct {
 type WebhookHandler struct {
 	client *WhatsAppClient
 	data   *WebhookData
+	value  *WebhookValue   // Change this handler is scoped to (nil = first change)
+	msg    *WebhookMessage // Message this handler is scoped to (nil = first message)
 }
 
 // NewWebhookHandler creates a new webhook handler
@@ -465,8 +662,70 @@ func (h *WebhookHandler) Parse(webhookData []byte) error {
 	return nil
 }
 
-// Message returns the first message from the webhook
-func (h *WebhookHandler) Message() *WebhookMessage {
+// ParseValue parses a single change value (the object under entry[].changes[].value)
+func (h *WebhookHandler) ParseValue(valueData []byte) error {
+	var value WebhookValue
+	if err := json.Unmarshal(valueData, &value); err != nil {
+		return fmt.Errorf("failed to parse webhook value: %w", err)
+	}
+	h.data = &WebhookData{
+		Entry: []WebhookEntry{{Changes: []WebhookChange{{Value: value}}}},
+	}
+	return nil
+}
+
+// Messages returns one handler per message in the payload, across every entry
+// and change, in the order they were sent. Each returned handler answers
+// Sender(), Text(), ReplyText() etc. for its own message.
+func (h *WebhookHandler) Messages() []*WebhookHandler {
+	if h.data == nil {
+		return nil
+	}
+
+	var handlers []*WebhookHandler
+	for i := range h.data.Entry {
+		entry := &h.data.Entry[i]
+		for j := range entry.Changes {
+			value := &entry.Changes[j].Value
+			for k := range value.Messages {
+				handlers = append(handlers, h.ForMessage(value, &value.Messages[k]))
+			}
+		}
+	}
+	return handlers
+}
+
+// ForMessage returns a handler scoped to one message of a change value
+func (h *WebhookHandler) ForMessage(value *WebhookValue, msg *WebhookMessage) *WebhookHandler {
+	return &WebhookHandler{
+		client: h.client,
+		data:   h.data,
+		value:  value,
+		msg:    msg,
+	}
+}
+
+// Events returns every typed event in the payload, across every entry and change
+func (h *WebhookHandler) Events() []WebhookEvent {
+	if h.data == nil {
+		return nil
+	}
+
+	var events []WebhookEvent
+	for i := range h.data.Entry {
+		entry := &h.data.Entry[i]
+		for j := range entry.Changes {
+			events = append(events, entry.Changes[j].Value.Events()...)
+		}
+	}
+	return events
+}
+
+// currentValue returns the change value this handler is scoped to
+func (h *WebhookHandler) currentValue() *WebhookValue {
+	if h.value != nil {
+		return h.value
+	}
 	if h.data == nil || len(h.data.Entry) == 0 {
 		return nil
 	}
@@ -474,11 +733,19 @@ func (h *WebhookHandler) Message() *WebhookMessage {
 	if len(entry.Changes) == 0 {
 		return nil
 	}
-	change := entry.Changes[0]
-	if len(change.Value.Messages) == 0 {
+	return &entry.Changes[0].Value
+}
+
+// Message returns the message this handler is scoped to, or the first message from the webhook
+func (h *WebhookHandler) Message() *WebhookMessage {
+	if h.msg != nil {
+		return h.msg
+	}
+	value := h.currentValue()
+	if value == nil || len(value.Messages) == 0 {
 		return nil
 	}
-	return &change.Value.Messages[0]
+	return &value.Messages[0]
 }
 
 // Sender returns the sender's phone number
@@ -560,30 +827,35 @@ func (h *WebhookHandler) MessageID() string {
 
 // DisplayNumber returns the display phone number
 func (h *WebhookHandler) DisplayNumber() string {
-	if h.data == nil || len(h.data.Entry) == 0 {
-		return ""
-	}
-	entry := h.data.Entry[0]
-	if len(entry.Changes) == 0 {
+	value := h.currentValue()
+	if value == nil {
 		return ""
 	}
-	return entry.Changes[0].Value.Metadata.DisplayPhoneNumber
+	return value.Metadata.DisplayPhoneNumber
 }
 
-// ContactName returns the contact's name from profile
+// ContactName returns the sender's name from their contact profile
 func (h *WebhookHandler) ContactName() string {
-	if h.data == nil || len(h.data.Entry) == 0 {
+	value := h.currentValue()
+	if value == nil || len(value.Contacts) == 0 {
 		return ""
 	}
-	entry := h.data.Entry[0]
-	if len(entry.Changes) == 0 {
-		return ""
+
+	// Batched changes carry one contact per sender - pick the one matching this message
+	contact := &value.Contacts[0]
+	if msg := h.Message(); msg != nil {
+		for i := range value.Contacts {
+			if value.Contacts[i].WaID == msg.From {
+				contact = &value.Contacts[i]
+				break
+			}
+		}
 	}
-	contacts := entry.Changes[0].Value.Contacts
-	if len(contacts) == 0 || contacts[0].Profile == nil {
+
+	if contact.Profile == nil {
 		return ""
 	}
-	if name, ok := contacts[0].Profile["name"].(string); ok {
+	if name, ok := contact.Profile["name"].(string); ok {
 		return name
 	}
 	return ""
@@ -596,12 +868,7 @@ func (h *WebhookHandler) IsDuplicate() bool {
 		return false
 	}
 
-	if h.client.IsProcessed(msg.ID) {
-		return true
-	}
-
-	h.client.MarkProcessed(msg.ID)
-	return false
+	return h.client.CheckAndMarkProcessed(msg.ID)
 }
 
 // ReplyText sends a text reply to the sender
@@ -637,7 +904,7 @@ func NewWhatsAppSDK(token, phoneID string) *WhatsAppSDK {
 	}
 	if phoneID != "" {
 		config.PhoneID = phoneID
-		config.BaseURL = fmt.Sprintf("https://graph.facebook.com/%s/%s/messages", config.APIVersion, phoneID)
+		config.BaseURL = graphURL(config.APIVersion, phoneID, "messages")
 	}
 
 	return &WhatsAppSDK{
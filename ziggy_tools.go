@@ -1,211 +1,337 @@
 package main
 
 // ziggy_tools.go
-// Shared tool definitions for Ziggy AI assistant
-// Used by both voice API (OpenAI Realtime) and text API (Azure AI Foundry)
-// Ensures consistency across all interaction modes
-
-// GetZiggyTools returns all available tool definitions for Ziggy assistant
-// This single source of truth is used by both voice calls and text messages
-func GetZiggyTools() []map[string]interface{} {
-	return []map[string]interface{}{
+// Ziggy's built-in tools: tasks, reminders, notes and call control.
+// Each tool is defined once here and registered in toolRegistry, which renders
+// it for the Realtime API (voice) and the Responses API (text) and dispatches
+// the calls from both.
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// toolRegistry holds every tool the assistant can call
+var toolRegistry = newZiggyToolRegistry()
+
+var (
+	taskStatuses     = []string{"pending", "in_progress", "completed", "cancelled"}
+	reminderStatuses = []string{"pending", "called", "completed", "cancelled"}
+)
+
+// newZiggyToolRegistry creates a registry with Ziggy's built-in tools
+func newZiggyToolRegistry() *ToolRegistry {
+	registry := NewToolRegistry()
+	for _, tool := range ziggyTools() {
+		if err := registry.Register(tool); err != nil {
+			panic(err)
+		}
+	}
+	return registry
+}
+
+// ziggyTools returns the built-in tool definitions
+func ziggyTools() []*Tool {
+	return []*Tool{
 		// ============================================
 		// TASK MANAGEMENT TOOLS
 		// ============================================
 		{
-			"type":        "function",
-			"name":        "add_task",
-			"description": "Create a new task for the caller. Use this when they ask to add, create, or remember a task or todo item.",
-			"parameters": map[string]interface{}{
-				"type": "object",
-				"properties": map[string]interface{}{
-					"title": map[string]interface{}{
-						"type":        "string",
-						"description": "Brief title of the task",
-					},
-					"description": map[string]interface{}{
-						"type":        "string",
-						"description": "Detailed description of the task (optional)",
-					},
-					"priority": map[string]interface{}{
-						"type":        "string",
-						"description": "Priority level: low, medium, high, or urgent",
-						"enum":        []string{"low", "medium", "high", "urgent"},
-					},
-				},
-				"required":             []string{"title"},
-				
+			Name:        "add_task",
+			Description: "Create a new task for the caller. Use this when they ask to add, create, or remember a task or todo item.",
+			Params: []ToolParam{
+				{Name: "title", Description: "Brief title of the task", Required: true},
+				{Name: "description", Description: "Detailed description of the task (optional)"},
+				{Name: "priority", Description: "Priority level: low, medium, high, or urgent", Enum: []string{"low", "medium", "high", "urgent"}},
 			},
-			
+			Handler: addTaskTool,
 		},
 		{
-			"type":        "function",
-			"name":        "list_tasks",
-			"description": "List all tasks for the caller. Can optionally filter by status.",
-			"parameters": map[string]interface{}{
-				"type": "object",
-				"properties": map[string]interface{}{
-					"status": map[string]interface{}{
-						"type":        "string",
-						"description": "Filter by status: pending, in_progress, completed, or cancelled (optional)",
-						"enum":        []string{"pending", "in_progress", "completed", "cancelled"},
-					},
-				},
-				
+			Name:        "list_tasks",
+			Description: "List all tasks for the caller. Can optionally filter by status.",
+			Params: []ToolParam{
+				{Name: "status", Description: "Filter by status: pending, in_progress, completed, or cancelled (optional)", Enum: taskStatuses},
 			},
-			
+			Handler: listTasksTool,
 		},
 		{
-			"type":        "function",
-			"name":        "update_task_status",
-			"description": "Update the status of a task. Use when user wants to mark task as done, complete, in progress, etc.",
-			"parameters": map[string]interface{}{
-				"type": "object",
-				"properties": map[string]interface{}{
-					"task_id": map[string]interface{}{
-						"type":        "string",
-						"description": "The ID of the task to update",
-					},
-					"status": map[string]interface{}{
-						"type":        "string",
-						"description": "New status: pending, in_progress, completed, or cancelled",
-						"enum":        []string{"pending", "in_progress", "completed", "cancelled"},
-					},
-				},
-				"required":             []string{"task_id", "status"},
-				
+			Name:        "update_task_status",
+			Description: "Update the status of a task. Use when user wants to mark task as done, complete, in progress, etc.",
+			Params: []ToolParam{
+				{Name: "task_id", Description: "The ID of the task to update", Required: true},
+				{Name: "status", Description: "New status: pending, in_progress, completed, or cancelled", Enum: taskStatuses, Required: true},
 			},
-			
+			Handler: updateTaskStatusTool,
 		},
 
 		// ============================================
 		// REMINDER TOOLS
 		// ============================================
 		{
-			"type":        "function",
-			"name":        "add_reminder",
-			"description": "Set a reminder for the caller. Supports one-time and recurring reminders. When the reminder time comes, Ziggy will call them back.",
-			"parameters": map[string]interface{}{
-				"type": "object",
-				"properties": map[string]interface{}{
-					"reminder_text": map[string]interface{}{
-						"type":        "string",
-						"description": "What to remind the user about",
-					},
-					"reminder_time": map[string]interface{}{
-						"type":        "string",
-						"description": "When to send the reminder in local timezone using format YYYY-MM-DD HH:MM (e.g., 2025-11-09 14:30 for 2:30 PM). Use 24-hour format. Ask the user for the exact date and time if not provided.",
-					},
-					"recurrence": map[string]interface{}{
-						"type":        "string",
-						"description": "Recurrence pattern: 'once' (default, one-time), 'daily', 'weekly', 'monthly', 'yearly'. Only specify if user wants recurring reminder.",
-						"enum":        []string{"once", "daily", "weekly", "monthly", "yearly"},
-					},
-				},
-				"required":             []string{"reminder_text", "reminder_time"},
-				
+			Name:        "add_reminder",
+			Description: "Set a reminder for the caller. Supports one-time and recurring reminders. When the reminder time comes, Ziggy will call them back.",
+			Params: []ToolParam{
+				{Name: "reminder_text", Description: "What to remind the user about", Required: true},
+				{Name: "reminder_time", Description: "When to send the reminder in local timezone using format YYYY-MM-DD HH:MM (e.g., 2025-11-09 14:30 for 2:30 PM). Use 24-hour format. Ask the user for the exact date and time if not provided.", Required: true},
+				{Name: "recurrence", Description: "Recurrence pattern: 'once' (default, one-time), 'daily', 'weekly', 'monthly', 'yearly'. Only specify if user wants recurring reminder.", Enum: []string{"once", "daily", "weekly", "monthly", "yearly"}},
 			},
-			
+			Handler: addReminderTool,
 		},
 		{
-			"type":        "function",
-			"name":        "list_reminders",
-			"description": "List all reminders for the caller. Can filter by status (pending, called, completed, cancelled).",
-			"parameters": map[string]interface{}{
-				"type": "object",
-				"properties": map[string]interface{}{
-					"status": map[string]interface{}{
-						"type":        "string",
-						"description": "Optional filter by status: 'pending', 'called', 'completed', 'cancelled'. If not provided, shows all reminders.",
-						"enum":        []string{"pending", "called", "completed", "cancelled"},
-					},
-				},
-				
+			Name:        "list_reminders",
+			Description: "List all reminders for the caller. Can filter by status (pending, called, completed, cancelled).",
+			Params: []ToolParam{
+				{Name: "status", Description: "Optional filter by status: 'pending', 'called', 'completed', 'cancelled'. If not provided, shows all reminders.", Enum: reminderStatuses},
 			},
-			
+			Handler: listRemindersTool,
 		},
 		{
-			"type":        "function",
-			"name":        "cancel_reminder",
-			"description": "Cancel a reminder. Use this when user wants to stop or delete a reminder.",
-			"parameters": map[string]interface{}{
-				"type": "object",
-				"properties": map[string]interface{}{
-					"reminder_id": map[string]interface{}{
-						"type":        "string",
-						"description": "The ID of the reminder to cancel. Get this from list_reminders.",
-					},
-				},
-				"required":             []string{"reminder_id"},
-				
+			Name:        "cancel_reminder",
+			Description: "Cancel a reminder. Use this when user wants to stop or delete a reminder.",
+			Params: []ToolParam{
+				{Name: "reminder_id", Description: "The ID of the reminder to cancel. Get this from list_reminders.", Required: true},
 			},
-			
+			Handler: cancelReminderTool,
 		},
 
 		// ============================================
 		// NOTES TOOLS
 		// ============================================
 		{
-			"type":        "function",
-			"name":        "add_note",
-			"description": "Create a note for the caller. Use this when they ask to note something, write something down, remember something, or save information.",
-			"parameters": map[string]interface{}{
-				"type": "object",
-				"properties": map[string]interface{}{
-					"note_content": map[string]interface{}{
-						"type":        "string",
-						"description": "The content of the note to save",
-					},
-				},
-				"required":             []string{"note_content"},
-				
+			Name:        "add_note",
+			Description: "Create a note for the caller. Use this when they ask to note something, write something down, remember something, or save information.",
+			Params: []ToolParam{
+				{Name: "note_content", Description: "The content of the note to save", Required: true},
 			},
-			
+			Handler: addNoteTool,
+		},
+		{
+			Name:        "list_notes",
+			Description: "List all notes for the caller. Shows all saved notes in chronological order.",
+			Handler:     listNotesTool,
 		},
 		{
-			"type":        "function",
-			"name":        "list_notes",
-			"description": "List all notes for the caller. Shows all saved notes in chronological order.",
-			"parameters": map[string]interface{}{
-				"type":                 "object",
-				"properties":           map[string]interface{}{},
-				
+			Name:        "search_notes",
+			Description: "Search through notes for specific keywords or content. Use this when user wants to find specific notes.",
+			Params: []ToolParam{
+				{Name: "search_query", Description: "Search query to find in notes", Required: true},
 			},
-			
+			Handler: searchNotesTool,
 		},
 		{
-			"type":        "function",
-			"name":        "search_notes",
-			"description": "Search through notes for specific keywords or content. Use this when user wants to find specific notes.",
-			"parameters": map[string]interface{}{
-				"type": "object",
-				"properties": map[string]interface{}{
-					"search_query": map[string]interface{}{
-						"type":        "string",
-						"description": "Search query to find in notes",
-					},
-				},
-				"required":             []string{"search_query"},
-				
+			Name:        "delete_note",
+			Description: "Delete a specific note. Use this when user wants to remove or delete a note.",
+			Params: []ToolParam{
+				{Name: "note_id", Description: "The ID of the note to delete. Get this from list_notes or search_notes.", Required: true},
 			},
-			
+			Handler: deleteNoteTool,
 		},
+
+		// ============================================
+		// CALL CONTROL
+		// ============================================
 		{
-			"type":        "function",
-			"name":        "delete_note",
-			"description": "Delete a specific note. Use this when user wants to remove or delete a note.",
-			"parameters": map[string]interface{}{
-				"type": "object",
-				"properties": map[string]interface{}{
-					"note_id": map[string]interface{}{
-						"type":        "string",
-						"description": "The ID of the note to delete. Get this from list_notes or search_notes.",
-					},
-				},
-				"required":             []string{"note_id"},
-				
+			Name:        "end_call",
+			Description: "Hang up the phone call. Use this when the caller says goodbye or asks to end the call, or on a reminder call once the reminder is confirmed. Say a short goodbye first.",
+			Params: []ToolParam{
+				{Name: "reason", Description: "Short reason for ending the call (e.g. 'caller said goodbye', 'reminder confirmed')"},
 			},
-			
+			Channels: []string{toolChannelVoice},
+			Handler:  endCallTool,
 		},
 	}
 }
+
+func addTaskTool(ctx context.Context, caller *ToolCaller, args ToolArgs) (map[string]interface{}, error) {
+	title := args.String("title")
+	priority := args.String("priority")
+	if priority == "" {
+		priority = "medium"
+	}
+
+	task, err := AddTask(caller.Tenant.SupabaseSchema, title, args.String("description"), priority, caller.PhoneNumber)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create task: %v", err)
+	}
+	return map[string]interface{}{
+		"message": fmt.Sprintf("Task '%s' created successfully", title),
+		"task_id": task.ID,
+		"title":   task.Title,
+	}, nil
+}
+
+func listTasksTool(ctx context.Context, caller *ToolCaller, args ToolArgs) (map[string]interface{}, error) {
+	tasks, err := ListTasks(caller.Tenant.SupabaseSchema, caller.PhoneNumber, args.String("status"))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list tasks: %v", err)
+	}
+
+	taskList := make([]map[string]interface{}, len(tasks))
+	for i, task := range tasks {
+		taskList[i] = map[string]interface{}{
+			"id":          task.ID,
+			"title":       task.Title,
+			"description": task.Description,
+			"status":      task.Status,
+			"priority":    task.Priority,
+		}
+	}
+	return map[string]interface{}{
+		"count": len(tasks),
+		"tasks": taskList,
+	}, nil
+}
+
+func updateTaskStatusTool(ctx context.Context, caller *ToolCaller, args ToolArgs) (map[string]interface{}, error) {
+	taskID := args.String("task_id")
+	newStatus := args.String("status")
+
+	if err := UpdateTaskStatus(caller.Tenant.SupabaseSchema, taskID, newStatus); err != nil {
+		return nil, fmt.Errorf("Failed to update task: %v", err)
+	}
+	return map[string]interface{}{
+		"message": fmt.Sprintf("Task updated to %s", newStatus),
+		"task_id": taskID,
+	}, nil
+}
+
+func addReminderTool(ctx context.Context, caller *ToolCaller, args ToolArgs) (map[string]interface{}, error) {
+	reminderTime := args.String("reminder_time")
+	recurrence := args.String("recurrence")
+	if recurrence == "" {
+		recurrence = "once"
+	}
+
+	reminder, err := AddReminder(caller.Tenant.SupabaseSchema, args.String("reminder_text"), reminderTime, caller.PhoneNumber, recurrence)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create reminder: %v", err)
+	}
+
+	message := fmt.Sprintf("Reminder set for %s. I'll call you back at that time.", reminderTime)
+	if recurrence != "once" {
+		message = fmt.Sprintf("%s reminder set for %s. I'll call you back %s.", recurrence, reminderTime, recurrence)
+	}
+	return map[string]interface{}{
+		"message":       message,
+		"reminder_id":   reminder.ID,
+		"reminder_text": reminder.ReminderText,
+		"reminder_time": reminder.ReminderTime,
+		"recurrence":    reminder.RecurrencePattern,
+	}, nil
+}
+
+func listRemindersTool(ctx context.Context, caller *ToolCaller, args ToolArgs) (map[string]interface{}, error) {
+	reminders, err := ListReminders(caller.Tenant.SupabaseSchema, caller.PhoneNumber, args.String("status"))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list reminders: %v", err)
+	}
+
+	reminderList := make([]map[string]interface{}, len(reminders))
+	for i, r := range reminders {
+		reminderList[i] = map[string]interface{}{
+			"id":         r.ID,
+			"text":       r.ReminderText,
+			"time":       r.ReminderTime,
+			"recurrence": r.RecurrencePattern,
+			"status":     r.Status,
+		}
+	}
+	return map[string]interface{}{
+		"count":     len(reminders),
+		"reminders": reminderList,
+	}, nil
+}
+
+func cancelReminderTool(ctx context.Context, caller *ToolCaller, args ToolArgs) (map[string]interface{}, error) {
+	reminderID := args.String("reminder_id")
+
+	if err := CancelReminder(caller.Tenant.SupabaseSchema, reminderID); err != nil {
+		return nil, fmt.Errorf("Failed to cancel reminder: %v", err)
+	}
+	return map[string]interface{}{
+		"message":     "Reminder cancelled successfully",
+		"reminder_id": reminderID,
+	}, nil
+}
+
+func addNoteTool(ctx context.Context, caller *ToolCaller, args ToolArgs) (map[string]interface{}, error) {
+	note, err := AddNote(caller.Tenant.SupabaseSchema, args.String("note_content"), caller.PhoneNumber)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to save note: %v", err)
+	}
+	return map[string]interface{}{
+		"message": "Note saved successfully",
+		"note_id": note.ID,
+		"content": note.NoteContent,
+	}, nil
+}
+
+func listNotesTool(ctx context.Context, caller *ToolCaller, args ToolArgs) (map[string]interface{}, error) {
+	notes, err := ListNotes(caller.Tenant.SupabaseSchema, caller.PhoneNumber, 0)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list notes: %v", err)
+	}
+	return map[string]interface{}{
+		"count": len(notes),
+		"notes": noteResults(notes),
+	}, nil
+}
+
+func searchNotesTool(ctx context.Context, caller *ToolCaller, args ToolArgs) (map[string]interface{}, error) {
+	query := args.String("search_query")
+
+	notes, err := SearchNotes(caller.Tenant.SupabaseSchema, caller.PhoneNumber, query)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to search notes: %v", err)
+	}
+	return map[string]interface{}{
+		"count": len(notes),
+		"query": query,
+		"notes": noteResults(notes),
+	}, nil
+}
+
+func deleteNoteTool(ctx context.Context, caller *ToolCaller, args ToolArgs) (map[string]interface{}, error) {
+	noteID := args.String("note_id")
+
+	if err := DeleteNote(caller.Tenant.SupabaseSchema, noteID); err != nil {
+		return nil, fmt.Errorf("Failed to delete note: %v", err)
+	}
+	return map[string]interface{}{
+		"message": "Note deleted successfully",
+		"note_id": noteID,
+	}, nil
+}
+
+// endCallTool hangs up once the goodbye has had time to play; the result is
+// sent back first so the assistant knows to say it
+func endCallTool(ctx context.Context, caller *ToolCaller, args ToolArgs) (map[string]interface{}, error) {
+	reason := args.String("reason")
+	if reason == "" {
+		reason = "assistant ended the call"
+	}
+	if caller.EndCall == nil {
+		return nil, fmt.Errorf("Ending the call is not supported here")
+	}
+
+	loggerFrom(ctx).Info("📴 Assistant requested hangup", "reason", reason)
+	time.AfterFunc(endCallGoodbyeDelay, func() {
+		caller.EndCall("assistant: " + reason)
+	})
+	return map[string]interface{}{
+		"message": "The call will end in a few seconds. Say a brief goodbye now.",
+	}, nil
+}
+
+// noteResults formats notes for the assistant
+func noteResults(notes []ZiggyNote) []map[string]interface{} {
+	noteList := make([]map[string]interface{}, len(notes))
+	for i, note := range notes {
+		noteList[i] = map[string]interface{}{
+			"id":      note.ID,
+			"content": note.NoteContent,
+		}
+	}
+	return noteList
+}